@@ -0,0 +1,282 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/filebeat/config"
+	"github.com/elastic/beats/v7/filebeat/registrar"
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/cmd/instance/locks"
+	"github.com/elastic/beats/v7/libbeat/common/cli"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+)
+
+func genRegistryCmd(settings instance.Settings) *cobra.Command {
+	registryCmd := cobra.Command{
+		Use:   "registry",
+		Short: "Inspect and modify the Filebeat registry",
+		Long: `Inspect and modify the Filebeat registry.
+Filebeat must not be running while these commands are used, the commands
+refuse to open the registry while the data path is locked.`,
+	}
+	registryCmd.AddCommand(genRegistryListCmd(settings))
+	registryCmd.AddCommand(genRegistryShowCmd(settings))
+	registryCmd.AddCommand(genRegistryDeleteCmd(settings))
+	registryCmd.AddCommand(genRegistryResetOffsetCmd(settings))
+	registryCmd.AddCommand(genRegistryExportCmd(settings))
+	registryCmd.AddCommand(genRegistryImportCmd(settings))
+	return &registryCmd
+}
+
+func genRegistryListCmd(settings instance.Settings) *cobra.Command {
+	var inputID string
+	command := &cobra.Command{
+		Use:   "list",
+		Short: "List registry entries",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			return withRegistryStore(settings, func(store *statestore.Store) error {
+				entries, err := registrar.ReadEntries(store, registrar.MatchInput(inputID))
+				if err != nil {
+					return err
+				}
+				return printEntries(os.Stdout, entries)
+			})
+		}),
+	}
+	command.Flags().StringVar(&inputID, "input", "", "Only list entries of the input with this ID")
+	return command
+}
+
+func genRegistryShowCmd(settings instance.Settings) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show KEY...",
+		Short: "Show registry entries",
+		Args:  cobra.MinimumNArgs(1),
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			return withRegistryStore(settings, func(store *statestore.Store) error {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				for _, key := range args {
+					entry, err := registrar.ReadEntry(store, key)
+					if err != nil {
+						return err
+					}
+					if err := enc.Encode(entry); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}),
+	}
+}
+
+func genRegistryDeleteCmd(settings instance.Settings) *cobra.Command {
+	var inputID string
+	command := &cobra.Command{
+		Use:   "delete [KEY...]",
+		Short: "Delete registry entries",
+		Long: `Delete registry entries by key, or all entries of an input with --input.
+The files of deleted entries are read again from the beginning once the input
+picks them up.`,
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 0) == (inputID == "") {
+				return errors.New("either keys or --input must be given")
+			}
+			return withRegistryStore(settings, func(store *statestore.Store) error {
+				keys := args
+				if inputID != "" {
+					entries, err := registrar.ReadEntries(store, registrar.MatchInput(inputID))
+					if err != nil {
+						return err
+					}
+					keys = make([]string, len(entries))
+					for i, entry := range entries {
+						keys[i] = entry.Key
+					}
+				}
+				if err := registrar.RemoveEntries(store, keys); err != nil {
+					return err
+				}
+				fmt.Fprintf(os.Stdout, "Deleted %d registry entries\n", len(keys))
+				return nil
+			})
+		}),
+	}
+	command.Flags().StringVar(&inputID, "input", "", "Delete all entries of the input with this ID")
+	return command
+}
+
+func genRegistryResetOffsetCmd(settings instance.Settings) *cobra.Command {
+	var offset int64
+	command := &cobra.Command{
+		Use:   "reset-offset KEY...",
+		Short: "Set the read offset of registry entries",
+		Args:  cobra.MinimumNArgs(1),
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			if offset < 0 {
+				return errors.New("offset must not be negative")
+			}
+			return withRegistryStore(settings, func(store *statestore.Store) error {
+				for _, key := range args {
+					if err := registrar.ResetOffset(store, key, offset); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}),
+	}
+	command.Flags().Int64Var(&offset, "offset", 0, "The new read offset")
+	return command
+}
+
+func genRegistryExportCmd(settings instance.Settings) *cobra.Command {
+	var inputID, file string
+	command := &cobra.Command{
+		Use:   "export",
+		Short: "Export registry entries as newline delimited JSON",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			return withRegistryStore(settings, func(store *statestore.Store) error {
+				var out io.Writer = os.Stdout
+				if file != "" && file != "-" {
+					f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					out = f
+				}
+				n, err := registrar.ExportEntries(store, out, registrar.MatchInput(inputID))
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "Exported %d registry entries\n", n)
+				return nil
+			})
+		}),
+	}
+	command.Flags().StringVar(&inputID, "input", "", "Only export entries of the input with this ID")
+	command.Flags().StringVar(&file, "file", "", "Write the entries to this file instead of stdout")
+	return command
+}
+
+func genRegistryImportCmd(settings instance.Settings) *cobra.Command {
+	var replace bool
+	command := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import registry entries from newline delimited JSON",
+		Long: `Import registry entries written by the export command. Use - to read
+from stdin. Existing entries with the same key are overwritten.`,
+		Args: cobra.ExactArgs(1),
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			var in io.Reader = os.Stdin
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			return withRegistryStore(settings, func(store *statestore.Store) error {
+				n, err := registrar.ImportEntries(store, in, replace)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(os.Stdout, "Imported %d registry entries\n", n)
+				return nil
+			})
+		}),
+	}
+	command.Flags().BoolVar(&replace, "replace", false, "Remove all existing entries before importing")
+	return command
+}
+
+// withRegistryStore opens the Filebeat store of the configured registry and
+// runs fn with it. The data path lock is held while the store is open, so
+// Filebeat can't be started until the command has finished.
+func withRegistryStore(settings instance.Settings, fn func(*statestore.Store) error) error {
+	b, err := instance.NewInitializedBeat(settings)
+	if err != nil {
+		return fmt.Errorf("error initializing beat: %w", err)
+	}
+
+	beatConfig, err := b.BeatConfig()
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %w", err)
+	}
+	cfg := struct {
+		Registry config.Registry `config:"registry"`
+	}{Registry: config.DefaultConfig.Registry}
+	if err := beatConfig.Unpack(&cfg); err != nil {
+		return fmt.Errorf("error reading registry configuration: %w", err)
+	}
+
+	lock := locks.NewWithRetry(b.Info, 1, 0)
+	if err := lock.Lock(); err != nil {
+		if errors.Is(err, locks.ErrAlreadyLocked) {
+			return fmt.Errorf("refusing to open the registry while %s is running: %w", b.Info.Beat, err)
+		}
+		return err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	reg, err := registrar.OpenBackend(b.Info.Logger.Named("registry"), cfg.Registry)
+	if err != nil {
+		return fmt.Errorf("failed to open the registry: %w", err)
+	}
+	registry := statestore.NewRegistry(reg)
+	defer registry.Close()
+
+	store, err := registry.Get(b.Info.Beat)
+	if err != nil {
+		return fmt.Errorf("failed to open the registry: %w", err)
+	}
+	defer store.Close()
+
+	return fn(store)
+}
+
+func printEntries(w io.Writer, entries []registrar.Entry) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tOFFSET\tTTL\tSOURCE")
+	for _, entry := range entries {
+		offset := "-"
+		if n, ok := entry.Offset(); ok {
+			offset = fmt.Sprint(n)
+		}
+		ttl := "-"
+		if d, ok := entry.TTL(); ok && d >= 0 {
+			ttl = d.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.Key, offset, ttl, entry.Source())
+	}
+	return tw.Flush()
+}
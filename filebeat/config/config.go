@@ -38,6 +38,12 @@ const (
 	DefaultType = "log"
 )
 
+// Registry backends that can be selected with filebeat.registry.backend.
+const (
+	RegistryBackendMemlog = "memlog"
+	RegistryBackendBbolt  = "bbolt"
+)
+
 type Config struct {
 	Inputs             []*conf.C            `config:"inputs"`
 	Registry           Registry             `config:"registry"`
@@ -56,6 +62,17 @@ type Registry struct {
 	FlushTimeout  time.Duration `config:"flush"`
 	CleanInterval time.Duration `config:"cleanup_interval"`
 	MigrateFile   string        `config:"migrate_file"`
+	Backend       string        `config:"backend"`
+}
+
+func (r *Registry) Validate() error {
+	switch r.Backend {
+	case "", RegistryBackendMemlog, RegistryBackendBbolt:
+		return nil
+	default:
+		return fmt.Errorf("unknown registry backend '%v', must be one of %v or %v",
+			r.Backend, RegistryBackendMemlog, RegistryBackendBbolt)
+	}
 }
 
 var DefaultConfig = Config{
@@ -65,6 +82,7 @@ var DefaultConfig = Config{
 		MigrateFile:   "",
 		CleanInterval: 5 * time.Minute,
 		FlushTimeout:  time.Second,
+		Backend:       RegistryBackendMemlog,
 	},
 	ShutdownTimeout:    0,
 	OverwritePipelines: false,
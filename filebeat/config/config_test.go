@@ -192,3 +192,36 @@ func TestEnabledInputs(t *testing.T) {
 		}
 	})
 }
+
+func TestRegistryBackend(t *testing.T) {
+	tests := map[string]struct {
+		backend string
+		wantErr bool
+	}{
+		"default": {backend: "", wantErr: false},
+		"memlog":  {backend: RegistryBackendMemlog, wantErr: false},
+		"bbolt":   {backend: RegistryBackendBbolt, wantErr: false},
+		"unknown": {backend: "sqlite", wantErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			settings := map[string]interface{}{}
+			if test.backend != "" {
+				settings["registry.backend"] = test.backend
+			}
+			config := DefaultConfig
+			err := conf.MustNewConfigFrom(settings).Unpack(&config)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if test.backend == "" {
+				assert.Equal(t, RegistryBackendMemlog, config.Registry.Backend)
+			} else {
+				assert.Equal(t, test.backend, config.Registry.Backend)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	compressionNone = "none"
+	compressionAuto = "auto"
+
+	gzipFormat = "gzip"
+	zstdFormat = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// compressionMode configures if compressed files are decompressed.
+type compressionMode string
+
+func (m *compressionMode) Unpack(v string) error {
+	switch v {
+	case compressionNone, compressionAuto:
+		*m = compressionMode(v)
+		return nil
+	}
+	return fmt.Errorf("invalid compression setting '%s', must be %s or %s", v, compressionNone, compressionAuto)
+}
+
+// decompressor reads the decompressed content of a file.
+type decompressor struct {
+	io.ReadCloser
+	format string
+}
+
+// newDecompressor detects the compression format of a file from its magic
+// bytes. It returns a decompressor reading from the beginning of the file, or
+// nil if the file is not compressed. In both cases the file is positioned at
+// its beginning.
+func newDecompressor(f io.ReadSeeker) (*decompressor, error) {
+	magic := make([]byte, len(zstdMagic))
+	n, err := io.ReadFull(f, magic)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	magic = magic[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		return &decompressor{ReadCloser: r, format: gzipFormat}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		d, err := zstd.NewReader(f, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		return &decompressor{ReadCloser: d.IOReadCloser(), format: zstdFormat}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// openedFile is a file opened for reading. The decompressed content of
+// compressed files is read.
+type openedFile struct {
+	*os.File
+
+	// decompressor reads the content of compressed files, it is nil if the
+	// file is not compressed.
+	decompressor *decompressor
+}
+
+func (f *openedFile) Read(p []byte) (int, error) {
+	if f.decompressor != nil {
+		return f.decompressor.Read(p)
+	}
+	return f.File.Read(p)
+}
+
+func (f *openedFile) Close() error {
+	if f.decompressor != nil {
+		f.decompressor.Close()
+	}
+	return f.File.Close()
+}
+
+// skip moves the file to the offset of its content. The content of
+// compressed files is read up to the offset, as they cannot seek. It returns
+// io.EOF if the content is shorter than the offset.
+func (f *openedFile) skip(offset int64) error {
+	if f.decompressor == nil {
+		_, err := f.Seek(offset, io.SeekCurrent)
+		return err
+	}
+
+	_, err := io.CopyN(io.Discard, f.decompressor, offset)
+	return err
+}
+
+// reset moves the file back to the beginning of its content.
+func (f *openedFile) reset() error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if f.decompressor == nil {
+		return nil
+	}
+
+	f.decompressor.Close()
+	dec, err := newDecompressor(f.File)
+	if err != nil {
+		return err
+	}
+	if dec == nil {
+		return fmt.Errorf("file %s is no longer compressed", f.Name())
+	}
+	f.decompressor = dec
+	return nil
+}
+
+// uncompressedSize returns the size of the decompressed content of a file.
+func uncompressedSize(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	dec, err := newDecompressor(f)
+	if err != nil {
+		return 0, err
+	}
+	if dec == nil {
+		fi, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+	defer dec.Close()
+
+	return io.Copy(io.Discard, dec)
+}
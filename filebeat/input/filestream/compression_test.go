@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipData(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func zstdData(t *testing.T, data string) []byte {
+	t.Helper()
+	w, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer w.Close()
+	return w.EncodeAll([]byte(data), nil)
+}
+
+func TestNewDecompressor(t *testing.T) {
+	content := strings.Repeat("a log line\n", 100)
+
+	cases := []struct {
+		name   string
+		data   []byte
+		format string
+	}{
+		{name: "gzip", data: gzipData(t, content), format: gzipFormat},
+		{name: "zstd", data: zstdData(t, content), format: zstdFormat},
+		{name: "plain", data: []byte(content)},
+		{name: "short plain", data: []byte("a")},
+		{name: "empty", data: []byte{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := bytes.NewReader(tc.data)
+			dec, err := newDecompressor(r)
+			require.NoError(t, err)
+
+			if tc.format == "" {
+				require.Nil(t, dec)
+				pos, err := r.Seek(0, io.SeekCurrent)
+				require.NoError(t, err)
+				require.Zero(t, pos, "the reader must be at the beginning")
+				return
+			}
+
+			require.NotNil(t, dec)
+			defer dec.Close()
+			require.Equal(t, tc.format, dec.format)
+			got, err := io.ReadAll(dec)
+			require.NoError(t, err)
+			require.Equal(t, content, string(got))
+		})
+	}
+
+	t.Run("invalid gzip header", func(t *testing.T) {
+		_, err := newDecompressor(bytes.NewReader([]byte{0x1f, 0x8b, 0x00}))
+		require.Error(t, err)
+	})
+}
+
+func TestOpenedFile(t *testing.T) {
+	content := "first line\nsecond line\n"
+	dir := t.TempDir()
+
+	open := func(t *testing.T, data []byte) *openedFile {
+		t.Helper()
+		path := filepath.Join(dir, t.Name())
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		dec, err := newDecompressor(f)
+		require.NoError(t, err)
+		of := &openedFile{File: f, decompressor: dec}
+		t.Cleanup(func() { of.Close() })
+		return of
+	}
+
+	for name, data := range map[string][]byte{
+		"plain": []byte(content),
+		"gzip":  gzipData(t, content),
+		"zstd":  zstdData(t, content),
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := open(t, data)
+
+			require.NoError(t, f.skip(11))
+			got, err := io.ReadAll(f)
+			require.NoError(t, err)
+			require.Equal(t, "second line\n", string(got))
+
+			require.NoError(t, f.reset())
+			got, err = io.ReadAll(f)
+			require.NoError(t, err)
+			require.Equal(t, content, string(got))
+		})
+	}
+
+	t.Run("skip past the end of compressed content", func(t *testing.T) {
+		f := open(t, gzipData(t, content))
+		require.ErrorIs(t, f.skip(int64(len(content)+1)), io.EOF)
+	})
+}
+
+func TestUncompressedSize(t *testing.T) {
+	content := strings.Repeat("a log line\n", 100)
+	dir := t.TempDir()
+
+	for name, data := range map[string][]byte{
+		"plain": []byte(content),
+		"gzip":  gzipData(t, content),
+		"zstd":  zstdData(t, content),
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, data, 0o644))
+
+			size, err := uncompressedSize(path)
+			require.NoError(t, err)
+			require.EqualValues(t, len(content), size)
+		})
+	}
+}
+
+func TestCompressionModeUnpack(t *testing.T) {
+	var m compressionMode
+	require.NoError(t, m.Unpack("auto"))
+	require.Equal(t, compressionMode(compressionAuto), m)
+	require.NoError(t, m.Unpack("none"))
+	require.Equal(t, compressionMode(compressionNone), m)
+	require.ErrorContains(t, m.Unpack("gzip"), "invalid compression setting 'gzip'")
+}
@@ -50,6 +50,7 @@ type config struct {
 	IgnoreInactive ignoreInactiveType `config:"ignore_inactive"`
 	Rotation       *conf.Namespace    `config:"rotation"`
 	TakeOver       takeOverConfig     `config:"take_over"`
+	StartFrom      *startFromConfig   `config:"start_from"`
 
 	// AllowIDDuplication is used by InputManager.Create
 	// (see internal/input-logfile/manager.go).
@@ -168,6 +169,11 @@ func (c *config) Validate() error {
 		return errors.New("'take_over' mode is only allowed if an input ID is set")
 	}
 
+	if c.StartFrom != nil && !supportsStartFrom(c.Reader.LineTerminator) {
+		return errors.New("start_from requires line_terminator to be auto, " +
+			"line_feed or carriage_return_line_feed")
+	}
+
 	return nil
 }
 
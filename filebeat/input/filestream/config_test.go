@@ -26,6 +26,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest/observer"
 
+	"github.com/elastic/beats/v7/libbeat/reader/readfile"
 	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
 )
@@ -55,6 +56,16 @@ func TestConfigValidate(t *testing.T) {
 		err := c.Validate()
 		assert.NoError(t, err)
 	})
+
+	t.Run("start_from requires lines terminated by a line feed", func(t *testing.T) {
+		c := defaultConfig()
+		c.Paths = []string{"/foo/bar"}
+		c.StartFrom = &startFromConfig{Timestamp: "2024-01-01T00:00:00Z"}
+		assert.NoError(t, c.Validate())
+
+		c.Reader.LineTerminator = readfile.NullTerminator
+		assert.Error(t, c.Validate())
+	})
 }
 
 func TestValidateInputIDs(t *testing.T) {
@@ -64,7 +64,8 @@ type inputTestingEnvironment struct {
 
 type registryEntry struct {
 	Cursor struct {
-		Offset int `json:"offset"`
+		Offset    int      `json:"offset"`
+		CSVHeader []string `json:"csv_header" struct:"csv_header"`
 	} `json:"cursor"`
 	Meta any `json:"meta,omitempty"`
 }
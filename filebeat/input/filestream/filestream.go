@@ -41,7 +41,7 @@ var (
 
 // logFile contains all log related data
 type logFile struct {
-	file      *os.File
+	file      *openedFile
 	log       *logp.Logger
 	readerCtx ctxtool.CancelContext
 
@@ -63,7 +63,7 @@ type logFile struct {
 func newFileReader(
 	log *logp.Logger,
 	canceler input.Canceler,
-	f *os.File,
+	f *openedFile,
 	config readerConfig,
 	closerConfig closerConfig,
 ) (*logFile, error) {
@@ -211,7 +211,7 @@ func (f *logFile) shouldBeClosed() bool {
 
 	if f.closeRemoved {
 		// Check if the file name exists. See https://github.com/elastic/filebeat/issues/93
-		if file.IsRemoved(f.file) {
+		if file.IsRemoved(f.file.File) {
 			f.log.Debugf("close.on_state_change.removed is enabled and file %s has been removed", f.file.Name())
 			return true
 		}
@@ -62,7 +62,7 @@ func TestLogFileTimedClosing(t *testing.T) {
 			reader, err := newFileReader(
 				logp.L(),
 				context.TODO(),
-				f,
+				&openedFile{File: f},
 				readerConfig{},
 				closerConfig{
 					OnStateChange: stateChangeCloserConfig{
@@ -90,7 +90,7 @@ func TestLogFileTruncated(t *testing.T) {
 	defer f.Close()
 	defer os.Remove(f.Name())
 
-	reader, err := newFileReader(logp.L(), context.TODO(), f, readerConfig{}, closerConfig{})
+	reader, err := newFileReader(logp.L(), context.TODO(), &openedFile{File: f}, readerConfig{}, closerConfig{})
 	if err != nil {
 		t.Fatalf("error while creating logReader: %+v", err)
 	}
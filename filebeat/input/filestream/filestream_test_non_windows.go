@@ -41,7 +41,7 @@ func TestLogFileRenamed(t *testing.T) {
 	reader, err := newFileReader(
 		logp.L(),
 		context.TODO(),
-		f,
+		&openedFile{File: f},
 		readerConfig{},
 		closerConfig{
 			OnStateChange: stateChangeCloserConfig{
@@ -76,7 +76,7 @@ func TestLogFileRemoved(t *testing.T) {
 	reader, err := newFileReader(
 		logp.L(),
 		context.TODO(),
-		f,
+		&openedFile{File: f},
 		readerConfig{},
 		closerConfig{
 			OnStateChange: stateChangeCloserConfig{
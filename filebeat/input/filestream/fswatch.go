@@ -271,6 +271,7 @@ type fileScannerConfig struct {
 	Symlinks      bool              `config:"symlinks"`
 	RecursiveGlob bool              `config:"recursive_glob"`
 	Fingerprint   fingerprintConfig `config:"fingerprint"`
+	Compression   compressionMode   `config:"compression"`
 }
 
 func defaultFileScannerConfig() fileScannerConfig {
@@ -282,6 +283,7 @@ func defaultFileScannerConfig() fileScannerConfig {
 			Offset:  0,
 			Length:  DefaultFingerprintSize,
 		},
+		Compression: compressionNone,
 	}
 }
 
@@ -477,43 +479,88 @@ func (s *fileScanner) toFileDescriptor(it *ingestTarget) (fd loginp.FileDescript
 	fd.Filename = it.filename
 	fd.Info = it.info
 
-	if s.cfg.Fingerprint.Enabled {
-		fileSize := it.info.Size()
-		// we should not open the file if we know it's too small
-		minSize := s.cfg.Fingerprint.Offset + s.cfg.Fingerprint.Length
-		if fileSize < minSize {
-			return fd, fmt.Errorf("filesize of %q is %d bytes, expected at least %d bytes for fingerprinting: %w", fd.Filename, fileSize, minSize, errFileTooSmall)
-		}
+	decompress := s.cfg.Compression == compressionAuto
+	if !s.cfg.Fingerprint.Enabled && !decompress {
+		return fd, nil
+	}
+
+	fileSize := it.info.Size()
+	minSize := s.cfg.Fingerprint.Offset + s.cfg.Fingerprint.Length
+	// we should not open the file if we know it's too small, unless it
+	// can be compressed and its decompressed size is unknown
+	if s.cfg.Fingerprint.Enabled && !decompress && fileSize < minSize {
+		return fd, fmt.Errorf("filesize of %q is %d bytes, expected at least %d bytes for fingerprinting: %w", fd.Filename, fileSize, minSize, errFileTooSmall)
+	}
+
+	file, err := os.Open(it.originalFilename)
+	if err != nil {
+		return fd, fmt.Errorf("failed to open %q for fingerprinting: %w", it.originalFilename, err)
+	}
+	defer file.Close()
 
-		file, err := os.Open(it.originalFilename)
+	var r io.Reader = file
+	if decompress {
+		dec, err := newDecompressor(file)
 		if err != nil {
-			return fd, fmt.Errorf("failed to open %q for fingerprinting: %w", it.originalFilename, err)
+			return fd, fmt.Errorf("failed to detect the compression of %q: %w", fd.Filename, err)
+		}
+		if dec != nil {
+			defer dec.Close()
+			fd.Compression = dec.format
+			r = dec
 		}
-		defer file.Close()
+	}
+
+	if !s.cfg.Fingerprint.Enabled {
+		return fd, nil
+	}
+
+	if fd.Compression == "" && fileSize < minSize {
+		return fd, fmt.Errorf("filesize of %q is %d bytes, expected at least %d bytes for fingerprinting: %w", fd.Filename, fileSize, minSize, errFileTooSmall)
+	}
 
-		if s.cfg.Fingerprint.Offset != 0 {
+	// The fingerprint of compressed files is computed from their
+	// decompressed content, so a file compressed after being rotated keeps
+	// its fingerprint.
+	if s.cfg.Fingerprint.Offset != 0 {
+		if fd.Compression != "" {
+			_, err = io.CopyN(io.Discard, r, s.cfg.Fingerprint.Offset)
+		} else {
 			_, err = file.Seek(s.cfg.Fingerprint.Offset, io.SeekStart)
-			if err != nil {
-				return fd, fmt.Errorf("failed to seek %q for fingerprinting: %w", fd.Filename, err)
-			}
 		}
-
-		s.hasher.Reset()
-		lr := io.LimitReader(file, s.cfg.Fingerprint.Length)
-		written, err := io.CopyBuffer(s.hasher, lr, s.readBuffer)
 		if err != nil {
-			return fd, fmt.Errorf("failed to compute hash for first %d bytes of %q: %w", s.cfg.Fingerprint.Length, fd.Filename, err)
-		}
-		if written != s.cfg.Fingerprint.Length {
-			return fd, fmt.Errorf("failed to read %d bytes from %q to compute fingerprint, read only %d", written, fd.Filename, s.cfg.Fingerprint.Length)
+			if fd.Compression != "" && isShortRead(err) {
+				return fd, fmt.Errorf("decompressed content of %q is too short for fingerprinting: %w", fd.Filename, errFileTooSmall)
+			}
+			return fd, fmt.Errorf("failed to seek %q for fingerprinting: %w", fd.Filename, err)
 		}
+	}
 
-		fd.Fingerprint = hex.EncodeToString(s.hasher.Sum(nil))
+	s.hasher.Reset()
+	lr := io.LimitReader(r, s.cfg.Fingerprint.Length)
+	written, err := io.CopyBuffer(s.hasher, lr, s.readBuffer)
+	if fd.Compression != "" && (written != s.cfg.Fingerprint.Length || isShortRead(err)) {
+		// compressed files can be read while they are being written
+		return fd, fmt.Errorf("decompressed content of %q is too short for fingerprinting: %w", fd.Filename, errFileTooSmall)
+	}
+	if err != nil {
+		return fd, fmt.Errorf("failed to compute hash for first %d bytes of %q: %w", s.cfg.Fingerprint.Length, fd.Filename, err)
+	}
+	if written != s.cfg.Fingerprint.Length {
+		return fd, fmt.Errorf("failed to read %d bytes from %q to compute fingerprint, read only %d", written, fd.Filename, s.cfg.Fingerprint.Length)
 	}
 
+	fd.Fingerprint = hex.EncodeToString(s.hasher.Sum(nil))
+
 	return fd, nil
 }
 
+// isShortRead reports whether err is caused by reading past the end of a
+// file.
+func isShortRead(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
 func (s *fileScanner) isFileExcluded(file string) bool {
 	return len(s.cfg.ExcludedFiles) > 0 && s.matchAny(s.cfg.ExcludedFiles, file)
 }
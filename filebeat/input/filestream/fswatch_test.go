@@ -806,6 +806,72 @@ scanner:
 		})
 	}
 
+	t.Run("fingerprints the decompressed content of compressed files", func(t *testing.T) {
+		dir := t.TempDir()
+		content := strings.Repeat("a compressed log line\n", 100)
+		files := map[string][]byte{
+			"app.log.1":     []byte(content),
+			"app.log.1.gz":  gzipData(t, content),
+			"app.log.1.zst": zstdData(t, content),
+			"short.log.gz":  gzipData(t, "too short to fingerprint\n"),
+		}
+		for name, data := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o644))
+		}
+
+		cfgStr := `
+scanner:
+  compression: auto
+  fingerprint:
+    enabled: true
+    offset: 64
+    length: 1024
+`
+		logp.DevelopmentSetup(logp.ToObserverOutput())
+
+		// each glob matches a single file, otherwise files with the same
+		// fingerprint are skipped
+		for name, compression := range map[string]string{
+			"app.log.1":     "",
+			"app.log.1.gz":  gzipFormat,
+			"app.log.1.zst": zstdFormat,
+		} {
+			path := filepath.Join(dir, name)
+			s := createScannerWithConfig(t, []string{path}, cfgStr)
+			got := s.GetFiles()
+			require.Contains(t, got, path)
+			require.Equal(t, compression, got[path].Compression)
+			require.Equal(t, "f42ef83ca5de3ee272984a68524e4724acaf82379dc60facb26120740fc6e243", got[path].Fingerprint, name)
+		}
+
+		s := createScannerWithConfig(t, []string{filepath.Join(dir, "short.log.gz")}, cfgStr)
+		require.Empty(t, s.GetFiles())
+		logs := logp.ObserverLogs().FilterLevelExact(logp.WarnLevel.ZapLevel()).TakeAll()
+		require.Empty(t, logs, "there must be no warning logs for files too small")
+	})
+
+	t.Run("does not detect compressed files by default", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log.gz")
+		var content strings.Builder
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(&content, "log line %d\n", i)
+		}
+		require.NoError(t, os.WriteFile(path, gzipData(t, content.String()), 0o644))
+
+		cfgStr := `
+scanner:
+  fingerprint:
+    enabled: true
+    offset: 0
+    length: 64
+`
+		s := createScannerWithConfig(t, []string{path}, cfgStr)
+		got := s.GetFiles()
+		require.Contains(t, got, path)
+		require.Empty(t, got[path].Compression)
+	})
+
 	t.Run("does not issue warnings when file is too small", func(t *testing.T) {
 		cfgStr := `
 scanner:
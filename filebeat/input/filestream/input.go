@@ -50,6 +50,9 @@ const pluginName = "filestream"
 
 type state struct {
 	Offset int64 `json:"offset" struct:"offset"`
+
+	// State of the parsers at the offset, like the header of CSV files.
+	parser.State `struct:",inline"`
 }
 
 type fileMeta struct {
@@ -65,6 +68,7 @@ type filestream struct {
 	closerConfig    closerConfig
 	parsers         parser.Config
 	takeOver        takeOverConfig
+	startFrom       *timestampSeeker
 }
 
 // Plugin creates a new filestream input plugin for creating a stateful input.
@@ -109,6 +113,13 @@ func configure(cfg *conf.C) (loginp.Prospector, loginp.Harvester, error) {
 		takeOver:        config.TakeOver,
 	}
 
+	if config.StartFrom != nil {
+		filestream.startFrom, err = newTimestampSeeker(config.StartFrom)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	return prospector, filestream, nil
 }
 
@@ -120,7 +131,7 @@ func (inp *filestream) Test(src loginp.Source, ctx input.TestContext) error {
 		return fmt.Errorf("not file source")
 	}
 
-	reader, _, err := inp.open(ctx.Logger, ctx.Cancelation, fs, 0)
+	reader, _, err := inp.open(ctx.Logger, ctx.Cancelation, fs, 0, &parser.State{})
 	if err != nil {
 		return err
 	}
@@ -142,7 +153,27 @@ func (inp *filestream) Run(
 	log := ctx.Logger.With("path", fs.newPath).With("state-id", src.Name())
 	state := initState(log, cursor, fs)
 
-	r, truncated, err := inp.open(log, ctx.Cancelation, fs, state.Offset)
+	if cursor.IsNew() && inp.startFrom != nil {
+		offset, err := inp.startFrom.Seek(fs.newPath, fs.desc.Compression != "")
+		if err != nil {
+			log.Errorf("Start offset could not be found: %v", err)
+			return err
+		}
+		log.Infof("Reading file from offset %d, the first line since %s", offset, inp.startFrom.since)
+		state.Offset = offset
+	}
+
+	// The parsers update their state as they read, it is copied to the state
+	// published with each event.
+	parserState := state.State
+	if state.Offset > 0 && inp.parsers.NeedsState(parserState) {
+		if err := inp.restoreParserState(log, ctx.Cancelation, fs, &parserState); err != nil {
+			log.Errorf("Parsers state could not be restored: %v", err)
+			return err
+		}
+	}
+
+	r, truncated, err := inp.open(log, ctx.Cancelation, fs, state.Offset, &parserState)
 	if err != nil {
 		log.Errorf("File could not be opened for reading: %v", err)
 		return err
@@ -168,7 +199,7 @@ func (inp *filestream) Run(
 
 	// The caller of Run already reports the error and filters out errors that
 	// must not be reported, like 'context cancelled'.
-	return inp.readFromSource(ctx, log, r, fs.newPath, state, publisher, metrics)
+	return inp.readFromSource(ctx, log, r, fs.newPath, state, &parserState, publisher, metrics)
 }
 
 func initState(log *logp.Logger, c loginp.Cursor, s fileSource) state {
@@ -190,9 +221,10 @@ func (inp *filestream) open(
 	canceler input.Canceler,
 	fs fileSource,
 	offset int64,
+	parserState *parser.State,
 ) (reader.Reader, bool, error) {
 
-	f, encoding, truncated, err := inp.openFile(log, fs.newPath, offset)
+	f, encoding, truncated, err := inp.openFile(log, fs.newPath, fs.desc.Compression != "", offset)
 	if err != nil {
 		return nil, truncated, err
 	}
@@ -200,16 +232,18 @@ func (inp *filestream) open(
 	if truncated {
 		offset = 0
 	}
+	if offset == 0 {
+		// The parsers read their state from the beginning of the file.
+		*parserState = parser.State{}
+	}
 
 	ok := false // used for cleanup
 	defer cleanup.IfNot(&ok, cleanup.IgnoreError(f.Close))
 
-	log.Debug("newLogFileReader with config.MaxBytes:", inp.readerConfig.MaxBytes)
-
-	// if the file is archived, it means that it is not going to be updated in the future
-	// thus, when EOF is reached, it can be closed
+	// if the file is archived or compressed, it means that it is not going to be updated
+	// in the future thus, when EOF is reached, it can be closed
 	closerCfg := inp.closerConfig
-	if fs.archived && !inp.closerConfig.Reader.OnEOF {
+	if (fs.archived || f.decompressor != nil) && !inp.closerConfig.Reader.OnEOF {
 		closerCfg = closerConfig{
 			Reader: readerCloserConfig{
 				OnEOF:         true,
@@ -218,17 +252,42 @@ func (inp *filestream) open(
 			OnStateChange: inp.closerConfig.OnStateChange,
 		}
 	}
+	r, err := inp.newLineReader(log, canceler, f, encoding, closerCfg)
+	if err != nil {
+		return nil, truncated, err
+	}
+
+	r = readfile.NewFilemeta(r, fs.newPath, fs.desc.Info, fs.desc.Fingerprint, offset)
+
+	r = inp.parsers.CreateWithState(r, parserState)
+
+	r = readfile.NewLimitReader(r, inp.readerConfig.MaxBytes)
+
+	ok = true // no need to close the file
+	return r, truncated, nil
+}
+
+// newLineReader creates a reader returning the lines of a file.
+func (inp *filestream) newLineReader(
+	log *logp.Logger,
+	canceler input.Canceler,
+	f *openedFile,
+	encoding encoding.Encoding,
+	closerCfg closerConfig,
+) (reader.Reader, error) {
+	log.Debug("newLogFileReader with config.MaxBytes:", inp.readerConfig.MaxBytes)
+
 	// NewLineReader uses additional buffering to deal with encoding and testing
 	// for new lines in input stream. Simple 8-bit based encodings, or plain
 	// don't require 'complicated' logic.
 	logReader, err := newFileReader(log, canceler, f, inp.readerConfig, closerCfg)
 	if err != nil {
-		return nil, truncated, err
+		return nil, err
 	}
 
 	dbgReader, err := debug.AppendReaders(logReader)
 	if err != nil {
-		return nil, truncated, err
+		return nil, err
 	}
 
 	// Configure MaxBytes limit for EncodeReader as multiplied by 4
@@ -245,19 +304,34 @@ func (inp *filestream) open(
 		MaxBytes:   encReaderMaxBytes,
 	})
 	if err != nil {
-		return nil, truncated, err
+		return nil, err
 	}
 
-	r = readfile.NewStripNewline(r, inp.readerConfig.LineTerminator)
-
-	r = readfile.NewFilemeta(r, fs.newPath, fs.desc.Info, fs.desc.Fingerprint, offset)
+	return readfile.NewStripNewline(r, inp.readerConfig.LineTerminator), nil
+}
 
-	r = inp.parsers.Create(r)
+// restoreParserState reads the state of the parsers from the beginning of
+// the file. It is needed when the offset was set without the parsers reading
+// the file, for example for files that were ignored.
+func (inp *filestream) restoreParserState(
+	log *logp.Logger,
+	canceler input.Canceler,
+	fs fileSource,
+	parserState *parser.State,
+) error {
+	f, encoding, _, err := inp.openFile(log, fs.newPath, fs.desc.Compression != "", 0)
+	if err != nil {
+		return err
+	}
 
-	r = readfile.NewLimitReader(r, inp.readerConfig.MaxBytes)
+	r, err := inp.newLineReader(log, canceler, f, encoding, closerConfig{Reader: readerCloserConfig{OnEOF: true}})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	defer r.Close()
 
-	ok = true // no need to close the file
-	return r, truncated, nil
+	return inp.parsers.RestoreState(r, parserState)
 }
 
 // openFile opens a file and checks for the encoding. In case the encoding cannot be detected
@@ -267,11 +341,15 @@ func (inp *filestream) open(
 //
 // openFile will also detect and hadle file truncation. If a file is truncated
 // then the 3rd return value is true.
+//
+// If compressed is true and the file is compressed, the decompressed content
+// of the file is read and offset is an offset in the decompressed content.
 func (inp *filestream) openFile(
 	log *logp.Logger,
 	path string,
+	compressed bool,
 	offset int64,
-) (*os.File, encoding.Encoding, bool, error) {
+) (*openedFile, encoding.Encoding, bool, error) {
 	fi, err := os.Stat(path)
 	if err != nil {
 		return nil, nil, false, fmt.Errorf("failed to stat source file %s: %w", path, err)
@@ -283,10 +361,11 @@ func (inp *filestream) openFile(
 		return nil, nil, false, fmt.Errorf("failed to open file %s, named pipes are not supported", fi.Name())
 	}
 
-	f, err := file.ReadOpen(path)
+	osFile, err := file.ReadOpen(path)
 	if err != nil {
 		return nil, nil, false, fmt.Errorf("failed opening %s: %w", path, err)
 	}
+	f := &openedFile{File: osFile}
 	ok := false
 	defer cleanup.IfNot(&ok, cleanup.IgnoreError(f.Close))
 
@@ -300,8 +379,17 @@ func (inp *filestream) openFile(
 		return nil, nil, false, err
 	}
 
+	if compressed {
+		f.decompressor, err = newDecompressor(f.File)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+	}
+
 	truncated := false
-	if fi.Size() < offset {
+	// the size of compressed files is not the size of their content, the
+	// truncation is detected when skipping to the offset
+	if f.decompressor == nil && fi.Size() < offset {
 		// if the file was truncated we need to reset the offset and notify
 		// all callers so they can also reset their offsets
 		truncated = true
@@ -309,6 +397,11 @@ func (inp *filestream) openFile(
 		offset = 0
 	}
 	err = inp.initFileOffset(f, offset)
+	if f.decompressor != nil && errors.Is(err, io.EOF) {
+		truncated = true
+		log.Infof("Decompressed file is shorter than the offset. Reading file from offset 0. Path=%s", path)
+		err = f.reset()
+	}
 	if err != nil {
 		return nil, nil, truncated, err
 	}
@@ -333,10 +426,9 @@ func checkFileBeforeOpening(fi os.FileInfo) error {
 	return nil
 }
 
-func (inp *filestream) initFileOffset(file *os.File, offset int64) error {
+func (inp *filestream) initFileOffset(file *openedFile, offset int64) error {
 	if offset > 0 {
-		_, err := file.Seek(offset, io.SeekCurrent)
-		return err
+		return file.skip(offset)
 	}
 
 	// get offset from file in case of encoding factory was required to read some data.
@@ -350,6 +442,7 @@ func (inp *filestream) readFromSource(
 	r reader.Reader,
 	path string,
 	s state,
+	parserState *parser.State,
 	p loginp.Publisher,
 	metrics *loginp.Metrics,
 ) error {
@@ -378,6 +471,7 @@ func (inp *filestream) readFromSource(
 		}
 
 		s.Offset += int64(message.Bytes) + int64(message.Offset)
+		s.State = *parserState
 
 		flags, err := message.Fields.GetValue("log.flags")
 		if err == nil {
@@ -27,6 +27,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -1161,3 +1162,132 @@ func TestRotatingCloseInactiveLowWriteRate(t *testing.T) {
 	cancelInput()
 	env.waitUntilInputStops()
 }
+
+func TestFilestreamCompressedFiles(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	id := "fake-ID-" + uuid.Must(uuid.NewV4()).String()
+	inp := env.mustCreateInput(map[string]interface{}{
+		"id":                                     id,
+		"paths":                                  []string{env.abspath("*")},
+		"prospector.scanner.check_interval":      "1ms",
+		"prospector.scanner.compression":         "auto",
+		"prospector.scanner.fingerprint.enabled": false,
+		"file_identity.native":                   map[string]any{},
+	})
+
+	content := "first line\nsecond line\nthird line\n"
+	env.mustWriteToFile("test.log.gz", gzipData(t, content))
+	env.mustWriteToFile("test.log.zst", zstdData(t, content))
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	env.startInput(ctx, id, inp)
+
+	env.waitUntilEventCount(6)
+	env.requireOffsetInRegistry("test.log.gz", id, len(content))
+	env.requireOffsetInRegistry("test.log.zst", id, len(content))
+
+	cancelInput()
+	env.waitUntilInputStops()
+}
+
+func TestFilestreamFileCompressedAfterRotation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("renaming files while Filebeat is running is not supported on Windows")
+	}
+
+	env := newInputTestingEnvironment(t)
+
+	testlogName := "test.log"
+	id := "fake-ID-" + uuid.Must(uuid.NewV4()).String()
+	config := map[string]interface{}{
+		"id":                                     id,
+		"paths":                                  []string{env.abspath("*")},
+		"prospector.scanner.check_interval":      "1ms",
+		"prospector.scanner.compression":         "auto",
+		"prospector.scanner.fingerprint.enabled": true,
+		"file_identity.fingerprint":              nil,
+		// the files are renamed while the input is stopped
+		"clean_removed": false,
+	}
+	inp := env.mustCreateInput(config)
+
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("log line number %d", i))
+	}
+	content := strings.Join(lines, "\n") + "\n"
+	env.mustWriteToFile(testlogName, []byte(content))
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	env.startInput(ctx, id, inp)
+
+	env.waitUntilEventCount(len(lines))
+
+	// The compressed file has the fingerprint of the rotated file, its
+	// content is not read again.
+	env.mustRenameFile(testlogName, testlogName+".1")
+	env.mustWriteToFile(testlogName+".1.gz", gzipData(t, content))
+	env.mustRemoveFile(testlogName + ".1")
+
+	time.Sleep(500 * time.Millisecond)
+	env.waitUntilEventCount(len(lines))
+
+	cancelInput()
+	env.waitUntilInputStops()
+
+	// The lines added before the file was compressed are read from the
+	// compressed file after a restart.
+	moreLines := []string{"written before compression", "and not read yet"}
+	content += strings.Join(moreLines, "\n") + "\n"
+	env.mustWriteToFile(testlogName+".2", []byte(content))
+	env.mustRemoveFile(testlogName + ".1.gz")
+	env.mustWriteToFile(testlogName+".2.gz", gzipData(t, content))
+	env.mustRemoveFile(testlogName + ".2")
+
+	env.pluginInitOnce = sync.Once{}
+	inp = env.mustCreateInput(config)
+	ctx, cancelInput = context.WithCancel(context.Background())
+	env.startInput(ctx, id, inp)
+
+	env.waitUntilEventCount(len(lines) + len(moreLines))
+	env.requireEventsReceived(append(lines, moreLines...))
+
+	cancelInput()
+	env.waitUntilInputStops()
+}
+
+func TestFilestreamStartFromTimestamp(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	testlogName := "test.log"
+	id := "fake-ID-" + uuid.Must(uuid.NewV4()).String()
+	inp := env.mustCreateInput(map[string]interface{}{
+		"id":                                     id,
+		"paths":                                  []string{env.abspath(testlogName)},
+		"prospector.scanner.check_interval":      "1ms",
+		"prospector.scanner.fingerprint.enabled": false,
+		"file_identity.native":                   map[string]any{},
+		"start_from.timestamp":                   "2024-01-01T00:02:00Z",
+		"start_from.layout":                      "2006-01-02 15:04:05",
+		"start_from.timezone":                    "UTC",
+	})
+
+	skipped := "2024-01-01 00:00:00 first line\n2024-01-01 00:01:00 second line\n"
+	env.mustWriteToFile(testlogName, []byte(skipped+
+		"2024-01-01 00:02:00 third line\n2024-01-01 00:03:00 fourth line\n"))
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	env.startInput(ctx, id, inp)
+
+	env.waitUntilEventCount(2)
+	env.requireEventContents(0, "message", "2024-01-01 00:02:00 third line")
+	env.requireEventContents(1, "message", "2024-01-01 00:03:00 fourth line")
+
+	// lines appended later are read as usual
+	env.mustAppendToFile(testlogName, []byte("2023-12-31 00:00:00 late line\n"))
+	env.waitUntilEventCount(3)
+
+	cancelInput()
+	env.waitUntilInputStops()
+}
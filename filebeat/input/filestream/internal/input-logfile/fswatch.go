@@ -64,6 +64,9 @@ type FileDescriptor struct {
 	Info file.ExtendedFileInfo
 	// Fingerprint is a computed hash of the file header
 	Fingerprint string
+	// Compression is the format the file is compressed with, like gzip.
+	// It is empty if the file is not compressed.
+	Compression string
 }
 
 // FileID returns a unique file ID
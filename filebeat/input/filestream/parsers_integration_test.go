@@ -21,9 +21,12 @@ package filestream
 
 import (
 	"context"
+	"os"
+	"sync"
 	"testing"
 
 	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParsersAgentLogs(t *testing.T) {
@@ -639,3 +642,63 @@ SetAdCodeMiddleware.default_ad_code route
 	cancelInput()
 	env.waitUntilInputStops()
 }
+
+func TestParsersCSV(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	testlogName := "test.csv"
+	id := "fake-ID-" + uuid.Must(uuid.NewV4()).String()
+	config := map[string]interface{}{
+		"id":                                     id,
+		"paths":                                  []string{env.abspath(testlogName)},
+		"prospector.scanner.check_interval":      "1ms",
+		"file_identity.native":                   map[string]any{},
+		"prospector.scanner.fingerprint.enabled": false,
+		"parsers": []map[string]interface{}{
+			{
+				"csv": map[string]interface{}{
+					"target": "csv",
+				},
+			},
+		},
+	}
+	inp := env.mustCreateInput(config)
+
+	testlines := []byte("user,comment\nalice,\"first line\nsecond line\"\n")
+	env.mustWriteToFile(testlogName, testlines)
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	env.startInput(ctx, id, inp)
+
+	env.waitUntilEventCount(1)
+	env.requireOffsetInRegistry(testlogName, id, len(testlines))
+	env.requireEventContents(0, "csv.user", "alice")
+	env.requireEventContents(0, "csv.comment", "first line\nsecond line")
+
+	cancelInput()
+	env.waitUntilInputStops()
+
+	fi, err := os.Stat(env.abspath(testlogName))
+	require.NoError(t, err)
+	entry, err := env.getRegistryState(getIDFromPath(env.abspath(testlogName), id, fi))
+	require.NoError(t, err)
+	require.Equal(t, []string{"user", "comment"}, entry.Cursor.CSVHeader)
+
+	// The header is read from the cursor after a restart.
+	moreTestlines := []byte("bob,hello\n")
+	env.mustAppendToFile(testlogName, moreTestlines)
+
+	// Start a new input manager on the same registry, like a restart would.
+	env.pluginInitOnce = sync.Once{}
+	inp = env.mustCreateInput(config)
+	ctx, cancelInput = context.WithCancel(context.Background())
+	env.startInput(ctx, id, inp)
+
+	env.waitUntilEventCount(2)
+	env.requireOffsetInRegistry(testlogName, id, len(testlines)+len(moreTestlines))
+	env.requireEventContents(1, "csv.user", "bob")
+	env.requireEventContents(1, "csv.comment", "hello")
+
+	cancelInput()
+	env.waitUntilInputStops()
+}
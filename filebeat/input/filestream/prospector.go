@@ -339,7 +339,17 @@ func (p *fileProspector) onFSEvent(
 		}
 
 		if p.isFileIgnored(log, event, ignoreSince) {
-			err := updater.ResetCursor(src, state{Offset: event.Descriptor.Info.Size()})
+			size := event.Descriptor.Info.Size()
+			if event.Descriptor.Compression != "" {
+				// the offset is an offset in the decompressed content
+				var err error
+				size, err = uncompressedSize(event.NewPath)
+				if err != nil {
+					log.Errorf("getting the decompressed size of ignored file: %v", err)
+					return
+				}
+			}
+			err := updater.ResetCursor(src, state{Offset: size})
 			if err != nil {
 				log.Errorf("setting cursor for ignored file: %v", err)
 			}
@@ -438,6 +448,13 @@ func (p *fileProspector) onRename(log *logp.Logger, ctx input.Context, fe loginp
 			fe.Op = loginp.OpDelete
 			srcToClose := p.identifier.GetSource(fe)
 			hg.Stop(srcToClose)
+		} else if fe.Descriptor.Compression != "" {
+			// A file compressed after being rotated replaces the rotated
+			// file, whose harvester stops once it is removed. The harvester
+			// is restarted on the compressed file so what is left to read
+			// is not lost.
+			log.Debugf("Restarting harvester as file %s has been renamed to compressed file %s.", fe.OldPath, fe.NewPath)
+			hg.Restart(ctx, src)
 		}
 	}
 }
@@ -0,0 +1,255 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	"github.com/elastic/beats/v7/libbeat/reader/readfile"
+	"github.com/elastic/beats/v7/libbeat/reader/syslog"
+)
+
+// maxTimestampLineBytes is the number of bytes at the beginning of lines
+// their timestamp is parsed from.
+const maxTimestampLineBytes = 1024
+
+// startFromConfig configures the offset files without a state in the
+// registry are read from.
+type startFromConfig struct {
+	// Timestamp is the instant files are read from, in RFC 3339 format.
+	Timestamp string `config:"timestamp" validate:"required"`
+
+	// Layout is the layout of the timestamp at the beginning of lines, in
+	// the format of the Go time package. Lines are parsed as syslog messages
+	// if it is empty.
+	Layout string `config:"layout"`
+
+	// TimeZone is the time zone of timestamps without one.
+	TimeZone *cfgtype.Timezone `config:"timezone"`
+}
+
+func (c *startFromConfig) Validate() error {
+	if _, err := time.Parse(time.RFC3339Nano, c.Timestamp); err != nil {
+		return fmt.Errorf("invalid timestamp '%s', must be in RFC 3339 format: %w", c.Timestamp, err)
+	}
+	return nil
+}
+
+// timestampSeeker finds the first line of a file whose timestamp is not
+// before an instant.
+type timestampSeeker struct {
+	since  time.Time
+	layout string
+	loc    *time.Location
+}
+
+func newTimestampSeeker(cfg *startFromConfig) (*timestampSeeker, error) {
+	since, err := time.Parse(time.RFC3339Nano, cfg.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp '%s': %w", cfg.Timestamp, err)
+	}
+
+	loc := time.Local
+	if cfg.TimeZone != nil {
+		loc = cfg.TimeZone.Location()
+	}
+	return &timestampSeeker{since: since, layout: cfg.Layout, loc: loc}, nil
+}
+
+// Seek returns the offset of the first line of a file whose timestamp is
+// not before the instant, or the size of the file if there is none. Lines
+// without timestamp, like the continuation lines of multiline messages,
+// are skipped.
+//
+// The lines of compressed files are read in order, the lines of other files
+// are binary searched, which assumes the timestamps of their lines are
+// ordered.
+func (s *timestampSeeker) Seek(path string, compressed bool) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if compressed {
+		dec, err := newDecompressor(f)
+		if err != nil {
+			return 0, err
+		}
+		if dec != nil {
+			defer dec.Close()
+			offset, _, _, err := s.firstTimestamp(newLineScanner(dec, 0), -1, true)
+			return offset, err
+		}
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := fi.Size()
+
+	// The first timestamp of the lines starting at or after an offset is
+	// not before the instant from lo on, and hi is always such an offset.
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		scanner, err := newLineScannerAt(f, mid)
+		if err != nil {
+			return 0, err
+		}
+		offset, ts, found, err := s.firstTimestamp(scanner, hi, false)
+		if err != nil {
+			return 0, err
+		}
+		if found && ts.Before(s.since) {
+			lo = offset + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	scanner, err := newLineScannerAt(f, lo)
+	if err != nil {
+		return 0, err
+	}
+	offset, _, found, err := s.firstTimestamp(scanner, size, true)
+	if err != nil || !found {
+		return size, err
+	}
+	return offset, nil
+}
+
+// firstTimestamp returns the offset and timestamp of the first line with a
+// timestamp starting before the offset end, or before the end of the file
+// if end is negative. If notBefore is true, lines with a timestamp before
+// the instant are skipped. The returned offset is the end of the scanned
+// lines if no line is found.
+func (s *timestampSeeker) firstTimestamp(scanner *lineScanner, end int64, notBefore bool) (int64, time.Time, bool, error) {
+	for end < 0 || scanner.offset < end {
+		offset, line, err := scanner.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, time.Time{}, false, err
+		}
+
+		ts, ok := s.parse(line)
+		if !ok || (notBefore && ts.Before(s.since)) {
+			continue
+		}
+		return offset, ts, true, nil
+	}
+	return scanner.offset, time.Time{}, false, nil
+}
+
+// parse returns the timestamp at the beginning of a line.
+func (s *timestampSeeker) parse(line []byte) (time.Time, bool) {
+	line = bytes.TrimRight(line, "\r\n")
+
+	if s.layout == "" {
+		_, ts, _ := syslog.ParseMessage(string(line), syslog.FormatAuto, s.loc)
+		return ts, !ts.IsZero()
+	}
+
+	// The length of timestamps is not known, as values like month names
+	// or fractional seconds have a variable length. The timestamp is
+	// followed by a space or the end of the line.
+	maxLen := min(len(line), len(s.layout)+16)
+	for i := 1; i <= maxLen; i++ {
+		if i < len(line) && line[i] != ' ' {
+			continue
+		}
+		if ts, err := time.ParseInLocation(s.layout, string(line[:i]), s.loc); err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// lineScanner reads the lines of a file, keeping only their beginning.
+type lineScanner struct {
+	r      *bufio.Reader
+	offset int64
+	line   []byte
+}
+
+func newLineScanner(r io.Reader, offset int64) *lineScanner {
+	return &lineScanner{r: bufio.NewReader(r), offset: offset}
+}
+
+// newLineScannerAt returns a scanner of the lines starting at or after an
+// offset of a file.
+func newLineScannerAt(f *os.File, offset int64) (*lineScanner, error) {
+	if offset == 0 {
+		return newLineScanner(io.NewSectionReader(f, 0, 1<<62), 0), nil
+	}
+
+	// The line is skipped unless the offset is at the beginning of a line.
+	scanner := newLineScanner(io.NewSectionReader(f, offset-1, 1<<62), offset-1)
+	prev, err := scanner.r.ReadByte()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	scanner.offset++
+	if prev != '\n' {
+		if _, _, err := scanner.next(); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	}
+	return scanner, nil
+}
+
+// next returns the offset of the next line and up to maxTimestampLineBytes
+// of its beginning. The last line of the file is returned even if it is not
+// terminated.
+func (s *lineScanner) next() (int64, []byte, error) {
+	start := s.offset
+	s.line = s.line[:0]
+	for {
+		chunk, err := s.r.ReadSlice('\n')
+		s.offset += int64(len(chunk))
+		if n := maxTimestampLineBytes - len(s.line); n > 0 {
+			s.line = append(s.line, chunk[:min(n, len(chunk))]...)
+		}
+
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && s.offset > start:
+			return start, s.line, nil
+		case err != nil:
+			return start, nil, err
+		}
+		return start, s.line, nil
+	}
+}
+
+// supportsStartFrom reports if lines are terminated by a line feed, which
+// is needed to find their beginning.
+func supportsStartFrom(t readfile.LineTerminator) bool {
+	return t == readfile.AutoLineTerminator || t == readfile.LineFeed || t == readfile.CarriageReturnLineFeed
+}
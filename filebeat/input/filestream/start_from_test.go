@@ -0,0 +1,205 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+)
+
+func mustNewTimestampSeeker(t *testing.T, cfg map[string]any) *timestampSeeker {
+	t.Helper()
+	var c startFromConfig
+	require.NoError(t, conf.MustNewConfigFrom(cfg).Unpack(&c))
+	s, err := newTimestampSeeker(&c)
+	require.NoError(t, err)
+	return s
+}
+
+func writeTempFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.log")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	return path
+}
+
+func TestStartFromConfigValidate(t *testing.T) {
+	var c startFromConfig
+	err := conf.MustNewConfigFrom(map[string]any{"timestamp": "yesterday"}).Unpack(&c)
+	require.ErrorContains(t, err, "invalid timestamp 'yesterday'")
+
+	err = conf.MustNewConfigFrom(map[string]any{"layout": time.DateTime}).Unpack(&c)
+	require.Error(t, err, "timestamp is required")
+}
+
+func TestTimestampSeekerParse(t *testing.T) {
+	utc := mustNewTimestampSeeker(t, map[string]any{
+		"timestamp": "2024-01-01T00:00:00Z",
+		"layout":    time.DateTime,
+		"timezone":  "UTC",
+	})
+	syslog := mustNewTimestampSeeker(t, map[string]any{
+		"timestamp": "2024-01-01T00:00:00Z",
+		"timezone":  "UTC",
+	})
+	rfc3339 := mustNewTimestampSeeker(t, map[string]any{
+		"timestamp": "2024-01-01T00:00:00Z",
+		"layout":    time.RFC3339Nano,
+	})
+
+	cases := []struct {
+		name   string
+		seeker *timestampSeeker
+		line   string
+		want   time.Time
+	}{
+		{
+			name:   "layout with space",
+			seeker: utc,
+			line:   "2024-03-04 05:06:07 INFO started\n",
+			want:   time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC),
+		},
+		{
+			name:   "only timestamp",
+			seeker: utc,
+			line:   "2024-03-04 05:06:07\r\n",
+			want:   time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC),
+		},
+		{
+			name:   "variable length",
+			seeker: rfc3339,
+			line:   "2024-03-04T05:06:07.123+01:00 message",
+			want:   time.Date(2024, 3, 4, 4, 6, 7, 123e6, time.UTC),
+		},
+		{
+			name:   "no timestamp",
+			seeker: utc,
+			line:   "\tat com.example.Main.main(Main.java:10)\n",
+		},
+		{
+			name:   "rfc 3164",
+			seeker: syslog,
+			line:   "<13>Mar  4 05:06:07 host app[1]: started\n",
+			want:   time.Date(time.Now().Year(), 3, 4, 5, 6, 7, 0, time.UTC),
+		},
+		{
+			name:   "rfc 5424",
+			seeker: syslog,
+			line:   "<165>1 2024-03-04T05:06:07.000Z host app 1 ID47 - started\n",
+			want:   time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC),
+		},
+		{
+			name:   "not syslog",
+			seeker: syslog,
+			line:   "started\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, ok := tc.seeker.parse([]byte(tc.line))
+			require.Equal(t, !tc.want.IsZero(), ok)
+			require.True(t, tc.want.Equal(ts), "got %s, want %s", ts, tc.want)
+		})
+	}
+}
+
+func TestTimestampSeekerSeek(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Every line is a minute after the previous one, some of them have
+	// continuation lines without a timestamp.
+	var sb strings.Builder
+	var offsets []int64
+	for i := range 1000 {
+		offsets = append(offsets, int64(sb.Len()))
+		fmt.Fprintf(&sb, "%s line %d\n", start.Add(time.Duration(i)*time.Minute).Format(time.DateTime), i)
+		for j := range i % 4 {
+			fmt.Fprintf(&sb, "\tcontinuation %d of line %d\n", j, i)
+		}
+	}
+	content := sb.String()
+	size := int64(len(content))
+
+	cases := []struct {
+		name  string
+		since time.Time
+		want  int64
+	}{
+		{name: "before first line", since: start.Add(-time.Hour), want: 0},
+		{name: "first line", since: start, want: 0},
+		{name: "exact line", since: start.Add(500 * time.Minute), want: offsets[500]},
+		{name: "between lines", since: start.Add(501*time.Minute + time.Second), want: offsets[502]},
+		{name: "last line", since: start.Add(999 * time.Minute), want: offsets[999]},
+		{name: "after last line", since: start.Add(1000 * time.Minute), want: size},
+	}
+
+	files := map[string]struct {
+		path       string
+		compressed bool
+	}{
+		"plain": {path: writeTempFile(t, []byte(content))},
+		"gzip":  {path: writeTempFile(t, gzipData(t, content)), compressed: true},
+		"zstd":  {path: writeTempFile(t, zstdData(t, content)), compressed: true},
+	}
+
+	for name, f := range files {
+		for _, tc := range cases {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				s := mustNewTimestampSeeker(t, map[string]any{
+					"timestamp": tc.since.Format(time.RFC3339),
+					"layout":    time.DateTime,
+					"timezone":  "UTC",
+				})
+				offset, err := s.Seek(f.path, f.compressed)
+				require.NoError(t, err)
+				require.Equal(t, tc.want, offset)
+			})
+		}
+	}
+
+	t.Run("long lines", func(t *testing.T) {
+		long := strings.Repeat("x", 10*maxTimestampLineBytes)
+		content := "2024-01-01 00:00:00 " + long + "\n" +
+			"2024-01-01 00:01:00 " + long + "\n" +
+			"2024-01-01 00:02:00 " + long
+		s := mustNewTimestampSeeker(t, map[string]any{
+			"timestamp": "2024-01-01T00:02:00Z",
+			"layout":    time.DateTime,
+			"timezone":  "UTC",
+		})
+		offset, err := s.Seek(writeTempFile(t, []byte(content)), false)
+		require.NoError(t, err)
+		require.Equal(t, int64(2*(len(long)+21)), offset)
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		s := mustNewTimestampSeeker(t, map[string]any{"timestamp": "2024-01-01T00:00:00Z"})
+		offset, err := s.Seek(writeTempFile(t, nil), false)
+		require.NoError(t, err)
+		require.Zero(t, offset)
+	})
+}
@@ -175,6 +175,10 @@ func (c *config) Validate() error {
 		return fmt.Errorf("When using the JSON decoder and multiline together, you need to specify a message_key value")
 	}
 
+	if c.Multiline != nil && c.Multiline.CombinesFields() {
+		return fmt.Errorf("multiline type fields is not supported by the log input, use the filestream input instead")
+	}
+
 	if c.JSON != nil && len(c.JSON.MessageKey) == 0 &&
 		(len(c.IncludeLines) > 0 || len(c.ExcludeLines) > 0) {
 		return fmt.Errorf("When using the JSON decoder and line filtering together, you need to specify a message_key value")
@@ -24,8 +24,11 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/filebeat/harvester"
+	"github.com/elastic/beats/v7/libbeat/reader/multiline"
+	conf "github.com/elastic/elastic-agent-libs/config"
 )
 
 func TestCleanOlderError(t *testing.T) {
@@ -70,3 +73,22 @@ func TestCleanOlderIgnoreOlder(t *testing.T) {
 	err := config.Validate()
 	assert.NoError(t, err)
 }
+
+func TestMultilineFieldsTypeError(t *testing.T) {
+	var ml multiline.Config
+	require.NoError(t, conf.MustNewConfigFrom(map[string]interface{}{
+		"type":    "fields",
+		"pattern": "^[[:space:]]",
+	}).Unpack(&ml))
+
+	config := config{
+		Paths:     []string{"hello"},
+		Multiline: &ml,
+		ForwarderConfig: harvester.ForwarderConfig{
+			Type: "log",
+		},
+	}
+
+	err := config.Validate()
+	assert.ErrorContains(t, err, "multiline type fields is not supported by the log input")
+}
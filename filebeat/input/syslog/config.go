@@ -65,8 +65,14 @@ var defaultConfig = config{
 
 type syslogTCP struct {
 	tcp.Config    `config:",inline"`
-	LineDelimiter string                `config:"line_delimiter" validate:"nonzero"`
-	Framing       streaming.FramingType `config:"framing"`
+	LineDelimiter string                       `config:"line_delimiter" validate:"nonzero"`
+	Framing       streaming.FramingType        `config:"framing"`
+	LengthPrefix  streaming.LengthPrefixConfig `config:"length_prefix"`
+}
+
+// Validate validates the framing configuration of the tcp protocol.
+func (c *syslogTCP) Validate() error {
+	return streaming.ValidateFraming(c.Framing, c.LengthPrefix)
 }
 
 var defaultTCP = syslogTCP{
@@ -75,6 +81,7 @@ var defaultTCP = syslogTCP{
 		MaxMessageSize: 20 * humanize.MiByte,
 	},
 	LineDelimiter: "\n",
+	LengthPrefix:  streaming.DefaultLengthPrefixConfig(),
 }
 
 type syslogUnix struct {
@@ -87,6 +94,7 @@ func defaultUnix() syslogUnix {
 			Timeout:        time.Minute * 5,
 			MaxMessageSize: 20 * humanize.MiByte,
 			LineDelimiter:  "\n",
+			LengthPrefix:   streaming.DefaultLengthPrefixConfig(),
 		},
 	}
 }
@@ -109,7 +117,7 @@ func factory(
 			return nil, err
 		}
 
-		splitFunc, err := streaming.SplitFunc(config.Framing, []byte(config.LineDelimiter))
+		splitFunc, err := streaming.SplitFunc(config.Framing, []byte(config.LineDelimiter), config.LengthPrefix)
 		if err != nil {
 			return nil, err
 		}
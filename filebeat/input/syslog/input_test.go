@@ -26,6 +26,7 @@ import (
 
 	"github.com/elastic/beats/v7/filebeat/input/inputtest"
 	"github.com/elastic/beats/v7/filebeat/inputsource"
+	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/mapstr"
 )
@@ -253,6 +254,17 @@ func TestNewInputDone(t *testing.T) {
 	inputtest.AssertNotStartedInputCanBeDone(t, NewInput, &config)
 }
 
+func TestTCPInvalidLengthPrefixWidth(t *testing.T) {
+	c := conf.MustNewConfigFrom(mapstr.M{
+		"host":                "localhost:9000",
+		"framing":             "length_prefixed",
+		"length_prefix.width": 3,
+	})
+	config := defaultTCP
+	err := c.Unpack(&config)
+	assert.ErrorContains(t, err, "invalid length prefix width 3")
+}
+
 func dummyMetadata() inputsource.NetworkMetadata {
 	ip := "127.0.0.1"
 	parsedIP := net.ParseIP(ip)
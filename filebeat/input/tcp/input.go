@@ -63,6 +63,7 @@ func defaultConfig() config {
 			MaxMessageSize: 20 * humanize.MiByte,
 		},
 		LineDelimiter: "\n",
+		LengthPrefix:  streaming.DefaultLengthPrefixConfig(),
 	}
 }
 
@@ -74,8 +75,14 @@ type server struct {
 type config struct {
 	tcp.Config `config:",inline"`
 
-	LineDelimiter string                `config:"line_delimiter" validate:"nonzero"`
-	Framing       streaming.FramingType `config:"framing"`
+	LineDelimiter string                       `config:"line_delimiter" validate:"nonzero"`
+	Framing       streaming.FramingType        `config:"framing"`
+	LengthPrefix  streaming.LengthPrefixConfig `config:"length_prefix"`
+}
+
+// Validate validates the framing configuration of the tcp input.
+func (c *config) Validate() error {
+	return streaming.ValidateFraming(c.Framing, c.LengthPrefix)
 }
 
 func newServer(config config) (*server, error) {
@@ -102,7 +109,7 @@ func (s *server) Run(ctx input.Context, publisher stateless.Publisher) error {
 	metrics := netmetrics.NewTCP("tcp", ctx.ID, s.config.Host, pollInterval, log)
 	defer metrics.Close()
 
-	split, err := streaming.SplitFunc(s.config.Framing, []byte(s.config.LineDelimiter))
+	split, err := streaming.SplitFunc(s.config.Framing, []byte(s.config.LineDelimiter), s.config.LengthPrefix)
 	if err != nil {
 		return err
 	}
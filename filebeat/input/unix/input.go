@@ -27,6 +27,7 @@ import (
 	input "github.com/elastic/beats/v7/filebeat/input/v2"
 	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
 	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/streaming"
 	"github.com/elastic/beats/v7/filebeat/inputsource/unix"
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/feature"
@@ -69,6 +70,7 @@ func defaultConfig() config {
 			MaxMessageSize: 20 * humanize.MiByte,
 			SocketType:     unix.StreamSocket,
 			LineDelimiter:  "\n",
+			LengthPrefix:   streaming.DefaultLengthPrefixConfig(),
 		},
 	}
 }
@@ -18,6 +18,9 @@
 package streaming
 
 import (
+	"encoding/binary"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
@@ -29,3 +32,75 @@ type ListenerConfig struct {
 	MaxMessageSize cfgtype.ByteSize
 	MaxConnections int
 }
+
+// LengthPrefixConfig configures the length_prefixed framing.
+type LengthPrefixConfig struct {
+	// Width is the number of bytes of the length prefix, one of 1, 2, 4 or 8.
+	Width int `config:"width"`
+	// Endianness is the byte order of the length prefix.
+	Endianness Endianness `config:"endianness"`
+	// MaxSize is the maximum length of a frame, frames with a greater length
+	// close the connection. There is no limit if it is 0, other than the
+	// maximum message size.
+	MaxSize cfgtype.ByteSize `config:"max_size"`
+}
+
+// DefaultLengthPrefixConfig returns the default length_prefixed framing
+// configuration, a 4 bytes big endian length prefix.
+func DefaultLengthPrefixConfig() LengthPrefixConfig {
+	return LengthPrefixConfig{
+		Width:      4,
+		Endianness: BigEndian,
+	}
+}
+
+// ValidateFraming validates the configuration of the framing. The length
+// prefix configuration is only validated for the length_prefixed framing.
+func ValidateFraming(framing FramingType, lengthPrefix LengthPrefixConfig) error {
+	if framing == FramingLengthPrefixed {
+		return lengthPrefix.validate()
+	}
+	return nil
+}
+
+// validate validates the length_prefixed framing configuration. It is not
+// called when unpacking the configuration, as it is only used by one framing,
+// configurations embedding it call ValidateFraming instead.
+func (c *LengthPrefixConfig) validate() error {
+	switch c.Width {
+	case 1, 2, 4, 8:
+		return nil
+	default:
+		return fmt.Errorf("invalid length prefix width %d, must be 1, 2, 4 or 8", c.Width)
+	}
+}
+
+// Endianness is the byte order of a length prefix.
+type Endianness int
+
+const (
+	BigEndian Endianness = iota
+	LittleEndian
+)
+
+var endiannesses = map[string]Endianness{
+	"big":    BigEndian,
+	"little": LittleEndian,
+}
+
+// Unpack unpacks the Endianness string value.
+func (e *Endianness) Unpack(value string) error {
+	v, ok := endiannesses[strings.ToLower(value)]
+	if !ok {
+		return fmt.Errorf("invalid endianness %q, must be big or little", value)
+	}
+	*e = v
+	return nil
+}
+
+func (e Endianness) byteOrder() binary.ByteOrder {
+	if e == LittleEndian {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
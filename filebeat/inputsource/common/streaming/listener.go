@@ -55,12 +55,16 @@ type FramingType int
 const (
 	FramingDelimiter = iota
 	FramingRFC6587
+	FramingLengthPrefixed
+	FramingJSONStream
 )
 
 var (
 	framingTypes = map[string]FramingType{
-		"delimiter": FramingDelimiter,
-		"rfc6587":   FramingRFC6587,
+		"delimiter":       FramingDelimiter,
+		"rfc6587":         FramingRFC6587,
+		"length_prefixed": FramingLengthPrefixed,
+		"json_stream":     FramingJSONStream,
 	}
 
 	availableFramingTypesErrFormat string
@@ -213,8 +217,9 @@ func (l *Listener) Stop() {
 }
 
 // SplitFunc allows to create a `bufio.SplitFunc` based on a framing and
-// delimiter provided.
-func SplitFunc(framing FramingType, lineDelimiter []byte) (bufio.SplitFunc, error) {
+// delimiter provided. The length prefix configuration is only used by the
+// length_prefixed framing.
+func SplitFunc(framing FramingType, lineDelimiter []byte, lengthPrefix LengthPrefixConfig) (bufio.SplitFunc, error) {
 	if len(lineDelimiter) == 0 {
 		return nil, fmt.Errorf("line delimiter required")
 	}
@@ -230,6 +235,13 @@ func SplitFunc(framing FramingType, lineDelimiter []byte) (bufio.SplitFunc, erro
 		return FactoryDelimiter(lineDelimiter), nil
 	case FramingRFC6587:
 		return FactoryRFC6587Framing(lineDelimiter), nil
+	case FramingLengthPrefixed:
+		if err := lengthPrefix.validate(); err != nil {
+			return nil, err
+		}
+		return FactoryLengthPrefixedFraming(lengthPrefix), nil
+	case FramingJSONStream:
+		return ScanJSONObjects, nil
 	default:
 		return nil, fmt.Errorf("unknown SplitFunc for framing %d and line delimiter %q", framing, lineDelimiter)
 	}
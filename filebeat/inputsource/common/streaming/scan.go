@@ -20,6 +20,9 @@ package streaming
 import (
 	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
+	"math"
 	"strconv"
 )
 
@@ -87,3 +90,115 @@ func FactoryRFC6587Framing(delimiter []byte) bufio.SplitFunc {
 		return 0, nil, nil
 	}
 }
+
+var (
+	// ErrFrameTooLarge is returned when the length of a length prefixed
+	// frame is greater than the maximum size.
+	ErrFrameTooLarge = errors.New("frame too large")
+
+	// ErrIncompleteFrame is returned when the stream ends in the middle of
+	// a frame.
+	ErrIncompleteFrame = errors.New("incomplete frame at end of stream")
+)
+
+// FactoryLengthPrefixedFraming returns a function that splits frames
+// prefixed by their length, the length prefix is stripped from the returned
+// value.
+func FactoryLengthPrefixedFraming(config LengthPrefixConfig) bufio.SplitFunc {
+	width := config.Width
+	byteOrder := config.Endianness.byteOrder()
+	maxSize := uint64(config.MaxSize)
+
+	return func(data []byte, eof bool) (int, []byte, error) {
+		if eof && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		if len(data) < width {
+			if eof {
+				return 0, nil, ErrIncompleteFrame
+			}
+			// request more data
+			return 0, nil, nil
+		}
+
+		var length uint64
+		switch width {
+		case 1:
+			length = uint64(data[0])
+		case 2:
+			length = uint64(byteOrder.Uint16(data))
+		case 4:
+			length = uint64(byteOrder.Uint32(data))
+		default:
+			length = byteOrder.Uint64(data)
+		}
+
+		if (maxSize > 0 && length > maxSize) || length > uint64(math.MaxInt-width) {
+			return 0, nil, fmt.Errorf("%w: length %d is greater than the maximum size %d", ErrFrameTooLarge, length, maxSize)
+		}
+
+		end := width + int(length)
+		if len(data) < end {
+			if eof {
+				return 0, nil, ErrIncompleteFrame
+			}
+			// request more data
+			return 0, nil, nil
+		}
+		return end, data[width:end], nil
+	}
+}
+
+// ScanJSONObjects is a split function that splits a stream of JSON objects
+// that are not delimited, like `{"a":1}{"b":2}`, by balancing the braces of
+// the objects. Whitespace between objects is skipped.
+func ScanJSONObjects(data []byte, eof bool) (int, []byte, error) {
+	start := 0
+	for start < len(data) && isJSONSpace(data[start]) {
+		start++
+	}
+	if start == len(data) {
+		// skip the whitespace and request more data
+		return start, nil, nil
+	}
+	if data[start] != '{' {
+		return 0, nil, fmt.Errorf("invalid JSON stream, expected '{' and found %q", data[start])
+	}
+
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return i + 1, data[start : i+1], nil
+			}
+		}
+	}
+
+	if eof {
+		return 0, nil, ErrIncompleteFrame
+	}
+	// request more data
+	return start, nil, nil
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
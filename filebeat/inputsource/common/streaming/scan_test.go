@@ -21,6 +21,7 @@ import (
 	"bufio"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -178,3 +179,146 @@ func TestOctetCounting(t *testing.T) {
 		})
 	}
 }
+
+func TestLengthPrefixedFraming(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   LengthPrefixConfig
+		input    string
+		expected []string
+		err      error
+	}{
+		{
+			name:     "4 bytes big endian",
+			config:   DefaultLengthPrefixConfig(),
+			input:    "\x00\x00\x00\x05hello\x00\x00\x00\x00\x00\x00\x00\x07bonjour",
+			expected: []string{"hello", "", "bonjour"},
+		},
+		{
+			name:     "2 bytes little endian",
+			config:   LengthPrefixConfig{Width: 2, Endianness: LittleEndian},
+			input:    "\x05\x00hello\x07\x00bonjour",
+			expected: []string{"hello", "bonjour"},
+		},
+		{
+			name:     "1 byte",
+			config:   LengthPrefixConfig{Width: 1},
+			input:    "\x05hello\x04hola",
+			expected: []string{"hello", "hola"},
+		},
+		{
+			name:     "8 bytes big endian",
+			config:   LengthPrefixConfig{Width: 8},
+			input:    "\x00\x00\x00\x00\x00\x00\x00\x05hello",
+			expected: []string{"hello"},
+		},
+		{
+			name:     "binary payload",
+			config:   LengthPrefixConfig{Width: 1},
+			input:    "\x03\x00\n\xff",
+			expected: []string{"\x00\n\xff"},
+		},
+		{
+			name:     "frame too large",
+			config:   LengthPrefixConfig{Width: 1, MaxSize: 4},
+			input:    "\x04hola\x05hello",
+			expected: []string{"hola"},
+			err:      ErrFrameTooLarge,
+		},
+		{
+			name:     "incomplete frame",
+			config:   LengthPrefixConfig{Width: 1},
+			input:    "\x05hello\x05hel",
+			expected: []string{"hello"},
+			err:      ErrIncompleteFrame,
+		},
+		{
+			name:   "incomplete length",
+			config: DefaultLengthPrefixConfig(),
+			input:  "\x00\x00",
+			err:    ErrIncompleteFrame,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			split, err := SplitFunc(FramingLengthPrefixed, []byte("\n"), test.config)
+			assert.NoError(t, err)
+
+			// The reader returns one byte per read to check frames split
+			// across reads.
+			scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(test.input)))
+			scanner.Split(split)
+			var elements []string
+			for scanner.Scan() {
+				elements = append(elements, scanner.Text())
+			}
+			assert.EqualValues(t, test.expected, elements)
+			assert.ErrorIs(t, scanner.Err(), test.err)
+		})
+	}
+
+	t.Run("invalid width", func(t *testing.T) {
+		_, err := SplitFunc(FramingLengthPrefixed, []byte("\n"), LengthPrefixConfig{Width: 3})
+		assert.ErrorContains(t, err, "invalid length prefix width 3")
+	})
+}
+
+func TestJSONStreamFraming(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+		err      string
+	}{
+		{
+			name:     "concatenated objects",
+			input:    `{"a":1}{"b":2}{"c":3}`,
+			expected: []string{`{"a":1}`, `{"b":2}`, `{"c":3}`},
+		},
+		{
+			name:     "whitespace between objects",
+			input:    " {\"a\":1}\n\t{\"b\":2}\r\n ",
+			expected: []string{`{"a":1}`, `{"b":2}`},
+		},
+		{
+			name:     "nested objects and arrays",
+			input:    `{"a":{"b":[{"c":1},[2]]}}{"d":[]}`,
+			expected: []string{`{"a":{"b":[{"c":1},[2]]}}`, `{"d":[]}`},
+		},
+		{
+			name:     "braces in strings",
+			input:    `{"a":"}{"}{"b":"\"}"}{"c":"\\"}`,
+			expected: []string{`{"a":"}{"}`, `{"b":"\"}"}`, `{"c":"\\"}`},
+		},
+		{
+			name:     "incomplete object",
+			input:    `{"a":1}{"b":`,
+			expected: []string{`{"a":1}`},
+			err:      ErrIncompleteFrame.Error(),
+		},
+		{
+			name:  "not an object",
+			input: `[1,2]`,
+			err:   `invalid JSON stream, expected '{' and found '['`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			split, err := SplitFunc(FramingJSONStream, []byte("\n"), LengthPrefixConfig{})
+			assert.NoError(t, err)
+
+			scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(test.input)))
+			scanner.Split(split)
+			var elements []string
+			for scanner.Scan() {
+				elements = append(elements, scanner.Text())
+			}
+			assert.EqualValues(t, test.expected, elements)
+			if test.err == "" {
+				assert.NoError(t, scanner.Err())
+			} else {
+				assert.EqualError(t, scanner.Err(), test.err)
+			}
+		})
+	}
+}
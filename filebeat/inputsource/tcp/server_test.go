@@ -211,6 +211,28 @@ func testReceiveEventsAndMetadata(t *testing.T, network string) {
 			},
 			messageSent: "14 <9> message \n010 <6> msg \n114 <3> message \n2",
 		},
+		{
+			name:      "length prefixed framing",
+			cfg:       map[string]interface{}{},
+			framing:   streaming.FramingLengthPrefixed,
+			delimiter: []byte("\n"),
+			expectedMessages: []string{
+				"message 0",
+				"msg\n1",
+			},
+			messageSent: "\x00\x00\x00\x09message 0\x00\x00\x00\x05msg\n1",
+		},
+		{
+			name:      "json stream framing",
+			cfg:       map[string]interface{}{},
+			framing:   streaming.FramingJSONStream,
+			delimiter: []byte("\n"),
+			expectedMessages: []string{
+				`{"message":"0"}`,
+				`{"message":"}{"}`,
+			},
+			messageSent: `{"message":"0"} {"message":"}{"}`,
+		},
 	}
 
 	for _, test := range tests {
@@ -229,7 +251,7 @@ func testReceiveEventsAndMetadata(t *testing.T, network string) {
 			}
 			config.Network = network
 
-			splitFunc, err := streaming.SplitFunc(test.framing, test.delimiter)
+			splitFunc, err := streaming.SplitFunc(test.framing, test.delimiter, streaming.DefaultLengthPrefixConfig())
 			if !assert.NoError(t, err) {
 				return
 			}
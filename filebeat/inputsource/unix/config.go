@@ -46,15 +46,16 @@ var socketTypes = map[string]SocketType{
 
 // Config exposes the unix configuration.
 type Config struct {
-	Path           string                `config:"path"`
-	Group          *string               `config:"group"`
-	Mode           *string               `config:"mode"`
-	Timeout        time.Duration         `config:"timeout" validate:"nonzero,positive"`
-	MaxMessageSize cfgtype.ByteSize      `config:"max_message_size" validate:"nonzero,positive"`
-	MaxConnections int                   `config:"max_connections"`
-	LineDelimiter  string                `config:"line_delimiter"`
-	Framing        streaming.FramingType `config:"framing"`
-	SocketType     SocketType            `config:"socket_type"`
+	Path           string                       `config:"path"`
+	Group          *string                      `config:"group"`
+	Mode           *string                      `config:"mode"`
+	Timeout        time.Duration                `config:"timeout" validate:"nonzero,positive"`
+	MaxMessageSize cfgtype.ByteSize             `config:"max_message_size" validate:"nonzero,positive"`
+	MaxConnections int                          `config:"max_connections"`
+	LineDelimiter  string                       `config:"line_delimiter"`
+	Framing        streaming.FramingType        `config:"framing"`
+	LengthPrefix   streaming.LengthPrefixConfig `config:"length_prefix"`
+	SocketType     SocketType                   `config:"socket_type"`
 }
 
 // Validate validates the Config option for the unix input.
@@ -66,7 +67,7 @@ func (c *Config) Validate() error {
 	if c.SocketType == StreamSocket && c.LineDelimiter == "" {
 		return fmt.Errorf("line_delimiter cannot be empty when using stream socket")
 	}
-	return nil
+	return streaming.ValidateFraming(c.Framing, c.LengthPrefix)
 }
 
 func (s *SocketType) Unpack(value string) error {
@@ -63,3 +63,28 @@ func TestInvalidSocketType(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "unknown socket type")
 }
+
+func TestInvalidLengthPrefixWidth(t *testing.T) {
+	c := conf.MustNewConfigFrom(map[string]interface{}{
+		"timeout":             1,
+		"max_message_size":    1,
+		"path":                "my-path",
+		"line_delimiter":      "\n",
+		"framing":             "length_prefixed",
+		"length_prefix.width": 3,
+	})
+	var config Config
+	err := c.Unpack(&config)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid length prefix width 3")
+
+	// The length prefix is only used by the length_prefixed framing.
+	c = conf.MustNewConfigFrom(map[string]interface{}{
+		"timeout":             1,
+		"max_message_size":    1,
+		"path":                "my-path",
+		"line_delimiter":      "\n",
+		"length_prefix.width": 3,
+	})
+	assert.NoError(t, c.Unpack(&config))
+}
@@ -0,0 +1,267 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package registrar
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// Entry is a single key-value pair of the registry. It uses the same
+// field names as the memlog update log, so exported entries look like the
+// `set` operations found in log.json.
+type Entry struct {
+	Key   string   `json:"k"`
+	Value mapstr.M `json:"v"`
+}
+
+// Key holds the parts of a registry key written by inputs based on
+// input-logfile, which use the layout `<input type>::<input id>::<file id>`,
+// for example `filestream::my-input::native::1234-56`. The file ID can
+// contain `::` itself.
+type Key struct {
+	InputType string
+	InputID   string
+	FileID    string
+}
+
+// ParseKey splits a registry key into its parts. It returns false if the
+// key does not follow the input-logfile layout.
+func ParseKey(key string) (Key, bool) {
+	parts := strings.SplitN(key, "::", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return Key{}, false
+	}
+	return Key{InputType: parts[0], InputID: parts[1], FileID: parts[2]}, true
+}
+
+// Offset returns the read offset of the entry. Filestream stores it in the
+// cursor, the log input at the top level of the state.
+func (e Entry) Offset() (int64, bool) {
+	for _, field := range []string{"cursor.offset", "offset"} {
+		if v, err := e.Value.GetValue(field); err == nil {
+			if offset, ok := toInt64(v); ok {
+				return offset, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// TTL returns how long the entry is kept after its file was last seen.
+// A negative TTL means the entry is never removed automatically.
+func (e Entry) TTL() (time.Duration, bool) {
+	v, err := e.Value.GetValue("ttl")
+	if err != nil {
+		return 0, false
+	}
+	ttl, ok := toInt64(v)
+	return time.Duration(ttl), ok
+}
+
+// Source returns the path of the file the entry was written for.
+func (e Entry) Source() string {
+	for _, field := range []string{"meta.source", "source"} {
+		if v, err := e.Value.GetValue(field); err == nil {
+			if source, ok := v.(string); ok {
+				return source
+			}
+		}
+	}
+	return ""
+}
+
+// MatchInput returns a filter for entries written by the input with the
+// given ID. An empty ID matches all entries.
+func MatchInput(inputID string) func(key string) bool {
+	return func(key string) bool {
+		if inputID == "" {
+			return true
+		}
+		k, ok := ParseKey(key)
+		return ok && k.InputID == inputID
+	}
+}
+
+// ReadEntries returns all entries of the store accepted by match, sorted
+// by key.
+func ReadEntries(store *statestore.Store, match func(key string) bool) ([]Entry, error) {
+	var entries []Entry
+	err := store.Each(func(key string, dec statestore.ValueDecoder) (bool, error) {
+		if match != nil && !match(key) {
+			return true, nil
+		}
+		var value mapstr.M
+		if err := dec.Decode(&value); err != nil {
+			return false, fmt.Errorf("failed to decode registry entry '%v': %w", key, err)
+		}
+		entries = append(entries, Entry{Key: key, Value: value})
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// ReadEntry returns the entry stored under key.
+func ReadEntry(store *statestore.Store, key string) (Entry, error) {
+	has, err := store.Has(key)
+	if err != nil {
+		return Entry{}, err
+	}
+	if !has {
+		return Entry{}, fmt.Errorf("registry entry '%v' not found", key)
+	}
+	var value mapstr.M
+	if err := store.Get(key, &value); err != nil {
+		return Entry{}, err
+	}
+	return Entry{Key: key, Value: value}, nil
+}
+
+// RemoveEntries removes the given keys from the store. All keys are checked
+// before any entry is removed, so nothing is changed if a key is unknown.
+func RemoveEntries(store *statestore.Store, keys []string) error {
+	for _, key := range keys {
+		has, err := store.Has(key)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return fmt.Errorf("registry entry '%v' not found", key)
+		}
+	}
+	for _, key := range keys {
+		if err := store.Remove(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResetOffset sets the read offset of an entry, which makes the input
+// continue reading the file from that offset on its next start.
+func ResetOffset(store *statestore.Store, key string, offset int64) error {
+	entry, err := ReadEntry(store, key)
+	if err != nil {
+		return err
+	}
+
+	field := "offset"
+	if _, err := entry.Value.GetValue("cursor"); err == nil {
+		field = "cursor.offset"
+	} else if _, ok := entry.Offset(); !ok {
+		return fmt.Errorf("registry entry '%v' has no offset", key)
+	}
+	if _, err := entry.Value.Put(field, offset); err != nil {
+		return err
+	}
+	return store.Set(key, entry.Value)
+}
+
+// ExportEntries writes the entries accepted by match as newline delimited
+// JSON and returns the number of entries written.
+func ExportEntries(store *statestore.Store, w io.Writer, match func(key string) bool) (int, error) {
+	entries, err := ReadEntries(store, match)
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// ImportEntries reads newline delimited JSON entries as written by
+// ExportEntries and stores them. If replace is set, all existing entries
+// are removed first. All entries are validated before the store is
+// changed. Returns the number of imported entries.
+func ImportEntries(store *statestore.Store, r io.Reader, replace bool) (int, error) {
+	var entries []Entry
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var entry Entry
+		err := dec.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read entry %d: %w", len(entries)+1, err)
+		}
+		if entry.Key == "" || entry.Value == nil {
+			return 0, fmt.Errorf("entry %d must have a key and a value", len(entries)+1)
+		}
+		entries = append(entries, entry)
+	}
+
+	if replace {
+		existing, err := ReadEntries(store, nil)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range existing {
+			if err := store.Remove(entry.Key); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		if err := store.Set(entry.Key, entry.Value); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,162 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package registrar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	testKeyA1 = "filestream::input-a::native::1-2"
+	testKeyA2 = "filestream::input-a::fingerprint::abcd"
+	testKeyB  = "filestream::input-b::native::3-4"
+	testKeyL  = "filebeat::logs::native::5-6"
+)
+
+func TestParseKey(t *testing.T) {
+	key, ok := ParseKey(testKeyA1)
+	require.True(t, ok)
+	assert.Equal(t, Key{InputType: "filestream", InputID: "input-a", FileID: "native::1-2"}, key)
+
+	key, ok = ParseKey("filestream::::native::1-2")
+	require.True(t, ok)
+	assert.Equal(t, "", key.InputID)
+
+	_, ok = ParseKey("some-key")
+	assert.False(t, ok)
+}
+
+func TestReadEntries(t *testing.T) {
+	store := openTestStore(t)
+
+	entries, err := ReadEntries(store, nil)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, testKeyL, entries[0].Key, "entries must be sorted by key")
+
+	entries, err = ReadEntries(store, MatchInput("input-a"))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, testKeyA2, entries[0].Key)
+	assert.Equal(t, testKeyA1, entries[1].Key)
+
+	offset, ok := entries[1].Offset()
+	require.True(t, ok)
+	assert.EqualValues(t, 100, offset)
+	assert.Equal(t, "/var/log/a1.log", entries[1].Source())
+	ttl, ok := entries[1].TTL()
+	require.True(t, ok)
+	assert.Equal(t, 30*time.Minute, ttl)
+}
+
+func TestResetOffset(t *testing.T) {
+	store := openTestStore(t)
+
+	require.NoError(t, ResetOffset(store, testKeyA1, 10))
+	require.NoError(t, ResetOffset(store, testKeyL, 0))
+
+	entry, err := ReadEntry(store, testKeyA1)
+	require.NoError(t, err)
+	offset, _ := entry.Offset()
+	assert.EqualValues(t, 10, offset)
+	source, _ := entry.Value.GetValue("meta.source")
+	assert.Equal(t, "/var/log/a1.log", source, "other fields must be kept")
+
+	entry, err = ReadEntry(store, testKeyL)
+	require.NoError(t, err)
+	offset, _ = entry.Offset()
+	assert.EqualValues(t, 0, offset)
+
+	assert.Error(t, ResetOffset(store, "filestream::input-a::native::unknown", 0))
+}
+
+func TestRemoveEntries(t *testing.T) {
+	store := openTestStore(t)
+
+	err := RemoveEntries(store, []string{testKeyA1, "unknown"})
+	require.Error(t, err)
+	has, err := store.Has(testKeyA1)
+	require.NoError(t, err)
+	assert.True(t, has, "no entry must be removed if a key is unknown")
+
+	require.NoError(t, RemoveEntries(store, []string{testKeyA1, testKeyB}))
+	entries, err := ReadEntries(store, nil)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestExportImport(t *testing.T) {
+	store := openTestStore(t)
+
+	var buf bytes.Buffer
+	n, err := ExportEntries(store, &buf, MatchInput("input-a"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+	assert.Contains(t, buf.String(), `"k":"`+testKeyA1+`"`)
+
+	target := openTestStore(t)
+	require.NoError(t, RemoveEntries(target, []string{testKeyA1}))
+	n, err = ImportEntries(target, bytes.NewReader(buf.Bytes()), true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	entries, err := ReadEntries(target, nil)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "replace must remove entries that are not imported")
+	offset, _ := entries[1].Offset()
+	assert.EqualValues(t, 100, offset)
+
+	_, err = ImportEntries(target, strings.NewReader(`{"k":"key"}`), false)
+	assert.Error(t, err)
+}
+
+func openTestStore(t *testing.T) *statestore.Store {
+	t.Helper()
+
+	reg, err := memlog.New(logptest.NewTestingLogger(t, ""), memlog.Settings{Root: t.TempDir()})
+	require.NoError(t, err)
+	registry := statestore.NewRegistry(reg)
+	t.Cleanup(func() { registry.Close() })
+
+	store, err := registry.Get("filebeat")
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	states := map[string]mapstr.M{
+		testKeyA1: {"ttl": 30 * time.Minute, "cursor": mapstr.M{"offset": 100}, "meta": mapstr.M{"source": "/var/log/a1.log"}},
+		testKeyA2: {"ttl": -1, "cursor": mapstr.M{"offset": 200}, "meta": mapstr.M{"source": "/var/log/a2.log"}},
+		testKeyB:  {"ttl": -1, "cursor": mapstr.M{"offset": 300}, "meta": mapstr.M{"source": "/var/log/b.log"}},
+		testKeyL:  {"ttl": -1, "offset": 400, "source": "/var/log/l.log"},
+	}
+	for k, v := range states {
+		require.NoError(t, store.Set(k, v))
+	}
+	return store
+}
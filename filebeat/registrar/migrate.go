@@ -25,9 +25,12 @@ import (
 
 	"github.com/elastic/beats/v7/filebeat/config"
 	"github.com/elastic/beats/v7/filebeat/input/file"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/boltdb"
 	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
 	helper "github.com/elastic/elastic-agent-libs/file"
 	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
 	"github.com/elastic/elastic-agent-libs/paths"
 )
 
@@ -46,6 +49,7 @@ type Migrator struct {
 	dataPath    string
 	migrateFile string
 	permissions os.FileMode
+	backend     string
 	logger      *logp.Logger
 }
 
@@ -60,6 +64,7 @@ func NewMigrator(cfg config.Registry, logger *logp.Logger) *Migrator {
 		dataPath:    path,
 		migrateFile: migrateFile,
 		permissions: cfg.Permissions,
+		backend:     cfg.Backend,
 		logger:      logger,
 	}
 }
@@ -91,10 +96,13 @@ func (m *Migrator) Run() error {
 			}
 			fallthrough
 		case version0:
-			return m.updateToVersion1(fbRegHome)
+			if err := m.updateToVersion1(fbRegHome); err != nil {
+				return err
+			}
+			return m.updateBackend(fbRegHome)
 
 		case currentVersion:
-			return nil
+			return m.updateBackend(fbRegHome)
 
 		case noRegistry:
 			// check if we've been in the middle of a migration from the legacy
@@ -248,6 +256,91 @@ func (m *Migrator) updateToVersion1(regHome string) error {
 	return nil
 }
 
+// updateBackend moves the state of a memlog based registry into the
+// configured backend if memlog is not used anymore. The migration copies
+// all entries into the new store in a single transaction and then renames
+// the memlog directory, so the migration is not repeated on the next start.
+func (m *Migrator) updateBackend(regHome string) error {
+	if m.backend != config.RegistryBackendBbolt {
+		return nil
+	}
+
+	m.logger.Infof("Migrate memlog registry to the %v backend", m.backend)
+
+	states, err := readMemlogStates(m.dataPath, m.logger)
+	if err != nil {
+		return err
+	}
+
+	registryBackend, err := boltdb.New(m.logger.Named("migration"), boltdb.Settings{
+		Root:     m.dataPath,
+		FileMode: m.permissions,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create new registry backend: %w", err)
+	}
+	defer registryBackend.Close()
+
+	store, err := registryBackend.Access("filebeat")
+	if err != nil {
+		return fmt.Errorf("failed to open filebeat registry store: %w", err)
+	}
+	defer store.Close()
+
+	importer, ok := store.(interface {
+		SetAll(map[string]interface{}) error
+	})
+	if !ok {
+		return fmt.Errorf("registry backend %v does not support importing states", m.backend)
+	}
+	if err := importer.SetAll(states); err != nil {
+		return fmt.Errorf("failed to migrate registry states: %w", err)
+	}
+
+	backupDir := regHome + ".memlog.bak"
+	if err := os.RemoveAll(backupDir); err != nil {
+		return fmt.Errorf("failed to remove old memlog registry backup %v: %w", backupDir, err)
+	}
+	if err := os.Rename(regHome, backupDir); err != nil {
+		return fmt.Errorf("migration complete but failed to move memlog registry to %v: %w", backupDir, err)
+	}
+
+	m.logger.Infof("Migrated %v registry entries, the memlog registry has been moved to %v", len(states), backupDir)
+	return nil
+}
+
+// readMemlogStates loads all entries of the memlog based filebeat store.
+func readMemlogStates(dataPath string, logger *logp.Logger) (map[string]interface{}, error) {
+	registryBackend, err := memlog.New(logger.Named("migration"), memlog.Settings{
+		Root:       dataPath,
+		Checkpoint: func(sz uint64) bool { return false },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open memlog registry: %w", err)
+	}
+	defer registryBackend.Close()
+
+	store, err := registryBackend.Access("filebeat")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open memlog filebeat store: %w", err)
+	}
+	defer store.Close()
+
+	states := map[string]interface{}{}
+	err = store.Each(func(key string, dec backend.ValueDecoder) (bool, error) {
+		var state mapstr.M
+		if err := dec.Decode(&state); err != nil {
+			return false, fmt.Errorf("failed to read registry entry %v: %w", key, err)
+		}
+		states[key] = state
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
 func readVersion(regHome, migrateFile string) (registryVersion, error) {
 	if isFile(migrateFile) {
 		return legacyVersion, nil
@@ -0,0 +1,94 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package registrar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/filebeat/config"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/boltdb"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestMigrateMemlogToBbolt(t *testing.T) {
+	dataPath := t.TempDir()
+	logger := logptest.NewTestingLogger(t, "")
+
+	memlogRegistry, err := memlog.New(logger, memlog.Settings{Root: dataPath})
+	require.NoError(t, err)
+	memlogStore, err := memlogRegistry.Access("filebeat")
+	require.NoError(t, err)
+	states := map[string]mapstr.M{
+		"filestream::my-id::native::1-2": {"cursor": mapstr.M{"offset": 100}, "ttl": -1},
+		"filestream::my-id::native::3-4": {"cursor": mapstr.M{"offset": 200}, "ttl": -1},
+	}
+	for k, v := range states {
+		require.NoError(t, memlogStore.Set(k, v))
+	}
+	require.NoError(t, memlogStore.Close())
+	require.NoError(t, memlogRegistry.Close())
+
+	cfg := config.DefaultConfig.Registry
+	cfg.Path = dataPath
+	cfg.Backend = config.RegistryBackendBbolt
+	require.NoError(t, NewMigrator(cfg, logger).Run())
+
+	assert.NoDirExists(t, filepath.Join(dataPath, "filebeat"))
+	assert.DirExists(t, filepath.Join(dataPath, "filebeat.memlog.bak"))
+
+	boltRegistry, err := boltdb.New(logger, boltdb.Settings{Root: dataPath})
+	require.NoError(t, err)
+	defer boltRegistry.Close()
+	store, err := boltRegistry.Access("filebeat")
+	require.NoError(t, err)
+	defer store.Close()
+
+	for k, want := range states {
+		var got struct {
+			Cursor struct {
+				Offset int `struct:"offset"`
+			} `struct:"cursor"`
+		}
+		require.NoError(t, store.Get(k, &got))
+		offset, _ := want.GetValue("cursor.offset")
+		assert.Equal(t, offset, got.Cursor.Offset)
+	}
+}
+
+func TestMigrateKeepsMemlogBackend(t *testing.T) {
+	dataPath := t.TempDir()
+	logger := logptest.NewTestingLogger(t, "")
+
+	regHome := filepath.Join(dataPath, "filebeat")
+	require.NoError(t, os.MkdirAll(regHome, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(regHome, "meta.json"), []byte(`{"version": "1"}`), 0o600))
+
+	cfg := config.DefaultConfig.Registry
+	cfg.Path = dataPath
+	require.NoError(t, NewMigrator(cfg, logger).Run())
+
+	assert.DirExists(t, regHome)
+	assert.NoFileExists(t, filepath.Join(dataPath, "filebeat.db"))
+}
@@ -0,0 +1,284 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/common/jsontransform"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func GenTestProcessorsCmd(settings instance.Settings) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "processors",
+		Short: "Test the configured processors with events read from a file",
+		Long: "Runs the events of an NDJSON file through the processors configured in the processors section " +
+			"and prints the resulting events, or the difference with the expected events.",
+		Run: func(cmd *cobra.Command, args []string) {
+			eventsPath, _ := cmd.Flags().GetString("events")
+			expectedPath, _ := cmd.Flags().GetString("expected")
+
+			b, err := instance.NewInitializedBeat(settings)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing beat: %s\n", err)
+				os.Exit(1)
+			}
+
+			var config struct {
+				Processors processors.PluginConfig `config:"processors"`
+			}
+			if err := b.RawConfig.Unpack(&config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading processors configuration: %s\n", err)
+				os.Exit(1)
+			}
+			procs, err := processors.New(config.Processors)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing processors: %s\n", err)
+				os.Exit(1)
+			}
+
+			events, err := readEventsFile(eventsPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading events: %s\n", err)
+				os.Exit(1)
+			}
+
+			results, errs := runProcessors(procs, events)
+			for _, err := range errs {
+				fmt.Fprintln(os.Stderr, err)
+			}
+
+			if expectedPath == "" {
+				if err := writeEvents(os.Stdout, results); err != nil {
+					fmt.Fprintf(os.Stderr, "Error writing events: %s\n", err)
+					os.Exit(1)
+				}
+				if len(errs) > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			expected, err := readEventsFile(expectedPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading expected events: %s\n", err)
+				os.Exit(1)
+			}
+			diff, err := diffEvents(expected, results)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error comparing events: %s\n", err)
+				os.Exit(1)
+			}
+			if diff != "" {
+				fmt.Print(diff) //nolint:forbidigo // the diff is the output of the command
+			}
+			if diff != "" || len(errs) > 0 {
+				os.Exit(1)
+			}
+			fmt.Println("Processors OK") //nolint:forbidigo // same as the output of the other test commands
+		},
+	}
+
+	cmd.Flags().String("events", "", "NDJSON file with the input events, or - to read from stdin")
+	cmd.Flags().String("expected", "", "NDJSON file with the expected events. If set, the difference with the resulting events is printed")
+	_ = cmd.MarkFlagRequired("events")
+
+	return cmd
+}
+
+func readEventsFile(path string) ([]*beat.Event, error) {
+	if path == "-" {
+		return readEvents(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readEvents(f)
+}
+
+// readEvents reads one event per line. The @timestamp and @metadata keys are
+// read into the timestamp and metadata of the event. Empty lines are skipped.
+func readEvents(r io.Reader) ([]*beat.Event, error) {
+	var events []*beat.Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 10*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		var fields mapstr.M
+		if err := dec.Decode(&fields); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		jsontransform.TransformNumbers(fields)
+
+		event := &beat.Event{Fields: fields}
+		if v, found := fields[beat.TimestampFieldKey]; found {
+			s, _ := v.(string)
+			ts, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %v", n, beat.TimestampFieldKey, v)
+			}
+			event.Timestamp = ts
+			delete(fields, beat.TimestampFieldKey)
+		}
+		if v, found := fields[beat.MetadataFieldKey]; found {
+			meta, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("line %d: %s must be an object", n, beat.MetadataFieldKey)
+			}
+			event.Meta = meta
+			delete(fields, beat.MetadataFieldKey)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// runProcessors runs the events through the processors. Like the pipeline,
+// processing continues after errors. Events emitted by processors are
+// included in the results, including those emitted when the processors are
+// closed.
+func runProcessors(procs *processors.Processors, events []*beat.Event) ([]*beat.Event, []error) {
+	var (
+		mu      sync.Mutex
+		results []*beat.Event
+		errs    []error
+	)
+	procs.SetEmitter(func(event *beat.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, event)
+	})
+
+	for i, event := range events {
+		for _, p := range procs.List {
+			var err error
+			event, err = p.Run(event)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("event %d: failed applying processor %v: %w", i+1, p, err))
+			}
+			if event == nil {
+				break
+			}
+		}
+		if event != nil {
+			mu.Lock()
+			results = append(results, event)
+			mu.Unlock()
+		}
+	}
+
+	if err := procs.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed closing processors: %w", err))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return results, errs
+}
+
+// encodeEvent returns the event as a single line of JSON with sorted keys,
+// so that equal events have the same encoding.
+func encodeEvent(event *beat.Event) (string, error) {
+	m := event.Fields.Clone()
+	if m == nil {
+		m = mapstr.M{}
+	}
+	if !event.Timestamp.IsZero() {
+		m[beat.TimestampFieldKey] = event.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	if len(event.Meta) > 0 {
+		m[beat.MetadataFieldKey] = event.Meta
+	}
+
+	// Values are encoded and decoded once to normalize their types.
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", err
+	}
+	data, err = json.Marshal(v)
+	return string(data), err
+}
+
+func writeEvents(w io.Writer, events []*beat.Event) error {
+	for _, event := range events {
+		line, err := encodeEvent(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffEvents returns a unified diff of the expected and actual events, one
+// event per line. It is empty if the events are equal.
+func diffEvents(expected, actual []*beat.Event) (string, error) {
+	lines := func(events []*beat.Event) ([]string, error) {
+		var sb strings.Builder
+		if err := writeEvents(&sb, events); err != nil {
+			return nil, err
+		}
+		lines := strings.SplitAfter(sb.String(), "\n")
+		return lines[:len(lines)-1], nil
+	}
+
+	a, err := lines(expected)
+	if err != nil {
+		return "", err
+	}
+	b, err := lines(actual)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        a,
+		B:        b,
+		FromFile: "expected",
+		ToFile:   "actual",
+		Context:  1,
+	})
+}
@@ -0,0 +1,115 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/processors"
+	_ "github.com/elastic/beats/v7/libbeat/processors/actions"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestReadEvents(t *testing.T) {
+	input := `{"@timestamp": "2024-05-06T07:08:09.5Z", "@metadata": {"pipeline": "p"}, "message": "a", "n": 1, "f": 1.5}
+
+{"message": "b"}
+`
+	events, err := readEvents(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	assert.Equal(t, time.Date(2024, 5, 6, 7, 8, 9, 5e8, time.UTC), events[0].Timestamp)
+	assert.Equal(t, mapstr.M{"pipeline": "p"}, events[0].Meta)
+	assert.Equal(t, mapstr.M{"message": "a", "n": int64(1), "f": 1.5}, events[0].Fields)
+	assert.True(t, events[1].Timestamp.IsZero())
+	assert.Equal(t, mapstr.M{"message": "b"}, events[1].Fields)
+
+	for name, input := range map[string]string{
+		"invalid json":      `{"message": `,
+		"invalid timestamp": `{"@timestamp": "yesterday"}`,
+		"invalid metadata":  `{"@metadata": "x"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := readEvents(strings.NewReader(input))
+			assert.ErrorContains(t, err, "line 1")
+		})
+	}
+}
+
+func TestRunProcessors(t *testing.T) {
+	var config processors.PluginConfig
+	require.NoError(t, conf.MustNewConfigFrom(`
+- drop_event.when.equals.message: drop
+- rename.fields: [{from: missing, to: other}]
+- add_fields:
+    target: ""
+    fields: {added: true}
+`).Unpack(&config))
+	procs, err := processors.New(config)
+	require.NoError(t, err)
+
+	events, err := readEvents(strings.NewReader(`{"@timestamp": "2024-05-06T07:08:09Z", "message": "keep"}
+{"message": "drop"}
+`))
+	require.NoError(t, err)
+
+	results, errs := runProcessors(procs, events)
+	assert.Len(t, errs, 1)
+
+	// Processing continues after the rename error.
+	var sb strings.Builder
+	require.NoError(t, writeEvents(&sb, results))
+	assert.Equal(t, `{"@timestamp":"2024-05-06T07:08:09Z","added":true,`+
+		`"error":{"message":"Failed to rename fields in processor: could not fetch value for key: missing, Error: key not found"},`+
+		`"message":"keep"}`+"\n", sb.String())
+}
+
+func TestDiffEvents(t *testing.T) {
+	expected, err := readEvents(strings.NewReader(`{"message": "a", "n": 1}
+{"message": "b"}
+`))
+	require.NoError(t, err)
+
+	t.Run("equal", func(t *testing.T) {
+		actual, err := readEvents(strings.NewReader(`{"n": 1.0, "message": "a"}
+{"message": "b"}
+`))
+		require.NoError(t, err)
+		diff, err := diffEvents(expected, actual)
+		require.NoError(t, err)
+		assert.Empty(t, diff)
+	})
+
+	t.Run("different", func(t *testing.T) {
+		actual, err := readEvents(strings.NewReader(`{"message": "a", "n": 2}
+{"message": "b"}
+`))
+		require.NoError(t, err)
+		diff, err := diffEvents(expected, actual)
+		require.NoError(t, err)
+		assert.Contains(t, diff, `-{"message":"a","n":1}`)
+		assert.Contains(t, diff, `+{"message":"a","n":2}`)
+	})
+}
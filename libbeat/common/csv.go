@@ -20,6 +20,8 @@ package common
 import (
 	"bytes"
 	"encoding/csv"
+	"errors"
+	"io"
 	"strings"
 )
 
@@ -49,3 +51,26 @@ func DumpInCSVFormat(fields []string, rows [][]string) string {
 	csv := buf.String()
 	return csv
 }
+
+// ReadCSVWithHeader reads CSV data whose first record is a header. It returns
+// the header and the remaining records. All records must have the same number
+// of fields as the header. A leading UTF-8 byte order mark is ignored.
+func ReadCSVWithHeader(r io.Reader, separator rune) (fields []string, rows [][]string, err error) {
+	reader := csv.NewReader(r)
+	reader.Comma = separator
+
+	fields, err = reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("missing CSV header")
+		}
+		return nil, nil, err
+	}
+	fields[0] = strings.TrimPrefix(fields[0], "\ufeff")
+
+	rows, err = reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	return fields, rows, nil
+}
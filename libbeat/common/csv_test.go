@@ -20,6 +20,7 @@
 package common
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -63,3 +64,53 @@ func Test_CSVDump(t *testing.T) {
 		assert.Equal(t, test.Output, DumpInCSVFormat(test.Fields, test.Rows))
 	}
 }
+
+func Test_ReadCSVWithHeader(t *testing.T) {
+	tests := []struct {
+		Input     string
+		Separator rune
+		Fields    []string
+		Rows      [][]string
+		Err       string
+	}{
+		{
+			Input:     "f1,f2\n11,12\n21,22\n",
+			Separator: ',',
+			Fields:    []string{"f1", "f2"},
+			Rows:      [][]string{{"11", "12"}, {"21", "22"}},
+		},
+		{
+			Input:     "\ufefff1;f2\n\"1;1\";\"1\n2\"\n",
+			Separator: ';',
+			Fields:    []string{"f1", "f2"},
+			Rows:      [][]string{{"1;1", "1\n2"}},
+		},
+		{
+			Input:     "f1,f2\n",
+			Separator: ',',
+			Fields:    []string{"f1", "f2"},
+		},
+		{
+			Input:     "",
+			Separator: ',',
+			Err:       "missing CSV header",
+		},
+		{
+			Input:     "f1,f2\n11\n",
+			Separator: ',',
+			Err:       "wrong number of fields",
+		},
+	}
+
+	for _, test := range tests {
+		fields, rows, err := ReadCSVWithHeader(strings.NewReader(test.Input), test.Separator)
+		if test.Err != "" {
+			assert.ErrorContains(t, err, test.Err)
+			continue
+		}
+		if assert.NoError(t, err) {
+			assert.Equal(t, test.Fields, fields)
+			assert.Equal(t, test.Rows, rows)
+		}
+	}
+}
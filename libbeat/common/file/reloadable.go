@@ -0,0 +1,82 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package file
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloadable is the loaded content of a file that can be reloaded when the
+// file changes. Get is safe to call while the file is being reloaded.
+type Reloadable[T any] struct {
+	path  string
+	load  func(path string) (T, error)
+	value atomic.Pointer[T]
+
+	// mu serializes reloads. modTime and size identify the loaded version
+	// of the file.
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// NewReloadable loads the file at path with load.
+func NewReloadable[T any](path string, load func(path string) (T, error)) (*Reloadable[T], error) {
+	r := &Reloadable[T]{path: path, load: load}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Path returns the path of the file.
+func (r *Reloadable[T]) Path() string {
+	return r.path
+}
+
+// Get returns the currently loaded content of the file.
+func (r *Reloadable[T]) Get() T {
+	return *r.value.Load()
+}
+
+// Reload loads the file if it changed since it was last loaded. It reports
+// whether a new version was loaded. The previous version is kept on errors.
+func (r *Reloadable[T]) Reload() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false, err
+	}
+	if r.value.Load() != nil && info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return false, nil
+	}
+
+	v, err := r.load(r.path)
+	if err != nil {
+		return false, err
+	}
+
+	r.value.Store(&v)
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return true, nil
+}
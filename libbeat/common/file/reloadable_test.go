@@ -0,0 +1,70 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.txt")
+	require.NoError(t, os.WriteFile(path, []byte("first"), 0o600))
+
+	loads := 0
+	r, err := NewReloadable(path, func(path string) (string, error) {
+		loads++
+		data, err := os.ReadFile(path)
+		if string(data) == "invalid" {
+			return "", errors.New("invalid content")
+		}
+		return string(data), err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, path, r.Path())
+	assert.Equal(t, "first", r.Get())
+
+	reloaded, err := r.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "unchanged files must not be loaded again")
+	assert.Equal(t, 1, loads)
+
+	require.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+	reloaded, err = r.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "second", r.Get())
+
+	require.NoError(t, os.WriteFile(path, []byte("invalid"), 0o600))
+	_, err = r.Reload()
+	assert.ErrorContains(t, err, "invalid content")
+	assert.Equal(t, "second", r.Get(), "the previous version must be kept on errors")
+
+	require.NoError(t, os.Remove(path))
+	_, err = r.Reload()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Equal(t, "second", r.Get())
+
+	_, err = NewReloadable(path, func(string) (string, error) { return "", nil })
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"math/bits"
+	"net"
+)
+
+// cidrTree is a set of networks stored in a path compressed binary radix
+// tree. IPv4 networks are stored as IPv4-mapped IPv6 networks.
+type cidrTree struct {
+	root *cidrNode
+	size int
+}
+
+type cidrNode struct {
+	prefix   [net.IPv6len]byte // Masked to bits.
+	bits     int
+	terminal bool // Whether a network of the set ends at this node.
+	children [2]*cidrNode
+}
+
+// insert adds a network to the set.
+func (t *cidrTree) insert(network *net.IPNet) {
+	prefix, length := normalizeNetwork(network)
+	t.size++
+
+	n := &t.root
+	for {
+		cur := *n
+		if cur == nil {
+			*n = &cidrNode{prefix: prefix, bits: length, terminal: true}
+			return
+		}
+
+		common := commonPrefixLen(&cur.prefix, &prefix, min(cur.bits, length))
+		if common == cur.bits {
+			if length == cur.bits {
+				cur.terminal = true
+				return
+			}
+			n = &cur.children[bitAt(&prefix, cur.bits)]
+			continue
+		}
+
+		// The new network diverges from the node within its prefix, so the
+		// node is split at the common prefix.
+		split := &cidrNode{prefix: maskPrefix(prefix, common), bits: common}
+		split.children[bitAt(&cur.prefix, common)] = cur
+		if common == length {
+			split.terminal = true
+		} else {
+			split.children[bitAt(&prefix, common)] = &cidrNode{prefix: prefix, bits: length, terminal: true}
+		}
+		*n = split
+		return
+	}
+}
+
+// contains reports whether ip is in any network of the set.
+func (t *cidrTree) contains(ip net.IP) bool {
+	ip = ip.To16()
+	if ip == nil {
+		return false
+	}
+	var addr [net.IPv6len]byte
+	copy(addr[:], ip)
+
+	for n := t.root; n != nil; {
+		if commonPrefixLen(&n.prefix, &addr, n.bits) < n.bits {
+			return false
+		}
+		if n.terminal {
+			return true
+		}
+		if n.bits == 8*net.IPv6len {
+			return false
+		}
+		n = n.children[bitAt(&addr, n.bits)]
+	}
+	return false
+}
+
+// normalizeNetwork returns the 16 bytes prefix and the prefix length of a
+// network, mapping IPv4 networks into IPv6.
+func normalizeNetwork(network *net.IPNet) ([net.IPv6len]byte, int) {
+	var prefix [net.IPv6len]byte
+	ones, size := network.Mask.Size()
+	if size == 8*net.IPv4len {
+		ones += 8 * (net.IPv6len - net.IPv4len)
+	}
+	copy(prefix[:], network.IP.To16())
+	return maskPrefix(prefix, ones), ones
+}
+
+// maskPrefix clears the bits of prefix after the first n.
+func maskPrefix(prefix [net.IPv6len]byte, n int) [net.IPv6len]byte {
+	for i := range prefix {
+		switch {
+		case n >= 8*(i+1):
+		case n <= 8*i:
+			prefix[i] = 0
+		default:
+			prefix[i] &= ^byte(0xff >> (n - 8*i))
+		}
+	}
+	return prefix
+}
+
+// commonPrefixLen returns the number of leading bits a and b have in common,
+// up to limit.
+func commonPrefixLen(a, b *[net.IPv6len]byte, limit int) int {
+	n := 0
+	for i := 0; i < net.IPv6len && n < limit; i++ {
+		if x := a[i] ^ b[i]; x != 0 {
+			n += bits.LeadingZeros8(x)
+			break
+		}
+		n += 8
+	}
+	return min(n, limit)
+}
+
+// bitAt returns the bit of prefix at position i, counting from the most
+// significant bit.
+func bitAt(prefix *[net.IPv6len]byte, i int) int {
+	return int(prefix[i/8]>>(7-i%8)) & 1
+}
@@ -0,0 +1,120 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIDRTree(t *testing.T) {
+	tree := &cidrTree{}
+	for _, cidr := range []string{
+		"10.0.0.0/8",
+		"192.168.1.0/24",
+		"192.168.1.128/25", // Covered by the previous network.
+		"192.168.2.1/32",
+		"172.16.0.0/12",
+		"2001:db8::/32",
+		"2001:db8:1::/48",
+		"fe80::1/128",
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		tree.insert(network)
+	}
+	assert.Equal(t, 8, tree.size)
+
+	testCases := []struct {
+		ip       string
+		expected bool
+	}{
+		{"10.0.0.0", true},
+		{"10.255.255.255", true},
+		{"11.0.0.0", false},
+		{"9.255.255.255", false},
+		{"192.168.1.1", true},
+		{"192.168.1.200", true},
+		{"192.168.0.255", false},
+		{"192.168.2.1", true},
+		{"192.168.2.2", false},
+		{"172.31.255.255", true},
+		{"172.32.0.0", false},
+		{"2001:db8::1", true},
+		{"2001:db8:1::1", true},
+		{"2001:db9::1", false},
+		{"fe80::1", true},
+		{"fe80::2", false},
+		{"::ffff:10.1.2.3", true},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tree.contains(net.ParseIP(tc.ip)), tc.ip)
+	}
+
+	assert.False(t, (&cidrTree{}).contains(net.ParseIP("10.0.0.1")))
+	assert.False(t, tree.contains(nil))
+}
+
+func TestCIDRTreeMatchesLinearSearch(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	tree := &cidrTree{}
+	var networks []*net.IPNet
+	for i := 0; i < 2000; i++ {
+		ip := net.IPv4(10, byte(rng.IntN(4)), byte(rng.IntN(256)), byte(rng.IntN(256)))
+		mask := net.CIDRMask(16+rng.IntN(17), 32)
+		network := &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+		networks = append(networks, network)
+		tree.insert(network)
+	}
+
+	for i := 0; i < 10000; i++ {
+		ip := net.IPv4(10, byte(rng.IntN(4)), byte(rng.IntN(256)), byte(rng.IntN(256)))
+		expected := false
+		for _, network := range networks {
+			if network.Contains(ip) {
+				expected = true
+				break
+			}
+		}
+		require.Equal(t, expected, tree.contains(ip), fmt.Sprint(ip))
+	}
+}
+
+func BenchmarkCIDRTree(b *testing.B) {
+	tree := &cidrTree{}
+	for i := 0; i < 50000; i++ {
+		tree.insert(&net.IPNet{
+			IP:   net.IPv4(byte(i>>16), byte(i>>8), byte(i), 0),
+			Mask: net.CIDRMask(24, 32),
+		})
+	}
+	ip := net.ParseIP("0.150.200.1")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !tree.contains(ip) {
+			b.Fatal("expected match")
+		}
+	}
+}
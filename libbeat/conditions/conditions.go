@@ -22,6 +22,7 @@ import (
 
 	"github.com/elastic/beats/v7/libbeat/common/match"
 	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
 )
 
 const logName = "conditions"
@@ -34,6 +35,7 @@ type Config struct {
 	Range     *Fields                `config:"range"`
 	HasFields []string               `config:"has_fields"`
 	Network   map[string]interface{} `config:"network"`
+	In        map[string]interface{} `config:"in"`
 	OR        []Config               `config:"or"`
 	AND       []Config               `config:"and"`
 	NOT       *Config                `config:"not"`
@@ -74,6 +76,8 @@ func NewCondition(config *Config) (Condition, error) {
 		condition = NewHasFieldsCondition(config.HasFields)
 	case config.Network != nil && len(config.Network) > 0:
 		condition, err = NewNetworkCondition(config.Network)
+	case len(config.In) > 0:
+		condition, err = NewInCondition(mapstr.M(config.In).Flatten())
 	case len(config.OR) > 0:
 		var conditionsList []Condition
 		conditionsList, err = NewConditionList(config.OR)
@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/file"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// filePrefix marks condition values referencing a file, like
+// "file:///etc/filebeat/blocklist.txt" or "file:blocklist.txt". Relative
+// paths are resolved against the configuration directory.
+const filePrefix = "file:"
+
+// fileReloadInterval is how often files referenced by conditions are checked
+// for changes.
+var fileReloadInterval = time.Minute
+
+// fileReference returns the path of a value referencing a file.
+func fileReference(value string) (string, bool) {
+	path, found := strings.CutPrefix(value, filePrefix)
+	if !found {
+		return "", false
+	}
+	path = strings.TrimPrefix(path, "//")
+	return paths.Resolve(paths.Config, path), true
+}
+
+// watchedFile is the parsed content of a file that is reloaded when the file
+// changes. As conditions have no lifecycle, files are not watched in the
+// background: get checks for changes at most every fileReloadInterval, and
+// reloads the file without blocking the caller.
+type watchedFile[T any] struct {
+	*file.Reloadable[T]
+	log *logp.Logger
+
+	nextCheck atomic.Int64 // Unix time in nanoseconds.
+	reloading atomic.Bool
+}
+
+func newWatchedFile[T any](path string, parse func(io.Reader) (T, error)) (*watchedFile[T], error) {
+	r, err := file.NewReloadable(path, func(path string) (T, error) {
+		return parseFile(path, parse)
+	})
+	if err != nil {
+		return nil, err
+	}
+	f := &watchedFile[T]{Reloadable: r, log: logp.NewLogger(logName)}
+	f.nextCheck.Store(time.Now().Add(fileReloadInterval).UnixNano())
+	return f, nil
+}
+
+// get returns the current content of the file.
+func (f *watchedFile[T]) get() T {
+	if now := time.Now(); now.UnixNano() >= f.nextCheck.Load() && f.reloading.CompareAndSwap(false, true) {
+		f.nextCheck.Store(now.Add(fileReloadInterval).UnixNano())
+		go func() {
+			defer f.reloading.Store(false)
+			reloaded, err := f.Reload()
+			if err != nil {
+				f.log.Warnf("Keeping the previous version of %s: %v", f.Path(), err)
+			} else if reloaded {
+				f.log.Infof("Reloaded %s", f.Path())
+			}
+		}()
+	}
+	return f.Get()
+}
+
+// parseFile opens the file at path and parses it with parse.
+func parseFile[T any](path string, parse func(io.Reader) (T, error)) (T, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer r.Close()
+
+	v, err := parse(r)
+	if err != nil {
+		return v, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return v, nil
+}
+
+// readLines calls fn with each trimmed line of r, skipping empty lines and
+// comments starting with #.
+func readLines(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,162 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// In is a Condition for testing whether field values are in sets of values.
+type In map[string]valueSet
+
+type valueSet interface {
+	fmt.Stringer
+	contains(value string) bool
+}
+
+// literalSet is a set of values given in the configuration.
+type literalSet map[string]struct{}
+
+func (s literalSet) contains(value string) bool {
+	_, found := s[value]
+	return found
+}
+
+func (s literalSet) String() string {
+	return "[" + strconv.Itoa(len(s)) + " values]"
+}
+
+// fileSet is a set of values read from a file, one value per line.
+type fileSet struct {
+	file *watchedFile[literalSet]
+}
+
+func (s fileSet) contains(value string) bool {
+	return s.file.get().contains(value)
+}
+
+func (s fileSet) String() string {
+	return filePrefix + s.file.Path()
+}
+
+func parseValueSet(r io.Reader) (literalSet, error) {
+	set := literalSet{}
+	err := readLines(r, func(line string) error {
+		set[line] = struct{}{}
+		return nil
+	})
+	return set, err
+}
+
+// NewInCondition builds a new In using the given configuration. The values
+// of each field are either a list of values or a reference to a file with a
+// value per line.
+func NewInCondition(fields map[string]interface{}) (In, error) {
+	c := In{}
+	for field, value := range fields {
+		switch v := value.(type) {
+		case string:
+			path, ok := fileReference(v)
+			if !ok {
+				return nil, fmt.Errorf("in condition attempted to set '%v' -> '%v', "+
+					"values must be a list or a reference to a file like '%s/path/to/file'", field, v, filePrefix)
+			}
+			file, err := newWatchedFile(path, parseValueSet)
+			if err != nil {
+				return nil, fmt.Errorf("in condition failed to load values of '%v': %w", field, err)
+			}
+			c[field] = fileSet{file: file}
+		case []interface{}:
+			set := make(literalSet, len(v))
+			for _, elem := range v {
+				s, ok := inValue(elem)
+				if !ok {
+					return nil, fmt.Errorf("in condition attempted to set '%v' -> '%v' and encountered "+
+						"unexpected type '%T', only strings, numbers and booleans are allowed", field, elem, elem)
+				}
+				set[s] = struct{}{}
+			}
+			c[field] = set
+		default:
+			return nil, fmt.Errorf("in condition attempted to set '%v' -> '%v' and encountered "+
+				"unexpected type '%T', only lists and file references are allowed", field, value, value)
+		}
+	}
+	return c, nil
+}
+
+// inValue returns the string used to compare a scalar value.
+func inValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case net.IP:
+		return v.String(), true
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// Check determines whether the given event matches this condition. When a
+// field holds an array, any of its values must be in the set.
+func (c In) Check(event ValuesMap) bool {
+	for field, set := range c {
+		value, err := event.GetValue(field)
+		if err != nil {
+			return false
+		}
+		if !inSet(set, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func inSet(set valueSet, value interface{}) bool {
+	switch v := value.(type) {
+	case []string:
+		for _, s := range v {
+			if set.contains(s) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, elem := range v {
+			if s, ok := inValue(elem); ok && set.contains(s) {
+				return true
+			}
+		}
+		return false
+	}
+	s, ok := inValue(value)
+	return ok && set.contains(s)
+}
+
+func (c In) String() string {
+	var parts []string
+	for field, set := range c {
+		parts = append(parts, field+":"+set.String())
+	}
+	return "in: {" + strings.Join(parts, ", ") + "}"
+}
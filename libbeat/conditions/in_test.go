@@ -0,0 +1,143 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestInCondition(t *testing.T) {
+	values := filepath.Join(t.TempDir(), "users.txt")
+	require.NoError(t, os.WriteFile(values, []byte("# Blocked users\nalice\n\n  bob  \n"), 0o644))
+
+	testCases := []struct {
+		name     string
+		yaml     string
+		event    mapstr.M
+		expected bool
+	}{
+		{
+			name:     "string in list",
+			yaml:     "in.method: [GET, HEAD]",
+			event:    mapstr.M{"method": "GET"},
+			expected: true,
+		},
+		{
+			name:     "string not in list",
+			yaml:     "in.method: [GET, HEAD]",
+			event:    mapstr.M{"method": "POST"},
+			expected: false,
+		},
+		{
+			name:     "number in list",
+			yaml:     "in.http.code: [401, 403]",
+			event:    mapstr.M{"http": mapstr.M{"code": 403}},
+			expected: true,
+		},
+		{
+			name:     "any array element",
+			yaml:     "in.tags: [blocked]",
+			event:    mapstr.M{"tags": []string{"web", "blocked"}},
+			expected: true,
+		},
+		{
+			name:     "IP value",
+			yaml:     "in.ip: ['10.0.0.1']",
+			event:    mapstr.M{"ip": net.ParseIP("10.0.0.1")},
+			expected: true,
+		},
+		{
+			name:     "missing field",
+			yaml:     "in.method: [GET]",
+			event:    mapstr.M{},
+			expected: false,
+		},
+		{
+			name:     "all fields",
+			yaml:     "in: {method: [GET], path: [/]}",
+			event:    mapstr.M{"method": "GET", "path": "/index.html"},
+			expected: false,
+		},
+		{
+			name:     "file",
+			yaml:     "in.user.name: 'file://" + values + "'",
+			event:    mapstr.M{"user": mapstr.M{"name": "bob"}},
+			expected: true,
+		},
+		{
+			name:     "comments are not values",
+			yaml:     "in.user.name: 'file:" + values + "'",
+			event:    mapstr.M{"user": mapstr.M{"name": "# Blocked users"}},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := config.NewConfigWithYAML([]byte(tc.yaml), "test")
+			require.NoError(t, err)
+			var cfg Config
+			require.NoError(t, c.Unpack(&cfg))
+
+			testConfig(t, tc.expected, &beat.Event{Fields: tc.event}, &cfg)
+		})
+	}
+}
+
+func TestInConditionErrors(t *testing.T) {
+	_, err := NewInCondition(map[string]interface{}{"method": "GET"})
+	assert.ErrorContains(t, err, "values must be a list or a reference to a file")
+
+	_, err = NewInCondition(map[string]interface{}{"method": []interface{}{map[string]interface{}{}}})
+	assert.ErrorContains(t, err, "encountered unexpected type")
+
+	_, err = NewInCondition(map[string]interface{}{"method": "file:" + filepath.Join(t.TempDir(), "missing.txt")})
+	assert.ErrorContains(t, err, "in condition failed to load values of 'method'")
+}
+
+func TestInConditionReload(t *testing.T) {
+	defer func(d time.Duration) { fileReloadInterval = d }(fileReloadInterval)
+	fileReloadInterval = 0
+
+	path := filepath.Join(t.TempDir(), "users.txt")
+	require.NoError(t, os.WriteFile(path, []byte("alice\n"), 0o644))
+
+	cond, err := NewInCondition(map[string]interface{}{"user": "file:" + path})
+	require.NoError(t, err)
+
+	event := &beat.Event{Fields: mapstr.M{"user": "bob"}}
+	assert.False(t, cond.Check(event))
+
+	require.NoError(t, os.WriteFile(path, []byte("alice\nbob\n"), 0o644))
+	// Make sure the change is detected on file systems with coarse timestamps.
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	assert.Eventually(t, func() bool { return cond.Check(event) }, 10*time.Second, 10*time.Millisecond)
+}
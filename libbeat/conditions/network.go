@@ -19,6 +19,7 @@ package conditions
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"slices"
 	"strings"
@@ -95,7 +96,45 @@ func (m multiNetworkMatcher) String() string {
 	return strings.Join(names, " OR ")
 }
 
+// fileNetworkMatcher matches the networks listed in a file, one CIDR or IP
+// address per line.
+type fileNetworkMatcher struct {
+	file *watchedFile[*cidrTree]
+}
+
+func (m fileNetworkMatcher) Contains(ip net.IP) bool { return m.file.get().contains(ip) }
+func (m fileNetworkMatcher) String() string          { return filePrefix + m.file.Path() }
+
+func parseNetworks(r io.Reader) (*cidrTree, error) {
+	tree := &cidrTree{}
+	err := readLines(r, func(line string) error {
+		if !strings.Contains(line, "/") {
+			ip := net.ParseIP(line)
+			if ip == nil {
+				return fmt.Errorf("invalid IP address '%s'", line)
+			}
+			tree.insert(&net.IPNet{IP: ip, Mask: net.CIDRMask(8*net.IPv6len, 8*net.IPv6len)})
+			return nil
+		}
+		subnet, err := parseCIDR(line)
+		if err != nil {
+			return err
+		}
+		tree.insert(subnet)
+		return nil
+	})
+	return tree, err
+}
+
 func makeMatcher(network string) (networkMatcher, error) {
+	if path, ok := fileReference(network); ok {
+		file, err := newWatchedFile(path, parseNetworks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load networks: %w", err)
+		}
+		return fileNetworkMatcher{file: file}, nil
+	}
+
 	m := singleNetworkMatcher{name: network, netContainsFunc: namedNetworks[network]}
 	if m.netContainsFunc == nil {
 		subnet, err := parseCIDR(network)
@@ -19,10 +19,13 @@ package conditions
 
 import (
 	"net"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/elastic-agent-libs/config"
@@ -319,3 +322,32 @@ func BenchmarkNetworkCondition(b *testing.B) {
 		c.Check(event)
 	}
 }
+
+func TestNetworkFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "networks.txt")
+	require.NoError(t, os.WriteFile(path, []byte(`
+# Internal networks
+10.0.0.0/8
+192.168.1.1
+2001:db8::/32
+`), 0o644))
+
+	cond, err := NewNetworkCondition(map[string]interface{}{
+		"ip":        "file://" + path,
+		"client_ip": []interface{}{"loopback", "file:" + path},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, cond.String(), "ip:file:"+path)
+
+	check := func(ip, clientIP string) bool {
+		return cond.Check(&beat.Event{Fields: mapstr.M{"ip": ip, "client_ip": clientIP}})
+	}
+	assert.True(t, check("10.1.2.3", "127.0.0.1"))
+	assert.True(t, check("192.168.1.1", "2001:db8::1"))
+	assert.False(t, check("192.168.1.2", "127.0.0.1"))
+	assert.False(t, check("10.1.2.3", "172.16.0.1"))
+
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.0/33\n"), 0o644))
+	_, err = NewNetworkCondition(map[string]interface{}{"ip": "file:" + path})
+	assert.ErrorContains(t, err, "line 1: failed to parse CIDR")
+}
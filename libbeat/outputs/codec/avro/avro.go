@@ -0,0 +1,125 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package avro implements an output codec that serializes events in the
+// Avro binary encoding, optionally framed for the Confluent Schema Registry.
+package avro
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/schemaregistry"
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+// Encoder serializes a beat.Event using an Avro record schema.
+type Encoder struct {
+	buf      bytes.Buffer
+	version  string
+	schema   *schema
+	registry *schemaregistry.Resolver
+}
+
+// Config is used to pass encoding parameters to New.
+type Config struct {
+	// Schema is the path of the file containing the Avro schema (.avsc).
+	// The schema must be a record.
+	Schema string `config:"schema" validate:"required"`
+
+	// Registry enables the Confluent wire format. The schema ID is resolved
+	// from the registry.
+	Registry *schemaregistry.Config `config:"schema_registry"`
+}
+
+func init() {
+	codec.RegisterType("avro", func(info beat.Info, cfg *config.C) (codec.Codec, error) {
+		if cfg == nil {
+			return nil, errors.New("empty avro codec configuration")
+		}
+
+		config := Config{}
+		if cfg.HasField("schema_registry") {
+			registry := schemaregistry.DefaultConfig()
+			config.Registry = &registry
+		}
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+
+		return New(info.Version, config)
+	})
+}
+
+// New creates a new avro Encoder, reading the schema from the configured
+// file.
+func New(version string, config Config) (*Encoder, error) {
+	data, err := os.ReadFile(config.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avro schema: %w", err)
+	}
+
+	s, err := parseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse avro schema %s: %w", config.Schema, err)
+	}
+	if s.kind != kindRecord {
+		return nil, fmt.Errorf("avro schema %s must be a record, got %v", config.Schema, s)
+	}
+
+	e := &Encoder{version: version, schema: s}
+	if config.Registry != nil {
+		e.registry, err = schemaregistry.NewResolver(*config.Registry, "AVRO", string(data))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Connect resolves the schema ID if the schema registry is configured.
+func (e *Encoder) Connect(ctx context.Context) error {
+	if e.registry == nil {
+		return nil
+	}
+	return e.registry.Resolve(ctx)
+}
+
+// Encode serializes a beat event. An error is returned if the event does not
+// fit the schema; the error names the offending field.
+func (e *Encoder) Encode(index string, event *beat.Event) ([]byte, error) {
+	e.buf.Reset()
+
+	if e.registry != nil {
+		id, err := e.registry.ID()
+		if err != nil {
+			return nil, err
+		}
+		e.buf.Write(schemaregistry.AppendHeader(nil, id))
+	}
+
+	enc := encoder{buf: &e.buf}
+	if err := enc.encodeDocument(e.schema, codec.MakeDocument(index, e.version, event)); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
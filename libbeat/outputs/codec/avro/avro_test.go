@@ -0,0 +1,263 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/schemaregistry"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const testSchema = `{
+  "type": "record",
+  "name": "Event",
+  "namespace": "co.elastic",
+  "fields": [
+    {"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "message", "type": "string"},
+    {"name": "count", "type": "int"},
+    {"name": "ratio", "type": "double", "default": 0.5},
+    {"name": "level", "type": {"type": "enum", "name": "Level", "symbols": ["INFO", "WARN"]}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}},
+    {"name": "host", "type": ["null", {"type": "record", "name": "Host", "fields": [
+      {"name": "name", "type": "string"}
+    ]}]},
+    {"name": "labels", "type": {"type": "map", "values": "string"}},
+    {"name": "user", "type": ["null", "string"]}
+  ]
+}`
+
+var testTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func writeSchema(t *testing.T, schema string) string {
+	path := filepath.Join(t.TempDir(), "schema.avsc")
+	require.NoError(t, os.WriteFile(path, []byte(schema), 0o600))
+	return path
+}
+
+func long(i int64) []byte {
+	return binary.AppendVarint(nil, i)
+}
+
+func str(s string) []byte {
+	return append(long(int64(len(s))), s...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func testEvent() *beat.Event {
+	return &beat.Event{
+		Timestamp: testTime,
+		Fields: mapstr.M{
+			"message": "hello",
+			"count":   uint8(3),
+			"level":   "WARN",
+			"tags":    []string{"a", "b"},
+			"host":    mapstr.M{"name": "web-1"},
+			"labels":  map[string]interface{}{"env": "prod"},
+		},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	enc, err := New("1.2.3", Config{Schema: writeSchema(t, testSchema)})
+	require.NoError(t, err)
+
+	out, err := enc.Encode("test", testEvent())
+	require.NoError(t, err)
+
+	var ratio [8]byte
+	binary.LittleEndian.PutUint64(ratio[:], math.Float64bits(0.5))
+
+	expected := concat(
+		long(testTime.UnixMilli()),
+		str("hello"),
+		long(3),
+		ratio[:],
+		long(1),
+		long(2), str("a"), str("b"), long(0),
+		long(1), str("web-1"),
+		long(1), str("env"), str("prod"), long(0),
+		long(0),
+	)
+	assert.Equal(t, expected, out)
+}
+
+func TestEncodeFieldErrors(t *testing.T) {
+	enc, err := New("1.2.3", Config{Schema: writeSchema(t, testSchema)})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		update mapstr.M
+		delete string
+		field  string
+	}{
+		"wrong type":        {update: mapstr.M{"count": "three"}, field: "count"},
+		"int overflow":      {update: mapstr.M{"count": int64(math.MaxInt32) + 1}, field: "count"},
+		"fractional int":    {update: mapstr.M{"count": 1.5}, field: "count"},
+		"unknown symbol":    {update: mapstr.M{"level": "DEBUG"}, field: "level"},
+		"nested field":      {update: mapstr.M{"host.name": 42}, field: "host"},
+		"array item":        {update: mapstr.M{"tags": []interface{}{"a", 1}}, field: "tags.1"},
+		"map value":         {update: mapstr.M{"labels.env": true}, field: "labels.env"},
+		"missing required":  {delete: "message", field: "message"},
+		"null for non null": {update: mapstr.M{"message": nil}, field: "message"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			event := testEvent()
+			for k, v := range test.update {
+				_, err := event.Fields.Put(k, v)
+				require.NoError(t, err)
+			}
+			if test.delete != "" {
+				require.NoError(t, event.Fields.Delete(test.delete))
+			}
+
+			_, err := enc.Encode("test", event)
+			require.Error(t, err)
+
+			var fieldErr *FieldError
+			require.True(t, errors.As(err, &fieldErr), "unexpected error type %T: %v", err, err)
+			assert.Equal(t, test.field, fieldErr.Field)
+		})
+	}
+
+	// the encoder stays usable after an error
+	_, err = enc.Encode("test", testEvent())
+	assert.NoError(t, err)
+}
+
+func TestEncodeMetadata(t *testing.T) {
+	schema := `{"type": "record", "name": "Event", "fields": [
+	  {"name": "metadata", "type": {"type": "record", "name": "Meta", "fields": [
+	    {"name": "beat", "type": "string"},
+	    {"name": "version", "type": "string"},
+	    {"name": "pipeline", "type": ["null", "string"]}
+	  ]}}
+	]}`
+	enc, err := New("1.2.3", Config{Schema: writeSchema(t, schema)})
+	require.NoError(t, err)
+
+	out, err := enc.Encode("test", &beat.Event{Meta: mapstr.M{"pipeline": "p1"}})
+	require.NoError(t, err)
+	assert.Equal(t, concat(str("test"), str("1.2.3"), long(1), str("p1")), out)
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	tests := map[string]string{
+		"not a record":     `"string"`,
+		"invalid json":     `{"type": `,
+		"unknown type":     `{"type": "record", "name": "A", "fields": [{"name": "a", "type": "Missing"}]}`,
+		"invalid name":     `{"type": "record", "name": "A-B", "fields": []}`,
+		"duplicate field":  `{"type": "record", "name": "A", "fields": [{"name": "a", "type": "int"}, {"name": "a", "type": "int"}]}`,
+		"nested union":     `{"type": "record", "name": "A", "fields": [{"name": "a", "type": ["null", ["int"]]}]}`,
+		"enum w/o symbols": `{"type": "enum", "name": "E", "symbols": []}`,
+	}
+
+	for name, schema := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New("1.2.3", Config{Schema: writeSchema(t, schema)})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRecursiveSchema(t *testing.T) {
+	schema := `{"type": "record", "name": "Node", "fields": [
+	  {"name": "value", "type": "long"},
+	  {"name": "next", "type": ["null", "Node"]}
+	]}`
+	enc, err := New("1.2.3", Config{Schema: writeSchema(t, schema)})
+	require.NoError(t, err)
+
+	out, err := enc.Encode("test", &beat.Event{Fields: mapstr.M{
+		"value": 1,
+		"next":  mapstr.M{"value": 2},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, concat(long(1), long(1), long(2), long(0)), out)
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	schema := `{"type": "record", "name": "Event", "fields": [{"name": "message", "type": "string"}]}`
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/subjects/events-value", r.URL.Path)
+
+		var body map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, schema, body["schema"])
+		assert.Equal(t, "AVRO", body["schemaType"])
+
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"subject": "events-value", "id": 42, "version": 1}`))
+	}))
+	defer server.Close()
+
+	registry := schemaregistry.DefaultConfig()
+	registry.URL = server.URL
+	registry.Subject = "events-value"
+	enc, err := New("1.2.3", Config{Schema: writeSchema(t, schema), Registry: &registry})
+	require.NoError(t, err)
+
+	event := &beat.Event{Fields: mapstr.M{"message": "hi"}}
+
+	// events can't be encoded before the schema ID is resolved
+	_, err = enc.Encode("test", event)
+	require.ErrorIs(t, err, schemaregistry.ErrUnresolved)
+
+	// registry failures fail the connection and are retried
+	require.Error(t, enc.Connect(context.Background()))
+	require.NoError(t, enc.Connect(context.Background()))
+	require.NoError(t, enc.Connect(context.Background()))
+
+	for i := 0; i < 2; i++ {
+		out, err := enc.Encode("test", event)
+		require.NoError(t, err)
+		assert.Equal(t, concat([]byte{0, 0, 0, 0, 42}, str("hi")), out)
+	}
+	assert.Equal(t, 2, requests, "schema ID must be cached")
+}
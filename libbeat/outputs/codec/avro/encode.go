@@ -0,0 +1,329 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/internal/value"
+)
+
+// FieldError is returned when an event field does not fit the schema.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return "avro: " + e.Message
+	}
+	return fmt.Sprintf("avro: field '%s': %s", e.Field, e.Message)
+}
+
+// encoder writes values in the Avro binary encoding.
+type encoder struct {
+	buf  *bytes.Buffer
+	path []string
+	tmp  [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) fail(format string, args ...interface{}) error {
+	return &FieldError{Field: strings.Join(e.path, "."), Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *encoder) mismatch(s *schema, v interface{}) error {
+	return e.fail("expected %v, got %v", s, value.TypeName(v))
+}
+
+// encodeDocument encodes the top-level record. Record fields are looked up
+// with codec.LookupSchemaField so `timestamp` and `metadata` resolve to the
+// event timestamp and metadata.
+func (e *encoder) encodeDocument(s *schema, doc map[string]interface{}) error {
+	return e.encodeFields(s, func(name string) (interface{}, bool) {
+		return codec.LookupSchemaField(doc, name)
+	})
+}
+
+func (e *encoder) encode(s *schema, v interface{}) error {
+	switch s.kind {
+	case kindNull:
+		if v != nil {
+			return e.mismatch(s, v)
+		}
+		return nil
+
+	case kindBoolean:
+		b, ok := v.(bool)
+		if !ok {
+			return e.mismatch(s, v)
+		}
+		if b {
+			e.buf.WriteByte(1)
+		} else {
+			e.buf.WriteByte(0)
+		}
+		return nil
+
+	case kindInt, kindLong:
+		i, err := e.integer(s, v)
+		if err != nil {
+			return err
+		}
+		e.writeLong(i)
+		return nil
+
+	case kindFloat:
+		f, ok := value.Float(v)
+		if !ok {
+			return e.mismatch(s, v)
+		}
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], math.Float32bits(float32(f)))
+		e.buf.Write(b[:])
+		return nil
+
+	case kindDouble:
+		f, ok := value.Float(v)
+		if !ok {
+			return e.mismatch(s, v)
+		}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+		e.buf.Write(b[:])
+		return nil
+
+	case kindBytes:
+		switch b := v.(type) {
+		case []byte:
+			e.writeBytes(b)
+		case string:
+			e.writeBytes([]byte(b))
+		default:
+			return e.mismatch(s, v)
+		}
+		return nil
+
+	case kindString:
+		str, ok := value.String(v)
+		if !ok {
+			return e.mismatch(s, v)
+		}
+		e.writeLong(int64(len(str)))
+		e.buf.WriteString(str)
+		return nil
+
+	case kindRecord:
+		m, ok := value.Map(v)
+		if !ok {
+			return e.mismatch(s, v)
+		}
+		return e.encodeFields(s, func(name string) (interface{}, bool) {
+			v, ok := m[name]
+			return v, ok
+		})
+
+	case kindEnum:
+		sym, ok := v.(string)
+		if !ok {
+			return e.mismatch(s, v)
+		}
+		idx, ok := s.symbols[sym]
+		if !ok {
+			return e.fail("'%s' is not a symbol of enum %v", sym, s)
+		}
+		e.writeLong(int64(idx))
+		return nil
+
+	case kindArray:
+		items, ok := value.Slice(v)
+		if !ok {
+			return e.mismatch(s, v)
+		}
+		if len(items) > 0 {
+			e.writeLong(int64(len(items)))
+			for i, item := range items {
+				e.path = append(e.path, fmt.Sprint(i))
+				err := e.encode(s.items, item)
+				e.path = e.path[:len(e.path)-1]
+				if err != nil {
+					return err
+				}
+			}
+		}
+		e.writeLong(0)
+		return nil
+
+	case kindMap:
+		m, ok := value.Map(v)
+		if !ok {
+			return e.mismatch(s, v)
+		}
+		if len(m) > 0 {
+			keys := make([]string, 0, len(m))
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			e.writeLong(int64(len(keys)))
+			for _, k := range keys {
+				e.writeLong(int64(len(k)))
+				e.buf.WriteString(k)
+				e.path = append(e.path, k)
+				err := e.encode(s.values, m[k])
+				e.path = e.path[:len(e.path)-1]
+				if err != nil {
+					return err
+				}
+			}
+		}
+		e.writeLong(0)
+		return nil
+
+	case kindUnion:
+		return e.encodeUnion(s, v)
+
+	case kindFixed:
+		var b []byte
+		switch x := v.(type) {
+		case []byte:
+			b = x
+		case string:
+			b = []byte(x)
+		default:
+			return e.mismatch(s, v)
+		}
+		if len(b) != s.size {
+			return e.fail("expected %d bytes for fixed %v, got %d", s.size, s, len(b))
+		}
+		e.buf.Write(b)
+		return nil
+	}
+	return e.fail("unsupported schema type %v", s)
+}
+
+func (e *encoder) encodeFields(s *schema, get func(string) (interface{}, bool)) error {
+	for _, f := range s.fields {
+		v, ok := get(f.name)
+		e.path = append(e.path, f.name)
+		var err error
+		switch {
+		case ok:
+			err = e.encode(f.typ, v)
+		case f.hasDefault:
+			err = e.encodeDefault(f.typ, f.def)
+		case acceptsNull(f.typ):
+			err = e.encode(f.typ, nil)
+		default:
+			err = e.fail("missing required field of type %v", f.typ)
+		}
+		e.path = e.path[:len(e.path)-1]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeDefault encodes a field default value. Defaults of union fields
+// always use the first branch of the union.
+func (e *encoder) encodeDefault(s *schema, def interface{}) error {
+	if s.kind != kindUnion {
+		return e.encode(s, def)
+	}
+	e.writeLong(0)
+	return e.encode(s.branches[0], def)
+}
+
+// encodeUnion encodes v using the first union branch that accepts it.
+func (e *encoder) encodeUnion(s *schema, v interface{}) error {
+	start := e.buf.Len()
+	for i, branch := range s.branches {
+		if (v == nil) != (branch.kind == kindNull) {
+			continue
+		}
+		e.writeLong(int64(i))
+		if err := e.encode(branch, v); err == nil {
+			return nil
+		}
+		e.buf.Truncate(start)
+	}
+
+	names := make([]string, len(s.branches))
+	for i, branch := range s.branches {
+		names[i] = branch.String()
+	}
+	return e.fail("%v does not match any of [%v]", value.TypeName(v), strings.Join(names, ", "))
+}
+
+func (e *encoder) integer(s *schema, v interface{}) (int64, error) {
+	if t, ok := value.Time(v); ok {
+		switch s.logical {
+		case "timestamp-millis", "local-timestamp-millis":
+			return t.UnixMilli(), nil
+		case "timestamp-micros", "local-timestamp-micros":
+			return t.UnixMicro(), nil
+		case "date":
+			return int64(math.Floor(float64(t.Unix()) / 86400)), nil
+		}
+		return 0, e.mismatch(s, v)
+	}
+
+	i, ok := value.Int(v)
+	if !ok {
+		if _, isNumber := value.Float(v); isNumber {
+			return 0, e.fail("%v is not a valid %v", v, s)
+		}
+		return 0, e.mismatch(s, v)
+	}
+	if s.kind == kindInt && (i < math.MinInt32 || i > math.MaxInt32) {
+		return 0, e.fail("%d overflows %v", i, s)
+	}
+	return i, nil
+}
+
+func (e *encoder) writeLong(i int64) {
+	n := binary.PutVarint(e.tmp[:], i)
+	e.buf.Write(e.tmp[:n])
+}
+
+func (e *encoder) writeBytes(b []byte) {
+	e.writeLong(int64(len(b)))
+	e.buf.Write(b)
+}
+
+func acceptsNull(s *schema) bool {
+	if s.kind == kindNull {
+		return true
+	}
+	if s.kind == kindUnion {
+		for _, branch := range s.branches {
+			if branch.kind == kindNull {
+				return true
+			}
+		}
+	}
+	return false
+}
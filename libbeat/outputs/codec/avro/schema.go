@@ -0,0 +1,317 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBoolean
+	kindInt
+	kindLong
+	kindFloat
+	kindDouble
+	kindBytes
+	kindString
+	kindRecord
+	kindEnum
+	kindArray
+	kindMap
+	kindUnion
+	kindFixed
+)
+
+var primitives = map[string]kind{
+	"null":    kindNull,
+	"boolean": kindBoolean,
+	"int":     kindInt,
+	"long":    kindLong,
+	"float":   kindFloat,
+	"double":  kindDouble,
+	"bytes":   kindBytes,
+	"string":  kindString,
+}
+
+var kindNames = map[kind]string{
+	kindNull:    "null",
+	kindBoolean: "boolean",
+	kindInt:     "int",
+	kindLong:    "long",
+	kindFloat:   "float",
+	kindDouble:  "double",
+	kindBytes:   "bytes",
+	kindString:  "string",
+	kindRecord:  "record",
+	kindEnum:    "enum",
+	kindArray:   "array",
+	kindMap:     "map",
+	kindUnion:   "union",
+	kindFixed:   "fixed",
+}
+
+func (k kind) String() string { return kindNames[k] }
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// schema is a parsed Avro schema node.
+type schema struct {
+	kind    kind
+	logical string
+	name    string
+
+	fields   []field        // record
+	symbols  map[string]int // enum
+	items    *schema        // array
+	values   *schema        // map
+	branches []*schema      // union
+	size     int            // fixed
+}
+
+type field struct {
+	name       string
+	typ        *schema
+	def        interface{}
+	hasDefault bool
+}
+
+func (s *schema) String() string {
+	if s.name != "" {
+		return s.name
+	}
+	if s.logical != "" {
+		return s.kind.String() + "(" + s.logical + ")"
+	}
+	return s.kind.String()
+}
+
+// parseSchema parses an Avro schema in its JSON representation.
+func parseSchema(data []byte) (*schema, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var raw interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid schema JSON: %w", err)
+	}
+
+	p := &parser{names: map[string]*schema{}}
+	return p.parse(raw, "")
+}
+
+type parser struct {
+	names map[string]*schema
+}
+
+func (p *parser) parse(raw interface{}, namespace string) (*schema, error) {
+	switch v := raw.(type) {
+	case string:
+		return p.lookup(v, namespace)
+	case []interface{}:
+		return p.parseUnion(v, namespace)
+	case map[string]interface{}:
+		return p.parseComplex(v, namespace)
+	}
+	return nil, fmt.Errorf("invalid schema definition %v", raw)
+}
+
+func (p *parser) lookup(name, namespace string) (*schema, error) {
+	if k, ok := primitives[name]; ok {
+		return &schema{kind: k}, nil
+	}
+	if s, ok := p.names[fullName(name, namespace)]; ok {
+		return s, nil
+	}
+	if s, ok := p.names[name]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown type '%s'", name)
+}
+
+func (p *parser) parseUnion(raw []interface{}, namespace string) (*schema, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("union must have at least one branch")
+	}
+	s := &schema{kind: kindUnion}
+	for _, r := range raw {
+		branch, err := p.parse(r, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if branch.kind == kindUnion {
+			return nil, errors.New("unions must not immediately contain other unions")
+		}
+		s.branches = append(s.branches, branch)
+	}
+	return s, nil
+}
+
+func (p *parser) parseComplex(raw map[string]interface{}, namespace string) (*schema, error) {
+	typ, ok := raw["type"]
+	if !ok {
+		return nil, errors.New("schema object has no 'type'")
+	}
+	name, ok := typ.(string)
+	if !ok {
+		// nested type definition, e.g. {"type": {"type": "array", ...}}
+		return p.parse(typ, namespace)
+	}
+
+	logical, _ := raw["logicalType"].(string)
+	if k, ok := primitives[name]; ok {
+		return &schema{kind: k, logical: logical}, nil
+	}
+
+	switch name {
+	case "record", "error":
+		return p.parseRecord(raw, namespace)
+	case "enum":
+		return p.parseEnum(raw, namespace)
+	case "fixed":
+		return p.parseFixed(raw, namespace, logical)
+	case "array":
+		items, err := p.parse(raw["items"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("array items: %w", err)
+		}
+		return &schema{kind: kindArray, items: items}, nil
+	case "map":
+		values, err := p.parse(raw["values"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("map values: %w", err)
+		}
+		return &schema{kind: kindMap, values: values}, nil
+	}
+	return p.lookup(name, namespace)
+}
+
+// define registers a named type. The type is registered before its children
+// are parsed, so records can refer to themselves.
+func (p *parser) define(raw map[string]interface{}, namespace string, s *schema) (string, error) {
+	name, _ := raw["name"].(string)
+	if name == "" {
+		return "", fmt.Errorf("%v schema requires a name", s.kind)
+	}
+	if ns, ok := raw["namespace"].(string); ok && !strings.Contains(name, ".") {
+		namespace = ns
+	}
+
+	full := fullName(name, namespace)
+	for _, part := range strings.Split(full, ".") {
+		if !namePattern.MatchString(part) {
+			return "", fmt.Errorf("invalid name '%s'", full)
+		}
+	}
+	if _, exists := p.names[full]; exists {
+		return "", fmt.Errorf("type '%s' is defined more than once", full)
+	}
+
+	s.name = full
+	p.names[full] = s
+	if idx := strings.LastIndexByte(full, '.'); idx >= 0 {
+		return full[:idx], nil
+	}
+	return "", nil
+}
+
+func (p *parser) parseRecord(raw map[string]interface{}, namespace string) (*schema, error) {
+	s := &schema{kind: kindRecord}
+	namespace, err := p.define(raw, namespace, s)
+	if err != nil {
+		return nil, err
+	}
+
+	rawFields, ok := raw["fields"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("record '%s' requires a list of fields", s.name)
+	}
+	seen := map[string]bool{}
+	for _, rf := range rawFields {
+		fieldDef, ok := rf.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("record '%s' has an invalid field definition", s.name)
+		}
+		name, _ := fieldDef["name"].(string)
+		if !namePattern.MatchString(name) {
+			return nil, fmt.Errorf("record '%s' has invalid field name '%s'", s.name, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("record '%s' has duplicate field '%s'", s.name, name)
+		}
+		seen[name] = true
+
+		typ, err := p.parse(fieldDef["type"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("field '%s.%s': %w", s.name, name, err)
+		}
+		def, hasDefault := fieldDef["default"]
+		s.fields = append(s.fields, field{name: name, typ: typ, def: def, hasDefault: hasDefault})
+	}
+	return s, nil
+}
+
+func (p *parser) parseEnum(raw map[string]interface{}, namespace string) (*schema, error) {
+	s := &schema{kind: kindEnum, symbols: map[string]int{}}
+	if _, err := p.define(raw, namespace, s); err != nil {
+		return nil, err
+	}
+	symbols, ok := raw["symbols"].([]interface{})
+	if !ok || len(symbols) == 0 {
+		return nil, fmt.Errorf("enum '%s' requires a list of symbols", s.name)
+	}
+	for i, sym := range symbols {
+		name, ok := sym.(string)
+		if !ok || !namePattern.MatchString(name) {
+			return nil, fmt.Errorf("enum '%s' has invalid symbol %v", s.name, sym)
+		}
+		s.symbols[name] = i
+	}
+	return s, nil
+}
+
+func (p *parser) parseFixed(raw map[string]interface{}, namespace, logical string) (*schema, error) {
+	s := &schema{kind: kindFixed, logical: logical}
+	if _, err := p.define(raw, namespace, s); err != nil {
+		return nil, err
+	}
+	size, ok := raw["size"].(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("fixed '%s' requires a size", s.name)
+	}
+	n, err := size.Int64()
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("fixed '%s' has invalid size %v", s.name, size)
+	}
+	s.size = int(n)
+	return s, nil
+}
+
+func fullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
@@ -17,8 +17,27 @@
 
 package codec
 
-import "github.com/elastic/beats/v7/libbeat/beat"
+import (
+	"context"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+)
 
 type Codec interface {
 	Encode(index string, event *beat.Event) ([]byte, error)
 }
+
+// Connector is implemented by codecs that depend on a remote service, like a
+// schema registry. Outputs call Connect when they connect, so the failure is
+// retried like a connection error instead of failing every event.
+type Connector interface {
+	Connect(ctx context.Context) error
+}
+
+// Connect connects c if it implements Connector.
+func Connect(ctx context.Context, c Codec) error {
+	if connector, ok := c.(Connector); ok {
+		return connector.Connect(ctx)
+	}
+	return nil
+}
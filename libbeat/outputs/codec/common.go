@@ -20,6 +20,7 @@ package codec
 import (
 	"time"
 
+	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/common/dtfmt"
 	"github.com/elastic/go-structform"
@@ -63,3 +64,37 @@ func MakeBCTimestampEncoder() func(*common.Time, structform.ExtVisitor) error {
 		return enc((*time.Time)(t), v)
 	}
 }
+
+// MakeDocument returns the event as a single map, with the timestamp and
+// metadata stored under `@timestamp` and `@metadata` the same way the json
+// codec serializes them. It is used by codecs that map events onto a schema.
+// The event fields are not copied.
+func MakeDocument(index, version string, event *beat.Event) map[string]interface{} {
+	meta := make(map[string]interface{}, len(event.Meta)+3)
+	for k, v := range event.Meta {
+		meta[k] = v
+	}
+	meta["beat"] = index
+	meta["type"] = "_doc"
+	meta["version"] = version
+
+	doc := make(map[string]interface{}, len(event.Fields)+2)
+	for k, v := range event.Fields {
+		doc[k] = v
+	}
+	doc["@timestamp"] = event.Timestamp
+	doc["@metadata"] = meta
+	return doc
+}
+
+// LookupSchemaField returns the value for a schema field name from a document
+// created with MakeDocument. Schema languages do not allow '@' in field names,
+// so if the name is not found the '@'-prefixed name is tried, mapping
+// `timestamp` to `@timestamp` and `metadata` to `@metadata`.
+func LookupSchemaField(doc map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := doc[name]; ok {
+		return v, true
+	}
+	v, ok := doc["@"+name]
+	return v, ok
+}
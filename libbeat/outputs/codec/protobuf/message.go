@@ -0,0 +1,272 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package protobuf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/internal/value"
+)
+
+const timestampMessage protoreflect.FullName = "google.protobuf.Timestamp"
+
+// FieldError is returned when an event field does not fit the message type.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return "protobuf: " + e.Message
+	}
+	return fmt.Sprintf("protobuf: field '%s': %s", e.Field, e.Message)
+}
+
+// builder populates dynamic messages from event values.
+type builder struct {
+	path []string
+}
+
+func (b *builder) fail(format string, args ...interface{}) error {
+	return &FieldError{Field: strings.Join(b.path, "."), Message: fmt.Sprintf(format, args...)}
+}
+
+func (b *builder) mismatch(fd protoreflect.FieldDescriptor, v interface{}) error {
+	expected := fd.Kind().String()
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		expected = string(fd.Message().FullName())
+	case protoreflect.EnumKind:
+		expected = string(fd.Enum().FullName())
+	}
+	return b.fail("expected %v, got %v", expected, value.TypeName(v))
+}
+
+func (b *builder) setFields(msg protoreflect.Message, get func(protoreflect.FieldDescriptor) (interface{}, bool)) error {
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		v, ok := get(fd)
+		if !ok || v == nil {
+			continue
+		}
+
+		b.path = append(b.path, string(fd.Name()))
+		err := b.setField(msg, fd, v)
+		b.path = b.path[:len(b.path)-1]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *builder) setField(msg protoreflect.Message, fd protoreflect.FieldDescriptor, v interface{}) error {
+	switch {
+	case fd.IsMap():
+		m, ok := value.Map(v)
+		if !ok {
+			return b.fail("expected map, got %v", value.TypeName(v))
+		}
+		dst := msg.Mutable(fd).Map()
+		for k, item := range m {
+			key, err := b.mapKey(fd.MapKey(), k)
+			if err != nil {
+				return err
+			}
+			b.path = append(b.path, k)
+			val, err := b.value(fd.MapValue(), item, dst.NewValue)
+			b.path = b.path[:len(b.path)-1]
+			if err != nil {
+				return err
+			}
+			dst.Set(key, val)
+		}
+		return nil
+
+	case fd.IsList():
+		items, ok := value.Slice(v)
+		if !ok {
+			return b.fail("expected list, got %v", value.TypeName(v))
+		}
+		dst := msg.Mutable(fd).List()
+		for i, item := range items {
+			b.path = append(b.path, strconv.Itoa(i))
+			val, err := b.value(fd, item, dst.NewElement)
+			b.path = b.path[:len(b.path)-1]
+			if err != nil {
+				return err
+			}
+			dst.Append(val)
+		}
+		return nil
+	}
+
+	val, err := b.value(fd, v, func() protoreflect.Value { return msg.NewField(fd) })
+	if err != nil {
+		return err
+	}
+	msg.Set(fd, val)
+	return nil
+}
+
+// value converts v to a value for fd. newMessage allocates the message if fd
+// is a message field.
+func (b *builder) value(fd protoreflect.FieldDescriptor, v interface{}, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if x, ok := v.(bool); ok {
+			return protoreflect.ValueOfBool(x), nil
+		}
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if x, ok := value.Int(v); ok {
+			if x < math.MinInt32 || x > math.MaxInt32 {
+				return protoreflect.Value{}, b.fail("%d overflows %v", x, fd.Kind())
+			}
+			return protoreflect.ValueOfInt32(int32(x)), nil
+		}
+
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if x, ok := value.Int(v); ok {
+			return protoreflect.ValueOfInt64(x), nil
+		}
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if x, ok := value.Uint(v); ok {
+			if x > math.MaxUint32 {
+				return protoreflect.Value{}, b.fail("%d overflows %v", x, fd.Kind())
+			}
+			return protoreflect.ValueOfUint32(uint32(x)), nil
+		}
+
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if x, ok := value.Uint(v); ok {
+			return protoreflect.ValueOfUint64(x), nil
+		}
+
+	case protoreflect.FloatKind:
+		if x, ok := value.Float(v); ok {
+			return protoreflect.ValueOfFloat32(float32(x)), nil
+		}
+
+	case protoreflect.DoubleKind:
+		if x, ok := value.Float(v); ok {
+			return protoreflect.ValueOfFloat64(x), nil
+		}
+
+	case protoreflect.StringKind:
+		if x, ok := value.String(v); ok {
+			return protoreflect.ValueOfString(x), nil
+		}
+
+	case protoreflect.BytesKind:
+		switch x := v.(type) {
+		case []byte:
+			return protoreflect.ValueOfBytes(x), nil
+		case string:
+			return protoreflect.ValueOfBytes([]byte(x)), nil
+		}
+
+	case protoreflect.EnumKind:
+		if name, ok := v.(string); ok {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(name))
+			if ev == nil {
+				return protoreflect.Value{}, b.fail("'%s' is not a value of enum %v", name, fd.Enum().FullName())
+			}
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		if x, ok := value.Int(v); ok && x >= math.MinInt32 && x <= math.MaxInt32 {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(x)), nil
+		}
+
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return b.message(fd, v, newMessage)
+	}
+	return protoreflect.Value{}, b.mismatch(fd, v)
+}
+
+func (b *builder) message(fd protoreflect.FieldDescriptor, v interface{}, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	val := newMessage()
+	msg := val.Message()
+
+	if fd.Message().FullName() == timestampMessage {
+		t, ok := value.Time(v)
+		if !ok {
+			return protoreflect.Value{}, b.mismatch(fd, v)
+		}
+		fields := msg.Descriptor().Fields()
+		msg.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(t.Unix()))
+		msg.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(t.Nanosecond())))
+		return val, nil
+	}
+
+	m, ok := value.Map(v)
+	if !ok {
+		return protoreflect.Value{}, b.mismatch(fd, v)
+	}
+	err := b.setFields(msg, func(fd protoreflect.FieldDescriptor) (interface{}, bool) {
+		if v, ok := m[string(fd.Name())]; ok {
+			return v, true
+		}
+		v, ok := m[fd.JSONName()]
+		return v, ok
+	})
+	return val, err
+}
+
+func (b *builder) mapKey(fd protoreflect.FieldDescriptor, key string) (protoreflect.MapKey, error) {
+	var v protoreflect.Value
+	var err error
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		v = protoreflect.ValueOfString(key)
+	case protoreflect.BoolKind:
+		var x bool
+		x, err = strconv.ParseBool(key)
+		v = protoreflect.ValueOfBool(x)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var x int64
+		x, err = strconv.ParseInt(key, 10, 32)
+		v = protoreflect.ValueOfInt32(int32(x))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var x int64
+		x, err = strconv.ParseInt(key, 10, 64)
+		v = protoreflect.ValueOfInt64(x)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var x uint64
+		x, err = strconv.ParseUint(key, 10, 32)
+		v = protoreflect.ValueOfUint32(uint32(x))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var x uint64
+		x, err = strconv.ParseUint(key, 10, 64)
+		v = protoreflect.ValueOfUint64(x)
+	default:
+		return protoreflect.MapKey{}, b.fail("unsupported map key type %v", fd.Kind())
+	}
+	if err != nil {
+		return protoreflect.MapKey{}, b.fail("map key '%s' is not a valid %v", key, fd.Kind())
+	}
+	return v.MapKey(), nil
+}
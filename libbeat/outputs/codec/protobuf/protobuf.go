@@ -0,0 +1,171 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package protobuf implements an output codec that serializes events as
+// Protocol Buffers messages, optionally framed for the Confluent Schema
+// Registry.
+package protobuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/schemaregistry"
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+// Encoder serializes a beat.Event into a protobuf message.
+type Encoder struct {
+	buf      []byte
+	version  string
+	message  protoreflect.MessageDescriptor
+	indexes  []int
+	registry *schemaregistry.Resolver
+}
+
+// Config is used to pass encoding parameters to New.
+type Config struct {
+	// Schema is the path of a file containing a serialized
+	// FileDescriptorSet, as written by `protoc --descriptor_set_out`.
+	Schema string `config:"schema" validate:"required"`
+
+	// Message is the fully qualified name of the message type events are
+	// encoded as.
+	Message string `config:"message" validate:"required"`
+
+	// Registry enables the Confluent wire format. The schema ID is resolved
+	// from the registry.
+	Registry *schemaregistry.Config `config:"schema_registry"`
+}
+
+func init() {
+	codec.RegisterType("protobuf", func(info beat.Info, cfg *config.C) (codec.Codec, error) {
+		if cfg == nil {
+			return nil, errors.New("empty protobuf codec configuration")
+		}
+
+		config := Config{}
+		if cfg.HasField("schema_registry") {
+			registry := schemaregistry.DefaultConfig()
+			config.Registry = &registry
+		}
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+
+		return New(info.Version, config)
+	})
+}
+
+// New creates a new protobuf Encoder, loading the message type from the
+// configured descriptor set.
+func New(version string, config Config) (*Encoder, error) {
+	data, err := os.ReadFile(config.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protobuf descriptor set: %w", err)
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse protobuf descriptor set %s: %w", config.Schema, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protobuf descriptor set %s: %w", config.Schema, err)
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(config.Message))
+	if err != nil {
+		return nil, fmt.Errorf("message '%s' not found in %s: %w", config.Message, config.Schema, err)
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("'%s' in %s is not a message", config.Message, config.Schema)
+	}
+
+	e := &Encoder{version: version, message: md, indexes: messageIndexes(md)}
+	if config.Registry != nil {
+		e.registry, err = schemaregistry.NewResolver(*config.Registry, "PROTOBUF", "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Connect resolves the schema ID if the schema registry is configured.
+func (e *Encoder) Connect(ctx context.Context) error {
+	if e.registry == nil {
+		return nil
+	}
+	return e.registry.Resolve(ctx)
+}
+
+// Encode serializes a beat event. An error is returned if the event does not
+// fit the message type; the error names the offending field.
+func (e *Encoder) Encode(index string, event *beat.Event) ([]byte, error) {
+	e.buf = e.buf[:0]
+
+	if e.registry != nil {
+		id, err := e.registry.ID()
+		if err != nil {
+			return nil, err
+		}
+		e.buf = schemaregistry.AppendHeader(e.buf, id)
+		e.buf = schemaregistry.AppendMessageIndexes(e.buf, e.indexes)
+	}
+
+	msg := dynamicpb.NewMessage(e.message)
+	doc := codec.MakeDocument(index, e.version, event)
+	b := builder{}
+	if err := b.setFields(msg, func(fd protoreflect.FieldDescriptor) (interface{}, bool) {
+		return codec.LookupSchemaField(doc, string(fd.Name()))
+	}); err != nil {
+		return nil, err
+	}
+
+	var err error
+	e.buf, err = proto.MarshalOptions{Deterministic: true}.MarshalAppend(e.buf, msg)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf: failed to marshal event: %w", err)
+	}
+	return e.buf, nil
+}
+
+// messageIndexes returns the position of the message type within its file,
+// starting with the index of the top-level message, as required by the
+// Confluent wire format.
+func messageIndexes(md protoreflect.MessageDescriptor) []int {
+	var indexes []int
+	for d := protoreflect.Descriptor(md); ; d = d.Parent() {
+		if _, ok := d.(protoreflect.MessageDescriptor); !ok {
+			break
+		}
+		indexes = append([]int{d.Index()}, indexes...)
+	}
+	return indexes
+}
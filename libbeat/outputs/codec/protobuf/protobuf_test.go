@@ -0,0 +1,265 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package protobuf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/schemaregistry"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var testTime = time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+
+func testFile() *descriptorpb.FileDescriptorProto {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  label.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("event.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Other"),
+			},
+			{
+				Name: proto.String("Event"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("timestamp", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".google.protobuf.Timestamp"),
+					field("message", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+					field("count", 3, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+					field("level", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM, optional, ".test.Level"),
+					field("tags", 5, descriptorpb.FieldDescriptorProto_TYPE_STRING, repeated, ""),
+					field("host", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".test.Event.Host"),
+					field("labels", 7, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".test.Event.LabelsEntry"),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("Host"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+						},
+					},
+					{
+						Name: proto.String("LabelsEntry"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+							field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+						},
+						Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+					},
+				},
+			},
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{
+			{
+				Name: proto.String("Level"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{
+					{Name: proto.String("INFO"), Number: proto.Int32(0)},
+					{Name: proto.String("WARN"), Number: proto.Int32(1)},
+				},
+			},
+		},
+	}
+}
+
+func writeDescriptorSet(t *testing.T) string {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto),
+			testFile(),
+		},
+	}
+	data, err := proto.Marshal(set)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "event.desc")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func testEvent() *beat.Event {
+	return &beat.Event{
+		Timestamp: testTime,
+		Fields: mapstr.M{
+			"message": "hello",
+			"count":   uint8(3),
+			"level":   "WARN",
+			"tags":    []string{"a", "b"},
+			"host":    mapstr.M{"name": "web-1"},
+			"labels":  map[string]interface{}{"env": "prod"},
+			"unknown": "ignored",
+		},
+	}
+}
+
+func decode(t *testing.T, enc *Encoder, data []byte) protoreflect.Message {
+	msg := dynamicpb.NewMessage(enc.message)
+	require.NoError(t, proto.Unmarshal(data, msg))
+	return msg
+}
+
+func TestEncode(t *testing.T) {
+	enc, err := New("1.2.3", Config{Schema: writeDescriptorSet(t), Message: "test.Event"})
+	require.NoError(t, err)
+
+	out, err := enc.Encode("test", testEvent())
+	require.NoError(t, err)
+
+	msg := decode(t, enc, out)
+	fields := msg.Descriptor().Fields()
+	get := func(name string) protoreflect.Value {
+		return msg.Get(fields.ByName(protoreflect.Name(name)))
+	}
+
+	ts := get("timestamp").Message()
+	assert.Equal(t, testTime.Unix(), ts.Get(ts.Descriptor().Fields().ByName("seconds")).Int())
+	assert.Equal(t, int64(500), ts.Get(ts.Descriptor().Fields().ByName("nanos")).Int())
+	assert.Equal(t, "hello", get("message").String())
+	assert.Equal(t, int64(3), get("count").Int())
+	assert.Equal(t, protoreflect.EnumNumber(1), get("level").Enum())
+
+	tags := get("tags").List()
+	require.Equal(t, 2, tags.Len())
+	assert.Equal(t, "b", tags.Get(1).String())
+
+	host := get("host").Message()
+	assert.Equal(t, "web-1", host.Get(host.Descriptor().Fields().ByName("name")).String())
+
+	labels := get("labels").Map()
+	assert.Equal(t, "prod", labels.Get(protoreflect.ValueOfString("env").MapKey()).String())
+}
+
+func TestEncodeFieldErrors(t *testing.T) {
+	enc, err := New("1.2.3", Config{Schema: writeDescriptorSet(t), Message: "test.Event"})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		update mapstr.M
+		field  string
+	}{
+		"wrong type":     {update: mapstr.M{"count": "three"}, field: "count"},
+		"int overflow":   {update: mapstr.M{"count": int64(1) << 40}, field: "count"},
+		"unknown enum":   {update: mapstr.M{"level": "DEBUG"}, field: "level"},
+		"nested field":   {update: mapstr.M{"host.name": 42}, field: "host.name"},
+		"not a message":  {update: mapstr.M{"host": "web-1"}, field: "host"},
+		"list item":      {update: mapstr.M{"tags": []interface{}{"a", 1}}, field: "tags.1"},
+		"not a list":     {update: mapstr.M{"tags": "a"}, field: "tags"},
+		"map value":      {update: mapstr.M{"labels.env": true}, field: "labels.env"},
+		"bad timestamp":  {update: mapstr.M{"timestamp": "yesterday"}, field: "timestamp"},
+		"fractional int": {update: mapstr.M{"count": 1.5}, field: "count"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			event := testEvent()
+			for k, v := range test.update {
+				_, err := event.Fields.Put(k, v)
+				require.NoError(t, err)
+			}
+
+			_, err := enc.Encode("test", event)
+			require.Error(t, err)
+
+			var fieldErr *FieldError
+			require.True(t, errors.As(err, &fieldErr), "unexpected error type %T: %v", err, err)
+			assert.Equal(t, test.field, fieldErr.Field)
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	path := writeDescriptorSet(t)
+
+	_, err := New("1.2.3", Config{Schema: path, Message: "test.Missing"})
+	assert.Error(t, err)
+
+	_, err = New("1.2.3", Config{Schema: path, Message: "test.Level"})
+	assert.Error(t, err)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.desc")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a descriptor"), 0o600))
+	_, err = New("1.2.3", Config{Schema: invalid, Message: "test.Event"})
+	assert.Error(t, err)
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/subjects/events-value/versions/latest", r.URL.Path)
+		_, _ = w.Write([]byte(`{"subject": "events-value", "id": 7, "version": 3}`))
+	}))
+	defer server.Close()
+
+	registry := schemaregistry.DefaultConfig()
+	registry.URL = server.URL
+	registry.Subject = "events-value"
+
+	tests := map[string]struct {
+		message string
+		header  []byte
+	}{
+		"first message":  {message: "test.Other", header: []byte{0, 0, 0, 0, 7, 0}},
+		"second message": {message: "test.Event", header: []byte{0, 0, 0, 0, 7, 2, 2}},
+		"nested message": {message: "test.Event.Host", header: []byte{0, 0, 0, 0, 7, 4, 2, 0}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			enc, err := New("1.2.3", Config{Schema: writeDescriptorSet(t), Message: test.message, Registry: &registry})
+			require.NoError(t, err)
+			require.NoError(t, enc.Connect(context.Background()))
+
+			out, err := enc.Encode("test", &beat.Event{Fields: mapstr.M{}})
+			require.NoError(t, err)
+			require.True(t, len(out) >= len(test.header))
+			assert.Equal(t, test.header, out[:len(test.header)])
+		})
+	}
+}
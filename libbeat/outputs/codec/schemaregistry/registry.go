@@ -0,0 +1,215 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package schemaregistry implements the parts of the Confluent Schema
+// Registry protocol used by the binary output codecs: resolving the ID of a
+// schema and framing encoded payloads in the Confluent wire format.
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+// magicByte is the first byte of every message framed in the Confluent wire
+// format.
+const magicByte = 0
+
+// contentType is the media type used by the Schema Registry REST API.
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// Config configures access to a Confluent Schema Registry.
+type Config struct {
+	URL      string `config:"url" validate:"required"`
+	Subject  string `config:"subject"`
+	SchemaID int    `config:"schema_id" validate:"min=0"`
+	Username string `config:"username"`
+	Password string `config:"password"`
+
+	Transport httpcommon.HTTPTransportSettings `config:",inline"`
+}
+
+// DefaultConfig returns the default Schema Registry configuration.
+func DefaultConfig() Config {
+	return Config{
+		Transport: httpcommon.DefaultHTTPTransportSettings(),
+	}
+}
+
+// Validate checks that the schema can be resolved from the configuration.
+func (c *Config) Validate() error {
+	if c.SchemaID == 0 && c.Subject == "" {
+		return errors.New("either subject or schema_id must be configured for the schema registry")
+	}
+	if _, err := url.Parse(c.URL); err != nil {
+		return fmt.Errorf("invalid schema registry url: %w", err)
+	}
+	return nil
+}
+
+// ErrUnresolved is returned by Resolver.ID until the schema ID was resolved.
+var ErrUnresolved = errors.New("schema ID has not been resolved from the schema registry")
+
+// Resolver resolves and caches the ID the Schema Registry assigned to a
+// schema. The ID is resolved once by Resolve, when the output connects, so
+// encoding events never waits for the registry.
+type Resolver struct {
+	config Config
+	schema string
+	kind   string
+	client *http.Client
+
+	mu sync.Mutex
+	id int
+}
+
+// NewResolver creates a Resolver. If schema is not empty, the ID is resolved
+// by looking the schema up under the configured subject, otherwise the ID of
+// the latest version of the subject is used. kind is the registry schema type
+// (AVRO, PROTOBUF) sent along with the lookup.
+func NewResolver(config Config, kind, schema string) (*Resolver, error) {
+	client, err := config.Transport.Client()
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{
+		config: config,
+		schema: schema,
+		kind:   kind,
+		client: client,
+		id:     config.SchemaID,
+	}, nil
+}
+
+// Resolve queries the registry for the schema ID, unless it is already
+// known. A failed lookup is retried on the next call.
+func (r *Resolver) Resolve(ctx context.Context) error {
+	if _, err := r.ID(); err == nil {
+		return nil
+	}
+
+	id, err := r.lookup(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to resolve schema ID for subject '%s': %w", r.config.Subject, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.id = id
+	return nil
+}
+
+// ID returns the schema ID, or ErrUnresolved if Resolve did not succeed yet.
+func (r *Resolver) ID() (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.id == 0 {
+		return 0, ErrUnresolved
+	}
+	return r.id, nil
+}
+
+func (r *Resolver) lookup(ctx context.Context) (int, error) {
+	subject := url.PathEscape(r.config.Subject)
+	base := strings.TrimSuffix(r.config.URL, "/")
+
+	var req *http.Request
+	var err error
+	if r.schema != "" {
+		body, _ := json.Marshal(struct {
+			Schema     string `json:"schema"`
+			SchemaType string `json:"schemaType,omitempty"`
+		}{r.schema, r.kind})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, base+"/subjects/"+subject, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, base+"/subjects/"+subject+"/versions/latest", nil)
+	}
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", contentType)
+	if r.config.Username != "" || r.config.Password != "" {
+		req.SetBasicAuth(r.config.Username, r.config.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		ID      int    `json:"id"`
+		Code    int    `json:"error_code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("invalid schema registry response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if result.Message != "" {
+			return 0, fmt.Errorf("schema registry returned %v: %s (error code %d)", resp.Status, result.Message, result.Code)
+		}
+		return 0, fmt.Errorf("schema registry returned %v", resp.Status)
+	}
+	if result.ID <= 0 {
+		return 0, errors.New("schema registry response did not contain a schema ID")
+	}
+	return result.ID, nil
+}
+
+// AppendHeader appends the Confluent wire format header, a zero magic byte
+// followed by the schema ID as 4-byte big-endian integer, to buf.
+func AppendHeader(buf []byte, id int) []byte {
+	buf = append(buf, magicByte)
+	return binary.BigEndian.AppendUint32(buf, uint32(id))
+}
+
+// AppendMessageIndexes appends the protobuf message index list that follows
+// the header in the Confluent wire format. The indexes locate the message
+// type within the schema file, the common case of the first message being
+// encoded as a single zero byte.
+func AppendMessageIndexes(buf []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(buf, 0)
+	}
+	buf = binary.AppendVarint(buf, int64(len(indexes)))
+	for _, idx := range indexes {
+		buf = binary.AppendVarint(buf, int64(idx))
+	}
+	return buf
+}
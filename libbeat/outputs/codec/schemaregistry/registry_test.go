@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schemaregistry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver(t *testing.T) {
+	t.Run("configured schema ID", func(t *testing.T) {
+		config := DefaultConfig()
+		config.URL = "http://localhost:1"
+		config.SchemaID = 12
+
+		r, err := NewResolver(config, "AVRO", "{}")
+		require.NoError(t, err)
+		require.NoError(t, r.Resolve(context.Background()))
+		id, err := r.ID()
+		require.NoError(t, err)
+		assert.Equal(t, 12, id)
+	})
+
+	t.Run("registry error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, "user", user)
+			assert.Equal(t, "secret", pass)
+
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code": 40401, "message": "Subject 'events' not found."}`))
+		}))
+		defer server.Close()
+
+		config := DefaultConfig()
+		config.URL = server.URL
+		config.Subject = "events"
+		config.Username = "user"
+		config.Password = "secret"
+
+		r, err := NewResolver(config, "PROTOBUF", "")
+		require.NoError(t, err)
+		err = r.Resolve(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Subject 'events' not found.")
+
+		_, err = r.ID()
+		assert.ErrorIs(t, err, ErrUnresolved)
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := DefaultConfig()
+	config.URL = "http://localhost:8081"
+	assert.Error(t, config.Validate())
+
+	config.Subject = "events-value"
+	assert.NoError(t, config.Validate())
+}
+
+func TestAppendMessageIndexes(t *testing.T) {
+	assert.Equal(t, []byte{0}, AppendMessageIndexes(nil, []int{0}))
+	assert.Equal(t, []byte{2, 2}, AppendMessageIndexes(nil, []int{1}))
+	assert.Equal(t, []byte{4, 2, 6}, AppendMessageIndexes(nil, []int{1, 3}))
+}
+
+func TestAppendHeader(t *testing.T) {
+	assert.Equal(t, []byte{0, 0, 0, 1, 2}, AppendHeader(nil, 258))
+}
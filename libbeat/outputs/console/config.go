@@ -18,6 +18,8 @@
 package console
 
 import (
+	"time"
+
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
 	"github.com/elastic/elastic-agent-libs/config"
 )
@@ -29,7 +31,20 @@ type Config struct {
 	Pretty bool `config:"pretty"`
 
 	BatchSize int
+	Backoff   Backoff          `config:"backoff"`
 	Queue     config.Namespace `config:"queue"`
 }
 
-var defaultConfig = Config{}
+// Backoff configures how long to wait before connecting again after the codec
+// failed to connect, e.g. to a schema registry.
+type Backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+var defaultConfig = Config{
+	Backoff: Backoff{
+		Init: 1 * time.Second,
+		Max:  60 * time.Second,
+	},
+}
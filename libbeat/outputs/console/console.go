@@ -85,7 +85,8 @@ func makeConsole(
 		}
 	}
 
-	return outputs.Success(config.Queue, config.BatchSize, 0, nil, c)
+	client := outputs.WithBackoff(c, config.Backoff.Init, config.Backoff.Max)
+	return outputs.Success(config.Queue, config.BatchSize, 0, nil, client)
 }
 
 func newConsole(index string, observer outputs.Observer, codec codec.Codec, logger *logp.Logger) (*console, error) {
@@ -95,6 +96,14 @@ func newConsole(index string, observer outputs.Observer, codec codec.Codec, logg
 }
 
 func (c *console) Close() error { return nil }
+
+// Connect prepares the codec before events are written to stdout. It only
+// fails when the codec cannot be prepared, e.g. when the schema registry
+// cannot be reached, and is then retried after the configured backoff.
+func (c *console) Connect(ctx context.Context) error {
+	return codec.Connect(ctx, c.codec)
+}
+
 func (c *console) Publish(_ context.Context, batch publisher.Batch) error {
 	st := c.observer
 	events := batch.Events()
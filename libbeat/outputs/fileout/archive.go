@@ -0,0 +1,530 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fileout
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+var errRotatorClosed = errors.New("file rotator is closed")
+
+var formatExpression = regexp.MustCompile(`%\{[^}]*\}`)
+
+// archiveRotator writes events to an active file and, on rotation, renames
+// it using a naming template and optionally compresses it. Files are rotated
+// when the wall clock enters a new hour or day, when the size limit is
+// reached, and on startup if configured. Compression and the removal of old
+// files run in a background worker so publishing is not blocked.
+type archiveRotator struct {
+	log         *logp.Logger
+	active      string
+	template    *PathFormatString
+	pattern     *regexp.Regexp
+	interval    time.Duration
+	maxSize     uint
+	maxBackups  uint
+	permissions os.FileMode
+	compression string
+	now         func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   uint
+	period time.Time
+	closed bool
+
+	rotated chan string
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+type archiveSettings struct {
+	dir             string
+	filename        string
+	template        *PathFormatString
+	interval        time.Duration
+	maxSize         uint
+	maxBackups      uint
+	permissions     os.FileMode
+	compression     string
+	rotateOnStartup bool
+	now             func() time.Time
+}
+
+func newArchiveRotator(log *logp.Logger, settings archiveSettings) (*archiveRotator, error) {
+	template := settings.template
+	if template == nil {
+		template = &PathFormatString{}
+		if err := template.Unpack(defaultRotatedFilename(settings.filename, settings.interval)); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &archiveRotator{
+		log:         log,
+		active:      filepath.Join(settings.dir, settings.filename+".ndjson"),
+		template:    template,
+		pattern:     rotatedFilePattern(template.raw),
+		interval:    settings.interval,
+		maxSize:     settings.maxSize,
+		maxBackups:  settings.maxBackups,
+		permissions: settings.permissions,
+		compression: settings.compression,
+		now:         settings.now,
+		rotated:     make(chan string, 16),
+		done:        make(chan struct{}),
+	}
+	if r.compression == compressionNone {
+		r.compression = ""
+	}
+	if r.now == nil {
+		r.now = time.Now
+	}
+
+	if err := os.MkdirAll(settings.dir, dirMode(settings.permissions)); err != nil {
+		return nil, fmt.Errorf("failed to create directory for file output: %w", err)
+	}
+
+	r.wg.Add(1)
+	go r.worker()
+	if err := r.recover(settings.rotateOnStartup); err != nil {
+		close(r.rotated)
+		r.wg.Wait()
+		return nil, err
+	}
+
+	if r.interval > 0 {
+		r.wg.Add(1)
+		go r.scheduler()
+	}
+	return r, nil
+}
+
+// defaultRotatedFilename returns the naming template used if none is
+// configured. The timestamp resolution follows the rotation interval.
+func defaultRotatedFilename(filename string, interval time.Duration) string {
+	switch interval {
+	case time.Hour:
+		return filename + "-%{+yyyyMMddHH}.ndjson"
+	case 24 * time.Hour:
+		return filename + "-%{+yyyyMMdd}.ndjson"
+	}
+	return filename + "-%{+yyyyMMddHHmmss}.ndjson"
+}
+
+// rotatedFilePattern builds a regular expression matching all file names the
+// template can produce, including the index added to resolve name conflicts
+// and the compression extension.
+func rotatedFilePattern(template string) *regexp.Regexp {
+	base, ext := template, filepath.Ext(template)
+	if strings.ContainsAny(ext, "%{}") {
+		ext = ""
+	}
+	base = strings.TrimSuffix(base, ext)
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	last := 0
+	for _, loc := range formatExpression.FindAllStringIndex(base, -1) {
+		sb.WriteString(regexp.QuoteMeta(base[last:loc[0]]))
+		sb.WriteString(".+?")
+		last = loc[1]
+	}
+	sb.WriteString(regexp.QuoteMeta(base[last:]))
+	sb.WriteString(`(-\d+)?`)
+	sb.WriteString(regexp.QuoteMeta(ext))
+	sb.WriteString(`(\.gz|\.zst)?$`)
+	return regexp.MustCompile(sb.String())
+}
+
+// recover prepares the active file on startup. An existing active file is
+// rotated if configured to, or if it holds events of a previous period.
+// Rotated files left uncompressed by a previous run are queued for
+// compression.
+func (r *archiveRotator) recover(rotateOnStartup bool) error {
+	if r.compression != "" {
+		for _, name := range r.rotatedFiles() {
+			if filepath.Ext(name) != compressionExtension(r.compression) {
+				r.rotated <- name
+			}
+		}
+	}
+
+	info, err := os.Lstat(r.active)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat active file: %w", err)
+	}
+	if info.Size() == 0 && info.Mode().IsRegular() {
+		return nil
+	}
+
+	r.size = uint(info.Size())
+	r.period = r.periodStart(info.ModTime())
+	if rotateOnStartup || !info.Mode().IsRegular() || r.period != r.periodStart(r.now()) {
+		return r.rotate()
+	}
+
+	r.file, err = os.OpenFile(r.active, os.O_WRONLY|os.O_APPEND, r.permissions)
+	if err != nil {
+		return fmt.Errorf("failed to append to existing file: %w", err)
+	}
+	return nil
+}
+
+// Write writes the given bytes to the active file, rotating it first if its
+// period has ended or the write would exceed the size limit.
+func (r *archiveRotator) Write(data []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, errRotatorClosed
+	}
+
+	dataLen := uint(len(data))
+	if r.maxSize > 0 && dataLen > r.maxSize {
+		return 0, fmt.Errorf("data size (%d bytes) is greater than "+
+			"the max file size (%d bytes)", dataLen, r.maxSize)
+	}
+
+	now := r.now()
+	if r.size > 0 && (r.periodEnded(now) || (r.maxSize > 0 && r.size+dataLen > r.maxSize)) {
+		if err := r.rotate(); err != nil {
+			return 0, fmt.Errorf("error rotating file: %w", err)
+		}
+	}
+
+	if r.file == nil {
+		var err error
+		r.file, err = os.OpenFile(r.active, os.O_CREATE|os.O_WRONLY|os.O_APPEND, r.permissions)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open file '%s': %w", r.active, err)
+		}
+	}
+	if r.size == 0 {
+		r.period = r.periodStart(now)
+	}
+
+	n, err := r.file.Write(data)
+	r.size += uint(n)
+	if err != nil {
+		return n, fmt.Errorf("failed to write to file: %w", err)
+	}
+	return n, nil
+}
+
+// Close closes the active file and waits for pending compressions. The
+// active file is kept and picked up again on the next start.
+func (r *archiveRotator) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	err := r.closeFile()
+	close(r.done)
+	close(r.rotated)
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return err
+}
+
+// rotate renames the active file and queues it for compression. It must be
+// called with r.mu held.
+func (r *archiveRotator) rotate() error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+
+	name, err := r.rotatedName()
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(r.active, name); err != nil {
+		return fmt.Errorf("failed to rotate file: %w", err)
+	}
+	r.log.Debugw("Rotated file", "filename", name)
+
+	r.size = 0
+	r.rotated <- name
+	return nil
+}
+
+// rotatedName expands the naming template with the start of the active
+// file's period. If the name is taken an index is added before the
+// extension.
+func (r *archiveRotator) rotatedName() (string, error) {
+	name, err := r.template.Run(r.period.UTC())
+	if err != nil {
+		return "", fmt.Errorf("failed to expand rotated_filename: %w", err)
+	}
+
+	dir := filepath.Dir(r.active)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := filepath.Join(dir, name)
+	for i := 1; r.exists(candidate); i++ {
+		candidate = filepath.Join(dir, base+"-"+strconv.Itoa(i)+ext)
+	}
+	return candidate, nil
+}
+
+func (r *archiveRotator) exists(name string) bool {
+	for _, n := range []string{name, name + ".gz", name + ".zst"} {
+		if _, err := os.Lstat(n); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *archiveRotator) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close active file: %w", err)
+	}
+	return nil
+}
+
+// periodStart returns the start of the rotation period t falls into. Periods
+// are aligned to the wall clock in UTC.
+func (r *archiveRotator) periodStart(t time.Time) time.Time {
+	if r.interval == 0 {
+		return t.Truncate(time.Second)
+	}
+	return t.UTC().Truncate(r.interval)
+}
+
+func (r *archiveRotator) periodEnded(now time.Time) bool {
+	return r.interval > 0 && !r.periodStart(now).Equal(r.period)
+}
+
+// scheduler rotates the active file when its period ends, so files are
+// archived on time even if no further events are written.
+func (r *archiveRotator) scheduler() {
+	defer r.wg.Done()
+
+	for {
+		now := r.now()
+		next := r.periodStart(now).Add(r.interval)
+		timer := time.NewTimer(next.Sub(now))
+
+		select {
+		case <-r.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		r.tick()
+	}
+}
+
+func (r *archiveRotator) tick() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed || r.size == 0 || !r.periodEnded(r.now()) {
+		return
+	}
+	if err := r.rotate(); err != nil {
+		r.log.Errorf("Failed to rotate file at end of period: %v", err)
+	}
+}
+
+// worker compresses rotated files and removes the oldest files beyond the
+// configured number of files.
+func (r *archiveRotator) worker() {
+	defer r.wg.Done()
+
+	for name := range r.rotated {
+		if r.compression != "" {
+			if err := compressFile(name, r.compression, r.permissions); err != nil {
+				r.log.Errorf("Failed to compress rotated file %s: %v", name, err)
+			}
+		}
+		if err := r.purge(); err != nil {
+			r.log.Errorf("Failed to remove old rotated files: %v", err)
+		}
+	}
+}
+
+// rotatedFiles returns the rotated files, oldest first.
+func (r *archiveRotator) rotatedFiles() []string {
+	dir := filepath.Dir(r.active)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		r.log.Debugf("Failed to list rotated files: %v", err)
+		return nil
+	}
+
+	type rotatedFile struct {
+		name    string
+		modTime time.Time
+	}
+	var files []rotatedFile
+	activeName := filepath.Base(r.active)
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == activeName || !r.pattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{filepath.Join(dir, entry.Name()), info.ModTime()})
+	}
+
+	// Files rotated in quick succession can share a modification time. Fall
+	// back to the name, shorter first so a name without conflict index sorts
+	// before the ones with an index.
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		switch {
+		case !a.modTime.Equal(b.modTime):
+			return a.modTime.Before(b.modTime)
+		case len(a.name) != len(b.name):
+			return len(a.name) < len(b.name)
+		}
+		return a.name < b.name
+	})
+
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.name
+	}
+	return names
+}
+
+func (r *archiveRotator) purge() error {
+	files := r.rotatedFiles()
+	if uint(len(files)) <= r.maxBackups {
+		return nil
+	}
+	for _, name := range files[:uint(len(files))-r.maxBackups] {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete %v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func compressionExtension(compression string) string {
+	switch compression {
+	case compressionGzip:
+		return ".gz"
+	case compressionZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// compressFile compresses name into a file with the compression extension
+// appended and removes the original. The modification time is preserved so
+// rotated files keep their order.
+func compressFile(name, compression string, perm os.FileMode) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	target := name + compressionExtension(compression)
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	err = compressTo(dst, src, compression)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(target)
+		return err
+	}
+
+	if err := os.Chtimes(target, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
+
+func compressTo(dst io.Writer, src io.Reader, compression string) error {
+	var w io.WriteCloser
+	switch compression {
+	case compressionGzip:
+		w = gzip.NewWriter(dst)
+	case compressionZstd:
+		enc, err := zstd.NewWriter(dst)
+		if err != nil {
+			return err
+		}
+		w = enc
+	default:
+		return fmt.Errorf("unknown compression '%s'", compression)
+	}
+
+	if _, err := io.Copy(w, src); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// dirMode derives the mode of created directories from the file permissions.
+func dirMode(perm os.FileMode) os.FileMode {
+	mode := os.FileMode(0o700)
+	if perm&0o070 > 0 {
+		mode |= 0o050
+	}
+	if perm&0o007 > 0 {
+		mode |= 0o005
+	}
+	return mode
+}
@@ -0,0 +1,295 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package fileout
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func newTestArchiveRotator(t *testing.T, clock *testClock, settings archiveSettings) *archiveRotator {
+	t.Helper()
+
+	if settings.dir == "" {
+		settings.dir = t.TempDir()
+	}
+	if settings.filename == "" {
+		settings.filename = "out"
+	}
+	if settings.maxBackups == 0 {
+		settings.maxBackups = 7
+	}
+	if settings.permissions == 0 {
+		settings.permissions = 0o600
+	}
+
+	settings.now = clock.Now
+
+	r, err := newArchiveRotator(logptest.NewTestingLogger(t, ""), settings)
+	require.NoError(t, err)
+	return r
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+
+	f, err := os.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var r io.Reader = f
+	switch filepath.Ext(name) {
+	case ".gz":
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		r = gz
+	case ".zst":
+		dec, err := zstd.NewReader(f)
+		require.NoError(t, err)
+		defer dec.Close()
+		r = dec
+	}
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestArchiveRotatorInterval(t *testing.T) {
+	tests := map[string]struct {
+		interval time.Duration
+		later    time.Time
+		rotated  string
+	}{
+		"hourly": {
+			interval: time.Hour,
+			later:    time.Date(2024, 5, 1, 11, 5, 0, 0, time.UTC),
+			rotated:  "out-2024050110.ndjson",
+		},
+		"daily": {
+			interval: 24 * time.Hour,
+			later:    time.Date(2024, 5, 2, 0, 0, 1, 0, time.UTC),
+			rotated:  "out-20240501.ndjson",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clock := &testClock{now: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)}
+			dir := t.TempDir()
+			r := newTestArchiveRotator(t, clock, archiveSettings{dir: dir, interval: test.interval})
+
+			_, err := r.Write([]byte("first\n"))
+			require.NoError(t, err)
+			_, err = r.Write([]byte("second\n"))
+			require.NoError(t, err)
+
+			clock.now = test.later
+			_, err = r.Write([]byte("third\n"))
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+
+			assert.Equal(t, []string{test.rotated, "out.ndjson"}, listFiles(t, dir))
+			assert.Equal(t, "first\nsecond\n", readFile(t, filepath.Join(dir, test.rotated)))
+			assert.Equal(t, "third\n", readFile(t, filepath.Join(dir, "out.ndjson")))
+		})
+	}
+}
+
+func TestArchiveRotatorTick(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	r := newTestArchiveRotator(t, clock, archiveSettings{dir: dir, interval: time.Hour})
+
+	_, err := r.Write([]byte("event\n"))
+	require.NoError(t, err)
+
+	// the period has not ended yet
+	r.tick()
+	assert.Equal(t, []string{"out.ndjson"}, listFiles(t, dir))
+
+	clock.now = clock.now.Add(time.Hour)
+	r.tick()
+	require.NoError(t, r.Close())
+	assert.Equal(t, []string{"out-2024050110.ndjson"}, listFiles(t, dir))
+}
+
+func TestArchiveRotatorCompression(t *testing.T) {
+	for _, compression := range []string{compressionGzip, compressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			clock := &testClock{now: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)}
+			dir := t.TempDir()
+			r := newTestArchiveRotator(t, clock, archiveSettings{
+				dir:         dir,
+				interval:    time.Hour,
+				maxSize:     10,
+				compression: compression,
+			})
+
+			for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n"} {
+				_, err := r.Write([]byte(line))
+				require.NoError(t, err)
+			}
+			require.NoError(t, r.Close())
+
+			ext := compressionExtension(compression)
+			assert.Equal(t, []string{
+				"out-2024050110-1.ndjson" + ext,
+				"out-2024050110.ndjson" + ext,
+				"out.ndjson",
+			}, listFiles(t, dir))
+			assert.Equal(t, "aaaaaaaa\n", readFile(t, filepath.Join(dir, "out-2024050110.ndjson"+ext)))
+			assert.Equal(t, "bbbbbbbb\n", readFile(t, filepath.Join(dir, "out-2024050110-1.ndjson"+ext)))
+		})
+	}
+}
+
+func TestArchiveRotatorPurge(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	r := newTestArchiveRotator(t, clock, archiveSettings{dir: dir, interval: time.Hour, maxBackups: 2})
+
+	for i := 0; i < 5; i++ {
+		_, err := r.Write([]byte("event\n"))
+		require.NoError(t, err)
+		clock.now = clock.now.Add(time.Hour)
+	}
+	require.NoError(t, r.Close())
+
+	assert.Equal(t, []string{
+		"out-2024050112.ndjson",
+		"out-2024050113.ndjson",
+		"out.ndjson",
+	}, listFiles(t, dir))
+}
+
+func TestArchiveRotatorTemplate(t *testing.T) {
+	template := &PathFormatString{}
+	require.NoError(t, template.Unpack("archive-%{+yyyy-MM-dd}.log"))
+
+	clock := &testClock{now: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	r := newTestArchiveRotator(t, clock, archiveSettings{
+		dir:        dir,
+		template:   template,
+		interval:   time.Hour,
+		maxBackups: 1,
+	})
+
+	_, err := r.Write([]byte("event\n"))
+	require.NoError(t, err)
+	clock.now = clock.now.Add(time.Hour)
+	_, err = r.Write([]byte("event\n"))
+	require.NoError(t, err)
+	clock.now = clock.now.Add(time.Hour)
+	_, err = r.Write([]byte("event\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	assert.Equal(t, []string{"archive-2024-05-01-1.log", "out.ndjson"}, listFiles(t, dir))
+}
+
+func TestArchiveRotatorStartup(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)}
+
+	t.Run("append in same period", func(t *testing.T) {
+		dir := t.TempDir()
+		active := filepath.Join(dir, "out.ndjson")
+		require.NoError(t, os.WriteFile(active, []byte("old\n"), 0o600))
+		require.NoError(t, os.Chtimes(active, clock.now, clock.now))
+
+		r := newTestArchiveRotator(t, clock, archiveSettings{dir: dir, interval: time.Hour})
+		_, err := r.Write([]byte("new\n"))
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+
+		assert.Equal(t, "old\nnew\n", readFile(t, active))
+	})
+
+	t.Run("rotate previous period", func(t *testing.T) {
+		dir := t.TempDir()
+		active := filepath.Join(dir, "out.ndjson")
+		previous := clock.now.Add(-2 * time.Hour)
+		require.NoError(t, os.WriteFile(active, []byte("old\n"), 0o600))
+		require.NoError(t, os.Chtimes(active, previous, previous))
+
+		r := newTestArchiveRotator(t, clock, archiveSettings{dir: dir, interval: time.Hour})
+		require.NoError(t, r.Close())
+
+		assert.Equal(t, []string{"out-2024050108.ndjson"}, listFiles(t, dir))
+	})
+
+	t.Run("compress leftovers", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "out-2024050108.ndjson"), []byte("old\n"), 0o600))
+
+		r := newTestArchiveRotator(t, clock, archiveSettings{dir: dir, interval: time.Hour, compression: compressionGzip})
+		require.NoError(t, r.Close())
+
+		assert.Equal(t, []string{"out-2024050108.ndjson.gz"}, listFiles(t, dir))
+		assert.Equal(t, "old\n", readFile(t, filepath.Join(dir, "out-2024050108.ndjson.gz")))
+	})
+}
+
+func TestRotatedFilePattern(t *testing.T) {
+	pattern := rotatedFilePattern("out-%{+yyyyMMddHH}.ndjson")
+	for name, match := range map[string]bool{
+		"out-2024050110.ndjson":       true,
+		"out-2024050110-3.ndjson":     true,
+		"out-2024050110.ndjson.gz":    true,
+		"out-2024050110-1.ndjson.zst": true,
+		"out.ndjson":                  false,
+		"other-2024050110.ndjson":     false,
+		"out-2024050110.ndjson.bak":   false,
+	} {
+		assert.Equal(t, match, pattern.MatchString(name), name)
+	}
+
+	assert.Equal(t, `^a\.b.+?(-\d+)?(\.gz|\.zst)?$`, rotatedFilePattern("a.b%{+yyyy}").String())
+}
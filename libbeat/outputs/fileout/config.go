@@ -18,7 +18,10 @@
 package fileout
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
 	"github.com/elastic/elastic-agent-libs/config"
@@ -33,9 +36,29 @@ type fileOutConfig struct {
 	Codec           codec.Config      `config:"codec"`
 	Permissions     uint32            `config:"permissions"`
 	RotateOnStartup bool              `config:"rotate_on_startup"`
+	RotateInterval  string            `config:"rotate_interval"`
+	Compression     string            `config:"compression"`
+	RotatedFilename *PathFormatString `config:"rotated_filename"`
+	Backoff         backoff           `config:"backoff"`
 	Queue           config.Namespace  `config:"queue"`
 }
 
+// backoff configures how long to wait before opening the output again after
+// the codec failed to connect, e.g. to a schema registry.
+type backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+const (
+	rotateHourly = "hourly"
+	rotateDaily  = "daily"
+
+	compressionNone = "none"
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
 func defaultConfig() fileOutConfig {
 	return fileOutConfig{
 		Path:            &PathFormatString{},
@@ -43,6 +66,10 @@ func defaultConfig() fileOutConfig {
 		RotateEveryKb:   10 * 1024,
 		Permissions:     0600,
 		RotateOnStartup: true,
+		Backoff: backoff{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
 	}
 }
 
@@ -64,5 +91,48 @@ func (c *fileOutConfig) Validate() error {
 			file.MaxBackupsLimit)
 	}
 
+	switch c.RotateInterval {
+	case "", rotateHourly, rotateDaily:
+	default:
+		return fmt.Errorf("invalid rotate_interval '%s', must be one of %s or %s",
+			c.RotateInterval, rotateHourly, rotateDaily)
+	}
+
+	switch c.Compression {
+	case "", compressionNone, compressionGzip, compressionZstd:
+	default:
+		return fmt.Errorf("invalid compression '%s', must be one of %s, %s or %s",
+			c.Compression, compressionNone, compressionGzip, compressionZstd)
+	}
+
+	if c.RotatedFilename != nil {
+		if c.RotatedFilename.raw == "" {
+			return errors.New("rotated_filename must not be empty")
+		}
+		if strings.ContainsAny(c.RotatedFilename.raw, `/\`) {
+			return errors.New("rotated_filename must be a file name, not a path")
+		}
+	}
+
 	return nil
 }
+
+// archiveMode reports whether the output needs the archive rotator, which
+// supports time based rotation, compression and naming rotated files.
+func (c *fileOutConfig) archiveMode() bool {
+	return c.RotateInterval != "" ||
+		(c.Compression != "" && c.Compression != compressionNone) ||
+		c.RotatedFilename != nil
+}
+
+// interval returns the configured rotation interval, or 0 if files are
+// rotated by size only.
+func (c *fileOutConfig) interval() time.Duration {
+	switch c.RotateInterval {
+	case rotateHourly:
+		return time.Hour
+	case rotateDaily:
+		return 24 * time.Hour
+	}
+	return 0
+}
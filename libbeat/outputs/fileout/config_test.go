@@ -42,6 +42,10 @@ func TestConfig(t *testing.T) {
 					RotateEveryKb:   10 * 1024,
 					Permissions:     0600,
 					RotateOnStartup: true,
+					Backoff: backoff{
+						Init: 1 * time.Second,
+						Max:  60 * time.Second,
+					},
 				}
 
 				assert.Equal(t, expectedConfig, actual)
@@ -91,6 +95,41 @@ func TestConfig(t *testing.T) {
 				assert.Nil(t, err)
 			},
 		},
+		"config with time rotation and compression": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"rotate_interval":  "hourly",
+				"compression":      "zstd",
+				"rotated_filename": "pb-%{+yyyy-MM-dd-HH}.ndjson",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.Nil(t, err)
+				assert.True(t, actual.archiveMode())
+				assert.Equal(t, time.Hour, actual.interval())
+				assert.Equal(t, "zstd", actual.Compression)
+
+				name, runErr := actual.RotatedFilename.Run(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+				assert.Nil(t, runErr)
+				assert.Equal(t, "pb-2024-01-02-03.ndjson", name)
+			},
+		},
+		"invalid rotate_interval": {
+			config: config.MustNewConfigFrom(mapstr.M{"rotate_interval": "weekly"}),
+			assertion: func(t *testing.T, _ *fileOutConfig, err error) {
+				assert.ErrorContains(t, err, "invalid rotate_interval")
+			},
+		},
+		"invalid compression": {
+			config: config.MustNewConfigFrom(mapstr.M{"compression": "lz4"}),
+			assertion: func(t *testing.T, _ *fileOutConfig, err error) {
+				assert.ErrorContains(t, err, "invalid compression")
+			},
+		},
+		"rotated_filename with path": {
+			config: config.MustNewConfigFrom(mapstr.M{"rotated_filename": "archive/%{+yyyy}.ndjson"}),
+			assertion: func(t *testing.T, _ *fileOutConfig, err error) {
+				assert.ErrorContains(t, err, "rotated_filename must be a file name")
+			},
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			isWindowsPath = test.useWindowsPath
@@ -20,6 +20,7 @@ package fileout
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -42,7 +43,7 @@ type fileOutput struct {
 	filePath string
 	beat     beat.Info
 	observer outputs.Observer
-	rotator  *file.Rotator
+	rotator  io.WriteCloser
 	codec    codec.Codec
 }
 
@@ -67,7 +68,8 @@ func makeFileout(
 		return outputs.Fail(err)
 	}
 
-	return outputs.Success(foConfig.Queue, -1, 0, nil, fo)
+	client := outputs.WithBackoff(fo, foConfig.Backoff.Init, foConfig.Backoff.Max)
+	return outputs.Success(foConfig.Queue, -1, 0, nil, client)
 }
 
 func (out *fileOutput) init(beat beat.Info, c fileOutConfig) error {
@@ -85,14 +87,28 @@ func (out *fileOutput) init(beat beat.Info, c fileOutConfig) error {
 	out.filePath = path
 
 	var err error
-	out.rotator, err = file.NewFileRotator(
-		path,
-		file.MaxSizeBytes(c.RotateEveryKb*1024),
-		file.MaxBackups(c.NumberOfFiles),
-		file.Permissions(os.FileMode(c.Permissions)),
-		file.RotateOnStartup(c.RotateOnStartup),
-		file.WithLogger(beat.Logger.Named("rotator").With(logp.Namespace("rotator"))),
-	)
+	if c.archiveMode() {
+		out.rotator, err = newArchiveRotator(beat.Logger.Named("rotator"), archiveSettings{
+			dir:             filepath.Dir(path),
+			filename:        filepath.Base(path),
+			template:        c.RotatedFilename,
+			interval:        c.interval(),
+			maxSize:         c.RotateEveryKb * 1024,
+			maxBackups:      c.NumberOfFiles,
+			permissions:     os.FileMode(c.Permissions),
+			compression:     c.Compression,
+			rotateOnStartup: c.RotateOnStartup,
+		})
+	} else {
+		out.rotator, err = file.NewFileRotator(
+			path,
+			file.MaxSizeBytes(c.RotateEveryKb*1024),
+			file.MaxBackups(c.NumberOfFiles),
+			file.Permissions(os.FileMode(c.Permissions)),
+			file.RotateOnStartup(c.RotateOnStartup),
+			file.WithLogger(beat.Logger.Named("rotator").With(logp.Namespace("rotator"))),
+		)
+	}
 	if err != nil {
 		return err
 	}
@@ -103,8 +119,8 @@ func (out *fileOutput) init(beat beat.Info, c fileOutConfig) error {
 	}
 
 	out.log.Infof("Initialized file output. "+
-		"path=%v max_size_bytes=%v max_backups=%v permissions=%v",
-		path, c.RotateEveryKb*1024, c.NumberOfFiles, os.FileMode(c.Permissions))
+		"path=%v max_size_bytes=%v max_backups=%v permissions=%v rotate_interval=%v compression=%v",
+		path, c.RotateEveryKb*1024, c.NumberOfFiles, os.FileMode(c.Permissions), c.RotateInterval, c.Compression)
 
 	return nil
 }
@@ -114,6 +130,13 @@ func (out *fileOutput) Close() error {
 	return out.rotator.Close()
 }
 
+// Connect prepares the codec before events are written to the file. A codec
+// using a schema registry resolves its schema ID here, so that events are not
+// written until the registry can be reached.
+func (out *fileOutput) Connect(ctx context.Context) error {
+	return codec.Connect(ctx, out.codec)
+}
+
 func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
 	defer batch.ACK()
 
@@ -37,6 +37,7 @@ var isWindowsPath = os.PathSeparator == '\\'
 // the path separator so it is properly interpreted by the fmtstr processor
 type PathFormatString struct {
 	efs *fmtstr.EventFormatString
+	raw string
 }
 
 // Run executes the format string returning a new expanded string or an error
@@ -65,6 +66,7 @@ func (fs *PathFormatString) Unpack(v interface{}) error {
 		path = strings.ReplaceAll(path, "\\", "\\\\")
 	}
 
+	fs.raw = path
 	fs.efs = &fmtstr.EventFormatString{}
 	return fs.efs.Unpack(path)
 }
@@ -129,12 +129,17 @@ func newKafkaClient(
 	return c, nil
 }
 
-func (c *client) Connect(_ context.Context) error {
+func (c *client) Connect(ctx context.Context) error {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 
 	c.log.Debugf("connect: %v", c.hosts)
 
+	if err := codec.Connect(ctx, c.codec); err != nil {
+		c.log.Errorf("Kafka connect fails with: %+v", err)
+		return err
+	}
+
 	// try to connect
 	producer, err := sarama.NewAsyncProducer(c.hosts, &c.config)
 	if err != nil {
@@ -0,0 +1,384 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+const (
+	logsPath    = "/v1/logs"
+	metricsPath = "/v1/metrics"
+
+	protobufContentType = "application/x-protobuf"
+)
+
+// permanentError marks an export failure that must not be retried, because
+// the receiver rejected the payload itself.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func isPermanent(err error) bool {
+	var perr *permanentError
+	return errors.As(err, &perr)
+}
+
+type clientSettings struct {
+	protocol    string
+	endpoint    string
+	tls         *tlscommon.TLSConfig
+	headers     map[string]string
+	compression string
+	timeout     time.Duration
+	metrics     bool
+}
+
+type client struct {
+	log      *logp.Logger
+	observer outputs.Observer
+	beat     beat.Info
+	settings clientSettings
+
+	// gRPC transport
+	conn          *grpc.ClientConn
+	logsClient    plogotlp.GRPCClient
+	metricsClient pmetricotlp.GRPCClient
+
+	// HTTP transport
+	http *http.Client
+}
+
+func newClient(info beat.Info, observer outputs.Observer, settings clientSettings) *client {
+	return &client{
+		log:      info.Logger.Named("otlp"),
+		observer: observer,
+		beat:     info,
+		settings: settings,
+	}
+}
+
+func (c *client) Connect(_ context.Context) error {
+	c.log.Debugf("connect to %s", c.settings.endpoint)
+	switch c.settings.protocol {
+	case protocolHTTP:
+		transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+		if c.settings.tls != nil {
+			transport.TLSClientConfig = c.settings.tls.ToConfig()
+		}
+		c.http = &http.Client{Transport: transport, Timeout: c.settings.timeout}
+		return nil
+
+	default:
+		creds := insecure.NewCredentials()
+		if c.settings.tls != nil {
+			creds = credentials.NewTLS(c.settings.tls.BuildModuleClientConfig(hostname(c.settings.endpoint)))
+		}
+		opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+		if c.settings.compression == "gzip" {
+			opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(grpcgzip.Name)))
+		}
+		conn, err := grpc.NewClient(c.settings.endpoint, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to create OTLP gRPC connection to %s: %w", c.settings.endpoint, err)
+		}
+		c.conn = conn
+		c.logsClient = plogotlp.NewGRPCClient(conn)
+		c.metricsClient = pmetricotlp.NewGRPCClient(conn)
+		return nil
+	}
+}
+
+func (c *client) Close() error {
+	c.log.Debug("close connection")
+	if c.http != nil {
+		c.http.CloseIdleConnections()
+		c.http = nil
+	}
+	if c.conn != nil {
+		err := c.conn.Close()
+		c.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (c *client) String() string {
+	return "otlp/" + c.settings.protocol + "(" + c.settings.endpoint + ")"
+}
+
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+
+	logs, records := newLogs(c.beat)
+	metrics, metricSlice := newMetrics(c.beat)
+	var logEvents, metricEvents []publisher.Event
+	dropped := 0
+	for i := range events {
+		event := &events[i].Content
+		if c.settings.metrics && isMetricEvent(event) && appendMetrics(metricSlice, event) > 0 {
+			metricEvents = append(metricEvents, events[i])
+			continue
+		}
+		if err := appendLogRecord(records, event); err != nil {
+			c.log.Errorf("Dropping event: %v", err)
+			dropped++
+			continue
+		}
+		logEvents = append(logEvents, events[i])
+	}
+
+	var retry []publisher.Event
+	var lastErr error
+	if len(logEvents) > 0 {
+		n, err := c.exportLogs(ctx, plogotlp.NewExportRequestFromLogs(logs))
+		retry, dropped, lastErr = c.handleResult(logEvents, n, err, retry, dropped, lastErr)
+	}
+	if len(metricEvents) > 0 {
+		n, err := c.exportMetrics(ctx, pmetricotlp.NewExportRequestFromMetrics(metrics))
+		retry, dropped, lastErr = c.handleResult(metricEvents, n, err, retry, dropped, lastErr)
+	}
+
+	c.observer.PermanentErrors(dropped)
+	c.observer.AckedEvents(len(events) - len(retry) - dropped)
+	if len(retry) > 0 {
+		c.observer.RetryableErrors(len(retry))
+		batch.RetryEvents(retry)
+		return lastErr
+	}
+	batch.ACK()
+	return nil
+}
+
+// handleResult accounts for the outcome of a single export request covering
+// the given events. Events are scheduled for retry unless the receiver
+// reported a permanent error, in which case they are dropped.
+func (c *client) handleResult(
+	events []publisher.Event,
+	rejected int64,
+	err error,
+	retry []publisher.Event,
+	dropped int,
+	lastErr error,
+) ([]publisher.Event, int, error) {
+	if err == nil {
+		if rejected > 0 {
+			// OTLP partial success responses only report a count, so we
+			// cannot tell which events were rejected. The receiver will
+			// never accept them, so retrying the batch is of no use.
+			c.log.Warnf("OTLP receiver rejected %d of %d records", rejected, len(events))
+			dropped += int(rejected)
+		}
+		return retry, dropped, lastErr
+	}
+
+	if isPermanent(err) {
+		c.log.Errorf("Dropping %d events after permanent OTLP export error: %v", len(events), err)
+		return retry, dropped + len(events), lastErr
+	}
+
+	c.log.Errorf("Failed to export %d events, will retry: %v", len(events), err)
+	return append(retry, events...), dropped, err
+}
+
+func (c *client) exportLogs(ctx context.Context, req plogotlp.ExportRequest) (int64, error) {
+	if c.settings.protocol == protocolHTTP {
+		body, err := req.MarshalProto()
+		if err != nil {
+			return 0, &permanentError{err}
+		}
+		resp := plogotlp.NewExportResponse()
+		if err := c.post(ctx, logsPath, body, resp.UnmarshalProto); err != nil {
+			return 0, err
+		}
+		return resp.PartialSuccess().RejectedLogRecords(), nil
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	resp, err := c.logsClient.Export(ctx, req)
+	if err != nil {
+		return 0, classifyGRPCError(err)
+	}
+	return resp.PartialSuccess().RejectedLogRecords(), nil
+}
+
+func (c *client) exportMetrics(ctx context.Context, req pmetricotlp.ExportRequest) (int64, error) {
+	if c.settings.protocol == protocolHTTP {
+		body, err := req.MarshalProto()
+		if err != nil {
+			return 0, &permanentError{err}
+		}
+		resp := pmetricotlp.NewExportResponse()
+		if err := c.post(ctx, metricsPath, body, resp.UnmarshalProto); err != nil {
+			return 0, err
+		}
+		// Rejected data points can not be mapped back to events, an event
+		// is only counted as dropped if all of its data points were.
+		return 0, nil
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	if _, err := c.metricsClient.Export(ctx, req); err != nil {
+		return 0, classifyGRPCError(err)
+	}
+	return 0, nil
+}
+
+func (c *client) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if len(c.settings.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(c.settings.headers))
+	}
+	if c.settings.timeout > 0 {
+		return context.WithTimeout(ctx, c.settings.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (c *client) post(ctx context.Context, path string, body []byte, decode func([]byte) error) error {
+	if c.http == nil {
+		return errors.New("client is not connected")
+	}
+
+	contentEncoding := ""
+	if c.settings.compression == "gzip" {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(body); err != nil {
+			return &permanentError{err}
+		}
+		if err := w.Close(); err != nil {
+			return &permanentError{err}
+		}
+		body = buf.Bytes()
+		contentEncoding = "gzip"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.settings.endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", protobufContentType)
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	if c.beat.UserAgent != "" {
+		req.Header.Set("User-Agent", c.beat.UserAgent)
+	}
+	for k, v := range c.settings.headers {
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		c.observer.WriteError(err)
+		return err
+	}
+	defer resp.Body.Close()
+	c.observer.WriteBytes(len(body))
+	c.observer.ReportLatency(time.Since(start))
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.observer.ReadError(err)
+		return err
+	}
+	c.observer.ReadBytes(len(respBody))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if len(respBody) > 0 && strings.HasPrefix(resp.Header.Get("Content-Type"), protobufContentType) {
+			if err := decode(respBody); err != nil {
+				c.log.Debugf("failed to decode OTLP response: %v", err)
+			}
+		}
+		return nil
+	}
+
+	err = fmt.Errorf("OTLP receiver responded with %s", resp.Status)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		c.observer.ErrTooMany(1)
+		return err
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return err
+	default:
+		return &permanentError{err}
+	}
+}
+
+// classifyGRPCError wraps gRPC errors that the OTLP specification defines as
+// not retryable into a permanentError.
+func classifyGRPCError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.Canceled,
+		codes.DeadlineExceeded,
+		codes.Aborted,
+		codes.OutOfRange,
+		codes.Unavailable,
+		codes.DataLoss,
+		codes.ResourceExhausted:
+		return err
+	default:
+		return &permanentError{err}
+	}
+}
+
+func hostname(endpoint string) string {
+	host := endpoint
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
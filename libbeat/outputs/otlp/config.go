@@ -0,0 +1,89 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+const (
+	protocolGRPC = "grpc"
+	protocolHTTP = "http"
+)
+
+type otlpConfig struct {
+	Protocol    string            `config:"protocol"`
+	Headers     map[string]string `config:"headers"`
+	Compression string            `config:"compression"`
+	LoadBalance bool              `config:"loadbalance"`
+	Timeout     time.Duration     `config:"timeout"`
+	BulkMaxSize int               `config:"bulk_max_size"`
+	MaxRetries  int               `config:"max_retries"`
+	TLS         *tlscommon.Config `config:"ssl"`
+	Metrics     metricsConfig     `config:"metrics"`
+	Backoff     backoff           `config:"backoff"`
+	Queue       config.Namespace  `config:"queue"`
+}
+
+// metricsConfig controls how metricbeat style events are translated.
+// When enabled, events carrying a `metricset.name` field are exported as
+// OTLP metrics instead of log records.
+type metricsConfig struct {
+	Enabled bool `config:"enabled"`
+}
+
+type backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+func defaultConfig() otlpConfig {
+	return otlpConfig{
+		Protocol:    protocolGRPC,
+		Compression: "gzip",
+		LoadBalance: true,
+		Timeout:     30 * time.Second,
+		BulkMaxSize: 1600,
+		MaxRetries:  3,
+		Metrics:     metricsConfig{Enabled: true},
+		Backoff: backoff{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
+	}
+}
+
+func (c *otlpConfig) Validate() error {
+	switch c.Protocol {
+	case protocolGRPC, protocolHTTP:
+	default:
+		return fmt.Errorf("otlp protocol %q not supported, must be one of %q or %q", c.Protocol, protocolGRPC, protocolHTTP)
+	}
+
+	switch c.Compression {
+	case "", "none", "gzip":
+	default:
+		return fmt.Errorf("otlp compression %q not supported", c.Compression)
+	}
+
+	return nil
+}
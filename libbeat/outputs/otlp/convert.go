@@ -0,0 +1,291 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/otelbeat/otelmap"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	// esDocumentIDAttribute is the attribute key used to store the document ID in the log record.
+	esDocumentIDAttribute = "elasticsearch.document_id"
+
+	scopeName = "github.com/elastic/beats/v7/libbeat/outputs/otlp"
+)
+
+// metricAttributeFields lists the event fields that are copied as attributes
+// to every data point generated from a metricset event, so that series of
+// different hosts and services can be told apart downstream.
+var metricAttributeFields = []string{
+	"host.name",
+	"service.address",
+	"service.type",
+	"metricset.name",
+	"event.dataset",
+	"agent.id",
+}
+
+// setResource fills the resource attributes shared by all records produced
+// by this beat.
+func setResource(res pcommon.Resource, info beat.Info) {
+	attrs := res.Attributes()
+	attrs.PutStr("service.name", info.Beat)
+	if info.Version != "" {
+		attrs.PutStr("service.version", info.Version)
+	}
+	if info.Hostname != "" {
+		attrs.PutStr("host.name", info.Hostname)
+	}
+	if info.ID != uuid.Nil {
+		attrs.PutStr("service.instance.id", info.ID.String())
+	}
+}
+
+// newLogs creates an empty plog.Logs with a single resource and scope that
+// events can be appended to.
+func newLogs(info beat.Info) (plog.Logs, plog.LogRecordSlice) {
+	logs := plog.NewLogs()
+	resourceLogs := logs.ResourceLogs().AppendEmpty()
+	setResource(resourceLogs.Resource(), info)
+	scopeLogs := resourceLogs.ScopeLogs().AppendEmpty()
+	scopeLogs.Scope().SetName(scopeName)
+	scopeLogs.Scope().SetVersion(info.Version)
+	return logs, scopeLogs.LogRecords()
+}
+
+// newMetrics creates an empty pmetric.Metrics with a single resource and
+// scope that metricset events can be appended to.
+func newMetrics(info beat.Info) (pmetric.Metrics, pmetric.MetricSlice) {
+	metrics := pmetric.NewMetrics()
+	resourceMetrics := metrics.ResourceMetrics().AppendEmpty()
+	setResource(resourceMetrics.Resource(), info)
+	scopeMetrics := resourceMetrics.ScopeMetrics().AppendEmpty()
+	scopeMetrics.Scope().SetName(scopeName)
+	scopeMetrics.Scope().SetVersion(info.Version)
+	return metrics, scopeMetrics.Metrics()
+}
+
+// appendLogRecord converts a single beat event into an OTLP log record. All
+// event fields are stored as a map in the record body, so the original
+// document structure is preserved end to end.
+func appendLogRecord(records plog.LogRecordSlice, event *beat.Event) error {
+	record := plog.NewLogRecord()
+
+	if id, ok := event.Meta["_id"].(string); ok {
+		record.Attributes().PutStr(esDocumentIDAttribute, id)
+	}
+
+	// Work on a copy so that a retried event is converted from its
+	// original content and not from an already flattened version.
+	fields := event.Fields.Clone()
+	if fields == nil {
+		fields = mapstr.M{}
+	}
+	fields["@timestamp"] = event.Timestamp
+	record.SetTimestamp(pcommon.NewTimestampFromTime(event.Timestamp))
+
+	observed := record.Timestamp()
+	if created, err := fields.GetValue("event.created"); err == nil {
+		switch created := created.(type) {
+		case time.Time:
+			observed = pcommon.NewTimestampFromTime(created)
+		case common.Time:
+			observed = pcommon.NewTimestampFromTime(time.Time(created))
+		}
+	}
+	record.SetObservedTimestamp(observed)
+
+	if level, err := fields.GetValue("log.level"); err == nil {
+		if level, ok := level.(string); ok {
+			record.SetSeverityText(level)
+			record.SetSeverityNumber(severityNumber(level))
+		}
+	}
+
+	for _, name := range []string{"dataset", "namespace", "type"} {
+		if value, err := fields.GetValue("data_stream." + name); err == nil {
+			if value, ok := value.(string); ok && value != "" {
+				record.Attributes().PutStr("data_stream."+name, value)
+			}
+		}
+	}
+
+	otelmap.ConvertNonPrimitive(fields)
+	if err := record.Body().SetEmptyMap().FromRaw(map[string]any(fields)); err != nil {
+		return fmt.Errorf("failed to convert event to log record: %w", err)
+	}
+	record.MoveTo(records.AppendEmpty())
+	return nil
+}
+
+// severityNumber maps the common textual log levels to the OTLP severity
+// numbers. Unknown levels are reported as unspecified.
+func severityNumber(level string) plog.SeverityNumber {
+	switch strings.ToLower(level) {
+	case "trace":
+		return plog.SeverityNumberTrace
+	case "debug":
+		return plog.SeverityNumberDebug
+	case "info", "information", "informational", "notice":
+		return plog.SeverityNumberInfo
+	case "warn", "warning":
+		return plog.SeverityNumberWarn
+	case "error", "err":
+		return plog.SeverityNumberError
+	case "fatal", "critical", "crit", "alert", "emergency", "emerg":
+		return plog.SeverityNumberFatal
+	default:
+		return plog.SeverityNumberUnspecified
+	}
+}
+
+// isMetricEvent reports whether the event was produced by a metricset and
+// should be translated to OTLP metrics.
+func isMetricEvent(event *beat.Event) bool {
+	name, err := event.Fields.GetValue("metricset.name")
+	if err != nil {
+		return false
+	}
+	_, ok := name.(string)
+	return ok
+}
+
+// appendMetrics converts the numeric fields found under the module namespace
+// of a metricset event into gauge data points. String fields found in the
+// same namespace are attached to the data points as attributes. It returns
+// the number of metrics that were added.
+func appendMetrics(metrics pmetric.MetricSlice, event *beat.Event) int {
+	namespace := metricNamespace(event)
+	if namespace == "" {
+		return 0
+	}
+	root, err := event.Fields.GetValue(namespace)
+	if err != nil {
+		return 0
+	}
+	values, ok := tryToMapStr(root)
+	if !ok {
+		return 0
+	}
+
+	flat := values.Flatten()
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := pcommon.NewMap()
+	for _, field := range metricAttributeFields {
+		if v, err := event.Fields.GetValue(field); err == nil {
+			if s, ok := v.(string); ok {
+				attrs.PutStr(field, s)
+			}
+		}
+	}
+	for _, k := range keys {
+		if s, ok := flat[k].(string); ok {
+			attrs.PutStr(namespace+"."+k, s)
+		}
+	}
+
+	ts := pcommon.NewTimestampFromTime(event.Timestamp)
+	added := 0
+	for _, k := range keys {
+		metric := pmetric.NewMetric()
+		dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+		if !setNumberValue(dp, flat[k]) {
+			continue
+		}
+		dp.SetTimestamp(ts)
+		attrs.CopyTo(dp.Attributes())
+		metric.SetName(namespace + "." + k)
+		metric.MoveTo(metrics.AppendEmpty())
+		added++
+	}
+	return added
+}
+
+// metricNamespace returns the top level field holding the metricset values,
+// which by convention is named after the module.
+func metricNamespace(event *beat.Event) string {
+	for _, field := range []string{"event.module", "service.type"} {
+		if v, err := event.Fields.GetValue(field); err == nil {
+			if s, ok := v.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func setNumberValue(dp pmetric.NumberDataPoint, v interface{}) bool {
+	switch v := v.(type) {
+	case int:
+		dp.SetIntValue(int64(v))
+	case int8:
+		dp.SetIntValue(int64(v))
+	case int16:
+		dp.SetIntValue(int64(v))
+	case int32:
+		dp.SetIntValue(int64(v))
+	case int64:
+		dp.SetIntValue(v)
+	case uint:
+		dp.SetIntValue(int64(v))
+	case uint8:
+		dp.SetIntValue(int64(v))
+	case uint16:
+		dp.SetIntValue(int64(v))
+	case uint32:
+		dp.SetIntValue(int64(v))
+	case uint64:
+		dp.SetIntValue(int64(v))
+	case float32:
+		dp.SetDoubleValue(float64(v))
+	case float64:
+		dp.SetDoubleValue(v)
+	default:
+		return false
+	}
+	return true
+}
+
+func tryToMapStr(v interface{}) (mapstr.M, bool) {
+	switch m := v.(type) {
+	case mapstr.M:
+		return m, true
+	case map[string]interface{}:
+		return mapstr.M(m), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,105 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestAppendLogRecord(t *testing.T) {
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	event := beat.Event{
+		Timestamp: ts,
+		Meta:      mapstr.M{"_id": "doc-1"},
+		Fields: mapstr.M{
+			"message":     "hello",
+			"data_stream": mapstr.M{"dataset": "app", "namespace": "default", "type": "logs"},
+		},
+	}
+
+	_, records := newLogs(beat.Info{Beat: "testbeat"})
+	require.NoError(t, appendLogRecord(records, &event))
+	require.Equal(t, 1, records.Len())
+
+	record := records.At(0)
+	assert.Equal(t, ts, record.Timestamp().AsTime())
+	id, ok := record.Attributes().Get(esDocumentIDAttribute)
+	require.True(t, ok)
+	assert.Equal(t, "doc-1", id.Str())
+	dataset, ok := record.Attributes().Get("data_stream.dataset")
+	require.True(t, ok)
+	assert.Equal(t, "app", dataset.Str())
+
+	body := record.Body().Map().AsRaw()
+	assert.Equal(t, "hello", body["message"])
+	assert.Equal(t, "2025-01-02T03:04:05.000Z", body["@timestamp"])
+
+	_, isMapstr := event.Fields["data_stream"].(mapstr.M)
+	assert.True(t, isMapstr, "the original event must not be modified")
+}
+
+func TestAppendMetrics(t *testing.T) {
+	event := beat.Event{
+		Timestamp: time.Now(),
+		Fields: mapstr.M{
+			"event":     mapstr.M{"module": "system"},
+			"metricset": mapstr.M{"name": "filesystem"},
+			"host":      mapstr.M{"name": "host-1"},
+			"system": mapstr.M{"filesystem": mapstr.M{
+				"device_name": "/dev/sda1",
+				"total":       int64(100),
+				"used":        mapstr.M{"pct": 0.25},
+			}},
+		},
+	}
+	require.True(t, isMetricEvent(&event))
+
+	_, metrics := newMetrics(beat.Info{})
+	assert.Equal(t, 2, appendMetrics(metrics, &event))
+
+	byName := map[string]pmetric.Metric{}
+	for i := 0; i < metrics.Len(); i++ {
+		byName[metrics.At(i).Name()] = metrics.At(i)
+	}
+
+	total := byName["system.filesystem.total"].Gauge().DataPoints().At(0)
+	assert.Equal(t, int64(100), total.IntValue())
+	device, ok := total.Attributes().Get("system.filesystem.device_name")
+	require.True(t, ok)
+	assert.Equal(t, "/dev/sda1", device.Str())
+	host, ok := total.Attributes().Get("host.name")
+	require.True(t, ok)
+	assert.Equal(t, "host-1", host.Str())
+
+	used := byName["system.filesystem.used.pct"].Gauge().DataPoints().At(0)
+	assert.Equal(t, 0.25, used.DoubleValue())
+}
+
+func TestAppendMetricsWithoutNamespace(t *testing.T) {
+	event := beat.Event{Fields: mapstr.M{"metricset": mapstr.M{"name": "cpu"}}}
+	_, metrics := newMetrics(beat.Info{})
+	assert.Equal(t, 0, appendMetrics(metrics, &event))
+}
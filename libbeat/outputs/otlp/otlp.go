@@ -0,0 +1,123 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package otlp implements an output that ships events to an OpenTelemetry
+// collector, or any other receiver speaking OTLP over gRPC or HTTP.
+package otlp
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+const (
+	defaultGRPCPort = 4317
+	defaultHTTPPort = 4318
+)
+
+func init() {
+	outputs.RegisterType("otlp", makeOTLP)
+}
+
+func makeOTLP(
+	_ outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *config.C,
+) (outputs.Group, error) {
+	oConfig := defaultConfig()
+	if err := cfg.Unpack(&oConfig); err != nil {
+		return outputs.Fail(err)
+	}
+
+	hosts, err := outputs.ReadHostList(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(oConfig.TLS)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	clients := make([]outputs.NetworkClient, len(hosts))
+	for i, host := range hosts {
+		endpoint, hostTLS, err := makeEndpoint(oConfig.Protocol, host, tls)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		client := newClient(beat, observer, clientSettings{
+			protocol:    oConfig.Protocol,
+			endpoint:    endpoint,
+			tls:         hostTLS,
+			headers:     oConfig.Headers,
+			compression: oConfig.Compression,
+			timeout:     oConfig.Timeout,
+			metrics:     oConfig.Metrics.Enabled,
+		})
+		clients[i] = outputs.WithBackoff(client, oConfig.Backoff.Init, oConfig.Backoff.Max)
+	}
+
+	return outputs.SuccessNet(oConfig.Queue, oConfig.LoadBalance, oConfig.BulkMaxSize, oConfig.MaxRetries, nil, clients)
+}
+
+// makeEndpoint normalizes a configured host into the endpoint used by the
+// client. HTTP endpoints are full URLs the signal paths get appended to,
+// while gRPC endpoints are plain host:port targets. An `https` scheme enables
+// TLS with the system defaults if no `ssl` settings were configured, and an
+// explicit `http` scheme disables it.
+func makeEndpoint(protocol, host string, tls *tlscommon.TLSConfig) (string, *tlscommon.TLSConfig, error) {
+	scheme := ""
+	if parts := strings.SplitN(host, "://", 2); len(parts) == 2 {
+		scheme = parts[0]
+	}
+	switch scheme {
+	case "":
+	case "http":
+		tls = nil
+	case "https":
+		if tls == nil {
+			tls = &tlscommon.TLSConfig{}
+		}
+	default:
+		return "", nil, fmt.Errorf("invalid otlp url scheme %s", scheme)
+	}
+
+	if protocol == protocolHTTP {
+		defaultScheme := "http"
+		if tls != nil {
+			defaultScheme = "https"
+		}
+		endpoint, err := common.MakeURL(defaultScheme, "", host, defaultHTTPPort)
+		return endpoint, tls, err
+	}
+
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "http://"), "https://")
+	host = strings.TrimSuffix(host, "/")
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, fmt.Sprint(defaultGRPCPort))
+	}
+	return host, tls, nil
+}
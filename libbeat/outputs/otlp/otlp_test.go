@@ -0,0 +1,257 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type logsServer struct {
+	plogotlp.UnimplementedGRPCServer
+
+	mu   sync.Mutex
+	logs []plog.Logs
+	err  error
+}
+
+func (s *logsServer) Export(_ context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return plogotlp.NewExportResponse(), s.err
+	}
+	logs := plog.NewLogs()
+	req.Logs().CopyTo(logs)
+	s.logs = append(s.logs, logs)
+	return plogotlp.NewExportResponse(), nil
+}
+
+func (s *logsServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, l := range s.logs {
+		n += l.LogRecordCount()
+	}
+	return n
+}
+
+type metricsServer struct {
+	pmetricotlp.UnimplementedGRPCServer
+
+	mu     sync.Mutex
+	points int
+}
+
+func (s *metricsServer) Export(_ context.Context, req pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.points += req.Metrics().DataPointCount()
+	return pmetricotlp.NewExportResponse(), nil
+}
+
+func startGRPCServer(t *testing.T, logs *logsServer, metrics *metricsServer) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	plogotlp.RegisterGRPCServer(srv, logs)
+	pmetricotlp.RegisterGRPCServer(srv, metrics)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func makeTestClient(t *testing.T, settings clientSettings) *client {
+	t.Helper()
+
+	info := beat.Info{Beat: "testbeat", Version: "9.9.9", Logger: logptest.NewTestingLogger(t, "")}
+	c := newClient(info, outputs.NewNilObserver(), settings)
+	require.NoError(t, c.Connect(context.Background()))
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func testEvents() []beat.Event {
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []beat.Event{
+		{Timestamp: ts, Fields: mapstr.M{"message": "hello", "log": mapstr.M{"level": "warn"}}},
+		{Timestamp: ts, Fields: mapstr.M{"message": "world"}, Meta: mapstr.M{"_id": "abc"}},
+		{Timestamp: ts, Fields: mapstr.M{
+			"event":     mapstr.M{"module": "system"},
+			"metricset": mapstr.M{"name": "cpu"},
+			"system":    mapstr.M{"cpu": mapstr.M{"cores": 4, "total": mapstr.M{"pct": 0.5}}},
+		}},
+	}
+}
+
+func TestPublishGRPC(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ack batch on success", func(t *testing.T) {
+		logs, metrics := &logsServer{}, &metricsServer{}
+		addr := startGRPCServer(t, logs, metrics)
+		c := makeTestClient(t, clientSettings{protocol: protocolGRPC, endpoint: addr, timeout: 5 * time.Second, metrics: true})
+
+		batch := outest.NewBatch(testEvents()...)
+		require.NoError(t, c.Publish(ctx, batch))
+		require.Len(t, batch.Signals, 1)
+		assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+		assert.Equal(t, 2, logs.count())
+		assert.Equal(t, 2, metrics.points)
+
+		record := logs.logs[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+		assert.Equal(t, plog.SeverityNumberWarn, record.SeverityNumber())
+		service, _ := logs.logs[0].ResourceLogs().At(0).Resource().Attributes().Get("service.name")
+		assert.Equal(t, "testbeat", service.Str())
+	})
+
+	t.Run("retry events on retryable error", func(t *testing.T) {
+		logs := &logsServer{err: status.Error(codes.Unavailable, "try later")}
+		addr := startGRPCServer(t, logs, &metricsServer{})
+		c := makeTestClient(t, clientSettings{protocol: protocolGRPC, endpoint: addr, timeout: 5 * time.Second, metrics: true})
+
+		batch := outest.NewBatch(testEvents()...)
+		assert.Error(t, c.Publish(ctx, batch))
+		require.Len(t, batch.Signals, 1)
+		assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+		assert.Len(t, batch.Signals[0].Events, 2, "only the log events should be retried")
+	})
+
+	t.Run("drop events on permanent error", func(t *testing.T) {
+		logs := &logsServer{err: status.Error(codes.InvalidArgument, "bad data")}
+		addr := startGRPCServer(t, logs, &metricsServer{})
+		c := makeTestClient(t, clientSettings{protocol: protocolGRPC, endpoint: addr, timeout: 5 * time.Second})
+
+		batch := outest.NewBatch(testEvents()...)
+		assert.NoError(t, c.Publish(ctx, batch))
+		require.Len(t, batch.Signals, 1)
+		assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+	})
+}
+
+func TestPublishHTTP(t *testing.T) {
+	ctx := context.Background()
+
+	newServer := func(t *testing.T, status int, received *int) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, logsPath, r.URL.Path)
+			assert.Equal(t, protobufContentType, r.Header.Get("Content-Type"))
+			assert.Equal(t, "secret", r.Header.Get("Authorization"))
+
+			var body io.Reader = r.Body
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				gz, err := gzip.NewReader(r.Body)
+				require.NoError(t, err)
+				body = gz
+			}
+			raw, err := io.ReadAll(body)
+			require.NoError(t, err)
+			req := plogotlp.NewExportRequest()
+			require.NoError(t, req.UnmarshalProto(raw))
+			*received += req.Logs().LogRecordCount()
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+
+	cases := map[string]struct {
+		status   int
+		wantTag  outest.BatchSignalTag
+		wantErr  bool
+		compress string
+	}{
+		"ack on success":             {status: http.StatusOK, wantTag: outest.BatchACK, compress: "gzip"},
+		"retry on unavailable":       {status: http.StatusServiceUnavailable, wantTag: outest.BatchRetryEvents, wantErr: true},
+		"retry on too many requests": {status: http.StatusTooManyRequests, wantTag: outest.BatchRetryEvents, wantErr: true},
+		"drop on bad request":        {status: http.StatusBadRequest, wantTag: outest.BatchACK},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var received int
+			srv := newServer(t, tc.status, &received)
+			c := makeTestClient(t, clientSettings{
+				protocol:    protocolHTTP,
+				endpoint:    srv.URL,
+				headers:     map[string]string{"Authorization": "secret"},
+				compression: tc.compress,
+				timeout:     5 * time.Second,
+			})
+
+			batch := outest.NewBatch(testEvents()[:2]...)
+			err := c.Publish(ctx, batch)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			require.Len(t, batch.Signals, 1)
+			assert.Equal(t, tc.wantTag, batch.Signals[0].Tag)
+			assert.Equal(t, 2, received)
+		})
+	}
+}
+
+func TestMakeEndpoint(t *testing.T) {
+	cases := []struct {
+		protocol string
+		host     string
+		want     string
+		wantTLS  bool
+	}{
+		{protocolGRPC, "localhost", "localhost:4317", false},
+		{protocolGRPC, "collector:1234", "collector:1234", false},
+		{protocolGRPC, "https://collector", "collector:4317", true},
+		{protocolHTTP, "localhost", "http://localhost:4318", false},
+		{protocolHTTP, "https://collector:443", "https://collector:443", true},
+	}
+	for _, tc := range cases {
+		endpoint, tls, err := makeEndpoint(tc.protocol, tc.host, nil)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, endpoint, tc.host)
+		assert.Equal(t, tc.wantTLS, tls != nil, tc.host)
+	}
+
+	_, _, err := makeEndpoint(protocolGRPC, "ftp://collector", nil)
+	assert.Error(t, err)
+}
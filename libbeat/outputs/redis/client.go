@@ -91,9 +91,14 @@ func newClient(
 	}
 }
 
-func (c *client) Connect(_ context.Context) error {
+func (c *client) Connect(ctx context.Context) error {
 	c.log.Debug("connect")
-	err := c.Client.Connect()
+	err := codec.Connect(ctx, c.codec)
+	if err != nil {
+		return err
+	}
+
+	err = c.Client.Connect()
 	if err != nil {
 		return err
 	}
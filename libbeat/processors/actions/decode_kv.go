@@ -0,0 +1,294 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/checks"
+	"github.com/elastic/beats/v7/libbeat/processors/convert"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor/registry"
+	cfg "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const decodeKVProcessorName = "decode_kv"
+
+type decodeKV struct {
+	config  kvConfig
+	include map[string]struct{}
+	exclude map[string]struct{}
+	log     *logp.Logger
+}
+
+type kvConfig struct {
+	Field         string                      `config:"field"`
+	Target        string                      `config:"target"`
+	FieldSplit    string                      `config:"field_split"`
+	ValueSplit    string                      `config:"value_split"`
+	QuoteChars    string                      `config:"quote_chars"`
+	IncludeKeys   []string                    `config:"include_keys"`
+	ExcludeKeys   []string                    `config:"exclude_keys"`
+	Prefix        string                      `config:"prefix"`
+	TrimKey       string                      `config:"trim_key"`
+	TrimValue     string                      `config:"trim_value"`
+	Types         map[string]convert.DataType `config:"types"`
+	OverwriteKeys bool                        `config:"overwrite_keys"`
+	IgnoreMissing bool                        `config:"ignore_missing"`
+	FailOnError   bool                        `config:"fail_on_error"`
+}
+
+func (c *kvConfig) Validate() error {
+	if c.FieldSplit == "" {
+		return errors.New("field_split must not be empty")
+	}
+	if c.ValueSplit == "" {
+		return errors.New("value_split must not be empty")
+	}
+	return nil
+}
+
+// kvPair is a key and value decoded from a string.
+type kvPair struct {
+	key   string
+	value string
+}
+
+func init() {
+	processors.RegisterPlugin(decodeKVProcessorName,
+		checks.ConfigChecked(NewDecodeKV,
+			checks.AllowedFields("field", "target", "field_split", "value_split", "quote_chars",
+				"include_keys", "exclude_keys", "prefix", "trim_key", "trim_value", "types",
+				"overwrite_keys", "ignore_missing", "fail_on_error", "when")))
+	jsprocessor.RegisterPlugin("DecodeKV", NewDecodeKV)
+}
+
+// NewDecodeKV constructs a new decode_kv processor.
+func NewDecodeKV(c *cfg.C) (beat.Processor, error) {
+	config := kvConfig{
+		Field:       "message",
+		FieldSplit:  " ",
+		ValueSplit:  "=",
+		QuoteChars:  `"'`,
+		FailOnError: true,
+	}
+	if err := c.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %s configuration: %w", decodeKVProcessorName, err)
+	}
+
+	return &decodeKV{
+		config:  config,
+		include: stringSet(config.IncludeKeys),
+		exclude: stringSet(config.ExcludeKeys),
+		log:     logp.NewLogger(decodeKVProcessorName),
+	}, nil
+}
+
+func stringSet(keys []string) map[string]struct{} {
+	if len(keys) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return set
+}
+
+func (p *decodeKV) Run(event *beat.Event) (*beat.Event, error) {
+	var backup *beat.Event
+	// Creates a copy of the event to revert in case of failure
+	if p.config.FailOnError {
+		backup = event.Clone()
+	}
+
+	err := p.decode(event)
+	if err != nil {
+		errMsg := fmt.Errorf("failed to decode key-value pairs in processor: %w", err)
+		p.log.Debugw(errMsg.Error(), logp.TypeKey, logp.EventType)
+
+		if p.config.FailOnError {
+			event = backup
+			_, _ = event.PutValue("error.message", errMsg.Error())
+			return event, err
+		}
+	}
+	return event, nil
+}
+
+func (p *decodeKV) String() string {
+	return fmt.Sprintf("%s=[field=%s, target=%s, field_split=%q, value_split=%q]",
+		decodeKVProcessorName, p.config.Field, p.config.Target, p.config.FieldSplit, p.config.ValueSplit)
+}
+
+func (p *decodeKV) decode(event *beat.Event) error {
+	value, err := event.GetValue(p.config.Field)
+	if err != nil {
+		if p.config.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+			return nil
+		}
+		return fmt.Errorf("could not fetch value for key: %s, Error: %w", p.config.Field, err)
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid type for `%s`, expecting a string received %T", p.config.Field, value)
+	}
+
+	fields := mapstr.M{}
+	var keys []string
+	for _, pair := range p.parse(text) {
+		key := strings.Trim(pair.key, p.config.TrimKey)
+		if !p.selected(key) {
+			continue
+		}
+
+		v, err := p.convert(key, strings.Trim(pair.value, p.config.TrimValue))
+		if err != nil {
+			return err
+		}
+
+		key = p.config.Prefix + key
+		switch prev := fields[key].(type) {
+		case nil:
+			fields[key] = v
+			keys = append(keys, key)
+		case []interface{}:
+			fields[key] = append(prev, v)
+		default:
+			// Repeated keys are collected into an array.
+			fields[key] = []interface{}{prev, v}
+		}
+	}
+
+	target := ""
+	if p.config.Target != "" {
+		target = p.config.Target + "."
+	}
+
+	// Check all keys first so the event is left untouched on conflicts.
+	if !p.config.OverwriteKeys {
+		for _, key := range keys {
+			if _, err := event.GetValue(target + key); !errors.Is(err, mapstr.ErrKeyNotFound) {
+				return fmt.Errorf("cannot override existing key `%s`", target+key)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		if _, err := event.PutValue(target+key, fields[key]); err != nil {
+			return fmt.Errorf("could not put value for key: %s, %w", target+key, err)
+		}
+	}
+	return nil
+}
+
+// selected reports whether a key passes the include and exclude lists.
+func (p *decodeKV) selected(key string) bool {
+	if key == "" {
+		return false
+	}
+	if p.include != nil {
+		if _, found := p.include[key]; !found {
+			return false
+		}
+	}
+	_, excluded := p.exclude[key]
+	return !excluded
+}
+
+func (p *decodeKV) convert(key, value string) (interface{}, error) {
+	typ, found := p.config.Types[key]
+	if !found {
+		return value, nil
+	}
+	v, err := convert.TransformType(typ, value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert value of key `%s` to %s: %w", key, typ, err)
+	}
+	return v, nil
+}
+
+// parse splits text into key-value pairs. Keys and values can be quoted
+// with any of the quote characters, in which case they can contain the
+// separators, and quote characters escaped with a backslash. Keys without a
+// value and pairs with an empty value are skipped.
+func (p *decodeKV) parse(text string) []kvPair {
+	var pairs []kvPair
+	for i := 0; i < len(text); {
+		if strings.HasPrefix(text[i:], p.config.FieldSplit) {
+			i += len(p.config.FieldSplit)
+			continue
+		}
+
+		key, n := p.token(text[i:], p.config.ValueSplit)
+		i += n
+		if !strings.HasPrefix(text[i:], p.config.ValueSplit) {
+			continue
+		}
+		i += len(p.config.ValueSplit)
+
+		value, n := p.token(text[i:], "")
+		i += n
+		if value != "" {
+			pairs = append(pairs, kvPair{key: key, value: value})
+		}
+	}
+	return pairs
+}
+
+// token reads a quoted string, or an unquoted string up to the field
+// separator or stop. It returns the token and the number of bytes consumed.
+func (p *decodeKV) token(s, stop string) (string, int) {
+	if s != "" && strings.IndexByte(p.config.QuoteChars, s[0]) >= 0 {
+		if token, n, ok := unquote(s); ok {
+			return token, n
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], p.config.FieldSplit) || (stop != "" && strings.HasPrefix(s[i:], stop)) {
+			return s[:i], i
+		}
+	}
+	return s, len(s)
+}
+
+// unquote reads a string enclosed in the quote character s starts with. It
+// fails if the closing quote is missing.
+func unquote(s string) (string, int, bool) {
+	quote := s[0]
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == quote || s[i+1] == '\\'):
+			sb.WriteByte(s[i+1])
+			i++
+		case c == quote:
+			return sb.String(), i + 1, true
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,192 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package actions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestDecodeKVRun(t *testing.T) {
+	testCases := []struct {
+		description string
+		config      map[string]interface{}
+		input       mapstr.M
+		output      mapstr.M
+		error       bool
+	}{
+		{
+			description: "defaults",
+			config:      map[string]interface{}{},
+			input:       mapstr.M{"message": "user=alice action=login"},
+			output:      mapstr.M{"message": "user=alice action=login", "user": "alice", "action": "login"},
+		},
+		{
+			description: "target and nested keys",
+			config:      map[string]interface{}{"target": "kv"},
+			input:       mapstr.M{"message": "src.ip=10.0.0.1 dst.port=443"},
+			output: mapstr.M{
+				"message": "src.ip=10.0.0.1 dst.port=443",
+				"kv": mapstr.M{
+					"src": mapstr.M{"ip": "10.0.0.1"},
+					"dst": mapstr.M{"port": "443"},
+				},
+			},
+		},
+		{
+			description: "quoted values and escapes",
+			config:      map[string]interface{}{"target": "kv"},
+			input:       mapstr.M{"message": `msg="hello world" path='C:\\tmp' note="say \"hi\"" unterminated="oops`},
+			output: mapstr.M{
+				"message": `msg="hello world" path='C:\\tmp' note="say \"hi\"" unterminated="oops`,
+				"kv": mapstr.M{
+					"msg":          "hello world",
+					"path":         `C:\tmp`,
+					"note":         `say "hi"`,
+					"unterminated": `"oops`,
+				},
+			},
+		},
+		{
+			description: "custom separators",
+			config: map[string]interface{}{
+				"field":       "raw",
+				"target":      "kv",
+				"field_split": ", ",
+				"value_split": ":",
+			},
+			input: mapstr.M{"raw": "a:1, b:two words, flag, c:"},
+			output: mapstr.M{
+				"raw": "a:1, b:two words, flag, c:",
+				"kv":  mapstr.M{"a": "1", "b": "two words"},
+			},
+		},
+		{
+			description: "include, exclude, prefix and trim",
+			config: map[string]interface{}{
+				"include_keys": []string{"a", "b", "c"},
+				"exclude_keys": []string{"c"},
+				"prefix":       "kv_",
+				"trim_key":     "[]",
+				"trim_value":   "<>",
+			},
+			input: mapstr.M{"message": "[a]=<1> b=2 c=3 d=4"},
+			output: mapstr.M{
+				"message": "[a]=<1> b=2 c=3 d=4",
+				"kv_a":    "1",
+				"kv_b":    "2",
+			},
+		},
+		{
+			description: "type conversion and repeated keys",
+			config: map[string]interface{}{
+				"target": "kv",
+				"types":  map[string]interface{}{"bytes": "long", "ratio": "double", "ok": "boolean", "tag": "string"},
+			},
+			input: mapstr.M{"message": "bytes=1024 ratio=0.5 ok=true tag=a tag=b tag=c"},
+			output: mapstr.M{
+				"message": "bytes=1024 ratio=0.5 ok=true tag=a tag=b tag=c",
+				"kv": mapstr.M{
+					"bytes": int64(1024),
+					"ratio": 0.5,
+					"ok":    true,
+					"tag":   []interface{}{"a", "b", "c"},
+				},
+			},
+		},
+		{
+			description: "failed conversion",
+			config: map[string]interface{}{
+				"target": "kv",
+				"types":  map[string]interface{}{"bytes": "long"},
+			},
+			input: mapstr.M{"message": "bytes=lots user=bob"},
+			output: mapstr.M{
+				"message": "bytes=lots user=bob",
+				"error": mapstr.M{
+					"message": "failed to decode key-value pairs in processor: unable to convert value of key `bytes` to long: " +
+						`strconv.ParseInt: parsing "lots": invalid syntax`,
+				},
+			},
+			error: true,
+		},
+		{
+			description: "existing key",
+			config:      map[string]interface{}{},
+			input:       mapstr.M{"message": "message=overwritten user=bob"},
+			output: mapstr.M{
+				"message": "message=overwritten user=bob",
+				"error": mapstr.M{
+					"message": "failed to decode key-value pairs in processor: cannot override existing key `message`",
+				},
+			},
+			error: true,
+		},
+		{
+			description: "overwrite keys",
+			config:      map[string]interface{}{"overwrite_keys": true},
+			input:       mapstr.M{"message": "message=overwritten user=bob"},
+			output:      mapstr.M{"message": "overwritten", "user": "bob"},
+		},
+		{
+			description: "missing field",
+			config:      map[string]interface{}{"ignore_missing": true},
+			input:       mapstr.M{"other": "a=b"},
+			output:      mapstr.M{"other": "a=b"},
+		},
+		{
+			description: "missing field without fail on error",
+			config:      map[string]interface{}{"fail_on_error": false},
+			input:       mapstr.M{"other": "a=b"},
+			output:      mapstr.M{"other": "a=b"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.description, func(t *testing.T) {
+			p, err := NewDecodeKV(conf.MustNewConfigFrom(test.config))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: test.input})
+			if test.error {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.output, event.Fields)
+		})
+	}
+}
+
+func TestDecodeKVConfig(t *testing.T) {
+	for name, config := range map[string]map[string]interface{}{
+		"empty field split": {"field_split": ""},
+		"invalid type":      {"types": map[string]interface{}{"a": "decimal"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewDecodeKV(conf.MustNewConfigFrom(config))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package add_geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const processorName = "add_geoip"
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID atomic.Uint32
+
+func init() {
+	// We cannot use this as a JS plugin as it is stateful and includes a Close method.
+	processors.RegisterPlugin(processorName, New)
+}
+
+// result is the outcome of a lookup. Empty results are cached as well.
+type result struct {
+	geo mapstr.M
+	as  mapstr.M
+}
+
+type addGeoIP struct {
+	config config
+	log    *logp.Logger
+
+	city *database
+	asn  *database
+
+	// cache is replaced whenever a database is reloaded.
+	cacheMu sync.RWMutex
+	cache   *common.Cache
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New constructs a new add_geoip processor.
+func New(cfg *conf.C) (beat.Processor, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v configuration: %w", processorName, err)
+	}
+	if len(config.Fields) == 0 {
+		config.Fields = defaultFields
+	}
+
+	// Logging (each processor instance has a unique ID).
+	var (
+		id  = int(instanceID.Add(1))
+		log = logp.NewLogger(processorName).With("instance_id", id)
+	)
+
+	p := &addGeoIP{
+		config: config,
+		log:    log,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	var err error
+	if config.Database != "" {
+		if p.city, err = openDatabase(paths.Resolve(paths.Config, config.Database)); err != nil {
+			return nil, err
+		}
+	}
+	if config.ASNDatabase != "" {
+		if p.asn, err = openDatabase(paths.Resolve(paths.Config, config.ASNDatabase)); err != nil {
+			return nil, err
+		}
+	}
+	p.resetCache()
+
+	if config.ReloadInterval > 0 {
+		go p.reloadLoop()
+	} else {
+		close(p.done)
+	}
+	return p, nil
+}
+
+// Run enriches the configured IP fields of the event.
+func (p *addGeoIP) Run(event *beat.Event) (*beat.Event, error) {
+	var errs []error
+	for _, f := range p.config.Fields {
+		v, err := event.GetValue(f.From)
+		if err != nil {
+			if !errors.Is(err, mapstr.ErrKeyNotFound) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		ip, err := toIP(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("field '%s': %w", f.From, err))
+			continue
+		}
+
+		res, err := p.lookup(ip)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to look up '%s': %w", f.From, err))
+			continue
+		}
+
+		if res.geo != nil {
+			if _, err := event.PutValue(f.To+".geo", res.geo.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if res.as != nil {
+			if _, err := event.PutValue(f.To+".as", res.as.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return event, errors.Join(errs...)
+}
+
+func toIP(v interface{}) (net.IP, error) {
+	switch ip := v.(type) {
+	case net.IP:
+		return ip, nil
+	case string:
+		if parsed := net.ParseIP(ip); parsed != nil {
+			return parsed, nil
+		}
+		return nil, fmt.Errorf("invalid IP address '%s'", ip)
+	}
+	return nil, fmt.Errorf("unexpected type %T for IP address", v)
+}
+
+func (p *addGeoIP) lookup(ip net.IP) (*result, error) {
+	p.cacheMu.RLock()
+	cache := p.cache
+	p.cacheMu.RUnlock()
+
+	key := string(ip.To16())
+	if cache != nil {
+		if res, ok := cache.Get(key).(*result); ok {
+			return res, nil
+		}
+	}
+
+	res := &result{}
+	if p.city != nil {
+		rec, err := p.city.lookup(ip)
+		if err != nil {
+			return nil, err
+		}
+		res.geo = geoFields(rec, p.config.Language)
+	}
+	if p.asn != nil {
+		rec, err := p.asn.lookup(ip)
+		if err != nil {
+			return nil, err
+		}
+		res.as = asFields(rec)
+	}
+
+	if cache != nil {
+		cache.Put(key, res)
+	}
+	return res, nil
+}
+
+// resetCache replaces the cache, dropping the results of previous versions
+// of the databases.
+func (p *addGeoIP) resetCache() {
+	var cache *common.Cache
+	if p.config.Cache.Enabled {
+		cache = common.NewCache(p.config.Cache.TTL, 1024)
+		cache.StartJanitor(p.config.Cache.TTL)
+	}
+
+	p.cacheMu.Lock()
+	old := p.cache
+	p.cache = cache
+	p.cacheMu.Unlock()
+
+	if old != nil {
+		old.StopJanitor()
+	}
+}
+
+func (p *addGeoIP) reloadLoop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.reload()
+		}
+	}
+}
+
+// reload reloads the databases that changed on disk.
+func (p *addGeoIP) reload() {
+	var reloaded bool
+	for _, d := range []*database{p.city, p.asn} {
+		if d == nil {
+			continue
+		}
+		ok, err := d.Reload()
+		if err != nil {
+			p.log.Warnf("Keeping the previous version of the database %s: %v", d.Path(), err)
+			continue
+		}
+		if ok {
+			p.log.Infof("Reloaded database %s", d.Path())
+			reloaded = true
+		}
+	}
+	if reloaded {
+		p.resetCache()
+	}
+}
+
+// Close stops reloading the databases and releases the cache.
+func (p *addGeoIP) Close() error {
+	close(p.stop)
+	<-p.done
+
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	if p.cache != nil {
+		p.cache.StopJanitor()
+		p.cache = nil
+	}
+	return nil
+}
+
+func (p *addGeoIP) String() string {
+	fields := make([]string, 0, len(p.config.Fields))
+	for _, f := range p.config.Fields {
+		fields = append(fields, f.From+"->"+f.To)
+	}
+	return fmt.Sprintf("%v=[database=%v, asn_database=%v, fields=[%v]]",
+		processorName, p.config.Database, p.config.ASNDatabase, strings.Join(fields, ", "))
+}
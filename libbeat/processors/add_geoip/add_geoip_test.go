@@ -0,0 +1,262 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package add_geoip
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors/add_geoip/mmdb/mmdbtest"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var cityNetworks = []mmdbtest.Network{
+	{
+		CIDR: "81.2.69.0/24",
+		Record: map[string]interface{}{
+			"city":      map[string]interface{}{"names": map[string]interface{}{"en": "London", "de": "London"}},
+			"continent": map[string]interface{}{"code": "EU", "names": map[string]interface{}{"en": "Europe", "de": "Europa"}},
+			"country":   map[string]interface{}{"iso_code": "GB", "names": map[string]interface{}{"en": "United Kingdom", "de": "Vereinigtes Königreich"}},
+			"location": map[string]interface{}{
+				"latitude":  51.5142,
+				"longitude": -0.0931,
+				"time_zone": "Europe/London",
+			},
+			"postal": map[string]interface{}{"code": "EC2V"},
+			"subdivisions": []interface{}{
+				map[string]interface{}{"iso_code": "ENG", "names": map[string]interface{}{"en": "England"}},
+			},
+		},
+	},
+	{
+		CIDR: "2001:db8::/32",
+		Record: map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": "SE", "names": map[string]interface{}{"en": "Sweden"}},
+		},
+	},
+}
+
+var asnNetworks = []mmdbtest.Network{
+	{
+		CIDR: "81.2.69.0/24",
+		Record: map[string]interface{}{
+			"autonomous_system_number":       uint32(20712),
+			"autonomous_system_organization": "Andrews & Arnold Ltd",
+		},
+	},
+}
+
+func writeDatabase(t *testing.T, dir, name string, networks []mmdbtest.Network) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, mmdbtest.Write(path, mmdbtest.Options{DatabaseType: name}, networks))
+	return path
+}
+
+func newTestProcessor(t *testing.T, settings map[string]interface{}) *addGeoIP {
+	t.Helper()
+	p, err := New(conf.MustNewConfigFrom(settings))
+	require.NoError(t, err)
+	t.Cleanup(func() { p.(*addGeoIP).Close() })
+	return p.(*addGeoIP)
+}
+
+func TestAddGeoIP(t *testing.T) {
+	dir := t.TempDir()
+	city := writeDatabase(t, dir, "GeoLite2-City.mmdb", cityNetworks)
+	asn := writeDatabase(t, dir, "GeoLite2-ASN.mmdb", asnNetworks)
+
+	london := mapstr.M{
+		"continent_code":   "EU",
+		"continent_name":   "Europe",
+		"country_iso_code": "GB",
+		"country_name":     "United Kingdom",
+		"city_name":        "London",
+		"region_iso_code":  "GB-ENG",
+		"region_name":      "England",
+		"postal_code":      "EC2V",
+		"timezone":         "Europe/London",
+		"location":         mapstr.M{"lat": 51.5142, "lon": -0.0931},
+	}
+
+	testCases := []struct {
+		name     string
+		config   map[string]interface{}
+		fields   mapstr.M
+		expected mapstr.M
+		wantErr  bool
+	}{
+		{
+			name:   "default fields",
+			config: map[string]interface{}{"database": city, "asn_database": asn},
+			fields: mapstr.M{
+				"source":      mapstr.M{"ip": "81.2.69.142"},
+				"destination": mapstr.M{"ip": "2001:db8::1"},
+			},
+			expected: mapstr.M{
+				"source": mapstr.M{
+					"ip":  "81.2.69.142",
+					"geo": london,
+					"as": mapstr.M{
+						"number":       uint64(20712),
+						"organization": mapstr.M{"name": "Andrews & Arnold Ltd"},
+					},
+				},
+				"destination": mapstr.M{
+					"ip":  "2001:db8::1",
+					"geo": mapstr.M{"country_iso_code": "SE", "country_name": "Sweden"},
+				},
+			},
+		},
+		{
+			name: "custom fields",
+			config: map[string]interface{}{
+				"database": city,
+				"fields":   []map[string]interface{}{{"from": "client.address", "to": "client"}},
+			},
+			fields: mapstr.M{"client": mapstr.M{"address": net.ParseIP("81.2.69.1")}},
+			expected: mapstr.M{
+				"client": mapstr.M{"address": net.ParseIP("81.2.69.1"), "geo": london},
+			},
+		},
+		{
+			name:   "language",
+			config: map[string]interface{}{"database": city, "language": "de"},
+			fields: mapstr.M{"source": mapstr.M{"ip": "81.2.69.1"}},
+			expected: mapstr.M{
+				"source": mapstr.M{
+					"ip": "81.2.69.1",
+					"geo": mapstr.M{
+						"continent_code":   "EU",
+						"continent_name":   "Europa",
+						"country_iso_code": "GB",
+						"country_name":     "Vereinigtes Königreich",
+						"city_name":        "London",
+						"region_iso_code":  "GB-ENG",
+						"postal_code":      "EC2V",
+						"timezone":         "Europe/London",
+						"location":         mapstr.M{"lat": 51.5142, "lon": -0.0931},
+					},
+				},
+			},
+		},
+		{
+			name:     "not found",
+			config:   map[string]interface{}{"database": city, "asn_database": asn},
+			fields:   mapstr.M{"source": mapstr.M{"ip": "10.0.0.1"}},
+			expected: mapstr.M{"source": mapstr.M{"ip": "10.0.0.1"}},
+		},
+		{
+			name:     "missing fields",
+			config:   map[string]interface{}{"database": city},
+			fields:   mapstr.M{"message": "hello"},
+			expected: mapstr.M{"message": "hello"},
+		},
+		{
+			name:     "invalid IP",
+			config:   map[string]interface{}{"database": city},
+			fields:   mapstr.M{"source": mapstr.M{"ip": "not-an-ip"}},
+			expected: mapstr.M{"source": mapstr.M{"ip": "not-an-ip"}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newTestProcessor(t, tc.config)
+
+			event, err := p.Run(&beat.Event{Fields: tc.fields})
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, event.Fields)
+		})
+	}
+}
+
+func TestAddGeoIPConfig(t *testing.T) {
+	_, err := New(conf.MustNewConfigFrom(map[string]interface{}{}))
+	assert.ErrorContains(t, err, "at least one of database or asn_database is required")
+
+	_, err = New(conf.MustNewConfigFrom(map[string]interface{}{
+		"database": filepath.Join(t.TempDir(), "missing.mmdb"),
+	}))
+	assert.ErrorContains(t, err, "failed to load database")
+}
+
+func TestAddGeoIPCache(t *testing.T) {
+	dir := t.TempDir()
+	city := writeDatabase(t, dir, "GeoLite2-City.mmdb", cityNetworks)
+	p := newTestProcessor(t, map[string]interface{}{"database": city, "reload_interval": 0})
+
+	first, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "81.2.69.142"}}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.cache.Size())
+
+	// Events must not share the cached maps.
+	_, err = first.PutValue("source.geo.city_name", "changed")
+	require.NoError(t, err)
+
+	second, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "81.2.69.142"}}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.cache.Size())
+	name, _ := second.GetValue("source.geo.city_name")
+	assert.Equal(t, "London", name)
+}
+
+func TestAddGeoIPReload(t *testing.T) {
+	dir := t.TempDir()
+	city := writeDatabase(t, dir, "GeoLite2-City.mmdb", cityNetworks)
+	p := newTestProcessor(t, map[string]interface{}{"database": city, "reload_interval": 0})
+
+	countryOf := func(ip string) interface{} {
+		event, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": ip}}})
+		require.NoError(t, err)
+		v, _ := event.GetValue("source.geo.country_iso_code")
+		return v
+	}
+	assert.Equal(t, "GB", countryOf("81.2.69.142"))
+
+	writeDatabase(t, dir, "GeoLite2-City.mmdb", []mmdbtest.Network{
+		{
+			CIDR:   "81.2.69.0/24",
+			Record: map[string]interface{}{"country": map[string]interface{}{"iso_code": "IE"}},
+		},
+	})
+	// Make sure the change is detected on file systems with coarse timestamps.
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(city, future, future))
+
+	p.reload()
+	assert.Equal(t, "IE", countryOf("81.2.69.142"))
+
+	// An invalid file keeps the previous version of the database.
+	require.NoError(t, os.WriteFile(city, []byte("invalid"), 0o644))
+	p.reload()
+	assert.Equal(t, "IE", countryOf("81.2.69.142"))
+}
@@ -0,0 +1,79 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package add_geoip
+
+import (
+	"errors"
+	"time"
+)
+
+type config struct {
+	// Database is the path to a City or Country database.
+	Database string `config:"database"`
+
+	// ASNDatabase is the path to an ASN database.
+	ASNDatabase string `config:"asn_database"`
+
+	// Fields maps the IP fields to look up to the fields under which the
+	// geo and as fields are written. Defaults to defaultFields if empty.
+	Fields []fieldConfig `config:"fields"`
+
+	// Language of the names taken from the database.
+	Language string `config:"language"`
+
+	// ReloadInterval is how often the database files are checked for
+	// changes. Zero disables reloading.
+	ReloadInterval time.Duration `config:"reload_interval" validate:"min=0"`
+
+	Cache cacheConfig `config:"cache"`
+}
+
+type fieldConfig struct {
+	From string `config:"from" validate:"required"`
+	To   string `config:"to" validate:"required"`
+}
+
+type cacheConfig struct {
+	Enabled bool          `config:"enabled"`
+	TTL     time.Duration `config:"ttl" validate:"positive,nonzero"`
+}
+
+// defaultFields is not part of defaultConfig, as a user provided list would
+// be merged into it.
+var defaultFields = []fieldConfig{
+	{From: "source.ip", To: "source"},
+	{From: "destination.ip", To: "destination"},
+}
+
+func defaultConfig() config {
+	return config{
+		Language:       "en",
+		ReloadInterval: time.Minute,
+		Cache: cacheConfig{
+			Enabled: true,
+			TTL:     10 * time.Minute,
+		},
+	}
+}
+
+func (c *config) Validate() error {
+	if c.Database == "" && c.ASNDatabase == "" {
+		return errors.New("at least one of database or asn_database is required")
+	}
+	return nil
+}
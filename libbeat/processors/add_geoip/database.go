@@ -0,0 +1,51 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package add_geoip
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/common/file"
+	"github.com/elastic/beats/v7/libbeat/processors/add_geoip/mmdb"
+)
+
+// database is a database file that can be reloaded when it changes.
+type database struct {
+	*file.Reloadable[*mmdb.Reader]
+}
+
+func openDatabase(path string) (*database, error) {
+	r, err := file.NewReloadable(path, mmdb.Open)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load database %s: %w", path, err)
+	}
+	return &database{r}, nil
+}
+
+// lookup returns the record of ip as a map, or nil if it is not found.
+func (d *database) lookup(ip []byte) (map[string]interface{}, error) {
+	rec, _, err := d.Get().Lookup(ip)
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	m, ok := rec.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected record type %T in database %s", rec, d.Path())
+	}
+	return m, nil
+}
@@ -0,0 +1,98 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package add_geoip
+
+import (
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// geoFields maps a City or Country database record to ECS geo fields.
+func geoFields(rec map[string]interface{}, lang string) mapstr.M {
+	if rec == nil {
+		return nil
+	}
+
+	geo := mapstr.M{}
+	putString(geo, "continent_code", lookup(rec, "continent", "code"))
+	putString(geo, "continent_name", lookup(rec, "continent", "names", lang))
+	countryCode, _ := lookup(rec, "country", "iso_code").(string)
+	putString(geo, "country_iso_code", countryCode)
+	putString(geo, "country_name", lookup(rec, "country", "names", lang))
+	putString(geo, "city_name", lookup(rec, "city", "names", lang))
+	putString(geo, "postal_code", lookup(rec, "postal", "code"))
+	putString(geo, "timezone", lookup(rec, "location", "time_zone"))
+
+	if subdivisions, ok := rec["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		if sub, ok := subdivisions[0].(map[string]interface{}); ok {
+			if code, ok := sub["iso_code"].(string); ok && code != "" && countryCode != "" {
+				geo["region_iso_code"] = countryCode + "-" + code
+			}
+			putString(geo, "region_name", lookup(sub, "names", lang))
+		}
+	}
+
+	lat, latOK := lookup(rec, "location", "latitude").(float64)
+	lon, lonOK := lookup(rec, "location", "longitude").(float64)
+	if latOK && lonOK {
+		geo["location"] = mapstr.M{"lat": lat, "lon": lon}
+	}
+
+	if len(geo) == 0 {
+		return nil
+	}
+	return geo
+}
+
+// asFields maps an ASN database record to ECS as fields.
+func asFields(rec map[string]interface{}) mapstr.M {
+	if rec == nil {
+		return nil
+	}
+
+	as := mapstr.M{}
+	if number, ok := rec["autonomous_system_number"].(uint64); ok {
+		as["number"] = number
+	}
+	if org, ok := rec["autonomous_system_organization"].(string); ok && org != "" {
+		as["organization"] = mapstr.M{"name": org}
+	}
+
+	if len(as) == 0 {
+		return nil
+	}
+	return as
+}
+
+// lookup returns the value at the path of nested maps, or nil.
+func lookup(m map[string]interface{}, path ...string) interface{} {
+	var v interface{} = m
+	for _, key := range path {
+		next, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = next[key]
+	}
+	return v
+}
+
+func putString(m mapstr.M, key string, v interface{}) {
+	if s, ok := v.(string); ok && s != "" {
+		m[key] = s
+	}
+}
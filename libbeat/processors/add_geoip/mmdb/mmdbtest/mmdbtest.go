@@ -0,0 +1,261 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package mmdbtest builds small MaxMind DB files for tests.
+package mmdbtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sort"
+)
+
+// Network is a network and the record stored for it.
+type Network struct {
+	CIDR   string
+	Record interface{}
+}
+
+// Options describe the database to build.
+type Options struct {
+	DatabaseType string
+	IPVersion    int // 4 or 6, default 6
+	RecordSize   int // 24, 28 or 32, default 24
+}
+
+// record is a search tree record: a node index, empty or a data offset.
+type record struct {
+	kind  int
+	value int
+}
+
+const (
+	recordEmpty = iota
+	recordNode
+	recordData
+)
+
+// Build returns a database containing the networks. IPv4 networks are
+// stored in the ::/96 subtree of IPv6 databases.
+func Build(opts Options, networks []Network) ([]byte, error) {
+	if opts.IPVersion == 0 {
+		opts.IPVersion = 6
+	}
+	if opts.RecordSize == 0 {
+		opts.RecordSize = 24
+	}
+
+	var data bytes.Buffer
+	nodes := [][2]record{{}}
+	for _, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		ones, bits := ipnet.Mask.Size()
+		addr := []byte(ipnet.IP)
+		if bits == 32 && opts.IPVersion == 6 {
+			addr = append(make([]byte, 12), ipnet.IP.To4()...)
+			ones += 96
+		} else if bits == 128 && opts.IPVersion == 4 {
+			return nil, fmt.Errorf("cannot store %s in an IPv4 database", n.CIDR)
+		}
+
+		offset := data.Len()
+		if err := encode(&data, n.Record); err != nil {
+			return nil, err
+		}
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(addr[i/8]>>(7-uint(i%8))) & 1
+			if i == ones-1 {
+				nodes[node][bit] = record{kind: recordData, value: offset}
+				break
+			}
+			r := nodes[node][bit]
+			if r.kind != recordNode {
+				nodes = append(nodes, [2]record{r, r})
+				nodes[node][bit] = record{kind: recordNode, value: len(nodes) - 1}
+			}
+			node = nodes[node][bit].value
+		}
+	}
+
+	nodeCount := len(nodes)
+	var out bytes.Buffer
+	for _, n := range nodes {
+		var v [2]uint32
+		for i, r := range n {
+			switch r.kind {
+			case recordEmpty:
+				v[i] = uint32(nodeCount)
+			case recordNode:
+				v[i] = uint32(r.value)
+			case recordData:
+				v[i] = uint32(nodeCount + 16 + r.value)
+			}
+		}
+		switch opts.RecordSize {
+		case 24:
+			out.Write([]byte{byte(v[0] >> 16), byte(v[0] >> 8), byte(v[0]), byte(v[1] >> 16), byte(v[1] >> 8), byte(v[1])})
+		case 28:
+			out.Write([]byte{
+				byte(v[0] >> 16), byte(v[0] >> 8), byte(v[0]),
+				byte(v[0]>>20)&0xF0 | byte(v[1]>>24)&0x0F,
+				byte(v[1] >> 16), byte(v[1] >> 8), byte(v[1]),
+			})
+		case 32:
+			_ = binary.Write(&out, binary.BigEndian, v)
+		default:
+			return nil, fmt.Errorf("unsupported record size %d", opts.RecordSize)
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+
+	out.WriteString("\xAB\xCD\xEFMaxMind.com")
+	err := encode(&out, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               opts.DatabaseType,
+		"description":                 map[string]interface{}{"en": "Test database"},
+		"ip_version":                  uint16(opts.IPVersion),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(opts.RecordSize),
+	})
+	return out.Bytes(), err
+}
+
+// Write builds a database and writes it to path.
+func Write(path string, opts Options, networks []Network) error {
+	buf, err := Build(opts, networks)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf, 0o644)
+}
+
+// encode writes v in the data section format. Non-negative ints are stored
+// as uint32, negative ones as int32.
+func encode(w *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		writeControl(w, 2, len(v))
+		w.WriteString(v)
+	case float64:
+		writeControl(w, 3, 8)
+		_ = binary.Write(w, binary.BigEndian, math.Float64bits(v))
+	case float32:
+		writeControl(w, 15, 4)
+		_ = binary.Write(w, binary.BigEndian, math.Float32bits(v))
+	case []byte:
+		writeControl(w, 4, len(v))
+		w.Write(v)
+	case uint16:
+		writeUint(w, 5, uint64(v))
+	case uint32:
+		writeUint(w, 6, uint64(v))
+	case uint64:
+		writeUint(w, 9, v)
+	case int:
+		if v < 0 {
+			writeControl(w, 8, 4)
+			_ = binary.Write(w, binary.BigEndian, int32(v))
+		} else {
+			writeUint(w, 6, uint64(v))
+		}
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		writeControl(w, 14, size)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeControl(w, 7, len(v))
+		for _, k := range keys {
+			if err := encode(w, k); err != nil {
+				return err
+			}
+			if err := encode(w, v[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		writeControl(w, 11, len(v))
+		for _, e := range v {
+			if err := encode(w, e); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+func writeUint(w *bytes.Buffer, typ int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	i := 0
+	for i < 8 && b[i] == 0 {
+		i++
+	}
+	writeControl(w, typ, 8-i)
+	w.Write(b[i:])
+}
+
+func writeControl(w *bytes.Buffer, typ, size int) {
+	var ctrl byte
+	if typ <= 7 {
+		ctrl = byte(typ) << 5
+	}
+
+	var extra []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		extra = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl |= 30
+		s := size - 285
+		extra = []byte{byte(s >> 8), byte(s)}
+	default:
+		ctrl |= 31
+		s := size - 65821
+		extra = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+	}
+
+	w.WriteByte(ctrl)
+	if typ > 7 {
+		w.WriteByte(byte(typ - 7))
+	}
+	w.Write(extra)
+}
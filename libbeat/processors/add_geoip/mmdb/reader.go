@@ -0,0 +1,233 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package mmdb reads databases in the MaxMind DB format, as used by the
+// GeoIP2 and GeoLite2 databases.
+//
+// See https://maxmind.github.io/MaxMind-DB/ for the format specification.
+package mmdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata section at the end of the file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// maxMetadataSize is the maximum size of the metadata section.
+const maxMetadataSize = 128 * 1024
+
+// dataSectionSeparatorSize is the size of the zero bytes between the search
+// tree and the data section.
+const dataSectionSeparatorSize = 16
+
+// ErrInvalidDatabase is returned for files that are not valid databases.
+var ErrInvalidDatabase = errors.New("invalid MaxMind DB")
+
+// Metadata describes a database.
+type Metadata struct {
+	DatabaseType string
+	IPVersion    int
+	NodeCount    uint
+	RecordSize   uint
+	BuildEpoch   uint64
+	Languages    []string
+}
+
+// Reader looks up IP addresses in a database held in memory. It is safe for
+// concurrent use.
+type Reader struct {
+	Metadata Metadata
+
+	tree []byte
+	data decoder
+
+	// ipv4Start is the node IPv4 lookups start from in IPv6 databases.
+	ipv4Start uint
+}
+
+// Open reads the database file at path.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes creates a Reader for the database in buf. The buffer must not
+// be modified afterwards.
+func FromBytes(buf []byte) (*Reader, error) {
+	searchStart := 0
+	if len(buf) > maxMetadataSize {
+		searchStart = len(buf) - maxMetadataSize
+	}
+	i := bytes.LastIndex(buf[searchStart:], metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: metadata section not found", ErrInvalidDatabase)
+	}
+	metaStart := searchStart + i + len(metadataMarker)
+
+	raw, _, err := decoder{buf: buf[metaStart:]}.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode metadata: %w", ErrInvalidDatabase, err)
+	}
+	meta, err := parseMetadata(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	treeSize := meta.NodeCount * meta.RecordSize / 4
+	dataStart := treeSize + dataSectionSeparatorSize
+	if dataStart > uint(searchStart+i) {
+		return nil, fmt.Errorf("%w: search tree exceeds file size", ErrInvalidDatabase)
+	}
+
+	r := &Reader{
+		Metadata: meta,
+		tree:     buf[:treeSize],
+		data:     decoder{buf: buf[dataStart : searchStart+i]},
+	}
+
+	if meta.IPVersion == 6 {
+		// IPv4 addresses are stored in the ::/96 subtree.
+		node := uint(0)
+		for i := 0; i < 96 && node < meta.NodeCount; i++ {
+			if node, err = r.record(node, 0); err != nil {
+				return nil, err
+			}
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+func parseMetadata(raw interface{}) (Metadata, error) {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return Metadata{}, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+
+	var meta Metadata
+	meta.DatabaseType, _ = m["database_type"].(string)
+	nodeCount, _ := m["node_count"].(uint64)
+	recordSize, _ := m["record_size"].(uint64)
+	ipVersion, _ := m["ip_version"].(uint64)
+	meta.BuildEpoch, _ = m["build_epoch"].(uint64)
+	if langs, ok := m["languages"].([]interface{}); ok {
+		for _, l := range langs {
+			if s, ok := l.(string); ok {
+				meta.Languages = append(meta.Languages, s)
+			}
+		}
+	}
+
+	meta.NodeCount = uint(nodeCount)
+	meta.RecordSize = uint(recordSize)
+	meta.IPVersion = int(ipVersion)
+
+	switch meta.RecordSize {
+	case 24, 28, 32:
+	default:
+		return meta, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, meta.RecordSize)
+	}
+	if meta.IPVersion != 4 && meta.IPVersion != 6 {
+		return meta, fmt.Errorf("%w: unsupported IP version %d", ErrInvalidDatabase, meta.IPVersion)
+	}
+	return meta, nil
+}
+
+// Lookup returns the record of the network containing ip, and the prefix
+// length of that network. The prefix length of IPv4 addresses is relative
+// to the IPv4 address, also in IPv6 databases. It returns a nil record if the address is not in
+// the database.
+func (r *Reader) Lookup(ip net.IP) (interface{}, int, error) {
+	node, bits, err := r.find(ip)
+	if err != nil || node == r.Metadata.NodeCount {
+		return nil, bits, err
+	}
+
+	offset := node - r.Metadata.NodeCount - dataSectionSeparatorSize
+	if offset >= uint(len(r.data.buf)) {
+		return nil, bits, fmt.Errorf("%w: record points outside of the data section", ErrInvalidDatabase)
+	}
+	v, _, err := r.data.decode(offset)
+	return v, bits, err
+}
+
+// find walks the search tree and returns the record the address ends on.
+func (r *Reader) find(ip net.IP) (uint, int, error) {
+	var (
+		node uint
+		addr = ip.To4()
+	)
+	if addr != nil {
+		if r.Metadata.IPVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else {
+		if r.Metadata.IPVersion == 4 {
+			return 0, 0, fmt.Errorf("cannot look up IPv6 address %v in an IPv4 database", ip)
+		}
+		if addr = ip.To16(); addr == nil {
+			return 0, 0, fmt.Errorf("invalid IP address %v", ip)
+		}
+	}
+
+	nodeCount := r.Metadata.NodeCount
+	bitCount := len(addr) * 8
+	i := 0
+	for ; i < bitCount && node < nodeCount; i++ {
+		bit := uint(addr[i>>3]>>(7-uint(i&7))) & 1
+		var err error
+		if node, err = r.record(node, bit); err != nil {
+			return 0, 0, err
+		}
+	}
+	if node < nodeCount {
+		return 0, 0, fmt.Errorf("%w: search tree is too deep", ErrInvalidDatabase)
+	}
+	return node, i, nil
+}
+
+// record reads the left (0) or right (1) record of a node.
+func (r *Reader) record(node, bit uint) (uint, error) {
+	size := r.Metadata.RecordSize
+	offset := node * size / 4
+	if offset+size/4 > uint(len(r.tree)) {
+		return 0, fmt.Errorf("%w: node %d outside of the search tree", ErrInvalidDatabase, node)
+	}
+	b := r.tree[offset : offset+size/4]
+
+	switch size {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6]), nil
+	default:
+		b = b[bit*4:]
+		return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3]), nil
+	}
+}
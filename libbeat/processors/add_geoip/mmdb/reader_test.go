@@ -0,0 +1,148 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package mmdb_test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/processors/add_geoip/mmdb"
+	"github.com/elastic/beats/v7/libbeat/processors/add_geoip/mmdb/mmdbtest"
+)
+
+var networks = []mmdbtest.Network{
+	{CIDR: "81.2.69.0/24", Record: map[string]interface{}{"city": "London", "n": 1}},
+	{CIDR: "81.2.69.160/27", Record: map[string]interface{}{"city": "Wimbledon", "n": 2}},
+	{CIDR: "2001:db8::/32", Record: map[string]interface{}{
+		"city":     "Documentation",
+		"location": map[string]interface{}{"latitude": 51.5, "longitude": -0.12},
+		"codes":    []interface{}{"a", "b"},
+		"offset":   -5,
+		"big":      uint64(1) << 40,
+		"valid":    true,
+		"ratio":    float32(0.5),
+	}},
+}
+
+func TestLookup(t *testing.T) {
+	for _, recordSize := range []int{24, 28, 32} {
+		t.Run(fmt.Sprintf("record size %d", recordSize), func(t *testing.T) {
+			buf, err := mmdbtest.Build(mmdbtest.Options{DatabaseType: "Test-City", RecordSize: recordSize}, networks)
+			require.NoError(t, err)
+
+			r, err := mmdb.FromBytes(buf)
+			require.NoError(t, err)
+			assert.Equal(t, "Test-City", r.Metadata.DatabaseType)
+			assert.Equal(t, 6, r.Metadata.IPVersion)
+			assert.Equal(t, []string{"en"}, r.Metadata.Languages)
+
+			rec, prefix, err := r.Lookup(net.ParseIP("81.2.69.1"))
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"city": "London", "n": uint64(1)}, rec)
+			assert.Equal(t, 25, prefix)
+
+			rec, _, err = r.Lookup(net.ParseIP("81.2.69.170"))
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"city": "Wimbledon", "n": uint64(2)}, rec)
+
+			rec, _, err = r.Lookup(net.ParseIP("2001:db8::1"))
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{
+				"city":     "Documentation",
+				"location": map[string]interface{}{"latitude": 51.5, "longitude": -0.12},
+				"codes":    []interface{}{"a", "b"},
+				"offset":   int64(-5),
+				"big":      uint64(1) << 40,
+				"valid":    true,
+				"ratio":    0.5,
+			}, rec)
+
+			rec, _, err = r.Lookup(net.ParseIP("10.0.0.1"))
+			require.NoError(t, err)
+			assert.Nil(t, rec)
+		})
+	}
+}
+
+func TestLookupIPv4Database(t *testing.T) {
+	buf, err := mmdbtest.Build(mmdbtest.Options{IPVersion: 4}, networks[:2])
+	require.NoError(t, err)
+	r, err := mmdb.FromBytes(buf)
+	require.NoError(t, err)
+
+	rec, prefix, err := r.Lookup(net.ParseIP("81.2.69.161"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"city": "Wimbledon", "n": uint64(2)}, rec)
+	assert.Equal(t, 27, prefix)
+
+	_, _, err = r.Lookup(net.ParseIP("2001:db8::1"))
+	assert.Error(t, err)
+}
+
+func TestPointers(t *testing.T) {
+	// A map whose two values point to the same string.
+	data := []byte{
+		0x44, 'a', 'b', 'c', 'd', // offset 0: string "abcd"
+		0xe2,      // offset 5: map with 2 entries
+		0x41, 'x', // key "x"
+		0x20, 0x00, // pointer to offset 0
+		0x41, 'y', // key "y"
+		0x20, 0x00, // pointer to offset 0
+	}
+	// A single node: the left record points to the map, the right one is
+	// empty.
+	tree := []byte{0, 0, 1 + 16 + 5, 0, 0, 1}
+
+	var buf []byte
+	buf = append(buf, tree...)
+	buf = append(buf, make([]byte, 16)...)
+	buf = append(buf, data...)
+	buf = append(buf, "\xAB\xCD\xEFMaxMind.com"...)
+	buf = append(buf,
+		0xe3,
+		0x4a, 'n', 'o', 'd', 'e', '_', 'c', 'o', 'u', 'n', 't', 0xc1, 1,
+		0x4b, 'r', 'e', 'c', 'o', 'r', 'd', '_', 's', 'i', 'z', 'e', 0xa1, 24,
+		0x4a, 'i', 'p', '_', 'v', 'e', 'r', 's', 'i', 'o', 'n', 0xa1, 4,
+	)
+
+	r, err := mmdb.FromBytes(buf)
+	require.NoError(t, err)
+
+	rec, prefix, err := r.Lookup(net.ParseIP("1.2.3.4"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"x": "abcd", "y": "abcd"}, rec)
+	assert.Equal(t, 1, prefix)
+
+	rec, _, err = r.Lookup(net.ParseIP("200.2.3.4"))
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	_, err := mmdb.FromBytes([]byte("not a database"))
+	assert.ErrorIs(t, err, mmdb.ErrInvalidDatabase)
+
+	buf, err := mmdbtest.Build(mmdbtest.Options{}, networks)
+	require.NoError(t, err)
+	_, err = mmdb.FromBytes(buf[:len(buf)-10])
+	assert.Error(t, err)
+}
@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package add_user_agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor/registry"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	procName = "add_user_agent"
+	logName  = "processor." + procName
+)
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+	jsprocessor.RegisterPlugin("AddUserAgent", New)
+}
+
+type processor struct {
+	config
+	log    *logp.Logger
+	parser *parser
+
+	// cache holds the parsed user agents by user agent string. It is nil if
+	// caching is disabled.
+	cache *lru.Cache[string, *userAgent]
+}
+
+// New constructs a new add_user_agent processor.
+func New(cfg *conf.C) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	return newAddUserAgent(c, logp.NewLogger(logName))
+}
+
+func newAddUserAgent(c config, log *logp.Logger) (*processor, error) {
+	rules := defaultRules
+	if c.RegexFile != "" {
+		path := paths.Resolve(paths.Config, c.RegexFile)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read regex file: %w", err)
+		}
+		rules = data
+	}
+
+	parser, err := newParser(rules, func(section string, index int, err error) {
+		log.Warnf("Skipping rule %d of %s in regex file %s: %v", index, section, c.RegexFile, err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user agent rules: %w", err)
+	}
+
+	p := &processor{config: c, log: log, parser: parser}
+	if c.CacheSize > 0 {
+		if p.cache, err = lru.New[string, *userAgent](c.CacheSize); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *processor) String() string {
+	json, _ := json.Marshal(p.config)
+	return procName + "=" + string(json)
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	v, err := event.GetValue(p.Field)
+	if err != nil {
+		if p.IgnoreMissing {
+			return event, nil
+		}
+		return event, fmt.Errorf("%v source field [%v] not found: %w", procName, p.Field, err)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return event, fmt.Errorf("%v source field [%v] is not a string", procName, p.Field)
+	}
+	if s == "" {
+		return event, nil
+	}
+
+	if err := p.put(event, p.parse(s)); err != nil {
+		return event, fmt.Errorf("failed to write user agent to target field [%v]: %w", p.Target, err)
+	}
+	return event, nil
+}
+
+func (p *processor) parse(s string) *userAgent {
+	if p.cache == nil {
+		return p.parser.parse(s)
+	}
+
+	if ua, ok := p.cache.Get(s); ok {
+		return ua
+	}
+	ua := p.parser.parse(s)
+	p.cache.Add(s, ua)
+	return ua
+}
+
+// put writes the fields of ua under the target field, keeping the other
+// fields of the target, such as the original user agent.
+func (p *processor) put(event *beat.Event, ua *userAgent) error {
+	fields := mapstr.M{
+		"name":   ua.Name,
+		"device": mapstr.M{"name": ua.Device},
+	}
+	if ua.Version != "" {
+		fields["version"] = ua.Version
+	}
+	if ua.OSName != "" {
+		osFields := mapstr.M{"name": ua.OSName, "full": ua.OSName}
+		if ua.OSVersion != "" {
+			osFields["version"] = ua.OSVersion
+			osFields["full"] = ua.OSName + " " + ua.OSVersion
+		}
+		fields["os"] = osFields
+	}
+
+	for k, v := range fields.Flatten() {
+		if _, err := event.PutValue(p.Target+"."+k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package add_user_agent
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const firefoxUA = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:121.0) Gecko/20100101 Firefox/121.0"
+
+func TestAddUserAgent(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   map[string]interface{}
+		fields   mapstr.M
+		expected mapstr.M
+		wantErr  string
+	}{
+		{
+			name:   "defaults",
+			config: map[string]interface{}{},
+			fields: mapstr.M{"user_agent": mapstr.M{"original": firefoxUA}},
+			expected: mapstr.M{
+				"user_agent": mapstr.M{
+					"original": firefoxUA,
+					"name":     "Firefox",
+					"version":  "121.0",
+					"os": mapstr.M{
+						"name":    "Mac OS X",
+						"version": "10.15",
+						"full":    "Mac OS X 10.15",
+					},
+					"device": mapstr.M{"name": "Mac"},
+				},
+			},
+		},
+		{
+			name:   "custom field and target",
+			config: map[string]interface{}{"field": "http.request.headers.user-agent", "target": "client.user_agent"},
+			fields: mapstr.M{"http.request.headers.user-agent": "curl/8.4.0"},
+			expected: mapstr.M{
+				"http.request.headers.user-agent": "curl/8.4.0",
+				"client": mapstr.M{
+					"user_agent": mapstr.M{
+						"name":    "curl",
+						"version": "8.4.0",
+						"device":  mapstr.M{"name": "Other"},
+					},
+				},
+			},
+		},
+		{
+			name:   "os without version",
+			config: map[string]interface{}{},
+			fields: mapstr.M{"user_agent": mapstr.M{"original": "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"}},
+			expected: mapstr.M{
+				"user_agent": mapstr.M{
+					"original": "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+					"name":     "Firefox",
+					"version":  "121.0",
+					"os":       mapstr.M{"name": "Linux", "full": "Linux"},
+					"device":   mapstr.M{"name": "Other"},
+				},
+			},
+		},
+		{
+			name:     "missing field",
+			config:   map[string]interface{}{},
+			fields:   mapstr.M{"message": "hello"},
+			expected: mapstr.M{"message": "hello"},
+			wantErr:  "source field [user_agent.original] not found",
+		},
+		{
+			name:     "ignore missing",
+			config:   map[string]interface{}{"ignore_missing": true},
+			fields:   mapstr.M{"message": "hello"},
+			expected: mapstr.M{"message": "hello"},
+		},
+		{
+			name:     "not a string",
+			config:   map[string]interface{}{},
+			fields:   mapstr.M{"user_agent": mapstr.M{"original": 1}},
+			expected: mapstr.M{"user_agent": mapstr.M{"original": 1}},
+			wantErr:  "is not a string",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := New(conf.MustNewConfigFrom(tc.config))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: tc.fields})
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, event.Fields)
+		})
+	}
+}
+
+func TestAddUserAgentRegexFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "regexes.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+user_agent_parsers:
+  - regex: '^(MyAgent)/(\d+)\.(\d+)'
+    family_replacement: 'My Agent'
+`), 0o644))
+
+	p, err := New(conf.MustNewConfigFrom(map[string]interface{}{"regex_file": path}))
+	require.NoError(t, err)
+
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{"user_agent": mapstr.M{"original": "MyAgent/1.2"}}})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{
+		"original": "MyAgent/1.2",
+		"name":     "My Agent",
+		"version":  "1.2",
+		"device":   mapstr.M{"name": "Other"},
+	}, event.Fields["user_agent"])
+
+	// The embedded rules are replaced by the file.
+	event, err = p.Run(&beat.Event{Fields: mapstr.M{"user_agent": mapstr.M{"original": firefoxUA}}})
+	require.NoError(t, err)
+	name, _ := event.GetValue("user_agent.name")
+	assert.Equal(t, "Other", name)
+
+	_, err = New(conf.MustNewConfigFrom(map[string]interface{}{"regex_file": filepath.Join(t.TempDir(), "missing.yaml")}))
+	assert.ErrorContains(t, err, "failed to read regex file")
+}
+
+func TestAddUserAgentCache(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(map[string]interface{}{"cache_size": 1}))
+	require.NoError(t, err)
+	cache := p.(*processor).cache
+
+	run := func(ua string) {
+		_, err := p.Run(&beat.Event{Fields: mapstr.M{"user_agent": mapstr.M{"original": ua}}})
+		require.NoError(t, err)
+	}
+
+	run(firefoxUA)
+	assert.True(t, cache.Contains(firefoxUA))
+	run("curl/8.4.0")
+	assert.False(t, cache.Contains(firefoxUA))
+	assert.True(t, cache.Contains("curl/8.4.0"))
+
+	p, err = New(conf.MustNewConfigFrom(map[string]interface{}{"cache_size": 0}))
+	require.NoError(t, err)
+	assert.Nil(t, p.(*processor).cache)
+}
+
+func BenchmarkAddUserAgent(b *testing.B) {
+	uas := []string{
+		firefoxUA,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.130 Safari/537.36",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+	}
+
+	for _, cacheSize := range []int{0, 1000} {
+		b.Run("cache_size="+strconv.Itoa(cacheSize), func(b *testing.B) {
+			p, err := New(conf.MustNewConfigFrom(map[string]interface{}{"cache_size": cacheSize}))
+			require.NoError(b, err)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := p.Run(&beat.Event{Fields: mapstr.M{"user_agent": mapstr.M{"original": uas[i%len(uas)]}}})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package add_user_agent
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// defaultRules are the rules used unless a regex file is configured.
+//
+//go:embed regexes.yaml
+var defaultRules []byte
+
+// other is the name of user agents and devices not matched by any rule.
+const other = "Other"
+
+// ruleFile is the format of a regexes.yaml file.
+type ruleFile struct {
+	UserAgentParsers []ruleConfig `yaml:"user_agent_parsers"`
+	OSParsers        []ruleConfig `yaml:"os_parsers"`
+	DeviceParsers    []ruleConfig `yaml:"device_parsers"`
+}
+
+type ruleConfig struct {
+	Regex     string `yaml:"regex"`
+	RegexFlag string `yaml:"regex_flag"`
+
+	FamilyReplacement string `yaml:"family_replacement"`
+	V1Replacement     string `yaml:"v1_replacement"`
+	V2Replacement     string `yaml:"v2_replacement"`
+	V3Replacement     string `yaml:"v3_replacement"`
+	V4Replacement     string `yaml:"v4_replacement"`
+
+	OSReplacement   string `yaml:"os_replacement"`
+	OSV1Replacement string `yaml:"os_v1_replacement"`
+	OSV2Replacement string `yaml:"os_v2_replacement"`
+	OSV3Replacement string `yaml:"os_v3_replacement"`
+	OSV4Replacement string `yaml:"os_v4_replacement"`
+
+	DeviceReplacement string `yaml:"device_replacement"`
+	BrandReplacement  string `yaml:"brand_replacement"`
+	ModelReplacement  string `yaml:"model_replacement"`
+}
+
+// rule is a compiled rule. The replacements are, in order, the name and the
+// version parts for user agent and OS rules, and the device, brand and model
+// for device rules. An empty replacement uses the capture group at the same
+// position.
+type rule struct {
+	re           *regexp.Regexp
+	replacements []string
+}
+
+// match applies the rule to s. It returns false if the rule does not match.
+func (r *rule) match(s string) ([]string, bool) {
+	m := r.re.FindStringSubmatchIndex(s)
+	if m == nil {
+		return nil, false
+	}
+
+	values := make([]string, len(r.replacements))
+	for i, repl := range r.replacements {
+		if repl != "" {
+			values[i] = strings.TrimSpace(expand(repl, s, m))
+		} else if g := i + 1; 2*g+1 < len(m) && m[2*g] >= 0 {
+			values[i] = s[m[2*g]:m[2*g+1]]
+		}
+	}
+	return values, true
+}
+
+// expand replaces the $1 to $9 references in repl with the capture groups of
+// the submatch m of s. References to groups that did not participate in the
+// match are replaced by an empty string.
+func expand(repl, s string, m []int) string {
+	if !strings.Contains(repl, "$") {
+		return repl
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(repl); i++ {
+		if repl[i] == '$' && i+1 < len(repl) && repl[i+1] >= '1' && repl[i+1] <= '9' {
+			g := int(repl[i+1] - '0')
+			if 2*g+1 < len(m) && m[2*g] >= 0 {
+				b.WriteString(s[m[2*g]:m[2*g+1]])
+			}
+			i++
+			continue
+		}
+		b.WriteByte(repl[i])
+	}
+	return b.String()
+}
+
+// parser parses user agent strings.
+type parser struct {
+	userAgents []rule
+	os         []rule
+	devices    []rule
+}
+
+// userAgent is the result of parsing a user agent string.
+type userAgent struct {
+	Name    string
+	Version string
+
+	OSName    string
+	OSVersion string
+
+	Device string
+}
+
+// newParser compiles the rules of a regexes.yaml file. Rules that cannot be
+// compiled, for example because they use syntax that is not supported by RE2,
+// are reported to skip and left out.
+func newParser(data []byte, skip func(section string, index int, err error)) (*parser, error) {
+	var file ruleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+	if len(file.UserAgentParsers) == 0 && len(file.OSParsers) == 0 && len(file.DeviceParsers) == 0 {
+		return nil, fmt.Errorf("no rules found")
+	}
+
+	compile := func(section string, configs []ruleConfig, replacements func(ruleConfig) []string) []rule {
+		rules := make([]rule, 0, len(configs))
+		for i, c := range configs {
+			expr := c.Regex
+			switch c.RegexFlag {
+			case "":
+			case "i":
+				expr = "(?i)" + expr
+			default:
+				skip(section, i, fmt.Errorf("unsupported regex_flag '%s'", c.RegexFlag))
+				continue
+			}
+
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				skip(section, i, err)
+				continue
+			}
+			rules = append(rules, rule{re: re, replacements: replacements(c)})
+		}
+		return rules
+	}
+
+	return &parser{
+		userAgents: compile("user_agent_parsers", file.UserAgentParsers, func(c ruleConfig) []string {
+			return []string{c.FamilyReplacement, c.V1Replacement, c.V2Replacement, c.V3Replacement, c.V4Replacement}
+		}),
+		os: compile("os_parsers", file.OSParsers, func(c ruleConfig) []string {
+			return []string{c.OSReplacement, c.OSV1Replacement, c.OSV2Replacement, c.OSV3Replacement, c.OSV4Replacement}
+		}),
+		devices: compile("device_parsers", file.DeviceParsers, func(c ruleConfig) []string {
+			return []string{c.DeviceReplacement, c.BrandReplacement, c.ModelReplacement}
+		}),
+	}, nil
+}
+
+// parse parses the user agent string s. The name and the device are set to
+// "Other" if no rule matches.
+func (p *parser) parse(s string) *userAgent {
+	ua := &userAgent{Name: other, Device: other}
+
+	if values, ok := firstMatch(p.userAgents, s); ok && values[0] != "" {
+		ua.Name = values[0]
+		ua.Version = joinVersion(values[1:])
+	}
+	if values, ok := firstMatch(p.os, s); ok && values[0] != "" {
+		ua.OSName = values[0]
+		ua.OSVersion = joinVersion(values[1:])
+	}
+	if values, ok := firstMatch(p.devices, s); ok && values[0] != "" {
+		ua.Device = values[0]
+	}
+	return ua
+}
+
+func firstMatch(rules []rule, s string) ([]string, bool) {
+	for i := range rules {
+		if values, ok := rules[i].match(s); ok {
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+// joinVersion joins the leading non-empty version parts with dots.
+func joinVersion(parts []string) string {
+	n := 0
+	for n < len(parts) && parts[n] != "" {
+		n++
+	}
+	return strings.Join(parts[:n], ".")
+}
@@ -0,0 +1,134 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package add_user_agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultRules(t *testing.T) {
+	p, err := newParser(defaultRules, func(section string, index int, err error) {
+		t.Errorf("rule %d of %s skipped: %v", index, section, err)
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		ua       string
+		expected userAgent
+	}{
+		{
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.130 Safari/537.36",
+			expected: userAgent{Name: "Chrome", Version: "120.0.6099.130", OSName: "Windows", OSVersion: "10", Device: "Other"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			expected: userAgent{Name: "Edge", Version: "120.0.2210.91", OSName: "Windows", OSVersion: "10", Device: "Other"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+			expected: userAgent{Name: "Safari", Version: "17.2", OSName: "Mac OS X", OSVersion: "10.15.7", Device: "Mac"},
+		},
+		{
+			ua:       "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			expected: userAgent{Name: "Firefox", Version: "121.0", OSName: "Ubuntu", Device: "Other"},
+		},
+		{
+			ua:       "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			expected: userAgent{Name: "Mobile Safari", Version: "17.2", OSName: "iOS", OSVersion: "17.2.1", Device: "iPhone"},
+		},
+		{
+			ua:       "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			expected: userAgent{Name: "Chrome Mobile iOS", Version: "120.0.6099.119", OSName: "iOS", OSVersion: "16.6", Device: "iPad"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			expected: userAgent{Name: "Chrome Mobile", Version: "120.0.6099.144", OSName: "Android", OSVersion: "14", Device: "Samsung SM-S918B"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Linux; Android 13; Pixel 7 Build/TQ3A.230901.001; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/119.0.6045.163 Mobile Safari/537.36",
+			expected: userAgent{Name: "Chrome Mobile WebView", Version: "119.0.6045.163", OSName: "Android", OSVersion: "13", Device: "Pixel 7"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Linux; U; Android 4.0.3; ko-kr; LG-L160L Build/IML74K) AppleWebkit/534.30 (KHTML, like Gecko) Version/4.0 Mobile Safari/534.30",
+			expected: userAgent{Name: "Android", Version: "4.0.3", OSName: "Android", OSVersion: "4.0.3", Device: "LG-L160L"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Windows NT 6.1; Trident/7.0; rv:11.0) like Gecko",
+			expected: userAgent{Name: "IE", Version: "11.0", OSName: "Windows", OSVersion: "7", Device: "Other"},
+		},
+		{
+			ua:       "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected: userAgent{Name: "Googlebot", Version: "2.1", Device: "Spider"},
+		},
+		{
+			ua:       "curl/8.4.0",
+			expected: userAgent{Name: "curl", Version: "8.4.0", Device: "Other"},
+		},
+		{
+			ua:       "python-requests/2.31.0",
+			expected: userAgent{Name: "Python Requests", Version: "2.31.0", Device: "Other"},
+		},
+		{
+			ua:       "Mozilla/5.0 (X11; CrOS x86_64 15633.69.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.6045.212 Safari/537.36",
+			expected: userAgent{Name: "Chrome", Version: "119.0.6045.212", OSName: "Chrome OS", OSVersion: "15633.69.0", Device: "Other"},
+		},
+		{
+			ua:       "something unknown",
+			expected: userAgent{Name: "Other", Device: "Other"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ua, func(t *testing.T) {
+			assert.Equal(t, tc.expected, *p.parse(tc.ua))
+		})
+	}
+}
+
+func TestNewParser(t *testing.T) {
+	var skipped []string
+	p, err := newParser([]byte(`
+user_agent_parsers:
+  - regex: '(?<!Foo)(Bar)/(\d+)'
+  - regex: '(Foo)/(\d+)\.(\d+)'
+    family_replacement: 'Foo $1'
+    v2_replacement: 'x'
+device_parsers:
+  - regex: '(widget)'
+    regex_flag: 'i'
+    device_replacement: 'Widget $1'
+  - regex: '(gadget)'
+    regex_flag: 'x'
+`), func(section string, index int, err error) {
+		skipped = append(skipped, section)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user_agent_parsers", "device_parsers"}, skipped)
+
+	assert.Equal(t, userAgent{Name: "Foo Foo", Version: "1.x", Device: "Widget WIDGET"}, *p.parse("Foo/1.2 WIDGET"))
+	assert.Equal(t, userAgent{Name: "Other", Device: "Other"}, *p.parse("Bar/1 gadget"))
+
+	_, err = newParser([]byte(`other: []`), nil)
+	assert.ErrorContains(t, err, "no rules found")
+
+	_, err = newParser([]byte(`user_agent_parsers: {`), nil)
+	assert.ErrorContains(t, err, "failed to parse rules")
+}
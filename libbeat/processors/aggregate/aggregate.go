@@ -0,0 +1,289 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/mitchellh/hashstructure"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID atomic.Uint32
+
+const (
+	processorName = "aggregate"
+	logName       = "processor." + processorName
+)
+
+func init() {
+	// We cannot use this as a JS plugin as it is stateful and includes a Close method.
+	processors.RegisterPlugin(processorName, New)
+}
+
+type metrics struct {
+	aggregated *monitoring.Int // events consumed into a group
+	overflow   *monitoring.Int // events passed through because max_groups was reached
+	emitted    *monitoring.Int // aggregated events emitted
+	dropped    *monitoring.Int // aggregated events lost as they could not be emitted
+}
+
+// group holds the state of one group within the current window.
+type group struct {
+	values []interface{}
+	count  int64
+	stats  []*stats
+}
+
+type aggregate struct {
+	config  config
+	log     *logp.Logger
+	metrics metrics
+	clock   clockwork.Clock
+
+	mu     sync.Mutex
+	emit   processors.EmitFunc
+	start  time.Time // start of the current window
+	groups map[uint64]*group
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New constructs a new aggregate processor.
+func New(cfg *conf.C) (beat.Processor, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("failed to unpack %v processor configuration: %w", processorName, err)
+	}
+
+	return newAggregate(config, clockwork.NewRealClock()), nil
+}
+
+func newAggregate(config config, clock clockwork.Clock) *aggregate {
+	if len(config.Percentiles) == 0 {
+		config.Percentiles = defaultPercentiles
+	}
+
+	// Logging and metrics (each processor instance has a unique ID).
+	var (
+		id  = int(instanceID.Add(1))
+		log = logp.NewLogger(logName).With("instance_id", id)
+		reg = monitoring.Default.NewRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+
+	p := &aggregate{
+		config: config,
+		log:    log,
+		metrics: metrics{
+			aggregated: monitoring.NewInt(reg, "aggregated"),
+			overflow:   monitoring.NewInt(reg, "overflow"),
+			emitted:    monitoring.NewInt(reg, "emitted"),
+			dropped:    monitoring.NewInt(reg, "dropped"),
+		},
+		clock:  clock,
+		start:  clock.Now().Truncate(config.Window),
+		groups: map[uint64]*group{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+// Run adds the event to its group. The event is dropped unless the maximum
+// number of groups has been reached, in which case it is returned as-is.
+func (p *aggregate) Run(event *beat.Event) (*beat.Event, error) {
+	values := make([]interface{}, len(p.config.GroupBy))
+	for i, field := range p.config.GroupBy {
+		v, err := event.GetValue(field)
+		if err != nil {
+			if !errors.Is(err, mapstr.ErrKeyNotFound) {
+				return event, fmt.Errorf("error getting value of field '%v': %w", field, err)
+			}
+			continue
+		}
+		values[i] = v
+	}
+
+	key, err := hashstructure.Hash(values, nil)
+	if err != nil {
+		return event, fmt.Errorf("could not make group key: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	g, found := p.groups[key]
+	if !found {
+		if len(p.groups) >= p.config.MaxGroups {
+			p.metrics.overflow.Inc()
+			return event, nil
+		}
+		g = p.newGroup(values)
+		p.groups[key] = g
+	}
+
+	g.count++
+	for i, m := range p.config.Metrics {
+		v, err := event.GetValue(m.Field)
+		if err != nil {
+			continue
+		}
+		if f, ok := toFloat(v); ok {
+			g.stats[i].add(f)
+		}
+	}
+
+	p.metrics.aggregated.Inc()
+	return nil, nil
+}
+
+func (p *aggregate) newGroup(values []interface{}) *group {
+	g := &group{
+		values: values,
+		stats:  make([]*stats, len(p.config.Metrics)),
+	}
+	for i, m := range p.config.Metrics {
+		maxSamples := 0
+		if slices.Contains(m.stats(), statPercentiles) {
+			maxSamples = p.config.MaxSamples
+		}
+		g.stats[i] = newStats(maxSamples)
+	}
+	return g
+}
+
+// SetEmitter implements processors.Emitter.
+func (p *aggregate) SetEmitter(emit processors.EmitFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.emit = emit
+}
+
+// loop closes the window whenever its end is reached.
+func (p *aggregate) loop() {
+	defer close(p.done)
+
+	for {
+		p.mu.Lock()
+		end := p.start.Add(p.config.Window)
+		p.mu.Unlock()
+
+		select {
+		case <-p.stop:
+			return
+		case <-p.clock.After(end.Sub(p.clock.Now())):
+			p.flush(end)
+		}
+	}
+}
+
+// flush closes the current window at end and emits its aggregates.
+func (p *aggregate) flush(end time.Time) {
+	p.mu.Lock()
+	events := p.closeWindow(end)
+	emit := p.emit
+	p.mu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	if emit == nil {
+		p.log.Warnf("Dropping %d aggregated events: the processor cannot emit events, "+
+			"it must be configured on an input or module instead of globally", len(events))
+		p.metrics.dropped.Add(int64(len(events)))
+		return
+	}
+
+	// Emit without holding the lock: the pipeline client might be running
+	// this processor concurrently.
+	for _, event := range events {
+		emit(event)
+		p.metrics.emitted.Inc()
+	}
+}
+
+// closeWindow builds an event per group of the current window and starts a
+// new window.
+func (p *aggregate) closeWindow(end time.Time) []*beat.Event {
+	events := make([]*beat.Event, 0, len(p.groups))
+	for _, g := range p.groups {
+		events = append(events, p.makeEvent(g, p.start, end))
+	}
+
+	p.groups = map[uint64]*group{}
+	p.start = p.clock.Now().Truncate(p.config.Window)
+	return events
+}
+
+func (p *aggregate) makeEvent(g *group, start, end time.Time) *beat.Event {
+	fields := mapstr.M{}
+	for i, field := range p.config.GroupBy {
+		if g.values[i] != nil {
+			_, _ = fields.Put(field, g.values[i])
+		}
+	}
+
+	agg := mapstr.M{
+		"count": g.count,
+		"window": mapstr.M{
+			"start": start,
+			"end":   end,
+		},
+	}
+	for i, m := range p.config.Metrics {
+		if values := g.stats[i].fields(m.stats(), p.config.Percentiles); values != nil {
+			_, _ = agg.Put(m.Field, values)
+		}
+	}
+	_, _ = fields.Put(p.config.Target, agg)
+
+	return &beat.Event{
+		Timestamp: start,
+		Fields:    fields,
+	}
+}
+
+// Close stops the window timer and emits the aggregates of the current,
+// partial window.
+func (p *aggregate) Close() error {
+	close(p.stop)
+	<-p.done
+	p.flush(p.clock.Now())
+	return nil
+}
+
+func (p *aggregate) String() string {
+	return fmt.Sprintf("%v=[group_by=%v, window=%v, target=%v]",
+		processorName, p.config.GroupBy, p.config.Window, p.config.Target)
+}
@@ -0,0 +1,236 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregate
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type collector struct {
+	mu     sync.Mutex
+	events []*beat.Event
+	ch     chan struct{}
+}
+
+func newCollector() *collector {
+	return &collector{ch: make(chan struct{}, 100)}
+}
+
+func (c *collector) emit(event *beat.Event) {
+	c.mu.Lock()
+	c.events = append(c.events, event)
+	c.mu.Unlock()
+	c.ch <- struct{}{}
+}
+
+// sorted returns the collected events sorted by the given field.
+func (c *collector) sorted(t *testing.T, field string) []*beat.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	events := append([]*beat.Event(nil), c.events...)
+	sort.Slice(events, func(i, j int) bool {
+		a, _ := events[i].GetValue(field)
+		b, _ := events[j].GetValue(field)
+		return a.(string) < b.(string)
+	})
+	return events
+}
+
+func testConfig(t *testing.T, settings map[string]interface{}) config {
+	t.Helper()
+	c := defaultConfig()
+	require.NoError(t, conf.MustNewConfigFrom(settings).Unpack(&c))
+	return c
+}
+
+func TestAggregate(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 30, 0, time.UTC)
+	clock := clockwork.NewFakeClockAt(start)
+	p := newAggregate(testConfig(t, map[string]interface{}{
+		"group_by": []string{"host.name"},
+		"metrics": []map[string]interface{}{
+			{"field": "bytes"},
+			{"field": "duration", "stats": []string{"max", "percentiles"}},
+		},
+		"percentiles": []float64{50, 99.5},
+	}), clock)
+	c := newCollector()
+	p.SetEmitter(c.emit)
+
+	for i, host := range []string{"a", "a", "b", "a"} {
+		out, err := p.Run(&beat.Event{Fields: mapstr.M{
+			"host":     mapstr.M{"name": host},
+			"bytes":    int64(i + 1),
+			"duration": float64(10 * (i + 1)),
+		}})
+		require.NoError(t, err)
+		assert.Nil(t, out)
+	}
+	// Non numeric values are only counted.
+	_, err := p.Run(&beat.Event{Fields: mapstr.M{"host": mapstr.M{"name": "b"}, "bytes": "many"}})
+	require.NoError(t, err)
+
+	require.NoError(t, p.Close())
+
+	events := c.sorted(t, "host.name")
+	require.Len(t, events, 2)
+
+	windowStart := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, windowStart, events[0].Timestamp)
+	assert.Equal(t, mapstr.M{
+		"host": mapstr.M{"name": "a"},
+		"aggregate": mapstr.M{
+			"count":  int64(3),
+			"window": mapstr.M{"start": windowStart, "end": start},
+			"bytes": mapstr.M{
+				"sum": float64(7),
+				"min": float64(1),
+				"max": float64(4),
+				"avg": float64(7) / 3,
+			},
+			"duration": mapstr.M{
+				"max": float64(40),
+				"percentiles": mapstr.M{
+					"p50":   float64(20),
+					"p99_5": 39.8,
+				},
+			},
+		},
+	}, events[0].Fields)
+
+	assert.Equal(t, mapstr.M{
+		"host": mapstr.M{"name": "b"},
+		"aggregate": mapstr.M{
+			"count":  int64(2),
+			"window": mapstr.M{"start": windowStart, "end": start},
+			"bytes": mapstr.M{
+				"sum": float64(3),
+				"min": float64(3),
+				"max": float64(3),
+				"avg": float64(3),
+			},
+			"duration": mapstr.M{
+				"max":         float64(30),
+				"percentiles": mapstr.M{"p50": float64(30), "p99_5": float64(30)},
+			},
+		},
+	}, events[1].Fields)
+	assert.Equal(t, int64(2), p.metrics.emitted.Get())
+	assert.Equal(t, int64(5), p.metrics.aggregated.Get())
+}
+
+func TestAggregateWindow(t *testing.T) {
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	p := newAggregate(testConfig(t, map[string]interface{}{
+		"group_by": []string{"service"},
+		"window":   "10s",
+	}), clock)
+	c := newCollector()
+	p.SetEmitter(c.emit)
+
+	_, err := p.Run(&beat.Event{Fields: mapstr.M{"service": "web"}})
+	require.NoError(t, err)
+
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Second)
+
+	select {
+	case <-c.ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("window was not flushed")
+	}
+
+	events := c.sorted(t, "service")
+	require.Len(t, events, 1)
+	count, _ := events[0].GetValue("aggregate.count")
+	assert.Equal(t, int64(1), count)
+	end, _ := events[0].GetValue("aggregate.window.end")
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 10, 0, time.UTC), end)
+
+	// The next window starts empty, so nothing is emitted on close.
+	require.NoError(t, p.Close())
+	assert.Len(t, c.sorted(t, "service"), 1)
+}
+
+func TestAggregateMaxGroups(t *testing.T) {
+	p := newAggregate(testConfig(t, map[string]interface{}{
+		"group_by":   []string{"id"},
+		"max_groups": 1,
+	}), clockwork.NewFakeClock())
+	p.SetEmitter(newCollector().emit)
+	defer p.Close()
+
+	out, err := p.Run(&beat.Event{Fields: mapstr.M{"id": 1}})
+	require.NoError(t, err)
+	assert.Nil(t, out)
+
+	event := &beat.Event{Fields: mapstr.M{"id": 2}}
+	out, err = p.Run(event)
+	require.NoError(t, err)
+	assert.Equal(t, event, out)
+	assert.Equal(t, int64(1), p.metrics.overflow.Get())
+}
+
+func TestAggregateWithoutEmitter(t *testing.T) {
+	p := newAggregate(defaultConfig(), clockwork.NewFakeClock())
+
+	_, err := p.Run(&beat.Event{Fields: mapstr.M{"message": "hello"}})
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+	assert.Equal(t, int64(1), p.metrics.dropped.Get())
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4}
+	tests := map[float64]float64{
+		50:  2.5,
+		100: 4,
+		25:  1.75,
+	}
+	for p, expected := range tests {
+		assert.InDelta(t, expected, percentile(values, p), 1e-9, "p%v", p)
+	}
+	assert.Equal(t, float64(0), percentile(nil, 50))
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"invalid stat":       {"metrics": []map[string]interface{}{{"field": "x", "stats": []string{"median"}}}},
+		"missing field":      {"metrics": []map[string]interface{}{{"stats": []string{"sum"}}}},
+		"invalid percentile": {"percentiles": []float64{0}},
+		"empty target":       {"target": ""},
+		"zero window":        {"window": "0s"},
+	}
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig()
+			assert.Error(t, conf.MustNewConfigFrom(settings).Unpack(&c))
+		})
+	}
+}
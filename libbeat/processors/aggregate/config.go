@@ -0,0 +1,108 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregate
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	statSum         = "sum"
+	statMin         = "min"
+	statMax         = "max"
+	statAvg         = "avg"
+	statPercentiles = "percentiles"
+)
+
+type config struct {
+	// GroupBy lists the fields whose values identify a group. All events are
+	// aggregated into a single group if it is empty.
+	GroupBy []string `config:"group_by"`
+
+	// Window is the length of the tumbling window.
+	Window time.Duration `config:"window" validate:"positive,nonzero"`
+
+	// Metrics lists the numeric fields to aggregate.
+	Metrics []metricConfig `config:"metrics"`
+
+	// Percentiles computed for metrics requesting them, defaultPercentiles
+	// if empty.
+	Percentiles []float64 `config:"percentiles"`
+
+	// Target is the field the aggregated values are written to.
+	Target string `config:"target"`
+
+	// MaxGroups bounds the number of groups per window. Events of new groups
+	// are passed through unaggregated once the limit is reached.
+	MaxGroups int `config:"max_groups" validate:"min=1"`
+
+	// MaxSamples bounds the number of values kept per group and metric to
+	// compute percentiles. Reservoir sampling is used past the limit.
+	MaxSamples int `config:"max_samples" validate:"min=1"`
+}
+
+type metricConfig struct {
+	Field string   `config:"field" validate:"required"`
+	Stats []string `config:"stats"`
+}
+
+// defaultPercentiles is not part of defaultConfig, as a user provided list
+// would be merged into it.
+var defaultPercentiles = []float64{50, 95, 99}
+
+func defaultConfig() config {
+	return config{
+		Window:     time.Minute,
+		Target:     "aggregate",
+		MaxGroups:  10000,
+		MaxSamples: 10000,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.Target == "" {
+		return fmt.Errorf("target must not be empty")
+	}
+	for _, p := range c.Percentiles {
+		if p <= 0 || p > 100 {
+			return fmt.Errorf("invalid percentile %v, must be in (0, 100]", p)
+		}
+	}
+	return nil
+}
+
+func (m *metricConfig) Validate() error {
+	for _, s := range m.Stats {
+		switch s {
+		case statSum, statMin, statMax, statAvg, statPercentiles:
+		default:
+			return fmt.Errorf("invalid stat '%s' for field '%s', must be one of %s, %s, %s, %s or %s",
+				s, m.Field, statSum, statMin, statMax, statAvg, statPercentiles)
+		}
+	}
+	return nil
+}
+
+// stats returns the configured stats or all stats but percentiles by default.
+func (m *metricConfig) stats() []string {
+	if len(m.Stats) == 0 {
+		return []string{statSum, statMin, statMax, statAvg}
+	}
+	return m.Stats
+}
@@ -0,0 +1,147 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregate
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// stats accumulates the values of one metric of a group.
+type stats struct {
+	count    int64
+	sum      float64
+	min, max float64
+
+	// samples is a reservoir of the observed values, only kept when
+	// percentiles are requested.
+	samples    []float64
+	maxSamples int
+}
+
+func newStats(maxSamples int) *stats {
+	return &stats{
+		min:        math.Inf(1),
+		max:        math.Inf(-1),
+		maxSamples: maxSamples,
+	}
+}
+
+func (s *stats) add(v float64) {
+	s.count++
+	s.sum += v
+	s.min = math.Min(s.min, v)
+	s.max = math.Max(s.max, v)
+
+	if s.maxSamples == 0 {
+		return
+	}
+	if len(s.samples) < s.maxSamples {
+		s.samples = append(s.samples, v)
+		return
+	}
+	if i := rand.Int64N(s.count); i < int64(s.maxSamples) {
+		s.samples[i] = v
+	}
+}
+
+// fields reports the requested stats. Nothing is reported if no value was
+// observed.
+func (s *stats) fields(names []string, percentiles []float64) mapstr.M {
+	if s.count == 0 {
+		return nil
+	}
+
+	m := mapstr.M{}
+	for _, name := range names {
+		switch name {
+		case statSum:
+			m[statSum] = s.sum
+		case statMin:
+			m[statMin] = s.min
+		case statMax:
+			m[statMax] = s.max
+		case statAvg:
+			m[statAvg] = s.sum / float64(s.count)
+		case statPercentiles:
+			sort.Float64s(s.samples)
+			ps := mapstr.M{}
+			for _, p := range percentiles {
+				ps[percentileKey(p)] = percentile(s.samples, p)
+			}
+			m[statPercentiles] = ps
+		}
+	}
+	return m
+}
+
+// percentile computes the p-th percentile of the sorted values by linear
+// interpolation between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	if lo == hi {
+		return sorted[lo]
+	}
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+// percentileKey formats a percentile as a key without dots, e.g. p99_9.
+func percentileKey(p float64) string {
+	return "p" + strings.ReplaceAll(strconv.FormatFloat(p, 'f', -1, 64), ".", "_")
+}
+
+// toFloat converts numeric field values.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -84,6 +84,11 @@ func (r *WhenProcessor) Run(event *beat.Event) (*beat.Event, error) {
 	return r.p.Run(event)
 }
 
+// SetEmitter forwards the emit function to the wrapped processor.
+func (r *WhenProcessor) SetEmitter(emit EmitFunc) {
+	SetEmitter(r.p, emit)
+}
+
 func (r *WhenProcessor) String() string {
 	return fmt.Sprintf("%v, condition=%v", r.p.String(), r.condition.String())
 }
@@ -171,6 +176,14 @@ func (p *IfThenElseProcessor) Run(event *beat.Event) (*beat.Event, error) {
 	return event, nil
 }
 
+// SetEmitter forwards the emit function to the processors of both branches.
+func (p *IfThenElseProcessor) SetEmitter(emit EmitFunc) {
+	p.then.SetEmitter(emit)
+	if p.els != nil {
+		p.els.SetEmitter(emit)
+	}
+}
+
 func (p *IfThenElseProcessor) String() string {
 	var sb strings.Builder
 	sb.WriteString("if ")
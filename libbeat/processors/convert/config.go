@@ -42,7 +42,7 @@ type config struct {
 type field struct {
 	From string   `config:"from" validate:"required"`
 	To   string   `config:"to"`
-	Type dataType `config:"type"`
+	Type DataType `config:"type"`
 }
 
 func (f field) Validate() error {
@@ -56,11 +56,13 @@ func (f field) String() string {
 	return fmt.Sprintf("{from=%v, to=%v, type=%v}", f.From, f.To, f.Type)
 }
 
-type dataType uint8
+// DataType is a type a value can be converted to. It is unpacked from its
+// name, e.g. "long".
+type DataType uint8
 
 // List of dataTypes.
 const (
-	unset dataType = iota
+	unset DataType = iota
 	Integer
 	Long
 	Float
@@ -70,7 +72,7 @@ const (
 	IP
 )
 
-var dataTypeNames = map[dataType]string{
+var dataTypeNames = map[DataType]string{
 	unset:   "[unset]",
 	Integer: "integer",
 	Long:    "long",
@@ -81,15 +83,15 @@ var dataTypeNames = map[dataType]string{
 	IP:      "ip",
 }
 
-func (dt dataType) String() string {
+func (dt DataType) String() string {
 	return dataTypeNames[dt]
 }
 
-func (dt dataType) MarshalText() ([]byte, error) {
+func (dt DataType) MarshalText() ([]byte, error) {
 	return []byte(dt.String()), nil
 }
 
-func (dt *dataType) Unpack(s string) error {
+func (dt *DataType) Unpack(s string) error {
 	s = strings.ToLower(s)
 	for typ, name := range dataTypeNames {
 		if s == name {
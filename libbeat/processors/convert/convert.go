@@ -124,7 +124,7 @@ func (p *processor) convertField(event *beat.Event, conversion field) (interface
 	}
 
 	if conversion.Type > unset {
-		t, err := transformType(conversion.Type, v)
+		t, err := TransformType(conversion.Type, v)
 		if err != nil {
 			return nil, newConvertError(conversion, err, p.Tag, "unable to convert value [%v]", v)
 		}
@@ -162,7 +162,8 @@ func (p *processor) writeToEvent(event *beat.Event, converted []interface{}) err
 	return nil
 }
 
-func transformType(typ dataType, value interface{}) (interface{}, error) {
+// TransformType converts value to the given data type.
+func TransformType(typ DataType, value interface{}) (interface{}, error) {
 	switch typ {
 	case String:
 		return toString(value)
@@ -286,7 +286,7 @@ func TestConvertRun(t *testing.T) {
 }
 
 type testCase struct {
-	Type dataType
+	Type DataType
 	In   interface{}
 	Out  interface{}
 	Err  bool
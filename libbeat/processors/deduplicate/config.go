@@ -0,0 +1,83 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deduplicate
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	modeDrop = "drop"
+	modeTag  = "tag"
+)
+
+type config struct {
+	// Fields used to compute the event fingerprint.
+	Fields []string `config:"fields" validate:"required"`
+
+	// Method is the hash method, as supported by the fingerprint processor.
+	Method string `config:"method"`
+
+	// IgnoreMissing computes the fingerprint from the fields present in the
+	// event instead of failing if a field is missing.
+	IgnoreMissing bool `config:"ignore_missing"`
+
+	// Window is how long a fingerprint is remembered.
+	Window time.Duration `config:"window" validate:"positive,nonzero"`
+
+	// MaxEntries bounds the number of remembered fingerprints. The oldest
+	// fingerprint is forgotten when the limit is reached.
+	MaxEntries int `config:"max_entries" validate:"min=1"`
+
+	// Mode is either drop or tag.
+	Mode string `config:"mode"`
+
+	// Tag added to duplicates in tag mode.
+	Tag string `config:"tag"`
+
+	// Store persists the window so it survives restarts.
+	Store *storeConfig `config:"store"`
+}
+
+type storeConfig struct {
+	ID string `config:"id" validate:"required"`
+}
+
+func defaultConfig() config {
+	return config{
+		Method:     "xxhash",
+		Window:     10 * time.Minute,
+		MaxEntries: 100000,
+		Mode:       modeDrop,
+		Tag:        "duplicate",
+	}
+}
+
+func (c *config) Validate() error {
+	switch c.Mode {
+	case modeDrop:
+	case modeTag:
+		if c.Tag == "" {
+			return fmt.Errorf("tag must not be empty in %s mode", modeTag)
+		}
+	default:
+		return fmt.Errorf("invalid mode '%s', must be %s or %s", c.Mode, modeDrop, modeTag)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deduplicate
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/fingerprint"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	processorName = "deduplicate"
+	logName       = "processor." + processorName
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID atomic.Uint32
+
+func init() {
+	// We cannot use this as a JS plugin as it is stateful and includes a Close method.
+	processors.RegisterPlugin(processorName, New)
+}
+
+type metrics struct {
+	duplicates *monitoring.Int
+}
+
+type deduplicate struct {
+	config  config
+	hasher  *fingerprint.Hasher
+	log     *logp.Logger
+	metrics metrics
+	now     func() time.Time
+
+	mu     sync.Mutex
+	window *window
+	store  *statestore.Store
+}
+
+// storedEntry is the value persisted for each fingerprint in the store.
+type storedEntry struct {
+	Seen int64 `json:"seen" struct:"seen"`
+}
+
+// New constructs a new deduplicate processor. If a store is configured, the
+// window is loaded from the store and kept up to date in it.
+func New(cfg *conf.C) (beat.Processor, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("failed to unpack the %s configuration: %w", processorName, err)
+	}
+	return newDeduplicate(config, time.Now)
+}
+
+func newDeduplicate(config config, now func() time.Time) (*deduplicate, error) {
+	hasher, err := fingerprint.NewHasher(config.Method, config.Fields, config.IgnoreMissing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure %s fingerprint: %w", processorName, err)
+	}
+
+	// Logging and metrics (each processor instance has a unique ID).
+	var (
+		id  = int(instanceID.Add(1))
+		log = logp.NewLogger(logName).With("instance_id", id)
+		reg = monitoring.Default.NewRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+
+	p := &deduplicate{
+		config:  config,
+		hasher:  hasher,
+		log:     log,
+		metrics: metrics{duplicates: monitoring.NewInt(reg, "duplicates")},
+		now:     now,
+		window:  newWindow(config.Window, config.MaxEntries),
+	}
+
+	if config.Store != nil {
+		p.store, err = openStore(log, config.Store.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s store: %w", processorName, err)
+		}
+		if err := p.load(); err != nil {
+			p.store.Close()
+			return nil, fmt.Errorf("failed to load %s store: %w", processorName, err)
+		}
+	}
+
+	return p, nil
+}
+
+// Run drops or tags the event if its fingerprint was seen within the window.
+func (p *deduplicate) Run(event *beat.Event) (*beat.Event, error) {
+	sum, err := p.hasher.Sum(event)
+	if err != nil {
+		return event, fmt.Errorf("failed to compute %s fingerprint: %w", processorName, err)
+	}
+	key := string(sum)
+
+	if !p.observe(key) {
+		return event, nil
+	}
+
+	p.metrics.duplicates.Inc()
+	if p.config.Mode == modeDrop {
+		p.log.Debugf("event [%v] dropped by %s processor", event, processorName)
+		return nil, nil
+	}
+	if err := mapstr.AddTags(event.Fields, []string{p.config.Tag}); err != nil {
+		return event, fmt.Errorf("failed to tag duplicate event: %w", err)
+	}
+	return event, nil
+}
+
+// observe records key and reports whether it was already in the window.
+func (p *deduplicate) observe(key string) (duplicate bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	p.window.expire(now, p.forget)
+	if p.window.contains(key) {
+		return true
+	}
+
+	if evicted := p.window.add(key, now); evicted != "" {
+		p.forget(evicted)
+	}
+	if p.store != nil {
+		if err := p.store.Set(hex.EncodeToString([]byte(key)), storedEntry{Seen: now.UnixNano()}); err != nil {
+			p.log.Warnf("Failed to persist fingerprint: %v", err)
+		}
+	}
+	return false
+}
+
+// forget removes a fingerprint that left the window from the store.
+func (p *deduplicate) forget(key string) {
+	if p.store == nil {
+		return
+	}
+	if err := p.store.Remove(hex.EncodeToString([]byte(key))); err != nil {
+		p.log.Warnf("Failed to remove fingerprint from store: %v", err)
+	}
+}
+
+// load restores the window from the store, removing expired entries.
+func (p *deduplicate) load() error {
+	var (
+		entries []entry
+		expired []string
+	)
+	deadline := p.now().Add(-p.config.Window)
+
+	err := p.store.Each(func(key string, dec statestore.ValueDecoder) (bool, error) {
+		var st storedEntry
+		if err := dec.Decode(&st); err != nil {
+			return false, fmt.Errorf("failed to decode entry %s: %w", key, err)
+		}
+		sum, err := hex.DecodeString(key)
+		seen := time.Unix(0, st.Seen)
+		if err != nil || !seen.After(deadline) {
+			expired = append(expired, key)
+			return true, nil
+		}
+		entries = append(entries, entry{key: string(sum), seen: seen})
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range expired {
+		if err := p.store.Remove(key); err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seen.Before(entries[j].seen)
+	})
+	for _, e := range entries {
+		if evicted := p.window.add(e.key, e.seen); evicted != "" {
+			p.forget(evicted)
+		}
+	}
+
+	p.log.Debugf("Loaded %d fingerprints from store", p.window.len())
+	return nil
+}
+
+// Close releases the store.
+func (p *deduplicate) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.store == nil {
+		return nil
+	}
+	err := p.store.Close()
+	p.store = nil
+	return err
+}
+
+func (p *deduplicate) String() string {
+	store := ""
+	if p.config.Store != nil {
+		store = p.config.Store.ID
+	}
+	return fmt.Sprintf("%v=[fields=%v, method=%v, window=%v, max_entries=%v, mode=%v, store=%v]",
+		processorName, p.config.Fields, p.config.Method, p.config.Window, p.config.MaxEntries, p.config.Mode, store)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   *statestore.Registry
+)
+
+// openStore opens the named store. All processor instances share a memlog
+// registry in the data path.
+func openStore(log *logp.Logger, id string) (*statestore.Store, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if registry == nil {
+		backend, err := memlog.New(log, memlog.Settings{
+			Root:     paths.Resolve(paths.Data, processorName),
+			FileMode: 0o600,
+		})
+		if err != nil {
+			return nil, err
+		}
+		registry = statestore.NewRegistry(backend)
+	}
+	return registry.Get(id)
+}
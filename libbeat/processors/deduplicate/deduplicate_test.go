@@ -0,0 +1,209 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deduplicate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestProcessor(t *testing.T, clock *testClock, settings mapstr.M) *deduplicate {
+	t.Helper()
+
+	config := defaultConfig()
+	require.NoError(t, conf.MustNewConfigFrom(settings).Unpack(&config))
+	p, err := newDeduplicate(config, clock.Now)
+	require.NoError(t, err)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func newEvent(id, msg string) *beat.Event {
+	return &beat.Event{Fields: mapstr.M{"id": id, "message": msg}}
+}
+
+func TestDeduplicateDrop(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	p := newTestProcessor(t, clock, mapstr.M{"fields": []string{"id"}, "window": "1m"})
+
+	out, err := p.Run(newEvent("a", "first"))
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+
+	out, err = p.Run(newEvent("a", "retry"))
+	require.NoError(t, err)
+	assert.Nil(t, out, "duplicate must be dropped")
+
+	out, err = p.Run(newEvent("b", "other"))
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+
+	// the fingerprint is forgotten once it leaves the window
+	clock.Advance(time.Minute)
+	out, err = p.Run(newEvent("a", "late"))
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+
+	assert.Equal(t, int64(1), p.metrics.duplicates.Get())
+}
+
+func TestDeduplicateTag(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	p := newTestProcessor(t, clock, mapstr.M{
+		"fields": []string{"id", "message"},
+		"mode":   "tag",
+		"tag":    "dup",
+	})
+
+	out, err := p.Run(newEvent("a", "msg"))
+	require.NoError(t, err)
+	_, err = out.GetValue("tags")
+	assert.Error(t, err, "first event must not be tagged")
+
+	out, err = p.Run(newEvent("a", "msg"))
+	require.NoError(t, err)
+	tags, err := out.GetValue("tags")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dup"}, tags)
+
+	// a different value in a fingerprinted field is not a duplicate
+	out, err = p.Run(newEvent("a", "other"))
+	require.NoError(t, err)
+	_, err = out.GetValue("tags")
+	assert.Error(t, err)
+}
+
+func TestDeduplicateMaxEntries(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	p := newTestProcessor(t, clock, mapstr.M{"fields": []string{"id"}, "max_entries": 2})
+
+	for _, id := range []string{"a", "b", "c"} {
+		out, err := p.Run(newEvent(id, ""))
+		require.NoError(t, err)
+		require.NotNil(t, out)
+		clock.Advance(time.Second)
+	}
+
+	// a was evicted to make room for c
+	out, err := p.Run(newEvent("a", ""))
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+
+	out, err = p.Run(newEvent("c", ""))
+	require.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+func TestDeduplicateMissingField(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	p := newTestProcessor(t, clock, mapstr.M{"fields": []string{"missing"}})
+
+	out, err := p.Run(newEvent("a", ""))
+	assert.Error(t, err)
+	assert.NotNil(t, out, "event must be kept on error")
+}
+
+func TestDeduplicateStore(t *testing.T) {
+	origDataPath := paths.Paths.Data
+	paths.Paths.Data = t.TempDir()
+	t.Cleanup(func() {
+		paths.Paths.Data = origDataPath
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		if registry != nil {
+			registry.Close()
+			registry = nil
+		}
+	})
+
+	settings := mapstr.M{"fields": []string{"id"}, "window": "1m", "store.id": "test"}
+	clock := &testClock{now: time.Now()}
+
+	p := newTestProcessor(t, clock, settings)
+	for _, id := range []string{"a", "b"} {
+		out, err := p.Run(newEvent(id, ""))
+		require.NoError(t, err)
+		require.NotNil(t, out)
+		clock.Advance(30 * time.Second)
+	}
+	require.NoError(t, p.Close())
+
+	// a restarted processor remembers b, a has expired meanwhile
+	clock.Advance(time.Second)
+	p = newTestProcessor(t, clock, settings)
+	assert.Equal(t, 1, p.window.len())
+
+	out, err := p.Run(newEvent("b", ""))
+	require.NoError(t, err)
+	assert.Nil(t, out)
+
+	out, err = p.Run(newEvent("a", ""))
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]mapstr.M{
+		"no fields":      {"window": "1m"},
+		"invalid mode":   {"fields": []string{"id"}, "mode": "delete"},
+		"empty tag":      {"fields": []string{"id"}, "mode": "tag", "tag": ""},
+		"zero window":    {"fields": []string{"id"}, "window": "0s"},
+		"bad method":     {"fields": []string{"id"}, "method": "crc"},
+		"store w/o id":   {"fields": []string{"id"}, "store.enabled": true},
+		"no max entries": {"fields": []string{"id"}, "max_entries": 0},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(settings))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWindow(t *testing.T) {
+	start := time.Now()
+	w := newWindow(time.Minute, 100)
+
+	for i := 0; i < 50; i++ {
+		w.add(string(rune('a'+i)), start.Add(time.Duration(i)*time.Second))
+	}
+	assert.Equal(t, 50, w.len())
+
+	var expired []string
+	w.expire(start.Add(70*time.Second), func(key string) { expired = append(expired, key) })
+	assert.Len(t, expired, 11)
+	assert.Equal(t, 39, w.len())
+	assert.False(t, w.contains("a"))
+	assert.True(t, w.contains(string(rune('a'+11))))
+}
@@ -0,0 +1,94 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deduplicate
+
+import "time"
+
+// window is a set of fingerprints seen within a bounded time window. Entries
+// are kept in insertion order, so expiring old entries and evicting the
+// oldest entry when the set is full are cheap.
+type window struct {
+	size time.Duration
+	max  int
+
+	seen  map[string]struct{}
+	order []entry
+	head  int
+}
+
+type entry struct {
+	key  string
+	seen time.Time
+}
+
+func newWindow(size time.Duration, max int) *window {
+	return &window{
+		size: size,
+		max:  max,
+		seen: map[string]struct{}{},
+	}
+}
+
+func (w *window) len() int {
+	return len(w.order) - w.head
+}
+
+func (w *window) contains(key string) bool {
+	_, exists := w.seen[key]
+	return exists
+}
+
+// add records key as seen at the given time. It returns the key of the entry
+// evicted to make room, if any.
+func (w *window) add(key string, seen time.Time) (evicted string) {
+	if w.len() >= w.max {
+		evicted = w.pop()
+	}
+	w.seen[key] = struct{}{}
+	w.order = append(w.order, entry{key: key, seen: seen})
+	return evicted
+}
+
+// expire removes all entries that are older than the window, calling fn for
+// each removed key.
+func (w *window) expire(now time.Time, fn func(key string)) {
+	deadline := now.Add(-w.size)
+	for w.len() > 0 && !w.order[w.head].seen.After(deadline) {
+		key := w.pop()
+		if fn != nil {
+			fn(key)
+		}
+	}
+}
+
+func (w *window) pop() string {
+	e := w.order[w.head]
+	w.order[w.head] = entry{}
+	w.head++
+	delete(w.seen, e.key)
+
+	// Reclaim the space of removed entries once they make up half of the
+	// queue.
+	if w.head > len(w.order)/2 {
+		n := copy(w.order, w.order[w.head:])
+		clear(w.order[n:])
+		w.order = w.order[:n]
+		w.head = 0
+	}
+	return e.key
+}
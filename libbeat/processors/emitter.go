@@ -0,0 +1,112 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// EmitFunc publishes an event that was produced by a processor outside of a
+// Run call.
+type EmitFunc func(event *beat.Event)
+
+// Emitter defines the interface for processors that produce new events on
+// their own, for example when an aggregation window closes.
+//
+// The pipeline client installs the EmitFunc when it is created. Emitted events
+// are passed through the processors that follow the emitting processor before
+// being published. The EmitFunc blocks while the event is published and must
+// not be called from within Run. Events emitted while the client is closing
+// are dropped if the queue is full, so shutdown is not blocked.
+type Emitter interface {
+	SetEmitter(emit EmitFunc)
+}
+
+// SetEmitter installs emit on a processor if it implements the Emitter interface.
+func SetEmitter(p beat.Processor, emit EmitFunc) {
+	if e, ok := p.(Emitter); ok {
+		e.SetEmitter(emit)
+	}
+}
+
+// Emits reports whether p produces events on its own. Wrappers that only
+// forward SetEmitter, like conditions, are looked through.
+func Emits(p beat.Processor) bool {
+	switch p := p.(type) {
+	case *SafeProcessor:
+		return Emits(p.Processor)
+	case *WhenProcessor:
+		return Emits(p.p)
+	case *IfThenElseProcessor:
+		return listEmits(p.then) || listEmits(p.els)
+	case *Processors:
+		return listEmits(p)
+	case Emitter:
+		return true
+	}
+	return false
+}
+
+func listEmits(procs *Processors) bool {
+	if procs == nil {
+		return false
+	}
+	for _, p := range procs.List {
+		if Emits(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetListEmitter installs an EmitFunc on each processor of list. Events emitted
+// by a processor are run through the processors following it in the list before
+// they are passed to emit.
+func SetListEmitter(log *logp.Logger, list []beat.Processor, emit EmitFunc) {
+	for i, p := range list {
+		if _, ok := p.(Emitter); !ok {
+			continue
+		}
+
+		next := list[i+1:]
+		SetEmitter(p, func(event *beat.Event) {
+			for _, sub := range next {
+				var err error
+				event, err = sub.Run(event)
+				if err != nil {
+					log.Debugf("Fail to apply processor %s on emitted event: %s", sub, err)
+				}
+				if event == nil {
+					return
+				}
+			}
+			emit(event)
+		})
+	}
+}
+
+// Emits reports whether any processor of the list produces events on its own.
+func (procs *Processors) Emits() bool {
+	return listEmits(procs)
+}
+
+// SetEmitter installs emit on all processors of the list that produce events.
+func (procs *Processors) SetEmitter(emit EmitFunc) {
+	SetListEmitter(procs.log, procs.List, emit)
+}
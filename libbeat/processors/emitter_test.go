@@ -0,0 +1,76 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type emittingProcessor struct {
+	emit EmitFunc
+}
+
+func (p *emittingProcessor) Run(event *beat.Event) (*beat.Event, error) { return nil, nil }
+func (p *emittingProcessor) String() string                             { return "emitting" }
+func (p *emittingProcessor) Close() error                               { return nil }
+func (p *emittingProcessor) SetEmitter(emit EmitFunc)                   { p.emit = emit }
+
+type annotateProcessor string
+
+func (p annotateProcessor) Run(event *beat.Event) (*beat.Event, error) {
+	if event.Fields["drop"] == true {
+		return nil, nil
+	}
+	_, _ = event.PutValue(string(p), true)
+	return event, nil
+}
+
+func (p annotateProcessor) String() string { return string(p) }
+
+func TestSetListEmitter(t *testing.T) {
+	emitter := &emittingProcessor{}
+	procs := NewList(nil)
+	procs.AddProcessor(annotateProcessor("before"))
+	procs.AddProcessor(&SafeProcessor{Processor: emitter})
+	procs.AddProcessor(annotateProcessor("after"))
+
+	var emitted []*beat.Event
+	SetEmitter(procs, func(event *beat.Event) {
+		emitted = append(emitted, event)
+	})
+
+	// Emitted events only pass through the processors following the emitter.
+	emitter.emit(&beat.Event{Fields: mapstr.M{}})
+	emitter.emit(&beat.Event{Fields: mapstr.M{"drop": true}})
+
+	assert.Equal(t, []*beat.Event{{Fields: mapstr.M{"after": true}}}, emitted)
+}
+
+func TestEmits(t *testing.T) {
+	emitter := &SafeProcessor{Processor: &emittingProcessor{}}
+	assert.True(t, Emits(emitter))
+	assert.True(t, Emits(&WhenProcessor{p: emitter}))
+	assert.True(t, Emits(&IfThenElseProcessor{then: NewList(nil), els: &Processors{List: []beat.Processor{emitter}}}))
+	assert.False(t, Emits(&SafeProcessor{Processor: annotateProcessor("plain")}))
+	assert.False(t, Emits(&IfThenElseProcessor{then: &Processors{List: []beat.Processor{annotateProcessor("plain")}}}))
+}
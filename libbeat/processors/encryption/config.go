@@ -0,0 +1,87 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type encryptConfig struct {
+	// Fields are the fields to encrypt.
+	Fields []string `config:"fields" validate:"required"`
+
+	// Key is the base64 encoded key encryption key, usually a reference to
+	// the keystore.
+	Key string `config:"key" validate:"required"`
+
+	// KeyID identifies Key, so that the matching key can be chosen to decrypt.
+	KeyID string `config:"key_id" validate:"required"`
+
+	// MetadataField is the field holding the key ID, the wrapped data key and
+	// the encrypted fields.
+	MetadataField string `config:"metadata_field" validate:"required"`
+
+	// DataKeyTTL is how long a data key is used before a new one is generated.
+	DataKeyTTL time.Duration `config:"data_key_ttl" validate:"positive,nonzero"`
+}
+
+type decryptConfig struct {
+	// Keys are the key encryption keys that can be used to decrypt.
+	Keys []keyConfig `config:"keys"`
+
+	// MetadataField is the field holding the key ID, the wrapped data key and
+	// the encrypted fields.
+	MetadataField string `config:"metadata_field" validate:"required"`
+}
+
+type keyConfig struct {
+	// ID is the key ID given to encrypt_fields.
+	ID string `config:"id" validate:"required"`
+
+	// Key is the base64 encoded key encryption key.
+	Key string `config:"key" validate:"required"`
+}
+
+func defaultEncryptConfig() encryptConfig {
+	return encryptConfig{
+		MetadataField: "encryption",
+		DataKeyTTL:    time.Hour,
+	}
+}
+
+func defaultDecryptConfig() decryptConfig {
+	return decryptConfig{
+		MetadataField: "encryption",
+	}
+}
+
+func (c *decryptConfig) Validate() error {
+	if len(c.Keys) == 0 {
+		return errors.New("at least one key is required")
+	}
+	ids := make(map[string]bool, len(c.Keys))
+	for _, k := range c.Keys {
+		if ids[k.ID] {
+			return fmt.Errorf("duplicate key ID '%s'", k.ID)
+		}
+		ids[k.ID] = true
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package encryption
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// dataKeyCacheSize is the number of unwrapped data keys kept by
+// decrypt_fields. Data keys are shared by all the events encrypted within
+// their lifetime, so only few are in use at a time.
+const dataKeyCacheSize = 64
+
+func init() {
+	processors.RegisterPlugin("decrypt_fields", NewDecryptFields)
+}
+
+type decryptFields struct {
+	config decryptConfig
+	keks   map[string]cipher.AEAD
+
+	// dataKeys caches unwrapped data keys by key ID and wrapped data key.
+	dataKeys *lru.Cache[string, *dataKey]
+}
+
+// NewDecryptFields constructs a new decrypt_fields processor.
+func NewDecryptFields(cfg *conf.C) (beat.Processor, error) {
+	config := defaultDecryptConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the decrypt_fields configuration: %w", err)
+	}
+
+	p := &decryptFields{config: config, keks: make(map[string]cipher.AEAD, len(config.Keys))}
+	for _, k := range config.Keys {
+		kek, err := parseKey(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decrypt_fields key '%s': %w", k.ID, err)
+		}
+		p.keks[k.ID] = kek
+	}
+
+	var err error
+	if p.dataKeys, err = lru.New[string, *dataKey](dataKeyCacheSize); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *decryptFields) Run(event *beat.Event) (*beat.Event, error) {
+	v, err := event.GetValue(p.config.MetadataField)
+	if err != nil {
+		if errors.Is(err, mapstr.ErrKeyNotFound) {
+			return event, nil
+		}
+		return event, err
+	}
+
+	keyID, wrapped, fields, err := parseMetadata(v)
+	if err != nil {
+		return event, fmt.Errorf("invalid encryption metadata field [%v]: %w", p.config.MetadataField, err)
+	}
+
+	key, err := p.dataKey(keyID, wrapped)
+	if err != nil {
+		return event, err
+	}
+
+	// Decrypt all the fields before changing the event, so that it is left
+	// untouched on errors.
+	decrypted := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			return event, fmt.Errorf("failed to get encrypted field [%v]: %w", field, err)
+		}
+		ciphertext, ok := v.(string)
+		if !ok {
+			return event, fmt.Errorf("encrypted field [%v] is not a string", field)
+		}
+		plaintext, err := key.decrypt(field, ciphertext)
+		if err != nil {
+			return event, fmt.Errorf("failed to decrypt field [%v]: %w", field, err)
+		}
+		if decrypted[field], err = decodeValue(plaintext); err != nil {
+			return event, fmt.Errorf("failed to decode field [%v]: %w", field, err)
+		}
+	}
+
+	for _, field := range fields {
+		if _, err := event.PutValue(field, decrypted[field]); err != nil {
+			return event, fmt.Errorf("failed to put decrypted field [%v]: %w", field, err)
+		}
+	}
+	return event, event.Delete(p.config.MetadataField)
+}
+
+func parseMetadata(v interface{}) (keyID, wrapped string, fields []string, err error) {
+	var m mapstr.M
+	switch v := v.(type) {
+	case mapstr.M:
+		m = v
+	case map[string]interface{}:
+		m = v
+	default:
+		return "", "", nil, fmt.Errorf("unexpected type %T", v)
+	}
+
+	keyID, _ = m[keyIDKey].(string)
+	wrapped, _ = m[dataKeyKey].(string)
+	if keyID == "" || wrapped == "" {
+		return "", "", nil, fmt.Errorf("missing %s or %s", keyIDKey, dataKeyKey)
+	}
+
+	switch list := m[fieldsKey].(type) {
+	case []string:
+		fields = list
+	case []interface{}:
+		for _, f := range list {
+			s, ok := f.(string)
+			if !ok {
+				return "", "", nil, fmt.Errorf("unexpected type %T in %s", f, fieldsKey)
+			}
+			fields = append(fields, s)
+		}
+	default:
+		return "", "", nil, fmt.Errorf("missing %s", fieldsKey)
+	}
+	return keyID, wrapped, fields, nil
+}
+
+// dataKey returns the unwrapped data key.
+func (p *decryptFields) dataKey(keyID, wrapped string) (*dataKey, error) {
+	cacheKey := keyID + ":" + wrapped
+	if key, ok := p.dataKeys.Get(cacheKey); ok {
+		return key, nil
+	}
+
+	kek, found := p.keks[keyID]
+	if !found {
+		return nil, fmt.Errorf("unknown key ID '%s'", keyID)
+	}
+	key, err := unwrapDataKey(kek, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	p.dataKeys.Add(cacheKey, key)
+	return key, nil
+}
+
+// decodeValue decodes a JSON encoded field value. Integer numbers are decoded
+// as int64 and other numbers as float64.
+func decodeValue(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return convertNumbers(v), nil
+}
+
+func convertNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		m := make(mapstr.M, len(v))
+		for k, elem := range v {
+			m[k] = convertNumbers(elem)
+		}
+		return m
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = convertNumbers(elem)
+		}
+	}
+	return v
+}
+
+func (p *decryptFields) String() string {
+	ids := make([]string, 0, len(p.keks))
+	for id := range p.keks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return fmt.Sprintf("decrypt_fields=[key_ids=[%v], metadata_field=%v]",
+		strings.Join(ids, ", "), p.config.MetadataField)
+}
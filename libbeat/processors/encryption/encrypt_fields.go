@@ -0,0 +1,133 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package encryption
+
+import (
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// maxDataKeyUses bounds the number of values encrypted with a data key, well
+// below the limit for random nonces with AES-GCM.
+const maxDataKeyUses = 1 << 30
+
+func init() {
+	processors.RegisterPlugin("encrypt_fields", NewEncryptFields)
+}
+
+type encryptFields struct {
+	config encryptConfig
+	kek    cipher.AEAD
+	now    func() time.Time
+
+	mu      sync.Mutex
+	key     *dataKey
+	expires time.Time
+	uses    int
+}
+
+// NewEncryptFields constructs a new encrypt_fields processor.
+func NewEncryptFields(cfg *conf.C) (beat.Processor, error) {
+	config := defaultEncryptConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the encrypt_fields configuration: %w", err)
+	}
+
+	kek, err := parseKey(config.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypt_fields key: %w", err)
+	}
+	return &encryptFields{config: config, kek: kek, now: time.Now}, nil
+}
+
+func (p *encryptFields) Run(event *beat.Event) (*beat.Event, error) {
+	if _, err := event.GetValue(p.config.MetadataField); !errors.Is(err, mapstr.ErrKeyNotFound) {
+		return event, fmt.Errorf("event already contains the encryption metadata field [%v]", p.config.MetadataField)
+	}
+
+	key, err := p.dataKey(len(p.config.Fields))
+	if err != nil {
+		return event, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	// Encrypt all the fields before changing the event, so that it is left
+	// untouched on errors.
+	encrypted := make(map[string]string, len(p.config.Fields))
+	var fields []string
+	for _, field := range p.config.Fields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			continue
+		}
+		plaintext, err := json.Marshal(v)
+		if err != nil {
+			return event, fmt.Errorf("failed to encode field [%v]: %w", field, err)
+		}
+		if encrypted[field], err = key.encrypt(field, plaintext); err != nil {
+			return event, fmt.Errorf("failed to encrypt field [%v]: %w", field, err)
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return event, nil
+	}
+
+	for _, field := range fields {
+		if _, err := event.PutValue(field, encrypted[field]); err != nil {
+			return event, fmt.Errorf("failed to put encrypted field [%v]: %w", field, err)
+		}
+	}
+	_, err = event.PutValue(p.config.MetadataField, mapstr.M{
+		keyIDKey:   p.config.KeyID,
+		dataKeyKey: key.wrapped,
+		fieldsKey:  fields,
+	})
+	return event, err
+}
+
+// dataKey returns the current data key for n encryptions, generating a new
+// one when the current one expired or was used too often.
+func (p *encryptFields) dataKey(n int) (*dataKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if p.key == nil || !now.Before(p.expires) || p.uses+n > maxDataKeyUses {
+		key, err := newDataKey(p.kek, p.config.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		p.key, p.expires, p.uses = key, now.Add(p.config.DataKeyTTL), 0
+	}
+	p.uses += n
+	return p.key, nil
+}
+
+func (p *encryptFields) String() string {
+	return fmt.Sprintf("encrypt_fields=[fields=%v, key_id=%v, metadata_field=%v]",
+		p.config.Fields, p.config.KeyID, p.config.MetadataField)
+}
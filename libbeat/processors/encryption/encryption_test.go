@@ -0,0 +1,232 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package encryption
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	testKey      = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // 32 bytes
+	testOtherKey = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=" // 32 bytes
+)
+
+func newEncrypt(t *testing.T, settings map[string]interface{}) *encryptFields {
+	t.Helper()
+	p, err := NewEncryptFields(conf.MustNewConfigFrom(settings))
+	require.NoError(t, err)
+	return p.(*encryptFields)
+}
+
+func newDecrypt(t *testing.T, settings map[string]interface{}) *decryptFields {
+	t.Helper()
+	p, err := NewDecryptFields(conf.MustNewConfigFrom(settings))
+	require.NoError(t, err)
+	return p.(*decryptFields)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	encrypt := newEncrypt(t, map[string]interface{}{
+		"fields": []string{"user.name", "client.ip", "client.port", "labels", "missing"},
+		"key":    testKey,
+		"key_id": "k1",
+	})
+	decrypt := newDecrypt(t, map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{"id": "k0", "key": testOtherKey},
+			{"id": "k1", "key": testKey},
+		},
+	})
+
+	fields := mapstr.M{
+		"message": "login",
+		"user":    mapstr.M{"name": "alice"},
+		"client":  mapstr.M{"ip": net.ParseIP("10.1.2.3"), "port": 54321},
+		"labels":  mapstr.M{"env": "prod", "ratio": 0.5, "tags": []string{"a", "b"}},
+	}
+
+	event, err := encrypt.Run(&beat.Event{Fields: fields.Clone()})
+	require.NoError(t, err)
+
+	assert.Equal(t, "login", event.Fields["message"])
+	for _, field := range []string{"user.name", "client.ip", "client.port", "labels"} {
+		v, err := event.GetValue(field)
+		require.NoError(t, err)
+		assert.IsType(t, "", v, field)
+	}
+	name, _ := event.GetValue("user.name")
+	assert.NotContains(t, name, "alice")
+
+	keyID, _ := event.GetValue("encryption.key_id")
+	assert.Equal(t, "k1", keyID)
+	dataKey, _ := event.GetValue("encryption.data_key")
+	assert.NotEmpty(t, dataKey)
+	encrypted, _ := event.GetValue("encryption.fields")
+	assert.Equal(t, []string{"user.name", "client.ip", "client.port", "labels"}, encrypted)
+
+	event, err = decrypt.Run(event)
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{
+		"message": "login",
+		"user":    mapstr.M{"name": "alice"},
+		"client":  mapstr.M{"ip": "10.1.2.3", "port": int64(54321)},
+		"labels":  mapstr.M{"env": "prod", "ratio": 0.5, "tags": []interface{}{"a", "b"}},
+	}, event.Fields)
+}
+
+func TestEncryptNoFields(t *testing.T) {
+	encrypt := newEncrypt(t, map[string]interface{}{
+		"fields": []string{"user.name"},
+		"key":    testKey,
+		"key_id": "k1",
+	})
+
+	event, err := encrypt.Run(&beat.Event{Fields: mapstr.M{"message": "hello"}})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"message": "hello"}, event.Fields)
+
+	_, err = encrypt.Run(&beat.Event{Fields: mapstr.M{
+		"user":       mapstr.M{"name": "alice"},
+		"encryption": mapstr.M{"key_id": "k0"},
+	}})
+	assert.ErrorContains(t, err, "already contains the encryption metadata field")
+}
+
+func TestEncryptDataKeyRotation(t *testing.T) {
+	encrypt := newEncrypt(t, map[string]interface{}{
+		"fields":       []string{"user.name"},
+		"key":          testKey,
+		"key_id":       "k1",
+		"data_key_ttl": "1h",
+	})
+	now := time.Now()
+	encrypt.now = func() time.Time { return now }
+
+	dataKeyOf := func() interface{} {
+		event, err := encrypt.Run(&beat.Event{Fields: mapstr.M{"user": mapstr.M{"name": "alice"}}})
+		require.NoError(t, err)
+		v, _ := event.GetValue("encryption.data_key")
+		return v
+	}
+
+	first := dataKeyOf()
+	assert.Equal(t, first, dataKeyOf())
+
+	now = now.Add(time.Hour)
+	second := dataKeyOf()
+	assert.NotEqual(t, first, second)
+
+	encrypt.uses = maxDataKeyUses
+	assert.NotEqual(t, second, dataKeyOf())
+}
+
+func TestDecryptErrors(t *testing.T) {
+	encrypt := newEncrypt(t, map[string]interface{}{
+		"fields": []string{"user.name", "user.email"},
+		"key":    testKey,
+		"key_id": "k1",
+	})
+	encrypted := func() *beat.Event {
+		event, err := encrypt.Run(&beat.Event{Fields: mapstr.M{
+			"user": mapstr.M{"name": "alice", "email": "alice@example.com"},
+		}})
+		require.NoError(t, err)
+		return event
+	}
+
+	t.Run("unknown key ID", func(t *testing.T) {
+		decrypt := newDecrypt(t, map[string]interface{}{
+			"keys": []map[string]interface{}{{"id": "k2", "key": testKey}},
+		})
+		_, err := decrypt.Run(encrypted())
+		assert.ErrorContains(t, err, "unknown key ID 'k1'")
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		decrypt := newDecrypt(t, map[string]interface{}{
+			"keys": []map[string]interface{}{{"id": "k1", "key": testOtherKey}},
+		})
+		_, err := decrypt.Run(encrypted())
+		assert.ErrorContains(t, err, "failed to unwrap data key")
+	})
+
+	t.Run("swapped fields", func(t *testing.T) {
+		decrypt := newDecrypt(t, map[string]interface{}{
+			"keys": []map[string]interface{}{{"id": "k1", "key": testKey}},
+		})
+		event := encrypted()
+		name, _ := event.GetValue("user.name")
+		email, _ := event.GetValue("user.email")
+		event.PutValue("user.name", email)
+		event.PutValue("user.email", name)
+		before := event.Fields.Clone()
+
+		_, err := decrypt.Run(event)
+		assert.ErrorContains(t, err, "failed to decrypt field [user.name]: invalid ciphertext")
+		assert.Equal(t, before, event.Fields, "event must be left untouched")
+	})
+
+	t.Run("not encrypted", func(t *testing.T) {
+		decrypt := newDecrypt(t, map[string]interface{}{
+			"keys": []map[string]interface{}{{"id": "k1", "key": testKey}},
+		})
+		event, err := decrypt.Run(&beat.Event{Fields: mapstr.M{"message": "hello"}})
+		assert.NoError(t, err)
+		assert.Equal(t, mapstr.M{"message": "hello"}, event.Fields)
+
+		_, err = decrypt.Run(&beat.Event{Fields: mapstr.M{"encryption": mapstr.M{"key_id": "k1"}}})
+		assert.ErrorContains(t, err, "invalid encryption metadata field [encryption]")
+	})
+}
+
+func TestConfig(t *testing.T) {
+	_, err := NewEncryptFields(conf.MustNewConfigFrom(map[string]interface{}{
+		"fields": []string{"user.name"},
+		"key":    "c2hvcnQ=",
+		"key_id": "k1",
+	}))
+	assert.ErrorContains(t, err, "key must be 16, 24 or 32 bytes long, got 5")
+
+	_, err = NewEncryptFields(conf.MustNewConfigFrom(map[string]interface{}{
+		"fields": []string{"user.name"},
+		"key":    testKey,
+	}))
+	assert.ErrorContains(t, err, "key_id")
+
+	_, err = NewDecryptFields(conf.MustNewConfigFrom(map[string]interface{}{}))
+	assert.ErrorContains(t, err, "at least one key is required")
+
+	_, err = NewDecryptFields(conf.MustNewConfigFrom(map[string]interface{}{
+		"keys": []map[string]interface{}{{"id": "k1", "key": testKey}, {"id": "k1", "key": testOtherKey}},
+	}))
+	assert.ErrorContains(t, err, "duplicate key ID 'k1'")
+
+	_, err = NewDecryptFields(conf.MustNewConfigFrom(map[string]interface{}{
+		"keys": []map[string]interface{}{{"id": "k1", "key": "not base64"}},
+	}))
+	assert.ErrorContains(t, err, "invalid decrypt_fields key 'k1'")
+}
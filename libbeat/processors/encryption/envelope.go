@@ -0,0 +1,143 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// dataKeySize is the size of the generated data keys, selecting AES-256.
+const dataKeySize = 32
+
+// Metadata keys, relative to the metadata field.
+const (
+	keyIDKey   = "key_id"
+	dataKeyKey = "data_key"
+	fieldsKey  = "fields"
+)
+
+var errInvalidCiphertext = errors.New("invalid ciphertext")
+
+// parseKey decodes a base64 encoded AES key.
+func parseKey(s string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64: %w", err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("key must be 16, 24 or 32 bytes long, got %d", len(key))
+	}
+	return newAEAD(key)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with a random nonce and returns the nonce followed
+// by the ciphertext. The additional data is authenticated but not encrypted.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the output of seal.
+func open(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errInvalidCiphertext
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, errInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+// dataKey is a data key used to encrypt field values.
+type dataKey struct {
+	aead    cipher.AEAD
+	wrapped string // The data key encrypted by the key encryption key.
+}
+
+// newDataKey generates a data key and wraps it with kek. The key ID is
+// authenticated with the wrapped key.
+func newDataKey(kek cipher.AEAD, keyID string) (*dataKey, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(kek, key, []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+	return &dataKey{aead: aead, wrapped: base64.StdEncoding.EncodeToString(wrapped)}, nil
+}
+
+// unwrapDataKey decrypts a data key wrapped by newDataKey.
+func unwrapDataKey(kek cipher.AEAD, keyID, wrapped string) (*dataKey, error) {
+	data, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, errInvalidCiphertext
+	}
+	key, err := open(kek, data, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return &dataKey{aead: aead, wrapped: wrapped}, nil
+}
+
+// encrypt encrypts a field value. The field name is authenticated, so that
+// values cannot be moved between fields.
+func (k *dataKey) encrypt(field string, plaintext []byte) (string, error) {
+	data, err := seal(k.aead, plaintext, []byte(field))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// decrypt decrypts a field value encrypted by encrypt.
+func (k *dataKey) decrypt(field, ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, errInvalidCiphertext
+	}
+	return open(k.aead, data, []byte(field))
+}
@@ -0,0 +1,125 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package enrich
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+const (
+	matchExact  = "exact"
+	matchCIDR   = "cidr"
+	matchPrefix = "prefix"
+)
+
+type config struct {
+	// File is the path to the lookup table.
+	File string `config:"file" validate:"required"`
+
+	// Format of the file, csv or json. By default it is derived from the file
+	// extension.
+	Format string `config:"format"`
+
+	// Separator is the field separator of CSV files.
+	Separator string `config:"separator"`
+
+	// Match lists the conditions a row must meet to be selected. All the
+	// conditions must match.
+	Match []matchConfig `config:"match"`
+
+	// Fields are the columns copied into the event. By default all columns
+	// except the matched ones are copied.
+	Fields []string `config:"fields"`
+
+	// Target is the field the columns are written to. By default they are
+	// written to the root of the event.
+	Target string `config:"target"`
+
+	// OverwriteKeys allows replacing existing event fields.
+	OverwriteKeys bool `config:"overwrite_keys"`
+
+	// ReloadInterval is how often the file is checked for changes. Zero
+	// disables reloading.
+	ReloadInterval time.Duration `config:"reload_interval" validate:"min=0"`
+}
+
+type matchConfig struct {
+	// Field is the event field holding the value to look up.
+	Field string `config:"field" validate:"required"`
+
+	// Column is the table column the value is matched against.
+	Column string `config:"column" validate:"required"`
+
+	// Type is how the value is matched, one of exact, cidr or prefix.
+	Type string `config:"type"`
+}
+
+func defaultConfig() config {
+	return config{
+		Separator:      ",",
+		ReloadInterval: time.Minute,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.Format == "" {
+		c.Format = formatFromExtension(c.File)
+	}
+	switch c.Format {
+	case formatCSV, formatJSON:
+	default:
+		return fmt.Errorf("invalid format '%s', must be %s or %s", c.Format, formatCSV, formatJSON)
+	}
+
+	if utf8.RuneCountInString(c.Separator) != 1 {
+		return fmt.Errorf("separator must be a single character")
+	}
+
+	if len(c.Match) == 0 {
+		return fmt.Errorf("at least one match condition is required")
+	}
+	for i := range c.Match {
+		m := &c.Match[i]
+		if m.Type == "" {
+			m.Type = matchExact
+		}
+		switch m.Type {
+		case matchExact, matchCIDR, matchPrefix:
+		default:
+			return fmt.Errorf("invalid match type '%s', must be %s, %s or %s", m.Type, matchExact, matchCIDR, matchPrefix)
+		}
+	}
+	return nil
+}
+
+func formatFromExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".ndjson":
+		return formatJSON
+	}
+	return formatCSV
+}
@@ -0,0 +1,271 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package enrich
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/file"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	procName = "enrich"
+	logName  = "processor." + procName
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID atomic.Uint32
+
+func init() {
+	// We cannot use this as a JS plugin as it is stateful and includes a Close method.
+	processors.RegisterPlugin(procName, New)
+}
+
+type enrich struct {
+	config
+	log   *logp.Logger
+	table *file.Reloadable[*table]
+
+	stop chan struct{}
+	done chan struct{}
+
+	metrics struct {
+		matched *monitoring.Int // Events enriched with a row of the table.
+		missed  *monitoring.Int // Events whose values did not match any row.
+		reloads *monitoring.Int // Number of times the table was reloaded.
+	}
+}
+
+// New constructs a new enrich processor.
+func New(cfg *conf.C) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	// Logging and metrics (each processor instance has a unique ID).
+	var (
+		id  = int(instanceID.Add(1))
+		log = logp.NewLogger(logName).With("instance_id", id)
+		reg = monitoring.Default.NewRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+
+	p := &enrich{
+		config: c,
+		log:    log,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	p.metrics.matched = monitoring.NewInt(reg, "matched")
+	p.metrics.missed = monitoring.NewInt(reg, "missed")
+	p.metrics.reloads = monitoring.NewInt(reg, "reloads")
+
+	t, err := file.NewReloadable(paths.Resolve(paths.Config, c.File), func(path string) (*table, error) {
+		return loadTable(path, c)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lookup table: %w", err)
+	}
+	p.table = t
+
+	if c.ReloadInterval > 0 {
+		go p.reloadLoop()
+	} else {
+		close(p.done)
+	}
+	return p, nil
+}
+
+func (p *enrich) reloadLoop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			reloaded, err := p.table.Reload()
+			if err != nil {
+				p.log.Warnf("Keeping the previous version of the lookup table: %v", err)
+				continue
+			}
+			if reloaded {
+				p.metrics.reloads.Inc()
+				p.log.Infof("Reloaded lookup table %s with %d rows", p.table.Path(), len(p.table.Get().rows))
+			}
+		}
+	}
+}
+
+func (p *enrich) Run(event *beat.Event) (*beat.Event, error) {
+	values := make([][]string, len(p.Match))
+	for i, m := range p.Match {
+		v, err := event.GetValue(m.Field)
+		if err != nil {
+			if errors.Is(err, mapstr.ErrKeyNotFound) {
+				return event, nil
+			}
+			return event, err
+		}
+		if values[i] = toStrings(v, nil); len(values[i]) == 0 {
+			return event, nil
+		}
+	}
+
+	rw, found := p.table.Get().lookup(values)
+	if !found {
+		p.metrics.missed.Inc()
+		return event, nil
+	}
+	p.metrics.matched.Inc()
+
+	if err := p.put(event, rw); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+// toStrings appends the string representations of the value v to values.
+// Arrays contribute each of their elements.
+func toStrings(v interface{}, values []string) []string {
+	switch v := v.(type) {
+	case string:
+		return append(values, v)
+	case net.IP:
+		return append(values, v.String())
+	case []string:
+		return append(values, v...)
+	case []interface{}:
+		for _, elem := range v {
+			values = toStrings(elem, values)
+		}
+		return values
+	}
+	if s, ok := scalarString(v); ok {
+		return append(values, s)
+	}
+	return values
+}
+
+// put copies the selected columns of the row into the event.
+func (p *enrich) put(event *beat.Event, rw row) error {
+	columns := p.Fields
+	if len(columns) == 0 {
+		columns = p.unmatchedColumns(rw)
+	}
+
+	target := ""
+	if p.Target != "" {
+		target = p.Target + "."
+	}
+
+	// Check all keys first so the event is left untouched on conflicts.
+	if !p.OverwriteKeys {
+		for _, column := range columns {
+			if _, found := rw[column]; !found {
+				continue
+			}
+			if _, err := event.GetValue(target + column); !errors.Is(err, mapstr.ErrKeyNotFound) {
+				return fmt.Errorf("cannot override existing key `%s`", target+column)
+			}
+		}
+	}
+
+	for _, column := range columns {
+		v, found := rw[column]
+		if !found {
+			continue
+		}
+		if _, err := event.PutValue(target+column, cloneValue(v)); err != nil {
+			return fmt.Errorf("could not put value for key: %s, %w", target+column, err)
+		}
+	}
+	return nil
+}
+
+// unmatchedColumns returns the columns of the row that are not used by a
+// match condition, sorted by name.
+func (p *enrich) unmatchedColumns(rw row) []string {
+	columns := make([]string, 0, len(rw))
+	for column := range rw {
+		matched := false
+		for _, m := range p.Match {
+			if m.Column == column {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// cloneValue copies the objects and arrays of JSON tables, as table values
+// are shared between events.
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(mapstr.M, len(v))
+		for k, elem := range v {
+			m[k] = cloneValue(elem)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, elem := range v {
+			s[i] = cloneValue(elem)
+		}
+		return s
+	}
+	return v
+}
+
+// Close stops reloading the lookup table.
+func (p *enrich) Close() error {
+	close(p.stop)
+	<-p.done
+	return nil
+}
+
+func (p *enrich) String() string {
+	conditions := make([]string, 0, len(p.Match))
+	for _, m := range p.Match {
+		conditions = append(conditions, m.Field+" "+m.Type+" "+m.Column)
+	}
+	return fmt.Sprintf("%v=[file=%v, match=[%v], fields=%v, target=%v]",
+		procName, p.File, strings.Join(conditions, ", "), p.Fields, p.Target)
+}
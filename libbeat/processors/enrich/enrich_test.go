@@ -0,0 +1,317 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package enrich
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const hostsCSV = `host,team,tier
+web-01,frontend,1
+db-01,storage,
+`
+
+const assetsJSON = `[
+  {"network": "10.0.0.0/8", "asset": {"tags": ["internal"]}},
+  {"network": "10.1.0.0/16", "asset": {"tags": ["internal", "pci"], "owner": "payments"}}
+]`
+
+const servicesJSON = `{"port": 8080, "service": "proxy"}
+{"port": 12345678, "service": "batch"}
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func newTestProcessor(t *testing.T, settings map[string]interface{}) *enrich {
+	t.Helper()
+	p, err := New(conf.MustNewConfigFrom(settings))
+	require.NoError(t, err)
+	t.Cleanup(func() { p.(*enrich).Close() })
+	return p.(*enrich)
+}
+
+func TestEnrich(t *testing.T) {
+	hosts := writeFile(t, "hosts.csv", hostsCSV)
+	assets := writeFile(t, "assets.json", assetsJSON)
+	services := writeFile(t, "services.json", servicesJSON)
+
+	testCases := []struct {
+		name     string
+		config   map[string]interface{}
+		fields   mapstr.M
+		expected mapstr.M
+		wantErr  string
+	}{
+		{
+			name: "all columns",
+			config: map[string]interface{}{
+				"file":  hosts,
+				"match": []map[string]interface{}{{"field": "host.name", "column": "host"}},
+			},
+			fields: mapstr.M{"host": mapstr.M{"name": "web-01"}},
+			expected: mapstr.M{
+				"host": mapstr.M{"name": "web-01"},
+				"team": "frontend",
+				"tier": "1",
+			},
+		},
+		{
+			name: "selected columns with target",
+			config: map[string]interface{}{
+				"file":   hosts,
+				"match":  []map[string]interface{}{{"field": "host.name", "column": "host"}},
+				"fields": []string{"team"},
+				"target": "host.owner",
+			},
+			fields: mapstr.M{"host": mapstr.M{"name": "db-01"}},
+			expected: mapstr.M{
+				"host": mapstr.M{"name": "db-01", "owner": mapstr.M{"team": "storage"}},
+			},
+		},
+		{
+			name: "empty cells are not copied",
+			config: map[string]interface{}{
+				"file":  hosts,
+				"match": []map[string]interface{}{{"field": "host.name", "column": "host"}},
+			},
+			fields: mapstr.M{"host": mapstr.M{"name": "db-01"}},
+			expected: mapstr.M{
+				"host": mapstr.M{"name": "db-01"},
+				"team": "storage",
+			},
+		},
+		{
+			name: "cidr on array of IPs",
+			config: map[string]interface{}{
+				"file":  assets,
+				"match": []map[string]interface{}{{"field": "host.ip", "column": "network", "type": "cidr"}},
+			},
+			fields: mapstr.M{"host": mapstr.M{"ip": []interface{}{"192.168.1.1", net.ParseIP("10.1.2.3")}}},
+			expected: mapstr.M{
+				"host":  mapstr.M{"ip": []interface{}{"192.168.1.1", net.ParseIP("10.1.2.3")}},
+				"asset": mapstr.M{"tags": []interface{}{"internal", "pci"}, "owner": "payments"},
+			},
+		},
+		{
+			name: "numeric match column",
+			config: map[string]interface{}{
+				"file":   services,
+				"match":  []map[string]interface{}{{"field": "destination.port", "column": "port"}},
+				"fields": []string{"service"},
+			},
+			fields: mapstr.M{"destination": mapstr.M{"port": 12345678}},
+			expected: mapstr.M{
+				"destination": mapstr.M{"port": 12345678},
+				"service":     "batch",
+			},
+		},
+		{
+			name: "no match",
+			config: map[string]interface{}{
+				"file":  hosts,
+				"match": []map[string]interface{}{{"field": "host.name", "column": "host"}},
+			},
+			fields:   mapstr.M{"host": mapstr.M{"name": "app-01"}},
+			expected: mapstr.M{"host": mapstr.M{"name": "app-01"}},
+		},
+		{
+			name: "missing field",
+			config: map[string]interface{}{
+				"file":  hosts,
+				"match": []map[string]interface{}{{"field": "host.name", "column": "host"}},
+			},
+			fields:   mapstr.M{"message": "hello"},
+			expected: mapstr.M{"message": "hello"},
+		},
+		{
+			name: "existing key",
+			config: map[string]interface{}{
+				"file":  hosts,
+				"match": []map[string]interface{}{{"field": "host.name", "column": "host"}},
+			},
+			fields:   mapstr.M{"host": mapstr.M{"name": "web-01"}, "tier": "0"},
+			expected: mapstr.M{"host": mapstr.M{"name": "web-01"}, "tier": "0"},
+			wantErr:  "cannot override existing key `tier`",
+		},
+		{
+			name: "overwrite keys",
+			config: map[string]interface{}{
+				"file":           hosts,
+				"match":          []map[string]interface{}{{"field": "host.name", "column": "host"}},
+				"overwrite_keys": true,
+			},
+			fields: mapstr.M{"host": mapstr.M{"name": "web-01"}, "tier": "0"},
+			expected: mapstr.M{
+				"host": mapstr.M{"name": "web-01"},
+				"team": "frontend",
+				"tier": "1",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newTestProcessor(t, tc.config)
+
+			event, err := p.Run(&beat.Event{Fields: tc.fields})
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, event.Fields)
+		})
+	}
+}
+
+func TestEnrichSharedValues(t *testing.T) {
+	assets := writeFile(t, "assets.json", assetsJSON)
+	p := newTestProcessor(t, map[string]interface{}{
+		"file":  assets,
+		"match": []map[string]interface{}{{"field": "source.ip", "column": "network", "type": "cidr"}},
+	})
+
+	first, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "10.0.0.1"}}})
+	require.NoError(t, err)
+	_, err = first.PutValue("asset.tags", "changed")
+	require.NoError(t, err)
+
+	second, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "10.0.0.1"}}})
+	require.NoError(t, err)
+	tags, _ := second.GetValue("asset.tags")
+	assert.Equal(t, []interface{}{"internal"}, tags)
+}
+
+func TestEnrichConfig(t *testing.T) {
+	hosts := writeFile(t, "hosts.csv", hostsCSV)
+
+	testCases := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "missing match",
+			config:  map[string]interface{}{"file": hosts},
+			wantErr: "at least one match condition is required",
+		},
+		{
+			name: "invalid match type",
+			config: map[string]interface{}{
+				"file":  hosts,
+				"match": []map[string]interface{}{{"field": "host.name", "column": "host", "type": "regex"}},
+			},
+			wantErr: "invalid match type 'regex'",
+		},
+		{
+			name: "invalid format",
+			config: map[string]interface{}{
+				"file":   hosts,
+				"format": "xml",
+				"match":  []map[string]interface{}{{"field": "host.name", "column": "host"}},
+			},
+			wantErr: "invalid format 'xml'",
+		},
+		{
+			name: "invalid separator",
+			config: map[string]interface{}{
+				"file":      hosts,
+				"separator": ";;",
+				"match":     []map[string]interface{}{{"field": "host.name", "column": "host"}},
+			},
+			wantErr: "separator must be a single character",
+		},
+		{
+			name: "missing file",
+			config: map[string]interface{}{
+				"file":  filepath.Join(t.TempDir(), "missing.csv"),
+				"match": []map[string]interface{}{{"field": "host.name", "column": "host"}},
+			},
+			wantErr: "failed to load lookup table",
+		},
+		{
+			name: "invalid network",
+			config: map[string]interface{}{
+				"file":  hosts,
+				"match": []map[string]interface{}{{"field": "host.name", "column": "host", "type": "cidr"}},
+			},
+			wantErr: "invalid IP address 'web-01'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(tc.config))
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestEnrichReload(t *testing.T) {
+	hosts := writeFile(t, "hosts.csv", hostsCSV)
+	p := newTestProcessor(t, map[string]interface{}{
+		"file":            hosts,
+		"match":           []map[string]interface{}{{"field": "host.name", "column": "host"}},
+		"fields":          []string{"team"},
+		"reload_interval": 0,
+	})
+
+	teamOf := func(host string) interface{} {
+		event, err := p.Run(&beat.Event{Fields: mapstr.M{"host": mapstr.M{"name": host}}})
+		require.NoError(t, err)
+		v, _ := event.GetValue("team")
+		return v
+	}
+	assert.Equal(t, "frontend", teamOf("web-01"))
+
+	require.NoError(t, os.WriteFile(hosts, []byte("host,team\nweb-01,platform\n"), 0o644))
+	// Make sure the change is detected on file systems with coarse timestamps.
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(hosts, future, future))
+
+	reloaded, err := p.table.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "platform", teamOf("web-01"))
+
+	reloaded, err = p.table.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// An invalid file keeps the previous version of the table.
+	require.NoError(t, os.WriteFile(hosts, []byte("host,team\nweb-01\n"), 0o644))
+	_, err = p.table.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "platform", teamOf("web-01"))
+}
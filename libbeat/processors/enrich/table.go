@@ -0,0 +1,366 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package enrich
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// row is a row of the lookup table, by column name.
+type row map[string]interface{}
+
+// table is a lookup table indexed on the match columns.
+type table struct {
+	rows     []row
+	matchers []matcher
+}
+
+// matcher is an index on a match column.
+type matcher interface {
+	// candidates returns the rows matching value, best match first.
+	candidates(value string) []int
+
+	// matches reports whether the row at index i matches value.
+	matches(i int, value string) bool
+}
+
+// loadTable reads and indexes the lookup table of the configuration.
+func loadTable(path string, c config) (*table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rows []row
+	switch c.Format {
+	case formatCSV:
+		sep := []rune(c.Separator)[0]
+		rows, err = readCSV(f, sep)
+	case formatJSON:
+		rows, err = readJSON(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	t, err := newTable(rows, c.Match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to index %s: %w", path, err)
+	}
+	return t, nil
+}
+
+func readCSV(r io.Reader, separator rune) ([]row, error) {
+	columns, records, err := common.ReadCSVWithHeader(r, separator)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]row, 0, len(records))
+	for _, record := range records {
+		rw := make(row, len(columns))
+		for i, column := range columns {
+			// Empty cells are left out, so that they are not copied.
+			if record[i] != "" {
+				rw[column] = record[i]
+			}
+		}
+		rows = append(rows, rw)
+	}
+	return rows, nil
+}
+
+// readJSON reads either a JSON array of objects or newline delimited objects.
+func readJSON(r io.Reader) ([]row, error) {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+
+	if isArray, err := startsWith(br, '['); err != nil {
+		return nil, err
+	} else if isArray {
+		var rows []row
+		if err := dec.Decode(&rows); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
+
+	var rows []row
+	for {
+		var rw row
+		if err := dec.Decode(&rw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return rows, nil
+			}
+			return nil, err
+		}
+		rows = append(rows, rw)
+	}
+}
+
+// startsWith reports whether the first non-space byte of r is b.
+func startsWith(r *bufio.Reader, b byte) (bool, error) {
+	for n := 1; ; n++ {
+		buf, err := r.Peek(n)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return false, nil
+			}
+			return false, err
+		}
+		switch c := buf[n-1]; c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c == b, nil
+		}
+	}
+}
+
+// scalarString returns the string representation of a scalar value, so that
+// JSON tables can be keyed by numbers like IDs or ports. Whole numbers are
+// formatted without exponent.
+func scalarString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case map[string]interface{}, mapstr.M, []interface{}, nil:
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+func newTable(rows []row, conditions []matchConfig) (*table, error) {
+	t := &table{rows: rows}
+	for _, cond := range conditions {
+		values := make([]string, len(rows))
+		for i, rw := range rows {
+			v, found := rw[cond.Column]
+			if !found || v == nil {
+				continue
+			}
+			s, ok := scalarString(v)
+			if !ok {
+				return nil, fmt.Errorf("row %d: value of match column '%s' is not a string, number or boolean", i+1, cond.Column)
+			}
+			values[i] = s
+		}
+
+		var (
+			m   matcher
+			err error
+		)
+		switch cond.Type {
+		case matchExact:
+			m = newExactMatcher(values)
+		case matchCIDR:
+			m, err = newCIDRMatcher(values)
+		case matchPrefix:
+			m = newPrefixMatcher(values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("match column '%s': %w", cond.Column, err)
+		}
+		t.matchers = append(t.matchers, m)
+	}
+	return t, nil
+}
+
+// lookup returns the first row matching all the conditions. values holds the
+// event values of each condition; a condition matches if any of its values
+// matches.
+func (t *table) lookup(values [][]string) (row, bool) {
+	for _, v := range values[0] {
+		for _, i := range t.matchers[0].candidates(v) {
+			if t.matchesRest(i, values) {
+				return t.rows[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (t *table) matchesRest(i int, values [][]string) bool {
+	for j := 1; j < len(t.matchers); j++ {
+		matched := false
+		for _, v := range values[j] {
+			if t.matchers[j].matches(i, v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// exactMatcher matches values equal to the column value.
+type exactMatcher struct {
+	values []string
+	index  map[string][]int
+}
+
+func newExactMatcher(values []string) *exactMatcher {
+	m := &exactMatcher{values: values, index: map[string][]int{}}
+	for i, v := range values {
+		if v != "" {
+			m.index[v] = append(m.index[v], i)
+		}
+	}
+	return m
+}
+
+func (m *exactMatcher) candidates(value string) []int {
+	return m.index[value]
+}
+
+func (m *exactMatcher) matches(i int, value string) bool {
+	return m.values[i] != "" && m.values[i] == value
+}
+
+// prefixMatcher matches values starting with the column value. Longer
+// prefixes are better matches.
+type prefixMatcher struct {
+	values  []string
+	lengths []int // Distinct prefix lengths, longest first.
+	index   map[string][]int
+}
+
+func newPrefixMatcher(values []string) *prefixMatcher {
+	m := &prefixMatcher{values: values, index: map[string][]int{}}
+	seen := map[int]bool{}
+	for i, v := range values {
+		if v == "" {
+			continue
+		}
+		m.index[v] = append(m.index[v], i)
+		if !seen[len(v)] {
+			seen[len(v)] = true
+			m.lengths = append(m.lengths, len(v))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(m.lengths)))
+	return m
+}
+
+func (m *prefixMatcher) candidates(value string) []int {
+	var rows []int
+	for _, n := range m.lengths {
+		if n <= len(value) {
+			rows = append(rows, m.index[value[:n]]...)
+		}
+	}
+	return rows
+}
+
+func (m *prefixMatcher) matches(i int, value string) bool {
+	return m.values[i] != "" && strings.HasPrefix(value, m.values[i])
+}
+
+// cidrMatcher matches IP addresses contained in the column network. Longer
+// network prefixes are better matches. A plain IP address is matched as a
+// single address network.
+type cidrMatcher struct {
+	networks []*net.IPNet
+	lengths  []int // Distinct prefix lengths, in IPv6 bits, longest first.
+	index    map[int]map[string][]int
+}
+
+func newCIDRMatcher(values []string) (*cidrMatcher, error) {
+	m := &cidrMatcher{networks: make([]*net.IPNet, len(values)), index: map[int]map[string][]int{}}
+	for i, v := range values {
+		if v == "" {
+			continue
+		}
+		network, err := parseNetwork(v)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		m.networks[i] = network
+
+		ones, bits := network.Mask.Size()
+		if bits == 8*net.IPv4len {
+			ones += 8 * (net.IPv6len - net.IPv4len)
+		}
+		if m.index[ones] == nil {
+			m.index[ones] = map[string][]int{}
+			m.lengths = append(m.lengths, ones)
+		}
+		key := string(network.IP.To16())
+		m.index[ones][key] = append(m.index[ones][key], i)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(m.lengths)))
+	return m, nil
+}
+
+func parseNetwork(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address '%s'", s)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(8*net.IPv4len, 8*net.IPv4len)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*net.IPv6len, 8*net.IPv6len)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+func (m *cidrMatcher) candidates(value string) []int {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil
+	}
+	ip = ip.To16()
+
+	var rows []int
+	for _, n := range m.lengths {
+		key := string(ip.Mask(net.CIDRMask(n, 8*net.IPv6len)))
+		rows = append(rows, m.index[n][key]...)
+	}
+	return rows
+}
+
+func (m *cidrMatcher) matches(i int, value string) bool {
+	ip := net.ParseIP(value)
+	return ip != nil && m.networks[i] != nil && m.networks[i].Contains(ip)
+}
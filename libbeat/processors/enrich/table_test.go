@@ -0,0 +1,186 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package enrich
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableLookup(t *testing.T) {
+	rows := []row{
+		{"host": "web-01", "prefix": "web-", "network": "10.0.0.0/8", "name": "a"},
+		{"host": "web-02", "prefix": "web-0", "network": "10.1.0.0/16", "name": "b"},
+		{"host": "db-01", "prefix": "db-", "network": "10.1.2.3", "name": "c"},
+		{"host": "web-01", "prefix": "w", "network": "2001:db8::/32", "name": "d"},
+		{"name": "e"},
+	}
+
+	testCases := []struct {
+		name       string
+		conditions []matchConfig
+		values     [][]string
+		expected   string // Name of the matched row, empty if none.
+	}{
+		{
+			name:       "exact",
+			conditions: []matchConfig{{Column: "host", Type: matchExact}},
+			values:     [][]string{{"db-01"}},
+			expected:   "c",
+		},
+		{
+			name:       "exact first row wins",
+			conditions: []matchConfig{{Column: "host", Type: matchExact}},
+			values:     [][]string{{"web-01"}},
+			expected:   "a",
+		},
+		{
+			name:       "exact no match",
+			conditions: []matchConfig{{Column: "host", Type: matchExact}},
+			values:     [][]string{{"web-03"}},
+		},
+		{
+			name:       "exact any value",
+			conditions: []matchConfig{{Column: "host", Type: matchExact}},
+			values:     [][]string{{"unknown", "web-02"}},
+			expected:   "b",
+		},
+		{
+			name:       "longest prefix",
+			conditions: []matchConfig{{Column: "prefix", Type: matchPrefix}},
+			values:     [][]string{{"web-07"}},
+			expected:   "b",
+		},
+		{
+			name:       "shorter prefix",
+			conditions: []matchConfig{{Column: "prefix", Type: matchPrefix}},
+			values:     [][]string{{"web-10"}},
+			expected:   "a",
+		},
+		{
+			name:       "prefix no match",
+			conditions: []matchConfig{{Column: "prefix", Type: matchPrefix}},
+			values:     [][]string{{"app-01"}},
+		},
+		{
+			name:       "longest network",
+			conditions: []matchConfig{{Column: "network", Type: matchCIDR}},
+			values:     [][]string{{"10.1.2.4"}},
+			expected:   "b",
+		},
+		{
+			name:       "single address",
+			conditions: []matchConfig{{Column: "network", Type: matchCIDR}},
+			values:     [][]string{{"10.1.2.3"}},
+			expected:   "c",
+		},
+		{
+			name:       "IPv6 network",
+			conditions: []matchConfig{{Column: "network", Type: matchCIDR}},
+			values:     [][]string{{"2001:db8::1"}},
+			expected:   "d",
+		},
+		{
+			name:       "network no match",
+			conditions: []matchConfig{{Column: "network", Type: matchCIDR}},
+			values:     [][]string{{"192.168.0.1", "not-an-ip"}},
+		},
+		{
+			name: "all conditions",
+			conditions: []matchConfig{
+				{Column: "host", Type: matchExact},
+				{Column: "network", Type: matchCIDR},
+			},
+			values:   [][]string{{"web-01"}, {"2001:db8::2"}},
+			expected: "d",
+		},
+		{
+			name: "all conditions next candidate",
+			conditions: []matchConfig{
+				{Column: "network", Type: matchCIDR},
+				{Column: "prefix", Type: matchPrefix},
+			},
+			values:   [][]string{{"10.1.2.3"}, {"web-01"}},
+			expected: "b",
+		},
+		{
+			name: "all conditions no match",
+			conditions: []matchConfig{
+				{Column: "network", Type: matchCIDR},
+				{Column: "prefix", Type: matchPrefix},
+			},
+			values: [][]string{{"10.1.2.3"}, {"app-01"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tbl, err := newTable(rows, tc.conditions)
+			require.NoError(t, err)
+
+			rw, found := tbl.lookup(tc.values)
+			if tc.expected == "" {
+				assert.False(t, found, "matched row %v", rw)
+				return
+			}
+			if assert.True(t, found) {
+				assert.Equal(t, tc.expected, rw["name"])
+			}
+		})
+	}
+}
+
+func TestNewTableErrors(t *testing.T) {
+	_, err := newTable([]row{{"network": "10.0.0.0/33"}}, []matchConfig{{Column: "network", Type: matchCIDR}})
+	assert.ErrorContains(t, err, "match column 'network': row 1")
+
+	_, err = newTable([]row{{"host": map[string]interface{}{"name": "web-01"}}}, []matchConfig{{Column: "host", Type: matchExact}})
+	assert.ErrorContains(t, err, "is not a string, number or boolean")
+}
+
+func TestReadTable(t *testing.T) {
+	expected := []row{
+		{"host": "web-01", "team": "frontend"},
+		{"host": "db-01"},
+	}
+
+	rows, err := readCSV(strings.NewReader("host,team\nweb-01,frontend\ndb-01,\n"), ',')
+	require.NoError(t, err)
+	assert.Equal(t, expected, rows)
+
+	rows, err = readJSON(strings.NewReader(`
+		[{"host": "web-01", "team": "frontend"}, {"host": "db-01"}]`))
+	require.NoError(t, err)
+	assert.Equal(t, expected, rows)
+
+	rows, err = readJSON(strings.NewReader(`{"host": "web-01", "team": "frontend"}
+{"host": "db-01"}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, expected, rows)
+
+	rows, err = readJSON(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+
+	_, err = readJSON(strings.NewReader(`{"host": `))
+	assert.Error(t, err)
+}
@@ -19,16 +19,11 @@ package fingerprint
 
 import (
 	"encoding/json"
-	"fmt"
-	"io"
-	"time"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
-	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/processors"
 	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor/registry"
 	"github.com/elastic/elastic-agent-libs/config"
-	"github.com/elastic/elastic-agent-libs/mapstr"
 )
 
 const (
@@ -42,8 +37,7 @@ func init() {
 
 type fingerprint struct {
 	config Config
-	fields []string
-	hash   hashMethod
+	hasher *Hasher
 }
 
 // New constructs a new fingerprint processor.
@@ -53,15 +47,9 @@ func New(cfg *config.C) (beat.Processor, error) {
 		return nil, makeErrConfigUnpack(err)
 	}
 
-	// The fields array must be sorted, to guarantee that we always
-	// get the same hash for a similar set of configured keys.
-	// The call `ToSlice` always returns a sorted slice.
-	fields := common.MakeStringSet(config.Fields...).ToSlice()
-
 	p := &fingerprint{
 		config: config,
-		hash:   config.Method.Hash,
-		fields: fields,
+		hasher: newHasher(config.Method.Hash, config.Fields, config.IgnoreMissing),
 	}
 
 	return p, nil
@@ -69,13 +57,12 @@ func New(cfg *config.C) (beat.Processor, error) {
 
 // Run enriches the given event with a fingerprint.
 func (p *fingerprint) Run(event *beat.Event) (*beat.Event, error) {
-	hashFn := p.hash()
-
-	if err := p.writeFields(hashFn, event); err != nil {
+	sum, err := p.hasher.Sum(event)
+	if err != nil {
 		return nil, makeErrComputeFingerprint(err)
 	}
 
-	encodedHash := p.config.Encoding.Encode(hashFn.Sum(nil))
+	encodedHash := p.config.Encoding.Encode(sum)
 
 	if _, err := event.PutValue(p.config.TargetField, encodedHash); err != nil {
 		return nil, makeErrComputeFingerprint(err)
@@ -88,28 +75,3 @@ func (p *fingerprint) String() string {
 	json, _ := json.Marshal(&p.config)
 	return procName + "=" + string(json)
 }
-
-func (p *fingerprint) writeFields(to io.Writer, event *beat.Event) error {
-	for _, k := range p.fields {
-		v, err := event.GetValue(k)
-		if err != nil {
-			if p.config.IgnoreMissing {
-				continue
-			}
-			return makeErrMissingField(k, err)
-		}
-
-		switch vv := v.(type) {
-		case map[string]interface{}, []interface{}, mapstr.M:
-			return makeErrNonScalarField(k)
-		case time.Time:
-			// Ensure we consistently hash times in UTC.
-			v = vv.UTC()
-		}
-
-		fmt.Fprintf(to, "|%v|%v", k, v)
-	}
-
-	_, _ = io.WriteString(to, "|")
-	return nil
-}
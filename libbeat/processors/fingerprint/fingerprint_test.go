@@ -18,6 +18,7 @@
 package fingerprint
 
 import (
+	"encoding/hex"
 	"fmt"
 	"math/rand/v2"
 	"strconv"
@@ -496,6 +497,31 @@ func BenchmarkHashMethods(b *testing.B) {
 	}
 }
 
+func TestHasher(t *testing.T) {
+	event := &beat.Event{Fields: mapstr.M{"field1": "foo", "field2": 42}}
+
+	p, err := New(config.MustNewConfigFrom(mapstr.M{
+		"fields": []string{"field2", "field1"},
+		"method": "xxhash",
+	}))
+	require.NoError(t, err)
+	out, err := p.Run(event.Clone())
+	require.NoError(t, err)
+	want, err := out.GetValue("fingerprint")
+	require.NoError(t, err)
+
+	h, err := NewHasher("xxhash", []string{"field1", "field2"}, false)
+	require.NoError(t, err)
+	sum, err := h.Sum(event)
+	require.NoError(t, err)
+	assert.Equal(t, want, hex.EncodeToString(sum))
+
+	_, err = NewHasher("crc32", []string{"field1"}, false)
+	assert.Error(t, err)
+	_, err = NewHasher("sha256", nil, false)
+	assert.Error(t, err)
+}
+
 func nRandomEvents(num int) []beat.Event {
 	prng := rand.New(rand.NewPCG(0, 12345))
 
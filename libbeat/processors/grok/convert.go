@@ -0,0 +1,50 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var errUnsupportedType = errors.New("unsupported type")
+
+// convert converts a captured value to the type of a typed capture such as
+// %{NUMBER:bytes:int}.
+func convert(v, typ string) (interface{}, error) {
+	switch typ {
+	case "", "string":
+		return v, nil
+	case "int", "integer", "long":
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i, nil
+		}
+		// Decimal numbers are truncated.
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		return int64(f), nil
+	case "float", "double":
+		return strconv.ParseFloat(v, 64)
+	case "bool", "boolean":
+		return strconv.ParseBool(v)
+	}
+	return nil, fmt.Errorf("%w '%s', must be one of int, long, float, double, boolean or string", errUnsupportedType, typ)
+}
@@ -0,0 +1,235 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxDepth bounds the nesting of pattern references.
+const maxDepth = 64
+
+var (
+	// referenceRE matches pattern references: %{NAME}, %{NAME:field} and
+	// %{NAME:field:type}.
+	referenceRE = regexp.MustCompile(`%\{(\w+)(?::([\w.@\[\]-]+))?(?::(\w+))?\}`)
+
+	// namedGroupRE matches named capture groups, which are rewritten so
+	// field names are not restricted to the regexp syntax.
+	namedGroupRE = regexp.MustCompile(`\(\?P?<([A-Za-z@_][\w.@\[\]-]*)>`)
+
+	errNoMatch = errors.New("no pattern matched")
+)
+
+// cache shares compiled regular expressions between processors using the
+// same patterns.
+var cache = struct {
+	sync.Mutex
+	regexps map[string]*regexp.Regexp
+}{regexps: map[string]*regexp.Regexp{}}
+
+func compileCached(expr string) (*regexp.Regexp, error) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if re, found := cache.regexps[expr]; found {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	cache.regexps[expr] = re
+	return re, nil
+}
+
+// capture maps a capture group to the field it is stored in.
+type capture struct {
+	group int
+	field string
+	typ   string
+}
+
+type pattern struct {
+	raw      string
+	re       *regexp.Regexp
+	captures []capture
+}
+
+// Grok matches strings against a list of grok patterns, tried in order.
+type Grok struct {
+	patterns []pattern
+}
+
+// NewGrok compiles the patterns. Pattern references are resolved against the
+// definitions first and the default pattern library second.
+func NewGrok(patterns []string, definitions map[string]string) (*Grok, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no patterns configured")
+	}
+
+	g := &Grok{}
+	for _, raw := range patterns {
+		p, err := compile(raw, definitions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile pattern '%s': %w", raw, err)
+		}
+		g.patterns = append(g.patterns, p)
+	}
+	return g, nil
+}
+
+// compiler expands a pattern into a regular expression, replacing captures
+// by numbered groups.
+type compiler struct {
+	definitions map[string]string
+	captures    map[string]capture
+}
+
+func compile(raw string, definitions map[string]string) (pattern, error) {
+	c := &compiler{definitions: definitions, captures: map[string]capture{}}
+	expr, err := c.expand(raw, nil)
+	if err != nil {
+		return pattern{}, err
+	}
+
+	re, err := compileCached(expr)
+	if err != nil {
+		return pattern{}, err
+	}
+
+	p := pattern{raw: raw, re: re}
+	for i, name := range re.SubexpNames() {
+		if capt, found := c.captures[name]; found {
+			capt.group = i
+			p.captures = append(p.captures, capt)
+		}
+	}
+	return p, nil
+}
+
+func (c *compiler) expand(expr string, stack []string) (string, error) {
+	if len(stack) > maxDepth {
+		return "", fmt.Errorf("pattern references nested too deeply: %s", strings.Join(stack, " -> "))
+	}
+
+	var err error
+	expr = namedGroupRE.ReplaceAllStringFunc(expr, func(m string) string {
+		field := namedGroupRE.FindStringSubmatch(m)[1]
+		return "(?P<" + c.addCapture(field, "") + ">"
+	})
+
+	expr = referenceRE.ReplaceAllStringFunc(expr, func(m string) string {
+		if err != nil {
+			return ""
+		}
+		ref := referenceRE.FindStringSubmatch(m)
+		name, field, typ := ref[1], ref[2], ref[3]
+
+		for _, s := range stack {
+			if s == name {
+				err = fmt.Errorf("recursive pattern reference: %s -> %s", strings.Join(stack, " -> "), name)
+				return ""
+			}
+		}
+
+		def, found := c.definitions[name]
+		if !found {
+			def, found = defaultPatterns[name]
+		}
+		if !found {
+			err = fmt.Errorf("pattern %%{%s} not defined", name)
+			return ""
+		}
+
+		var sub string
+		sub, err = c.expand(def, append(stack, name))
+		if err != nil {
+			return ""
+		}
+
+		if field == "" {
+			return "(?:" + sub + ")"
+		}
+		if typ != "" {
+			if _, convErr := convert("", typ); errors.Is(convErr, errUnsupportedType) {
+				err = convErr
+				return ""
+			}
+		}
+		return "(?P<" + c.addCapture(field, typ) + ">" + sub + ")"
+	})
+	return expr, err
+}
+
+func (c *compiler) addCapture(field, typ string) string {
+	name := "g" + strconv.Itoa(len(c.captures))
+	c.captures[name] = capture{field: normalizeField(field), typ: typ}
+	return name
+}
+
+// normalizeField turns the [a][b] field reference syntax into a.b.
+func normalizeField(field string) string {
+	if !strings.HasPrefix(field, "[") {
+		return field
+	}
+	return strings.Join(strings.Split(strings.Trim(field, "[]"), "]["), ".")
+}
+
+// Match returns the captures of the first pattern matching s, keyed by
+// field. If a field is captured multiple times, the first capture that
+// participated in the match wins.
+func (g *Grok) Match(s string) (map[string]interface{}, error) {
+	for _, p := range g.patterns {
+		idx := p.re.FindStringSubmatchIndex(s)
+		if idx == nil {
+			continue
+		}
+
+		fields := make(map[string]interface{}, len(p.captures))
+		for _, capt := range p.captures {
+			start, end := idx[2*capt.group], idx[2*capt.group+1]
+			if start < 0 {
+				continue
+			}
+			if _, found := fields[capt.field]; found {
+				continue
+			}
+			v, err := convert(s[start:end], capt.typ)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert field '%s': %w", capt.field, err)
+			}
+			fields[capt.field] = v
+		}
+		return fields, nil
+	}
+	return nil, errNoMatch
+}
+
+func (g *Grok) String() string {
+	raw := make([]string, 0, len(g.patterns))
+	for _, p := range g.patterns {
+		raw = append(raw, p.raw)
+	}
+	return "[" + strings.Join(raw, ", ") + "]"
+}
@@ -0,0 +1,204 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrok(t *testing.T) {
+	tests := []struct {
+		name        string
+		patterns    []string
+		definitions map[string]string
+		input       string
+		expected    map[string]interface{}
+	}{
+		{
+			name:     "simple",
+			patterns: []string{`%{IP:client.ip} %{WORD:method} %{URIPATHPARAM:path}`},
+			input:    "55.3.244.1 GET /index.html?a=1",
+			expected: map[string]interface{}{
+				"client.ip": "55.3.244.1",
+				"method":    "GET",
+				"path":      "/index.html?a=1",
+			},
+		},
+		{
+			name:     "typed captures",
+			patterns: []string{`%{NUMBER:bytes:int} %{NUMBER:duration:float} %{WORD:ok:boolean} %{NUMBER:rounded:long}`},
+			input:    "15824 0.043 true 3.7",
+			expected: map[string]interface{}{
+				"bytes":    int64(15824),
+				"duration": 0.043,
+				"ok":       true,
+				"rounded":  int64(3),
+			},
+		},
+		{
+			name:        "custom definitions",
+			patterns:    []string{`%{REQUEST_ID:request.id}: %{GREEDYDATA:message}`},
+			definitions: map[string]string{"REQUEST_ID": `req-[0-9a-f]{4}`},
+			input:       "req-12ab: started",
+			expected: map[string]interface{}{
+				"request.id": "req-12ab",
+				"message":    "started",
+			},
+		},
+		{
+			name:        "definitions override the library",
+			patterns:    []string{`%{WORD:w}`},
+			definitions: map[string]string{"WORD": `[a-z]+`},
+			input:       "ABC def",
+			expected:    map[string]interface{}{"w": "def"},
+		},
+		{
+			name: "patterns are tried in order",
+			patterns: []string{
+				`^%{IP:source.ip} -> %{IP:destination.ip}$`,
+				`^%{IP:source.ip}$`,
+				`^%{GREEDYDATA:other}$`,
+			},
+			input:    "10.0.0.1",
+			expected: map[string]interface{}{"source.ip": "10.0.0.1"},
+		},
+		{
+			name:     "optional and alternating formats",
+			patterns: []string{`^(?:%{IPV4:host}|%{HOSTNAME:host})(?: port %{POSINT:port:int})?$`},
+			input:    "example.com",
+			expected: map[string]interface{}{"host": "example.com"},
+		},
+		{
+			name:     "nested field syntax and named groups",
+			patterns: []string{`%{WORD:[log][level]} (?<event.code>\d+)`},
+			input:    "error 4625",
+			expected: map[string]interface{}{"log.level": "error", "event.code": "4625"},
+		},
+		{
+			name:     "IPv6",
+			patterns: []string{`^%{IP:ip}$`},
+			input:    "2001:db8::8a2e:370:7334",
+			expected: map[string]interface{}{"ip": "2001:db8::8a2e:370:7334"},
+		},
+		{
+			name:     "combined apache log",
+			patterns: []string{`%{COMBINEDAPACHELOG}`},
+			input:    `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`,
+			expected: map[string]interface{}{
+				"clientip":    "127.0.0.1",
+				"ident":       "-",
+				"auth":        "frank",
+				"timestamp":   "10/Oct/2000:13:55:36 -0700",
+				"verb":        "GET",
+				"request":     "/apache_pb.gif",
+				"httpversion": "1.0",
+				"response":    "200",
+				"bytes":       "2326",
+				"referrer":    `"http://www.example.com/start.html"`,
+				"agent":       `"Mozilla/4.08"`,
+			},
+		},
+		{
+			name:     "syslog",
+			patterns: []string{`%{SYSLOGBASE} %{GREEDYDATA:message}`},
+			input:    "Mar  7 04:03:02 web-1 sshd[1234]: Accepted publickey for root",
+			expected: map[string]interface{}{
+				"timestamp": "Mar  7 04:03:02",
+				"logsource": "web-1",
+				"program":   "sshd",
+				"pid":       "1234",
+				"message":   "Accepted publickey for root",
+			},
+		},
+		{
+			name:     "timestamp",
+			patterns: []string{`%{TIMESTAMP_ISO8601:ts} %{LOGLEVEL:level}`},
+			input:    "2024-05-01T10:00:00.123Z WARN",
+			expected: map[string]interface{}{"ts": "2024-05-01T10:00:00.123Z", "level": "WARN"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := NewGrok(test.patterns, test.definitions)
+			require.NoError(t, err)
+
+			fields, err := g.Match(test.input)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, fields)
+		})
+	}
+}
+
+func TestGrokNoMatch(t *testing.T) {
+	g, err := NewGrok([]string{`^%{IP:ip}$`, `^%{INT:n}$`}, nil)
+	require.NoError(t, err)
+
+	_, err = g.Match("not an ip")
+	assert.ErrorIs(t, err, errNoMatch)
+}
+
+func TestGrokCompileErrors(t *testing.T) {
+	tests := map[string]struct {
+		patterns    []string
+		definitions map[string]string
+	}{
+		"no patterns":       {},
+		"undefined pattern": {patterns: []string{`%{NOT_DEFINED:x}`}},
+		"invalid type":      {patterns: []string{`%{NUMBER:x:decimal}`}},
+		"invalid regexp":    {patterns: []string{`%{WORD:x}(`}},
+		"recursive pattern": {
+			patterns:    []string{`%{A}`},
+			definitions: map[string]string{"A": `a%{B}`, "B": `b%{A}`},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewGrok(test.patterns, test.definitions)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGrokRegexpCache(t *testing.T) {
+	a, err := NewGrok([]string{`%{IP:ip} %{WORD:w}`}, nil)
+	require.NoError(t, err)
+	b, err := NewGrok([]string{`%{IP:ip} %{WORD:w}`}, nil)
+	require.NoError(t, err)
+
+	assert.Same(t, a.patterns[0].re, b.patterns[0].re)
+}
+
+func BenchmarkGrok(b *testing.B) {
+	// Anchored patterns are considerably faster, as the regexp engine only
+	// tries to match at the start of the input.
+	g, err := NewGrok([]string{`^%{COMBINEDAPACHELOG}`}, nil)
+	require.NoError(b, err)
+	line := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := g.Match(line)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
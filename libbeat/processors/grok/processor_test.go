@@ -0,0 +1,132 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestProcessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        map[string]interface{}
+		fields   mapstr.M
+		expected mapstr.M
+		wantErr  bool
+	}{
+		{
+			name:     "default field",
+			c:        map[string]interface{}{"patterns": []string{`%{WORD:verb} %{NUMBER:status:int}`}},
+			fields:   mapstr.M{"message": "GET 200"},
+			expected: mapstr.M{"message": "GET 200", "verb": "GET", "status": int64(200)},
+		},
+		{
+			name: "specific field and target prefix",
+			c: map[string]interface{}{
+				"patterns":      []string{`%{WORD:verb}`},
+				"field":         "raw",
+				"target_prefix": "parsed",
+			},
+			fields:   mapstr.M{"raw": "GET"},
+			expected: mapstr.M{"raw": "GET", "parsed": mapstr.M{"verb": "GET"}},
+		},
+		{
+			name:     "conflicting key",
+			c:        map[string]interface{}{"patterns": []string{`%{WORD:verb} %{WORD:message}`}},
+			fields:   mapstr.M{"message": "GET index"},
+			expected: mapstr.M{"message": "GET index"},
+			wantErr:  true,
+		},
+		{
+			name: "overwrite keys",
+			c: map[string]interface{}{
+				"patterns":       []string{`%{WORD:verb} %{WORD:message}`},
+				"overwrite_keys": true,
+			},
+			fields:   mapstr.M{"message": "GET index"},
+			expected: mapstr.M{"message": "index", "verb": "GET"},
+		},
+		{
+			name:     "no match",
+			c:        map[string]interface{}{"patterns": []string{`^%{INT:n}$`}},
+			fields:   mapstr.M{"message": "hello"},
+			expected: mapstr.M{"message": "hello", "log": mapstr.M{"flags": []string{flagParsingError}}},
+			wantErr:  true,
+		},
+		{
+			name:     "no match ignore failure",
+			c:        map[string]interface{}{"patterns": []string{`^%{INT:n}$`}, "ignore_failure": true},
+			fields:   mapstr.M{"message": "hello"},
+			expected: mapstr.M{"message": "hello", "log": mapstr.M{"flags": []string{flagParsingError}}},
+		},
+		{
+			name:     "missing field",
+			c:        map[string]interface{}{"patterns": []string{`%{INT:n}`}},
+			fields:   mapstr.M{},
+			expected: mapstr.M{},
+			wantErr:  true,
+		},
+		{
+			name:     "missing field ignored",
+			c:        map[string]interface{}{"patterns": []string{`%{INT:n}`}, "ignore_missing": true},
+			fields:   mapstr.M{},
+			expected: mapstr.M{},
+		},
+		{
+			name:     "not a string",
+			c:        map[string]interface{}{"patterns": []string{`%{INT:n}`}},
+			fields:   mapstr.M{"message": 42},
+			expected: mapstr.M{"message": 42},
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := NewProcessor(conf.MustNewConfigFrom(test.c))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: test.fields})
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expected, event.Fields)
+		})
+	}
+}
+
+func TestProcessorConfigErrors(t *testing.T) {
+	for name, c := range map[string]map[string]interface{}{
+		"missing patterns":  {},
+		"undefined pattern": {"patterns": []string{`%{FOO}`}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewProcessor(conf.MustNewConfigFrom(c))
+			assert.Error(t, err)
+		})
+	}
+}
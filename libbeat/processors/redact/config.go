@@ -0,0 +1,107 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	actionMask = "mask"
+	actionHash = "hash"
+	actionDrop = "drop"
+)
+
+type config struct {
+	// Fields are the fields to redact. By default all string values of the
+	// event are redacted.
+	Fields []string `config:"fields"`
+
+	// Rules are the detectors and patterns looked for in the fields.
+	Rules []ruleConfig `config:"rules"`
+
+	// Action is the default action of the rules.
+	Action string `config:"action"`
+
+	// Mask replaces the matches of rules with the mask action.
+	Mask string `config:"mask"`
+
+	// HashKey is the key of the HMAC replacing the matches of rules with the
+	// hash action.
+	HashKey string `config:"hash_key"`
+}
+
+type ruleConfig struct {
+	// Detector is the name of a built-in detector.
+	Detector string `config:"detector"`
+
+	// Pattern is a custom regular expression.
+	Pattern string `config:"pattern"`
+
+	// Action overrides the default action for matches of this rule.
+	Action string `config:"action"`
+}
+
+func defaultConfig() config {
+	return config{
+		Action: actionMask,
+		Mask:   "[REDACTED]",
+	}
+}
+
+func (c *config) Validate() error {
+	if len(c.Rules) == 0 {
+		return errors.New("at least one rule is required")
+	}
+	if err := validateAction(c.Action); err != nil {
+		return err
+	}
+
+	for i, r := range c.Rules {
+		switch {
+		case r.Detector == "" && r.Pattern == "":
+			return fmt.Errorf("rule %d: one of detector or pattern is required", i)
+		case r.Detector != "" && r.Pattern != "":
+			return fmt.Errorf("rule %d: detector and pattern cannot be used together", i)
+		case r.Detector != "":
+			if _, ok := detectors[r.Detector]; !ok {
+				return fmt.Errorf("rule %d: unknown detector '%s'", i, r.Detector)
+			}
+		}
+
+		action := r.Action
+		if action == "" {
+			action = c.Action
+		} else if err := validateAction(action); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+		if action == actionHash && c.HashKey == "" {
+			return fmt.Errorf("rule %d: hash_key is required by the %s action", i, actionHash)
+		}
+	}
+	return nil
+}
+
+func validateAction(action string) error {
+	switch action {
+	case actionMask, actionHash, actionDrop:
+		return nil
+	}
+	return fmt.Errorf("invalid action '%s', must be %s, %s or %s", action, actionMask, actionHash, actionDrop)
+}
@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// detector finds sensitive values in strings.
+type detector struct {
+	re *regexp.Regexp
+
+	// find, if set, returns the locations of the sensitive values within the
+	// match of re at loc in s, or nil if the match has no sensitive value.
+	find func(s string, loc []int) [][]int
+}
+
+// locate returns the locations of the sensitive values in the match of the
+// detector at loc in s.
+func (d detector) locate(s string, loc []int) [][]int {
+	if d.find == nil {
+		return [][]int{loc}
+	}
+	return d.find(s, loc)
+}
+
+// detectors are the built-in detectors by name.
+var detectors = map[string]detector{
+	"credit_card": {
+		re:   regexp.MustCompile(`\b\d[\d -]*\d\b`),
+		find: findCreditCard,
+	},
+	"email": {
+		re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	},
+	"ipv4": {
+		re: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`),
+	},
+	"ipv6": {
+		re:   regexp.MustCompile(`(?:[0-9A-Fa-f]{0,4}:){2,7}(?:(?:\d{1,3}\.){3}\d{1,3}|[0-9A-Fa-f]{0,4})(?:%[0-9A-Za-z]+)?`),
+		find: findIPv6,
+	},
+	"jwt": {
+		re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	},
+}
+
+// findCreditCard finds the card numbers in a match of digit groups. The
+// match can include other digits next to the card numbers, as in "order
+// 123456 4111 1111 1111 1111", so the runs of whole digit groups of 13 to 19
+// digits are checked, longest first from each group.
+func findCreditCard(s string, loc []int) [][]int {
+	var groups [][]int
+	start := loc[0]
+	for i := loc[0]; i <= loc[1]; i++ {
+		if i == loc[1] || s[i] == ' ' || s[i] == '-' {
+			if i > start {
+				groups = append(groups, []int{start, i})
+			}
+			start = i + 1
+		}
+	}
+
+	var found [][]int
+	for first := 0; first < len(groups); first++ {
+		// Find the last group of the longest window of at most 19 digits.
+		last, digits := first, 0
+		for ; last < len(groups); last++ {
+			if digits += countDigits(s, groups[last]); digits > 19 {
+				break
+			}
+		}
+		for last--; last >= first; last-- {
+			window := []int{groups[first][0], groups[last][1]}
+			if validCreditCard(s, window) {
+				found = append(found, window)
+				first = last
+				break
+			}
+		}
+	}
+	return found
+}
+
+func countDigits(s string, loc []int) int {
+	n := 0
+	for i := loc[0]; i < loc[1]; i++ {
+		if c := s[i]; c >= '0' && c <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// validCreditCard checks the length and the Luhn checksum of a card number.
+func validCreditCard(s string, loc []int) bool {
+	var digits []byte
+	for i := loc[0]; i < loc[1]; i++ {
+		if c := s[i]; c >= '0' && c <= '9' {
+			digits = append(digits, c-'0')
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	return luhn(digits)
+}
+
+// luhn reports whether the digits have a valid Luhn checksum.
+func luhn(digits []byte) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i])
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// findIPv6 checks that the match is an IPv6 address that is not part of a
+// longer word, such as the "d::" of "std::vector".
+func findIPv6(s string, loc []int) [][]int {
+	if r, _ := utf8.DecodeLastRuneInString(s[:loc[0]]); loc[0] > 0 && isAddressRune(r) {
+		return nil
+	}
+	if r, _ := utf8.DecodeRuneInString(s[loc[1]:]); loc[1] < len(s) && isAddressRune(r) {
+		return nil
+	}
+
+	addr, _, _ := strings.Cut(s[loc[0]:loc[1]], "%")
+	if ip := net.ParseIP(addr); ip == nil || !strings.Contains(addr, ":") {
+		return nil
+	}
+	return [][]int{loc}
+}
+
+func isAddressRune(r rune) bool {
+	return r == ':' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
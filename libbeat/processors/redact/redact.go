@@ -0,0 +1,234 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor/registry"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const procName = "redact"
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+	jsprocessor.RegisterPlugin("Redact", New)
+}
+
+type redact struct {
+	config
+	rules []rule
+}
+
+// rule is a compiled rule.
+type rule struct {
+	detector
+	action string
+}
+
+// match is a match of a rule in a string.
+type match struct {
+	start, end int
+	action     string
+}
+
+// New constructs a new redact processor.
+func New(cfg *conf.C) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	return newRedact(c)
+}
+
+func newRedact(c config) (*redact, error) {
+	p := &redact{config: c}
+	for i, rc := range c.Rules {
+		r := rule{action: rc.Action}
+		if r.action == "" {
+			r.action = c.Action
+		}
+
+		if rc.Detector != "" {
+			r.detector = detectors[rc.Detector]
+		} else {
+			re, err := regexp.Compile(rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile pattern of rule %d: %w", i, err)
+			}
+			r.detector = detector{re: re}
+		}
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+func (p *redact) Run(event *beat.Event) (*beat.Event, error) {
+	if len(p.Fields) == 0 {
+		p.redactMap(event.Fields)
+		return event, nil
+	}
+
+	for _, field := range p.Fields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			continue
+		}
+
+		v, drop := p.redactValue(v)
+		if drop {
+			err = event.Delete(field)
+		} else {
+			_, err = event.PutValue(field, v)
+		}
+		if err != nil {
+			return event, fmt.Errorf("failed to redact field [%v]: %w", field, err)
+		}
+	}
+	return event, nil
+}
+
+// redactMap redacts all the string values of m, deleting the keys whose
+// value must be dropped.
+func (p *redact) redactMap(m map[string]interface{}) {
+	for k, v := range m {
+		v, drop := p.redactValue(v)
+		if drop {
+			delete(m, k)
+		} else {
+			m[k] = v
+		}
+	}
+}
+
+// redactValue redacts the strings of v, including those nested in objects
+// and arrays. It returns the redacted value and whether it must be dropped.
+// Arrays are dropped as a whole if any of their strings must be dropped.
+func (p *redact) redactValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		return p.redactString(v)
+	case mapstr.M:
+		p.redactMap(v)
+	case map[string]interface{}:
+		p.redactMap(v)
+	case []mapstr.M:
+		for _, m := range v {
+			p.redactMap(m)
+		}
+	case []map[string]interface{}:
+		for _, m := range v {
+			p.redactMap(m)
+		}
+	case []string:
+		for i, s := range v {
+			s, drop := p.redactString(s)
+			if drop {
+				return nil, true
+			}
+			v[i] = s
+		}
+	case []interface{}:
+		for i, elem := range v {
+			elem, drop := p.redactValue(elem)
+			if drop {
+				return nil, true
+			}
+			v[i] = elem
+		}
+	}
+	return v, false
+}
+
+// redactString replaces the matches of the rules in s. The matches of all
+// rules are found in the original string; where matches overlap, the rule
+// listed first wins.
+func (p *redact) redactString(s string) (string, bool) {
+	var matches []match
+	for _, r := range p.rules {
+		for _, m := range r.re.FindAllStringIndex(s, -1) {
+			for _, loc := range r.locate(s, m) {
+				if r.action == actionDrop {
+					return "", true
+				}
+				if !overlaps(matches, loc) {
+					matches = append(matches, match{start: loc[0], end: loc[1], action: r.action})
+				}
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return s, false
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m.start])
+		switch m.action {
+		case actionMask:
+			b.WriteString(p.Mask)
+		case actionHash:
+			b.WriteString(p.hash(s[m.start:m.end]))
+		}
+		last = m.end
+	}
+	b.WriteString(s[last:])
+	return b.String(), false
+}
+
+func overlaps(matches []match, loc []int) bool {
+	for _, m := range matches {
+		if loc[0] < m.end && m.start < loc[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// hash returns the hex encoded HMAC-SHA256 of s.
+func (p *redact) hash(s string) string {
+	mac := hmac.New(sha256.New, []byte(p.HashKey))
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *redact) String() string {
+	rules := make([]string, 0, len(p.Rules))
+	for i, r := range p.Rules {
+		name := r.Detector
+		if name == "" {
+			name = "pattern"
+		}
+		rules = append(rules, name+":"+p.rules[i].action)
+	}
+	return fmt.Sprintf("%v=[fields=%v, rules=[%v]]", procName, p.Fields, strings.Join(rules, ", "))
+}
@@ -50,6 +50,11 @@ func (p *SafeProcessor) Close() (err error) {
 	return nil
 }
 
+// SetEmitter forwards the emit function to the underlying processor.
+func (p *SafeProcessor) SetEmitter(emit EmitFunc) {
+	SetEmitter(p.Processor, emit)
+}
+
 // SafeWrap makes sure that the processor handles all the required edge-cases.
 //
 // Each processor might end up in multiple processor groups.
@@ -0,0 +1,92 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	modeProbabilistic = "probabilistic"
+	modeHash          = "hash"
+	modeReservoir     = "reservoir"
+)
+
+type config struct {
+	// Mode is the sampling method, one of probabilistic, hash or reservoir.
+	Mode string `config:"mode"`
+
+	// Rate is the fraction of events kept in probabilistic and hash mode.
+	Rate float64 `config:"rate"`
+
+	// Fields are hashed in hash mode and identify the reservoir of an event
+	// in reservoir mode.
+	Fields []string `config:"fields"`
+
+	// IgnoreMissing hashes the fields present in the event instead of failing
+	// if a field is missing.
+	IgnoreMissing bool `config:"ignore_missing"`
+
+	// Size is the number of events kept per key and window in reservoir mode.
+	Size int `config:"size" validate:"min=1"`
+
+	// Window is the length of the reservoir window.
+	Window time.Duration `config:"window" validate:"positive,nonzero"`
+
+	// MaxKeys bounds the number of reservoirs per window. Events of new keys
+	// are passed through once the limit is reached.
+	MaxKeys int `config:"max_keys" validate:"min=1"`
+
+	// RateField is the field the sampling rate is written to.
+	RateField string `config:"rate_field"`
+}
+
+func defaultConfig() config {
+	return config{
+		Mode:      modeProbabilistic,
+		Size:      100,
+		Window:    time.Minute,
+		MaxKeys:   10000,
+		RateField: "sample.rate",
+	}
+}
+
+func (c *config) Validate() error {
+	if c.RateField == "" {
+		return fmt.Errorf("rate_field must not be empty")
+	}
+
+	switch c.Mode {
+	case modeProbabilistic:
+	case modeHash:
+		if len(c.Fields) == 0 {
+			return fmt.Errorf("fields are required in %s mode", modeHash)
+		}
+	case modeReservoir:
+		return nil
+	default:
+		return fmt.Errorf("invalid mode '%s', must be %s, %s or %s",
+			c.Mode, modeProbabilistic, modeHash, modeReservoir)
+	}
+
+	if c.Rate <= 0 || c.Rate > 1 {
+		return fmt.Errorf("invalid rate %v, must be in (0, 1]", c.Rate)
+	}
+	return nil
+}
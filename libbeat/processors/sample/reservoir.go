@@ -0,0 +1,203 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/fingerprint"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// reservoir holds a uniform sample of the events of one key.
+type reservoir struct {
+	seen   int64
+	events []*beat.Event
+}
+
+// reservoirSampler keeps a uniform sample of up to size events per key and
+// window. The sampled events are held back and emitted when the window
+// closes, with the fraction of events they represent as rate.
+type reservoirSampler struct {
+	config  config
+	hasher  *fingerprint.Hasher
+	log     *logp.Logger
+	clock   clockwork.Clock
+	randN   func(n int64) int64
+	metrics metrics
+
+	emitted  *monitoring.Int
+	overflow *monitoring.Int
+
+	mu         sync.Mutex
+	emit       processors.EmitFunc
+	start      time.Time // start of the current window
+	reservoirs map[uint64]*reservoir
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newReservoirSampler(
+	config config,
+	hasher *fingerprint.Hasher,
+	log *logp.Logger,
+	reg *monitoring.Registry,
+	m metrics,
+	clock clockwork.Clock,
+) *reservoirSampler {
+	p := &reservoirSampler{
+		config:     config,
+		hasher:     hasher,
+		log:        log,
+		clock:      clock,
+		randN:      rand.Int64N,
+		metrics:    m,
+		emitted:    monitoring.NewInt(reg, "emitted"),
+		overflow:   monitoring.NewInt(reg, "overflow"),
+		start:      clock.Now().Truncate(config.Window),
+		reservoirs: map[uint64]*reservoir{},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+// Run adds the event to the reservoir of its key. The event is dropped
+// unless the maximum number of keys has been reached, in which case it is
+// returned with a rate of 1.
+func (p *reservoirSampler) Run(event *beat.Event) (*beat.Event, error) {
+	key, err := hashKey(p.hasher, event)
+	if err != nil {
+		return event, fmt.Errorf("failed to compute reservoir key: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r, found := p.reservoirs[key]
+	if !found {
+		if len(p.reservoirs) >= p.config.MaxKeys {
+			p.overflow.Inc()
+			return addRate(event, p.config.RateField, 1)
+		}
+		r = &reservoir{}
+		p.reservoirs[key] = r
+	}
+
+	// The original event is reported as filtered, so the copy being held
+	// back must not be acknowledged again once it is emitted.
+	held := *event
+	held.Private = nil
+
+	r.seen++
+	if len(r.events) < p.config.Size {
+		r.events = append(r.events, &held)
+	} else if i := p.randN(r.seen); i < int64(p.config.Size) {
+		r.events[i] = &held
+	}
+	return nil, nil
+}
+
+// SetEmitter implements processors.Emitter.
+func (p *reservoirSampler) SetEmitter(emit processors.EmitFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.emit = emit
+}
+
+// loop closes the window whenever its end is reached.
+func (p *reservoirSampler) loop() {
+	defer close(p.done)
+
+	for {
+		p.mu.Lock()
+		end := p.start.Add(p.config.Window)
+		p.mu.Unlock()
+
+		select {
+		case <-p.stop:
+			return
+		case <-p.clock.After(end.Sub(p.clock.Now())):
+			p.flush()
+		}
+	}
+}
+
+// flush emits the sampled events of the current window and starts a new one.
+func (p *reservoirSampler) flush() {
+	p.mu.Lock()
+	reservoirs := p.reservoirs
+	p.reservoirs = map[uint64]*reservoir{}
+	p.start = p.clock.Now().Truncate(p.config.Window)
+	emit := p.emit
+	p.mu.Unlock()
+
+	var events []*beat.Event
+	for _, r := range reservoirs {
+		p.metrics.dropped.Add(r.seen - int64(len(r.events)))
+
+		rate := float64(len(r.events)) / float64(r.seen)
+		for _, event := range r.events {
+			if _, err := addRate(event, p.config.RateField, rate); err != nil {
+				p.log.Debugf("Failed to add sampling rate: %v", err)
+			}
+			events = append(events, event)
+		}
+	}
+
+	if len(events) == 0 {
+		return
+	}
+	if emit == nil {
+		p.log.Warnf("Dropping %d sampled events: the processor cannot emit events, "+
+			"it must be configured on an input or module instead of globally", len(events))
+		p.metrics.dropped.Add(int64(len(events)))
+		return
+	}
+
+	// Emit without holding the lock: the pipeline client might be running
+	// this processor concurrently.
+	for _, event := range events {
+		emit(event)
+		p.emitted.Inc()
+	}
+}
+
+// Close stops the window timer and emits the events sampled in the current,
+// partial window.
+func (p *reservoirSampler) Close() error {
+	close(p.stop)
+	<-p.done
+	p.flush()
+	return nil
+}
+
+func (p *reservoirSampler) String() string {
+	return fmt.Sprintf("%v=[mode=%v, size=%v, window=%v, fields=%v]",
+		processorName, p.config.Mode, p.config.Size, p.config.Window, p.config.Fields)
+}
@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/jonboulle/clockwork"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/fingerprint"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID atomic.Uint32
+
+const (
+	processorName = "sample"
+	logName       = "processor." + processorName
+
+	// hashMethod is used to compute keys. It is fast and stable across
+	// hosts, so hash sampling makes the same decisions everywhere.
+	hashMethod = "xxhash"
+)
+
+func init() {
+	processors.RegisterPlugin(processorName, New)
+}
+
+type metrics struct {
+	dropped *monitoring.Int
+}
+
+// New constructs a new sample processor.
+func New(cfg *conf.C) (beat.Processor, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("failed to unpack %v processor configuration: %w", processorName, err)
+	}
+
+	// Logging and metrics (each processor instance has a unique ID).
+	var (
+		id  = int(instanceID.Add(1))
+		log = logp.NewLogger(logName).With("instance_id", id)
+		reg = monitoring.Default.NewRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+		m   = metrics{dropped: monitoring.NewInt(reg, "dropped")}
+	)
+
+	switch config.Mode {
+	case modeHash:
+		hasher, err := fingerprint.NewHasher(hashMethod, config.Fields, config.IgnoreMissing)
+		if err != nil {
+			return nil, err
+		}
+		return &hashSampler{config: config, hasher: hasher, metrics: m}, nil
+	case modeReservoir:
+		var hasher *fingerprint.Hasher
+		if len(config.Fields) > 0 {
+			var err error
+			if hasher, err = fingerprint.NewHasher(hashMethod, config.Fields, true); err != nil {
+				return nil, err
+			}
+		}
+		return newReservoirSampler(config, hasher, log, reg, m, clockwork.NewRealClock()), nil
+	default:
+		return &probabilisticSampler{config: config, random: rand.Float64, metrics: m}, nil
+	}
+}
+
+// probabilisticSampler keeps each event with the configured probability.
+type probabilisticSampler struct {
+	config  config
+	random  func() float64
+	metrics metrics
+}
+
+func (p *probabilisticSampler) Run(event *beat.Event) (*beat.Event, error) {
+	if p.random() >= p.config.Rate {
+		p.metrics.dropped.Inc()
+		return nil, nil
+	}
+	return addRate(event, p.config.RateField, p.config.Rate)
+}
+
+func (p *probabilisticSampler) String() string {
+	return fmt.Sprintf("%v=[mode=%v, rate=%v]", processorName, p.config.Mode, p.config.Rate)
+}
+
+// hashSampler keeps an event if the hash of its fields falls into the
+// configured fraction of the hash space, so events sharing the values of the
+// fields are either all kept or all dropped.
+type hashSampler struct {
+	config  config
+	hasher  *fingerprint.Hasher
+	metrics metrics
+}
+
+func (p *hashSampler) Run(event *beat.Event) (*beat.Event, error) {
+	key, err := hashKey(p.hasher, event)
+	if err != nil {
+		return event, fmt.Errorf("failed to compute sampling hash: %w", err)
+	}
+
+	// Map the 53 most significant bits of the hash to [0, 1), like a random
+	// float64 would be.
+	if float64(key>>11)/(1<<53) >= p.config.Rate {
+		p.metrics.dropped.Inc()
+		return nil, nil
+	}
+	return addRate(event, p.config.RateField, p.config.Rate)
+}
+
+func (p *hashSampler) String() string {
+	return fmt.Sprintf("%v=[mode=%v, rate=%v, fields=%v]",
+		processorName, p.config.Mode, p.config.Rate, p.config.Fields)
+}
+
+// hashKey returns the hash of the event fields as an integer. A nil hasher
+// puts all events under the same key.
+func hashKey(hasher *fingerprint.Hasher, event *beat.Event) (uint64, error) {
+	if hasher == nil {
+		return 0, nil
+	}
+	sum, err := hasher.Sum(event)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(sum), nil
+}
+
+func addRate(event *beat.Event, field string, rate float64) (*beat.Event, error) {
+	if _, err := event.PutValue(field, rate); err != nil {
+		return event, fmt.Errorf("failed to set field '%s': %w", field, err)
+	}
+	return event, nil
+}
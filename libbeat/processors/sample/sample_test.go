@@ -0,0 +1,203 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors/fingerprint"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+func newTestProcessor(t *testing.T, settings map[string]interface{}) beat.Processor {
+	t.Helper()
+	p, err := New(conf.MustNewConfigFrom(settings))
+	require.NoError(t, err)
+	return p
+}
+
+func TestProbabilistic(t *testing.T) {
+	p := newTestProcessor(t, map[string]interface{}{"rate": 0.25}).(*probabilisticSampler)
+	randoms := []float64{0.1, 0.25, 0.9, 0.2}
+	p.random = func() float64 {
+		r := randoms[0]
+		randoms = randoms[1:]
+		return r
+	}
+
+	var kept []*beat.Event
+	for i := 0; i < 4; i++ {
+		out, err := p.Run(&beat.Event{Fields: mapstr.M{"n": i}})
+		require.NoError(t, err)
+		if out != nil {
+			kept = append(kept, out)
+		}
+	}
+
+	assert.Equal(t, []*beat.Event{
+		{Fields: mapstr.M{"n": 0, "sample": mapstr.M{"rate": 0.25}}},
+		{Fields: mapstr.M{"n": 3, "sample": mapstr.M{"rate": 0.25}}},
+	}, kept)
+	assert.Equal(t, int64(2), p.metrics.dropped.Get())
+}
+
+func TestHash(t *testing.T) {
+	p := newTestProcessor(t, map[string]interface{}{
+		"mode":   "hash",
+		"rate":   0.5,
+		"fields": []string{"trace.id"},
+	})
+
+	kept := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("trace-%d", i%100)
+		out, err := p.Run(&beat.Event{Fields: mapstr.M{"trace": mapstr.M{"id": id}}})
+		require.NoError(t, err)
+
+		if k, seen := kept[id]; seen {
+			require.Equal(t, k, out != nil, "inconsistent decision for %s", id)
+		}
+		kept[id] = out != nil
+	}
+
+	var n int
+	for _, k := range kept {
+		if k {
+			n++
+		}
+	}
+	assert.InDelta(t, 50, n, 20)
+
+	t.Run("missing field", func(t *testing.T) {
+		event := &beat.Event{Fields: mapstr.M{"message": "hello"}}
+		out, err := p.Run(event)
+		assert.Error(t, err)
+		assert.Equal(t, event, out)
+	})
+}
+
+func TestReservoir(t *testing.T) {
+	config := defaultConfig()
+	require.NoError(t, conf.MustNewConfigFrom(map[string]interface{}{
+		"mode":   "reservoir",
+		"size":   2,
+		"window": "10s",
+		"fields": []string{"service"},
+	}).Unpack(&config))
+
+	hasher, err := fingerprint.NewHasher(hashMethod, config.Fields, true)
+	require.NoError(t, err)
+
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	reg := monitoring.NewRegistry()
+	p := newReservoirSampler(config, hasher, logptest.NewTestingLogger(t, ""), reg,
+		metrics{dropped: monitoring.NewInt(reg, "dropped")}, clock)
+	// Always replace the first sampled event.
+	p.randN = func(int64) int64 { return 0 }
+
+	emitted := make(chan *beat.Event, 10)
+	p.SetEmitter(func(event *beat.Event) { emitted <- event })
+
+	for i := 0; i < 4; i++ {
+		out, err := p.Run(&beat.Event{
+			Fields:  mapstr.M{"service": "web", "n": i},
+			Private: i,
+		})
+		require.NoError(t, err)
+		assert.Nil(t, out)
+	}
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"service": "db", "n": 4}})
+	require.NoError(t, err)
+
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Second)
+
+	got := map[interface{}]*beat.Event{}
+	for i := 0; i < 3; i++ {
+		select {
+		case event := <-emitted:
+			n, _ := event.GetValue("n")
+			got[n] = event
+		case <-time.After(5 * time.Second):
+			t.Fatal("window was not flushed")
+		}
+	}
+
+	require.Contains(t, got, 3)
+	require.Contains(t, got, 1)
+	require.Contains(t, got, 4)
+	assert.Nil(t, got[3].Private)
+	rate, _ := got[3].GetValue("sample.rate")
+	assert.Equal(t, 0.5, rate)
+	rate, _ = got[4].GetValue("sample.rate")
+	assert.Equal(t, 1.0, rate)
+	assert.Equal(t, int64(2), p.metrics.dropped.Get())
+
+	require.NoError(t, p.Close())
+	assert.Empty(t, emitted)
+}
+
+func TestReservoirMaxKeys(t *testing.T) {
+	p := newTestProcessor(t, map[string]interface{}{
+		"mode":     "reservoir",
+		"fields":   []string{"id"},
+		"max_keys": 1,
+	}).(*reservoirSampler)
+	emitted := make(chan *beat.Event, 10)
+	p.SetEmitter(func(event *beat.Event) { emitted <- event })
+
+	out, err := p.Run(&beat.Event{Fields: mapstr.M{"id": 1}})
+	require.NoError(t, err)
+	assert.Nil(t, out)
+
+	out, err = p.Run(&beat.Event{Fields: mapstr.M{"id": 2}})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"id": 2, "sample": mapstr.M{"rate": 1.0}}, out.Fields)
+
+	// Pending events are emitted on close.
+	require.NoError(t, p.Close())
+	require.Len(t, emitted, 1)
+	assert.Equal(t, mapstr.M{"id": 1, "sample": mapstr.M{"rate": 1.0}}, (<-emitted).Fields)
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"invalid mode":        {"mode": "random", "rate": 0.5},
+		"missing rate":        {},
+		"rate too high":       {"rate": 1.5},
+		"hash without fields": {"mode": "hash", "rate": 0.5},
+		"empty rate field":    {"rate": 0.5, "rate_field": ""},
+		"zero size":           {"mode": "reservoir", "size": 0},
+	}
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(settings))
+			assert.Error(t, err)
+		})
+	}
+}
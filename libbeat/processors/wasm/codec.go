@@ -0,0 +1,136 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/go-structform"
+	"github.com/elastic/go-structform/cborl"
+	"github.com/elastic/go-structform/gotype"
+	"github.com/elastic/go-structform/json"
+)
+
+// message is the representation of an event exchanged with the module.
+// Keys missing from the message returned by the module keep their value.
+type message struct {
+	Timestamp string   `struct:"timestamp,omitempty"`
+	Metadata  mapstr.M `struct:"metadata,omitempty"`
+	Fields    mapstr.M `struct:"fields,omitempty"`
+}
+
+// eventCodec encodes and decodes messages. It is not safe for concurrent use,
+// each module instance has its own.
+type eventCodec struct {
+	encoding encoding
+
+	buf    bytes.Buffer
+	folder *gotype.Iterator
+
+	unfolder   *gotype.Unfolder
+	jsonParser *json.Parser
+	cborParser *cborl.Parser
+}
+
+func newEventCodec(enc encoding) *eventCodec {
+	c := &eventCodec{encoding: enc}
+	c.resetEncoder()
+	c.resetDecoder()
+	return c
+}
+
+func (c *eventCodec) resetEncoder() {
+	var visitor structform.Visitor
+	switch c.encoding {
+	case encodingCBOR:
+		visitor = cborl.NewVisitor(&c.buf)
+	default:
+		visitor = json.NewVisitor(&c.buf)
+	}
+	// NewIterator does not fail with these fixed options.
+	c.folder, _ = gotype.NewIterator(visitor,
+		gotype.Folders(
+			codec.MakeTimestampEncoder(),
+			codec.MakeBCTimestampEncoder(),
+		),
+	)
+}
+
+func (c *eventCodec) resetDecoder() {
+	// NewUnfolder does not fail when called on nil.
+	c.unfolder, _ = gotype.NewUnfolder(nil)
+	c.jsonParser = json.NewParser(c.unfolder)
+	c.cborParser = cborl.NewParser(c.unfolder)
+}
+
+// encode returns the encoded event. The result is only valid until the next
+// call.
+func (c *eventCodec) encode(event *beat.Event) ([]byte, error) {
+	c.buf.Reset()
+	err := c.folder.Fold(message{
+		Timestamp: event.Timestamp.UTC().Format(time.RFC3339Nano),
+		Metadata:  event.Meta,
+		Fields:    event.Fields,
+	})
+	if err != nil {
+		c.resetEncoder()
+		return nil, err
+	}
+	return c.buf.Bytes(), nil
+}
+
+// decode updates event from the encoded message.
+func (c *eventCodec) decode(data []byte, event *beat.Event) error {
+	var msg message
+	if err := c.unfolder.SetTarget(&msg); err != nil {
+		return err
+	}
+	defer c.unfolder.Reset()
+
+	var err error
+	switch c.encoding {
+	case encodingCBOR:
+		err = c.cborParser.Parse(data)
+	default:
+		err = c.jsonParser.Parse(data)
+	}
+	if err != nil {
+		c.resetDecoder()
+		return fmt.Errorf("failed to decode the event returned by the module: %w", err)
+	}
+
+	if msg.Timestamp != "" {
+		ts, err := time.Parse(time.RFC3339Nano, msg.Timestamp)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp returned by the module: %w", err)
+		}
+		event.Timestamp = ts
+	}
+	if msg.Metadata != nil {
+		event.Meta = msg.Metadata
+	}
+	if msg.Fields != nil {
+		event.Fields = msg.Fields
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package wasm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// encoding is the format events are passed to and from the module in.
+type encoding uint8
+
+const (
+	encodingJSON encoding = iota
+	encodingCBOR
+)
+
+var encodingNames = map[encoding]string{
+	encodingJSON: "json",
+	encodingCBOR: "cbor",
+}
+
+func (e encoding) String() string {
+	return encodingNames[e]
+}
+
+func (e *encoding) Unpack(s string) error {
+	s = strings.ToLower(s)
+	for enc, name := range encodingNames {
+		if s == name {
+			*e = enc
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid encoding: %v", s)
+}
+
+type config struct {
+	// File is the path to the WebAssembly module.
+	File string `config:"file" validate:"required"`
+
+	// Encoding is the format events are passed in, json or cbor.
+	Encoding encoding `config:"encoding"`
+
+	// Timeout bounds the duration of each call into the module. Zero
+	// disables the limit.
+	Timeout time.Duration `config:"timeout" validate:"min=0"`
+
+	// MaxFunctionCalls bounds the number of function calls made by the
+	// module while processing a single event. Zero disables the limit. It
+	// does not bound loops, only Timeout bounds the CPU time of a call.
+	MaxFunctionCalls uint64 `config:"max_function_calls"`
+
+	// MaxMemoryPages bounds the memory of each module instance, in pages of
+	// 64KiB. Zero uses the limit of the runtime.
+	MaxMemoryPages uint32 `config:"max_memory_pages" validate:"max=65536"`
+
+	// TagOnError is added to events the module failed to process.
+	TagOnError string `config:"tag_on_error"`
+
+	// MaxCachedInstances is the number of module instances kept for reuse.
+	MaxCachedInstances int `config:"max_cached_instances" validate:"min=0"`
+
+	// OnlyCachedInstances blocks until a cached instance is available
+	// instead of creating new instances when all of them are busy.
+	OnlyCachedInstances bool `config:"only_cached_instances"`
+}
+
+func defaultConfig() config {
+	return config{
+		Encoding:           encodingJSON,
+		Timeout:            time.Second,
+		TagOnError:         "_wasm_error",
+		MaxCachedInstances: 4,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.OnlyCachedInstances && c.MaxCachedInstances == 0 {
+		return fmt.Errorf("max_cached_instances must be greater than 0 when only_cached_instances is set")
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// Functions exported by the module.
+const (
+	allocFunction   = "alloc"
+	deallocFunction = "dealloc"
+	processFunction = "process"
+)
+
+// errTooManyCalls aborts a call that made too many function calls.
+var errTooManyCalls = errors.New("module exceeded its function call limit")
+
+// callState is the state of a call into a module. It is passed to the host
+// functions and the call listener through the context.
+type callState struct {
+	calls   uint64 // Remaining function calls. Unlimited if metered is false.
+	metered bool
+	err     string // Error reported by the module.
+	log     *logp.Logger
+}
+
+type callStateKey struct{}
+
+func getCallState(ctx context.Context) *callState {
+	s, _ := ctx.Value(callStateKey{}).(*callState)
+	return s
+}
+
+// callListener counts the function calls and aborts the call once the limit
+// is exceeded. Loops are not counted, only the timeout bounds them.
+var callListener = experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener {
+	return experimental.FunctionListenerFunc(func(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+		s := getCallState(ctx)
+		if s == nil || !s.metered {
+			return
+		}
+		if s.calls == 0 {
+			panic(errTooManyCalls)
+		}
+		s.calls--
+	})
+})
+
+// instance is an instance of the module. It processes one event at a time.
+type instance struct {
+	module  api.Module
+	memory  api.Memory
+	alloc   api.Function
+	dealloc api.Function // Optional.
+	process api.Function
+	codec   *eventCodec
+}
+
+func newInstance(ctx context.Context, rt wazero.Runtime, compiled wazero.CompiledModule, enc encoding) (*instance, error) {
+	// Instances are anonymous so that any number of them can coexist.
+	mod, err := rt.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithSysWalltime().
+		WithSysNanotime())
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate module: %w", err)
+	}
+
+	inst := &instance{
+		module:  mod,
+		memory:  mod.ExportedMemory("memory"),
+		alloc:   mod.ExportedFunction(allocFunction),
+		dealloc: mod.ExportedFunction(deallocFunction),
+		process: mod.ExportedFunction(processFunction),
+		codec:   newEventCodec(enc),
+	}
+	switch {
+	case inst.memory == nil:
+		err = errors.New("module does not export memory")
+	case inst.alloc == nil:
+		err = fmt.Errorf("module does not export %s", allocFunction)
+	case inst.process == nil:
+		err = fmt.Errorf("module does not export %s", processFunction)
+	}
+	if err != nil {
+		_ = mod.Close(ctx)
+		return nil, err
+	}
+	return inst, nil
+}
+
+// run passes the event to the module. It returns nil if the module dropped
+// the event. An instance must not be used again after run failed with a
+// fatal error.
+func (inst *instance) run(ctx context.Context, event *beat.Event) (out *beat.Event, fatal bool, err error) {
+	data, err := inst.codec.encode(event)
+	if err != nil {
+		return event, false, fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	res, err := inst.alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return event, true, fmt.Errorf("failed to allocate memory: %w", err)
+	}
+	inPtr := uint32(res[0])
+	if !inst.memory.Write(inPtr, data) {
+		return event, true, fmt.Errorf("module allocated out of range memory %d+%d", inPtr, len(data))
+	}
+
+	res, err = inst.process.Call(ctx, uint64(inPtr), uint64(len(data)))
+	if err != nil {
+		return event, true, err
+	}
+	if err = inst.free(ctx, inPtr, uint32(len(data))); err != nil {
+		return event, true, err
+	}
+	if s := getCallState(ctx); s != nil && s.err != "" {
+		return event, false, errors.New(s.err)
+	}
+
+	// The result packs the pointer to the output in the upper 32 bits and
+	// its length in the lower 32 bits. Zero drops the event.
+	if res[0] == 0 {
+		return nil, false, nil
+	}
+	outPtr, outLen := uint32(res[0]>>32), uint32(res[0])
+	output, ok := inst.memory.Read(outPtr, outLen)
+	if !ok {
+		return event, true, fmt.Errorf("module returned out of range memory %d+%d", outPtr, outLen)
+	}
+	// The output is decoded into copies, so the memory can be freed
+	// afterwards.
+	err = inst.codec.decode(output, event)
+	if ferr := inst.free(ctx, outPtr, outLen); ferr != nil {
+		return event, true, ferr
+	}
+	return event, false, err
+}
+
+// free releases memory allocated by the module if it exports a dealloc
+// function.
+func (inst *instance) free(ctx context.Context, ptr, size uint32) error {
+	if inst.dealloc == nil || size == 0 {
+		return nil
+	}
+	if _, err := inst.dealloc.Call(ctx, uint64(ptr), uint64(size)); err != nil {
+		return fmt.Errorf("failed to free memory: %w", err)
+	}
+	return nil
+}
+
+func (inst *instance) close(ctx context.Context) {
+	_ = inst.module.Close(ctx)
+}
+
+// instancePool holds instances of the module for reuse. When new instances
+// are not allowed, the pool holds a nil instance in place of each instance
+// that could not be replaced, and Get tries to create it again.
+type instancePool struct {
+	New                 func() (*instance, error)
+	C                   chan *instance
+	NewInstancesAllowed bool
+}
+
+func newInstancePool(first *instance, newInstance func() (*instance, error), c config) (*instancePool, error) {
+	pool := instancePool{
+		New:                 newInstance,
+		C:                   make(chan *instance, c.MaxCachedInstances),
+		NewInstancesAllowed: !c.OnlyCachedInstances,
+	}
+	pool.Put(first)
+
+	// If we are not allowed to create new instances, pre-cache requested instances.
+	if !pool.NewInstancesAllowed {
+		for i := 0; i < c.MaxCachedInstances-1; i++ {
+			inst, err := pool.New()
+			if err != nil {
+				return nil, err
+			}
+			pool.Put(inst)
+		}
+	}
+	return &pool, nil
+}
+
+func (p *instancePool) Get() (*instance, error) {
+	if !p.NewInstancesAllowed {
+		inst := <-p.C
+		if inst != nil {
+			return inst, nil
+		}
+		inst, err := p.New()
+		if err != nil {
+			p.C <- nil
+			return nil, err
+		}
+		return inst, nil
+	}
+
+	// Try to get an instance from the pool, if none is available, create a new one.
+	select {
+	case inst := <-p.C:
+		return inst, nil
+	default:
+		return p.New()
+	}
+}
+
+// Put returns an instance to the pool. It is closed if the pool is full.
+func (p *instancePool) Put(inst *instance) {
+	select {
+	case p.C <- inst:
+	default:
+		inst.close(context.Background())
+	}
+}
+
+// Replace discards a broken instance. The pool is refilled if it is the only
+// source of instances. If the new instance cannot be created, it is created
+// by a later Get instead, so that the pool does not shrink.
+func (p *instancePool) Replace(inst *instance) error {
+	inst.close(context.Background())
+	if p.NewInstancesAllowed {
+		return nil
+	}
+	inst, err := p.New()
+	if err != nil {
+		p.C <- nil
+		return err
+	}
+	p.Put(inst)
+	return nil
+}
@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package wasm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dataOffset is the address of the data passed to buildModule.
+const dataOffset = 16
+
+// Instructions used by the test modules.
+var (
+	// echoBody returns the input of process.
+	echoBody = []byte{
+		0x20, 0x00, // local.get 0
+		0xad,       // i64.extend_i32_u
+		0x42, 0x20, // i64.const 32
+		0x86,       // i64.shl
+		0x20, 0x01, // local.get 1
+		0xad, // i64.extend_i32_u
+		0x84, // i64.or
+	}
+
+	// dropBody returns zero.
+	dropBody = []byte{0x42, 0x00} // i64.const 0
+
+	// loopBody never returns.
+	loopBody = []byte{
+		0x03, 0x40, // loop
+		0x0c, 0x00, // br 0
+		0x0b,       // end
+		0x42, 0x00, // i64.const 0
+	}
+
+	// callLoopBody calls an empty function forever.
+	callLoopBody = []byte{
+		0x03, 0x40, // loop
+		0x10, 0x03, // call 3
+		0x0c, 0x00, // br 0
+		0x0b,       // end
+		0x42, 0x00, // i64.const 0
+	}
+)
+
+// dataBody returns the data of the module.
+func dataBody(data string) []byte {
+	body := []byte{0x42} // i64.const
+	return appendSLEB(body, dataOffset<<32|int64(len(data)))
+}
+
+// errorBody reports the data of the module as error.
+func errorBody(data string) []byte {
+	body := []byte{0x41, dataOffset, 0x41} // i32.const 16, i32.const
+	body = appendSLEB(body, int64(len(data)))
+	return append(body,
+		0x10, 0x00, // call 0
+		0x42, 0x00, // i64.const 0
+	)
+}
+
+// buildModule returns a module with a bump allocator and the given process
+// function. data is written to memory at dataOffset. Functions:
+//
+//	0: beats.set_error(ptr, len i32) (import)
+//	1: alloc(size i32) i32
+//	2: process(ptr, len i32) i64
+//	3: an empty function
+func buildModule(process []byte, data string) []byte {
+	const (
+		i32 = 0x7f
+		i64 = 0x7e
+	)
+	funcType := func(params, results []byte) []byte {
+		b := append([]byte{0x60}, vector(params)...)
+		return append(b, vector(results)...)
+	}
+	name := func(s string) []byte { return vector([]byte(s)) }
+	code := func(body []byte) []byte {
+		b := append([]byte{0x00}, body...) // No locals.
+		return vector(append(b, 0x0b))
+	}
+	alloc := []byte{
+		0x23, 0x00, // global.get 0
+		0x23, 0x00, // global.get 0
+		0x20, 0x00, // local.get 0
+		0x6a,       // i32.add
+		0x24, 0x00, // global.set 0
+	}
+
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	module = appendSection(module, 1, 4,
+		funcType([]byte{i32}, []byte{i32}),
+		funcType([]byte{i32, i32}, []byte{i64}),
+		funcType([]byte{i32, i32}, nil),
+		funcType(nil, nil))
+	module = appendSection(module, 2, 1,
+		concat(name(hostModule), name("set_error"), []byte{0x00, 0x02}))
+	module = appendSection(module, 3, 3, []byte{0x00}, []byte{0x01}, []byte{0x03})
+	module = appendSection(module, 5, 1, []byte{0x00, 0x01})
+	module = appendSection(module, 6, 1, []byte{i32, 0x01, 0x41, 0x80, 0x08, 0x0b}) // 1024
+	module = appendSection(module, 7, 3,
+		concat(name("memory"), []byte{0x02, 0x00}),
+		concat(name(allocFunction), []byte{0x00, 0x01}),
+		concat(name(processFunction), []byte{0x00, 0x02}))
+	module = appendSection(module, 10, 3, code(alloc), code(process), code(nil))
+	module = appendSection(module, 11, 1,
+		concat([]byte{0x00, 0x41, dataOffset, 0x0b}, vector([]byte(data))))
+	return module
+}
+
+func appendSection(module []byte, id byte, n int, entries ...[]byte) []byte {
+	content := appendULEB(nil, uint64(n))
+	for _, e := range entries {
+		content = append(content, e...)
+	}
+	return append(append(module, id), vector(content)...)
+}
+
+// vector prefixes b with its length.
+func vector(b []byte) []byte {
+	return append(appendULEB(nil, uint64(len(b))), b...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func appendULEB(b []byte, v uint64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func appendSLEB(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// writeModule writes a module to a temporary file and returns its path.
+func writeModule(t testing.TB, process []byte, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "module.wasm")
+	require.NoError(t, os.WriteFile(path, buildModule(process, data), 0o644))
+	return path
+}
@@ -0,0 +1,252 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	procName = "wasm"
+	logName  = "processor." + procName
+
+	// hostModule is the name of the module providing the host functions.
+	hostModule = "beats"
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID atomic.Uint32
+
+func init() {
+	// We cannot use this as a JS plugin as it is stateful and includes a Close method.
+	processors.RegisterPlugin(procName, New)
+}
+
+type processor struct {
+	config  config
+	log     *logp.Logger
+	runtime wazero.Runtime
+	pool    *instancePool
+
+	processed *monitoring.Int // Number of events processed.
+	dropped   *monitoring.Int // Number of events dropped by the module.
+	failed    *monitoring.Int // Number of events the module failed to process.
+	discarded *monitoring.Int // Number of instances discarded after a fatal error.
+}
+
+// New constructs a new wasm processor.
+func New(cfg *conf.C) (beat.Processor, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	path := paths.Resolve(paths.Config, config.File)
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module: %w", err)
+	}
+
+	// Logging and metrics (each processor instance has a unique ID).
+	var (
+		id      = int(instanceID.Add(1))
+		log     = logp.NewLogger(logName).With("instance_id", id)
+		metrics = monitoring.Default.NewRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+
+	p := &processor{
+		config:    config,
+		log:       log,
+		processed: monitoring.NewInt(metrics, "processed"),
+		dropped:   monitoring.NewInt(metrics, "dropped"),
+		failed:    monitoring.NewInt(metrics, "failed"),
+		discarded: monitoring.NewInt(metrics, "discarded"),
+	}
+	if err := p.init(code); err != nil {
+		if p.runtime != nil {
+			_ = p.runtime.Close(context.Background())
+		}
+		return nil, fmt.Errorf("failed to load module %s: %w", path, err)
+	}
+	return p, nil
+}
+
+func (p *processor) init(code []byte) error {
+	ctx := context.Background()
+
+	rtConfig := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if p.config.MaxMemoryPages > 0 {
+		rtConfig = rtConfig.WithMemoryLimitPages(p.config.MaxMemoryPages)
+	}
+	p.runtime = wazero.NewRuntimeWithConfig(ctx, rtConfig)
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, p.runtime); err != nil {
+		return err
+	}
+	_, err := p.runtime.NewHostModuleBuilder(hostModule).
+		NewFunctionBuilder().WithFunc(hostSetError).Export("set_error").
+		NewFunctionBuilder().WithFunc(hostLog).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Function calls are only metered when needed as it slows down the
+	// module.
+	compileCtx := ctx
+	if p.config.MaxFunctionCalls > 0 {
+		compileCtx = experimental.WithFunctionListenerFactory(ctx, callListener)
+	}
+	compiled, err := p.runtime.CompileModule(compileCtx, code)
+	if err != nil {
+		return err
+	}
+
+	newInst := func() (*instance, error) {
+		return newInstance(ctx, p.runtime, compiled, p.config.Encoding)
+	}
+	first, err := newInst()
+	if err != nil {
+		return err
+	}
+	p.pool, err = newInstancePool(first, newInst, p.config)
+	return err
+}
+
+// hostSetError is called by the module to report that it failed to process
+// the event.
+func hostSetError(ctx context.Context, mod api.Module, ptr, size uint32) {
+	s := getCallState(ctx)
+	if s == nil {
+		return
+	}
+	msg, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		s.err = "module reported an error out of range of its memory"
+		return
+	}
+	s.err = string(msg)
+}
+
+// hostLog is called by the module to write to the log of the processor.
+func hostLog(ctx context.Context, mod api.Module, level, ptr, size uint32) {
+	s := getCallState(ctx)
+	if s == nil {
+		return
+	}
+	msg, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		return
+	}
+	switch level {
+	case 0:
+		s.log.Debug(string(msg))
+	case 1:
+		s.log.Info(string(msg))
+	case 2:
+		s.log.Warn(string(msg))
+	default:
+		s.log.Error(string(msg))
+	}
+}
+
+// Run passes the event to the module and returns the event it returned, or
+// nil if the module dropped the event.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	p.processed.Inc()
+
+	inst, err := p.pool.Get()
+	if err != nil {
+		return event, p.fail(event, fmt.Errorf("failed to instantiate module: %w", err))
+	}
+
+	state := &callState{log: p.log}
+	if p.config.MaxFunctionCalls > 0 {
+		state.calls = p.config.MaxFunctionCalls
+		state.metered = true
+	}
+	ctx := context.WithValue(context.Background(), callStateKey{}, state)
+	if p.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.Timeout)
+		defer cancel()
+	}
+
+	// The event is only modified once the output of the module is decoded,
+	// so it is returned unchanged on errors.
+	out, fatal, err := inst.run(ctx, event)
+	if fatal {
+		// The state of the instance is unknown after a trap, or it was
+		// closed due to a timeout.
+		p.discarded.Inc()
+		if rerr := p.pool.Replace(inst); rerr != nil {
+			p.log.Errorf("Failed to replace module instance: %v", rerr)
+		}
+	} else {
+		p.pool.Put(inst)
+	}
+
+	if err != nil {
+		if errors.Is(err, errTooManyCalls) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("module exceeded its limits: %w", err)
+		}
+		return event, p.fail(event, err)
+	}
+	if out == nil {
+		p.dropped.Inc()
+	}
+	return out, nil
+}
+
+// fail marks an event the module failed to process.
+func (p *processor) fail(event *beat.Event, err error) error {
+	p.failed.Inc()
+	if p.config.TagOnError != "" {
+		_ = mapstr.AddTags(event.Fields, []string{p.config.TagOnError})
+	}
+	_, _ = event.PutValue("error.message", err.Error())
+	return fmt.Errorf("failed in %s processor: %w", procName, err)
+}
+
+// Close releases the module instances.
+func (p *processor) Close() error {
+	return p.runtime.Close(context.Background())
+}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("%v=[file=%v, encoding=%v, timeout=%v, max_function_calls=%v]",
+		procName, p.config.File, p.config.Encoding, p.config.Timeout, p.config.MaxFunctionCalls)
+}
@@ -0,0 +1,249 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package wasm
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func newTestProcessor(t testing.TB, path string, settings map[string]interface{}) *processor {
+	t.Helper()
+	c := map[string]interface{}{"file": path}
+	for k, v := range settings {
+		c[k] = v
+	}
+	p, err := New(conf.MustNewConfigFrom(c))
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, p.(*processor).Close()) })
+	return p.(*processor)
+}
+
+func testEvent() *beat.Event {
+	return &beat.Event{
+		Timestamp: time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC),
+		Meta:      mapstr.M{"_id": "abc"},
+		Fields: mapstr.M{
+			"message": "hello",
+			"count":   int64(3),
+			"ratio":   0.5,
+			"ok":      true,
+			"list":    []interface{}{"a", "b"},
+			"nested":  mapstr.M{"key": "value"},
+		},
+	}
+}
+
+func TestEcho(t *testing.T) {
+	path := writeModule(t, echoBody, "")
+	for _, enc := range []string{"json", "cbor"} {
+		t.Run(enc, func(t *testing.T) {
+			p := newTestProcessor(t, path, map[string]interface{}{"encoding": enc})
+
+			out, err := p.Run(testEvent())
+			require.NoError(t, err)
+			require.NotNil(t, out)
+
+			want := testEvent()
+			assert.Equal(t, want.Timestamp, out.Timestamp)
+			// Decoded numbers and objects may use other types.
+			assert.Equal(t, want.Meta.String(), out.Meta.String())
+			assert.Equal(t, want.Fields.String(), out.Fields.String())
+		})
+	}
+}
+
+func TestModifyEvent(t *testing.T) {
+	output := `{"timestamp":"2020-01-02T03:04:05Z","fields":{"message":"replaced"}}`
+	path := writeModule(t, dataBody(output), output)
+	p := newTestProcessor(t, path, nil)
+
+	out, err := p.Run(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), out.Timestamp)
+	assert.Equal(t, mapstr.M{"message": "replaced"}, out.Fields)
+	assert.Equal(t, mapstr.M{"_id": "abc"}, out.Meta, "metadata missing from the output is kept")
+}
+
+func TestDrop(t *testing.T) {
+	p := newTestProcessor(t, writeModule(t, dropBody, ""), nil)
+
+	out, err := p.Run(testEvent())
+	require.NoError(t, err)
+	assert.Nil(t, out)
+	assert.EqualValues(t, 1, p.dropped.Get())
+}
+
+func TestModuleError(t *testing.T) {
+	p := newTestProcessor(t, writeModule(t, errorBody("bad event"), "bad event"), nil)
+
+	for i := 0; i < 2; i++ {
+		out, err := p.Run(testEvent())
+		require.ErrorContains(t, err, "bad event")
+		require.NotNil(t, out)
+		assert.Equal(t, "hello", out.Fields["message"])
+		assert.Equal(t, []string{"_wasm_error"}, out.Fields["tags"])
+		msg, _ := out.GetValue("error.message")
+		assert.Equal(t, "bad event", msg)
+	}
+	assert.EqualValues(t, 2, p.failed.Get())
+	assert.EqualValues(t, 0, p.discarded.Get(), "errors reported by the module keep the instance")
+}
+
+func TestInvalidOutput(t *testing.T) {
+	output := `{"fields":`
+	p := newTestProcessor(t, writeModule(t, dataBody(output), output), nil)
+
+	out, err := p.Run(testEvent())
+	require.ErrorContains(t, err, "failed to decode")
+	assert.Equal(t, "hello", out.Fields["message"])
+}
+
+func TestLimits(t *testing.T) {
+	tests := map[string]struct {
+		body     []byte
+		settings map[string]interface{}
+		err      string
+	}{
+		"timeout": {
+			body:     loopBody,
+			settings: map[string]interface{}{"timeout": "50ms"},
+			err:      "deadline exceeded",
+		},
+		"function calls": {
+			body:     callLoopBody,
+			settings: map[string]interface{}{"timeout": 0, "max_function_calls": 1000},
+			err:      errTooManyCalls.Error(),
+		},
+		"only cached instances": {
+			body: loopBody,
+			settings: map[string]interface{}{
+				"timeout":               "50ms",
+				"max_cached_instances":  1,
+				"only_cached_instances": true,
+			},
+			err: "deadline exceeded",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTestProcessor(t, writeModule(t, tc.body, ""), tc.settings)
+
+			// Instances are replaced after exceeding the limits.
+			for i := 0; i < 2; i++ {
+				out, err := p.Run(testEvent())
+				require.ErrorContains(t, err, tc.err)
+				require.ErrorContains(t, err, "module exceeded its limits")
+				assert.Equal(t, []string{"_wasm_error"}, out.Fields["tags"])
+			}
+			assert.EqualValues(t, 2, p.discarded.Get())
+		})
+	}
+}
+
+func TestOnlyCachedInstancesRefill(t *testing.T) {
+	p := newTestProcessor(t, writeModule(t, loopBody, ""), map[string]interface{}{
+		"timeout":               "50ms",
+		"max_cached_instances":  1,
+		"only_cached_instances": true,
+	})
+	newInstance := p.pool.New
+	p.pool.New = func() (*instance, error) { return nil, errors.New("out of memory") }
+
+	// The instance that timed out cannot be replaced, later calls fail
+	// instead of waiting for an instance forever.
+	_, err := p.Run(testEvent())
+	require.ErrorContains(t, err, "module exceeded its limits")
+	_, err = p.Run(testEvent())
+	require.ErrorContains(t, err, "failed to instantiate module: out of memory")
+
+	// The instance is created again once it is possible.
+	p.pool.New = newInstance
+	_, err = p.Run(testEvent())
+	require.ErrorContains(t, err, "module exceeded its limits")
+	assert.Len(t, p.pool.C, 1)
+}
+
+func TestConcurrentRun(t *testing.T) {
+	p := newTestProcessor(t, writeModule(t, echoBody, ""), map[string]interface{}{"max_cached_instances": 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				out, err := p.Run(testEvent())
+				if assert.NoError(t, err) {
+					assert.Equal(t, "hello", out.Fields["message"])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, len(p.pool.C), 2)
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := map[string]struct {
+		module []byte
+		config map[string]interface{}
+		err    string
+	}{
+		"invalid module": {
+			module: []byte("not a module"),
+			err:    "failed to load module",
+		},
+		"missing export": {
+			module: []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00},
+			err:    "module does not export memory",
+		},
+		"invalid encoding": {
+			module: buildModule(echoBody, ""),
+			config: map[string]interface{}{"encoding": "xml"},
+			err:    "invalid encoding",
+		},
+		"no cached instances": {
+			module: buildModule(echoBody, ""),
+			config: map[string]interface{}{"only_cached_instances": true, "max_cached_instances": 0},
+			err:    "max_cached_instances",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := writeModule(t, nil, "")
+			require.NoError(t, os.WriteFile(path, tc.module, 0o644))
+			c := map[string]interface{}{"file": path}
+			for k, v := range tc.config {
+				c[k] = v
+			}
+			_, err := New(conf.MustNewConfigFrom(c))
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -29,6 +29,10 @@ import (
 	"github.com/elastic/elastic-agent-libs/logp"
 )
 
+// processorsCloseTimeout is how long Close waits for the processors to be
+// closed before it closes the queue producer.
+var processorsCloseTimeout = 5 * time.Second
+
 // client connects a beat with the processors and pipeline queue.
 type client struct {
 	logger     *logp.Logger
@@ -129,6 +133,37 @@ func (c *client) publish(e beat.Event) {
 	}
 }
 
+// emit publishes an event produced by one of the client processors outside
+// of Publish, e.g. an aggregate that is emitted when its window closes. The
+// event has already been run through the remaining processors.
+func (c *client) emit(event *beat.Event) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.onNewEvent()
+	c.eventListener.AddEvent(*event, true)
+
+	pubEvent := publisher.Event{
+		Content: *event,
+		Flags:   c.eventFlags,
+	}
+
+	// Events emitted while the client is closing must not block the
+	// shutdown on a full queue.
+	var published bool
+	if c.canDrop || !c.isOpen.Load() {
+		_, published = c.producer.TryPublish(pubEvent)
+	} else {
+		_, published = c.producer.Publish(pubEvent)
+	}
+
+	if published {
+		c.onPublished()
+	} else {
+		c.onDroppedOnPublish(*event)
+	}
+}
+
 func (c *client) Close() error {
 	if c.isOpen.Swap(false) {
 		// Only do shutdown handling the first time Close is called
@@ -141,23 +176,50 @@ func (c *client) Close() error {
 		c.eventListener.ClientClosed()
 		c.logger.Debug("client: done closing acker")
 
+		// Processors are closed before the producer, so events they emit when
+		// being closed (e.g. pending aggregates) can still be published. If
+		// they don't finish in time, e.g. because a Publish call is blocked on
+		// a full queue, they are closed in the background once it returns and
+		// the events they emit are dropped.
+		select {
+		case <-c.closeProcessors():
+		case <-time.After(processorsCloseTimeout):
+			c.logger.Warn("client: processors did not close in time, closing them in the background")
+		}
+
 		c.logger.Debug("client: close queue producer")
 		c.producer.Close()
 		c.onClosed()
 		c.logger.Debug("client: done producer close")
-
-		if c.processors != nil {
-			c.logger.Debug("client: closing processors")
-			err := processors.Close(c.processors)
-			if err != nil {
-				c.logger.Errorf("client: error closing processors: %v", err)
-			}
-			c.logger.Debug("client: done closing processors")
-		}
 	}
 	return nil
 }
 
+// closeProcessors closes the client processors in the background and returns
+// a channel that is closed when they are done.
+func (c *client) closeProcessors() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if c.processors == nil {
+			return
+		}
+
+		// Wait for a running Publish call to finish with the processors.
+		// Later calls see the client is closed and don't run them.
+		c.mutex.Lock()
+		c.mutex.Unlock() //nolint:staticcheck // only waiting for the lock holder
+
+		c.logger.Debug("client: closing processors")
+		err := processors.Close(c.processors)
+		if err != nil {
+			c.logger.Errorf("client: error closing processors: %v", err)
+		}
+		c.logger.Debug("client: done closing processors")
+	}()
+	return done
+}
+
 func (c *client) onClosing() {
 	c.clientListener.Closing()
 }
@@ -22,6 +22,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -189,6 +190,80 @@ func TestClient(t *testing.T) {
 		<-done
 		require.Equal(t, expected, received)
 	})
+
+	t.Run("events emitted by processors on close are published", func(t *testing.T) {
+		l := logptest.NewTestingLogger(t, "")
+		q := memqueue.NewQueue(l, nil, memqueue.Settings{
+			Events:        5,
+			MaxGetRequest: 1,
+			FlushTimeout:  time.Millisecond,
+		}, 5, nil)
+
+		p := &emittingProcessor{}
+		pipeline := makePipeline(t, Settings{
+			Processors: testProcessorSupporter{Processor: p},
+		}, q)
+		defer pipeline.Close()
+
+		client, err := pipeline.Connect()
+		require.NoError(t, err)
+
+		client.PublishAll([]beat.Event{{Fields: mapstr.M{"n": 1}}, {Fields: mapstr.M{"n": 2}}})
+		require.NoError(t, client.Close())
+
+		batch, err := q.Get(1)
+		require.NoError(t, err)
+		require.Equal(t, 1, batch.Count())
+		//nolint:errcheck // it always succeeds
+		e := batch.Entry(0).(publisher.Event)
+		assert.Equal(t, mapstr.M{"count": 2}, e.Content.Fields)
+		batch.Done()
+	})
+
+	t.Run("close does not block on a full queue", func(t *testing.T) {
+		defer func(timeout time.Duration) { processorsCloseTimeout = timeout }(processorsCloseTimeout)
+		processorsCloseTimeout = 10 * time.Millisecond
+
+		l := logptest.NewTestingLogger(t, "")
+		q := memqueue.NewQueue(l, nil, memqueue.Settings{
+			Events:        2,
+			MaxGetRequest: 1,
+			FlushTimeout:  time.Millisecond,
+		}, 2, nil)
+
+		p := &emittingProcessor{passThrough: true}
+		pipeline := makePipeline(t, Settings{
+			Processors: testProcessorSupporter{Processor: p},
+		}, q)
+
+		client, err := pipeline.Connect()
+		require.NoError(t, err)
+
+		// Fill the queue, the last Publish blocks until the client is closed.
+		published := make(chan struct{})
+		go func() {
+			defer close(published)
+			client.PublishAll([]beat.Event{{Fields: mapstr.M{"n": 1}}, {Fields: mapstr.M{"n": 2}}, {Fields: mapstr.M{"n": 3}}})
+		}()
+		require.Eventually(t, func() bool { return p.runs() == 3 }, 5*time.Second, time.Millisecond)
+
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			assert.NoError(t, client.Close())
+		}()
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("client.Close blocked on a full queue")
+		}
+
+		// The blocked Publish returns when the queue is closed, then the
+		// processors are closed in the background.
+		require.NoError(t, pipeline.Close())
+		<-published
+		require.Eventually(t, p.closed.Load, 5*time.Second, time.Millisecond)
+	})
 }
 
 func TestClientWaitClose(t *testing.T) {
@@ -444,6 +519,47 @@ func (p *testProcessor) Run(in *beat.Event) (event *beat.Event, err error) {
 	return p.processorFn(in)
 }
 
+// emittingProcessor counts and drops all events, unless passThrough is set,
+// and emits the count when it is closed.
+type emittingProcessor struct {
+	passThrough bool
+
+	mu     sync.Mutex
+	count  int
+	emit   processors.EmitFunc
+	closed atomic.Bool
+}
+
+func (p *emittingProcessor) String() string {
+	return "emittingProcessor"
+}
+
+func (p *emittingProcessor) Run(in *beat.Event) (*beat.Event, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.count++
+	if p.passThrough {
+		return in, nil
+	}
+	return nil, nil
+}
+
+func (p *emittingProcessor) runs() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.count
+}
+
+func (p *emittingProcessor) SetEmitter(emit processors.EmitFunc) {
+	p.emit = emit
+}
+
+func (p *emittingProcessor) Close() error {
+	p.emit(&beat.Event{Fields: mapstr.M{"count": p.runs()}})
+	p.closed.Store(true)
+	return nil
+}
+
 type processorList struct {
 	processors []beat.Processor
 }
@@ -28,11 +28,13 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common/acker"
 	"github.com/elastic/beats/v7/libbeat/common/reload"
 	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/processors"
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/processing"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/spillqueue"
 	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
 )
@@ -203,7 +205,7 @@ func (p *Pipeline) ConnectWith(cfg beat.ClientConfig) (beat.Client, error) {
 
 	waitClose := cfg.WaitClose
 
-	processors, err := p.createEventProcessing(cfg.Processing, publishDisabled)
+	procs, err := p.createEventProcessing(cfg.Processing, publishDisabled)
 	if err != nil {
 		return nil, err
 	}
@@ -216,7 +218,7 @@ func (p *Pipeline) ConnectWith(cfg beat.ClientConfig) (beat.Client, error) {
 	client := &client{
 		logger:         p.monitors.Logger,
 		clientListener: clientListener,
-		processors:     processors,
+		processors:     procs,
 		eventFlags:     eventFlags,
 		canDrop:        canDrop,
 		observer:       p.observer,
@@ -258,6 +260,10 @@ func (p *Pipeline) ConnectWith(cfg beat.ClientConfig) (beat.Client, error) {
 		return nil, fmt.Errorf("client failed to connect because the pipeline is shutting down")
 	}
 
+	if client.processors != nil {
+		processors.SetEmitter(client.processors, client.emit)
+	}
+
 	p.observer.clientConnected()
 	return client, nil
 }
@@ -292,6 +298,12 @@ func queueFactoryForUserConfig(queueType string, userConfig *conf.C) (queue.Queu
 			return nil, err
 		}
 		return diskqueue.FactoryForSettings(settings), nil
+	case spillqueue.QueueType:
+		settings, err := spillqueue.SettingsForUserConfig(userConfig)
+		if err != nil {
+			return nil, err
+		}
+		return spillqueue.FactoryForSettings(settings), nil
 	default:
 		return nil, fmt.Errorf("unrecognized queue type '%v'", queueType)
 	}
@@ -18,6 +18,7 @@
 package processing
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/elastic/beats/v7/libbeat/asset"
@@ -214,6 +215,12 @@ func newBuilder(
 
 	hasProcessors := processors != nil && len(processors.List) > 0
 	if hasProcessors {
+		// Global processors are shared by all clients, so the events they
+		// emit on their own can't be published.
+		if processors.Emits() {
+			_ = processors.Close()
+			return nil, errors.New("processors that emit events, like aggregate or sample in reservoir mode, must be configured on an input or module, not globally")
+		}
 		tmp := newGroup("global", log)
 		for _, p := range processors.List {
 			tmp.add(p)
@@ -38,6 +38,8 @@ import (
 	_ "github.com/elastic/beats/v7/libbeat/processors/add_docker_metadata"
 	_ "github.com/elastic/beats/v7/libbeat/processors/add_host_metadata"
 	_ "github.com/elastic/beats/v7/libbeat/processors/add_kubernetes_metadata"
+	_ "github.com/elastic/beats/v7/libbeat/processors/aggregate"
+	_ "github.com/elastic/beats/v7/libbeat/processors/sample"
 )
 
 func TestGenerateProcessorList(t *testing.T) {
@@ -482,6 +484,48 @@ func TestProcessingClose(t *testing.T) {
 	assert.True(t, factoryProcessor.closed)
 }
 
+func TestGlobalEmittingProcessors(t *testing.T) {
+	tests := map[string]struct {
+		processor mapstr.M
+		rejected  bool
+	}{
+		"aggregate": {
+			processor: mapstr.M{"aggregate": mapstr.M{"group_by": []string{"host.name"}}},
+			rejected:  true,
+		},
+		"conditional aggregate": {
+			processor: mapstr.M{
+				"if":   mapstr.M{"has_fields": []string{"host.name"}},
+				"then": []mapstr.M{{"aggregate": mapstr.M{}}},
+			},
+			rejected: true,
+		},
+		"reservoir sample": {
+			processor: mapstr.M{"sample": mapstr.M{"mode": "reservoir"}},
+			rejected:  true,
+		},
+		"probabilistic sample": {
+			processor: mapstr.M{"sample": mapstr.M{"rate": 0.5}},
+		},
+		"plain processor": {
+			processor: mapstr.M{"add_fields": mapstr.M{"fields": mapstr.M{"a": 1}}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.MustNewConfigFrom(mapstr.M{"processors": []mapstr.M{test.processor}})
+			factory, err := MakeDefaultSupport(true, nil)(beat.Info{}, logp.NewLogger(""), cfg)
+			if test.rejected {
+				assert.ErrorContains(t, err, "must be configured on an input or module")
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, factory.Close())
+		})
+	}
+}
+
 func TestProcessingDiagnostics(t *testing.T) {
 	factory, err := MakeDefaultSupport(true, nil)(beat.Info{}, logp.L(), config.NewConfig())
 	require.NoError(t, err)
@@ -112,6 +112,12 @@ func (p *group) String() string {
 	return fmt.Sprintf("%v{%v}", p.title, str)
 }
 
+// SetEmitter installs emit on the processors of the group. Events emitted by
+// a processor are run through the remaining processors of the group.
+func (p *group) SetEmitter(emit processors.EmitFunc) {
+	processors.SetListEmitter(p.log, p.list, emit)
+}
+
 func (p *group) All() []beat.Processor {
 	return p.list
 }
@@ -109,6 +109,10 @@ type diskQueueACKs struct {
 	// file.
 	positionFile *os.File
 
+	// The cipher used to encrypt the queue position, or nil if encryption
+	// is not configured.
+	cipher *frameCipher
+
 	// When the queue is closed, diskQueueACKs.done is closed to signal that
 	// the core loop will not accept any more acked segments and any future
 	// ACKs should be ignored.
@@ -116,7 +120,7 @@ type diskQueueACKs struct {
 }
 
 func newDiskQueueACKs(
-	logger *logp.Logger, position queuePosition, positionFile *os.File,
+	logger *logp.Logger, position queuePosition, positionFile *os.File, cipher *frameCipher,
 ) *diskQueueACKs {
 	return &diskQueueACKs{
 		logger:            logger,
@@ -126,6 +130,7 @@ func newDiskQueueACKs(
 		segmentBoundaries: make(map[frameID]*queueSegment),
 		segmentACKChan:    make(chan segmentID, 1),
 		positionFile:      positionFile,
+		cipher:            cipher,
 		done:              make(chan struct{}),
 	}
 }
@@ -175,7 +180,7 @@ func (dqa *diskQueueACKs) addFrames(frames []*readFrame) {
 		}
 		// We advanced the ACK position at least somewhat, so write its
 		// new value.
-		err := writeQueuePositionToHandle(dqa.positionFile, dqa.nextPosition)
+		err := writeQueuePositionToHandle(dqa.positionFile, dqa.nextPosition, dqa.cipher)
 		if err != nil {
 			// TODO: Don't spam this warning on every ACK if it's a permanent error.
 			dqa.logger.Warnf("Couldn't save queue position: %v", err)
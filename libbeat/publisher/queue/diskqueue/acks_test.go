@@ -246,7 +246,7 @@ func runAddFramesTest(t *testing.T, name string, test addFramesTest) {
 	defer stateFile.Close()
 
 	logger := logptest.NewTestingLogger(t, "")
-	dqa := newDiskQueueACKs(logger, test.position, stateFile)
+	dqa := newDiskQueueACKs(logger, test.position, stateFile, nil)
 	dqa.nextFrameID = test.frameID
 	for _, step := range test.steps {
 		prefix := fmt.Sprintf("[%v] %v", name, step.description)
@@ -64,8 +64,16 @@ type Settings struct {
 	RetryInterval    time.Duration
 	MaxRetryInterval time.Duration
 
-	// UseCompression enables or disables LZ4 compression
+	// UseCompression enables or disables LZ4 compression. It can't be used
+	// with EncryptionKeys.
 	UseCompression bool
+
+	// EncryptionKeys enables AES-GCM encryption of the data frames and the
+	// state file when non-empty. The first key is used to encrypt new data,
+	// the remaining keys are only used to read data that was written before
+	// a key rotation. Frames are encrypted before they reach the segment
+	// file, so they can't be compressed and UseCompression must be false.
+	EncryptionKeys [][]byte
 }
 
 // userConfig holds the parameters for a disk queue that are configurable
@@ -80,6 +88,8 @@ type userConfig struct {
 
 	RetryInterval    *time.Duration `config:"retry_interval" validate:"positive"`
 	MaxRetryInterval *time.Duration `config:"max_retry_interval" validate:"positive"`
+
+	Encryption *encryptionConfig `config:"encryption"`
 }
 
 func (c *userConfig) Validate() error {
@@ -163,6 +173,14 @@ func SettingsForUserConfig(config *config.C) (Settings, error) {
 		settings.MaxRetryInterval = *userConfig.MaxRetryInterval
 	}
 
+	if userConfig.Encryption != nil {
+		keys, err := userConfig.Encryption.keys()
+		if err != nil {
+			return Settings{}, err
+		}
+		settings.EncryptionKeys = keys
+	}
+
 	return settings, nil
 }
 
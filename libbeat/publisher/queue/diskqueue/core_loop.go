@@ -154,6 +154,7 @@ func (dq *diskQueue) handleReaderLoopResponse(response readerLoopResponse) {
 	// Advance the frame / offset based on what was just completed.
 	dq.segments.nextReadFrameID += frameID(response.frameCount)
 	dq.segments.nextReadPosition += response.byteCount
+	dq.segments.nextReadIndex += response.frameCount
 
 	segment := dq.segments.readingSegment()
 	segment.framesRead += response.frameCount
@@ -387,11 +388,13 @@ func (dq *diskQueue) maybeReadPending() {
 		// case firstFrameID is already initialized to the correct value.
 		segment.firstFrameID = dq.segments.nextReadFrameID
 		dq.segments.nextReadPosition = segment.headerSize()
+		dq.segments.nextReadIndex = 0
 	}
 	request := readerLoopRequest{
 		segment:       segment,
 		startFrameID:  dq.segments.nextReadFrameID,
 		startPosition: dq.segments.nextReadPosition,
+		startIndex:    dq.segments.nextReadIndex,
 		endPosition:   segment.byteCount,
 	}
 	dq.readerLoop.requestChan <- request
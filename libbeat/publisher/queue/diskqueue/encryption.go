@@ -0,0 +1,213 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Size of the key ID prepended to every encrypted block. The key ID lets
+// the queue find the right key for data written before a key rotation.
+const encryptionKeyIDSize = 4
+
+// Size of the random nonce prepended to every encrypted block.
+const encryptionNonceSize = 12
+
+// encryptionOverhead is the number of bytes an encrypted block occupies
+// in addition to its plaintext: key ID, nonce and the GCM tag.
+const encryptionOverhead = encryptionKeyIDSize + encryptionNonceSize + 16
+
+var errUnknownEncryptionKey = errors.New("data was encrypted with an unknown key")
+
+// frameCipher encrypts and authenticates data frames and the state file
+// with AES-GCM. A frameCipher is safe for concurrent use, so it can be shared
+// between the producers, the reader loop and the acks handler.
+//
+// Encrypted blocks have the layout
+//
+//	key ID (4 bytes) | nonce (12 bytes) | ciphertext | GCM tag (16 bytes)
+//
+// where the key ID is also authenticated as additional data, together with
+// a caller supplied context. Data frames use their segment ID and frame index
+// as context (see frameContext), so a frame can't be reordered, replayed or
+// moved to another segment without failing authentication.
+type frameCipher struct {
+	// The ID of the key used to encrypt new data.
+	activeID uint32
+
+	// All known keys by ID, including the active one.
+	aeads map[uint32]cipher.AEAD
+}
+
+// newFrameCipher creates a frameCipher from a list of raw AES keys. The
+// first key is used to encrypt new data, the remaining keys are only used to
+// decrypt data that was written before a key rotation. If keys is empty,
+// newFrameCipher returns nil, meaning encryption is disabled.
+func newFrameCipher(keys [][]byte) (*frameCipher, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	fc := &frameCipher{aeads: make(map[uint32]cipher.AEAD, len(keys))}
+	for i, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid disk queue encryption key: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid disk queue encryption key: %w", err)
+		}
+		id := encryptionKeyID(key)
+		if _, exists := fc.aeads[id]; exists {
+			return nil, fmt.Errorf("disk queue encryption key %d is a duplicate", i)
+		}
+		fc.aeads[id] = aead
+		if i == 0 {
+			fc.activeID = id
+		}
+	}
+	return fc, nil
+}
+
+// encryptionKeyID derives a stable identifier from a key, so keys don't need
+// to be configured with an explicit ID.
+func encryptionKeyID(key []byte) uint32 {
+	sum := sha256.Sum256(key)
+	return binary.LittleEndian.Uint32(sum[:encryptionKeyIDSize])
+}
+
+// frameContext returns the additional data that binds a data frame to its
+// position in the queue: the segment ID followed by the index of the frame
+// within the segment.
+func frameContext(id segmentID, frameIndex uint64) []byte {
+	context := make([]byte, 0, 16)
+	context = binary.LittleEndian.AppendUint64(context, uint64(id))
+	return binary.LittleEndian.AppendUint64(context, frameIndex)
+}
+
+// seal encrypts plaintext with the active key and returns the encrypted
+// block in a newly allocated slice. The block can only be opened with the
+// same context.
+func (fc *frameCipher) seal(plaintext []byte, context []byte) []byte {
+	out := make([]byte, encryptionKeyIDSize+encryptionNonceSize, len(plaintext)+encryptionOverhead)
+	binary.LittleEndian.PutUint32(out, fc.activeID)
+	nonce := out[encryptionKeyIDSize:]
+	// crypto/rand.Read never returns an error.
+	_, _ = rand.Read(nonce)
+	return fc.aeads[fc.activeID].Seal(out, nonce, plaintext, additionalData(out, context))
+}
+
+// open authenticates and decrypts a block created by seal with the same
+// context. Any modification of the block results in an error.
+func (fc *frameCipher) open(data []byte, context []byte) ([]byte, error) {
+	if len(data) < encryptionOverhead {
+		return nil, fmt.Errorf("encrypted data is too short (%d bytes)", len(data))
+	}
+	id := binary.LittleEndian.Uint32(data)
+	aead, ok := fc.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w (key id %x)", errUnknownEncryptionKey, id)
+	}
+	nonce := data[encryptionKeyIDSize : encryptionKeyIDSize+encryptionNonceSize]
+	ciphertext := data[encryptionKeyIDSize+encryptionNonceSize:]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData(data, context))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decrypt data: %w", err)
+	}
+	return plaintext, nil
+}
+
+// additionalData returns the key ID at the start of block followed by
+// context, in a new slice so block itself is never modified.
+func additionalData(block []byte, context []byte) []byte {
+	ad := make([]byte, 0, encryptionKeyIDSize+len(context))
+	ad = append(ad, block[:encryptionKeyIDSize]...)
+	return append(ad, context...)
+}
+
+// encryptionConfig is the user configuration of the queue encryption. Keys
+// are base64 encoded AES-128, AES-192 or AES-256 keys. Inline keys are
+// usually references to keystore entries, e.g. `key: ${DISKQUEUE_KEY}`.
+type encryptionConfig struct {
+	Key              string   `config:"key"`
+	KeyFile          string   `config:"key_file"`
+	PreviousKeys     []string `config:"previous_keys"`
+	PreviousKeyFiles []string `config:"previous_key_files"`
+}
+
+func (c *encryptionConfig) Validate() error {
+	if (c.Key == "") == (c.KeyFile == "") {
+		return errors.New("disk queue encryption requires exactly one of key or key_file")
+	}
+	return nil
+}
+
+// keys loads and decodes the configured keys. The current key is always the
+// first element of the result.
+func (c *encryptionConfig) keys() ([][]byte, error) {
+	var encoded []string
+	if c.KeyFile != "" {
+		key, err := readKeyFile(c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, key)
+	} else {
+		encoded = append(encoded, c.Key)
+	}
+	encoded = append(encoded, c.PreviousKeys...)
+	for _, path := range c.PreviousKeyFiles {
+		key, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, key)
+	}
+
+	keys := make([][]byte, 0, len(encoded))
+	for i, s := range encoded {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("disk queue encryption key %d is not valid base64: %w", i, err)
+		}
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("disk queue encryption key %d must be 16, 24 or 32 bytes long, got %d", i, len(key))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func readKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read disk queue encryption key file: %w", err)
+	}
+	return string(data), nil
+}
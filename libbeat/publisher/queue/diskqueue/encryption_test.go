@@ -0,0 +1,394 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var (
+	testKey1 = bytes.Repeat([]byte{0x01}, 32)
+	testKey2 = bytes.Repeat([]byte{0x02}, 16)
+)
+
+func TestFrameCipherRoundTrip(t *testing.T) {
+	fc, err := newFrameCipher([][]byte{testKey1})
+	require.NoError(t, err)
+
+	plaintext := []byte("the quick brown fox")
+	context := frameContext(1, 0)
+	sealed := fc.seal(plaintext, context)
+	assert.Len(t, sealed, len(plaintext)+encryptionOverhead)
+	assert.False(t, bytes.Contains(sealed, plaintext))
+
+	opened, err := fc.open(sealed, context)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+
+	_, err = fc.open(sealed, frameContext(1, 1))
+	assert.Error(t, err, "a frame must not be readable at another index")
+	_, err = fc.open(sealed, frameContext(2, 0))
+	assert.Error(t, err, "a frame must not be readable in another segment")
+
+	noCipher, err := newFrameCipher(nil)
+	require.NoError(t, err)
+	assert.Nil(t, noCipher)
+}
+
+func TestFrameCipherRejectsTampering(t *testing.T) {
+	fc, err := newFrameCipher([][]byte{testKey1})
+	require.NoError(t, err)
+
+	sealed := fc.seal([]byte("sensitive data"), nil)
+
+	regions := map[string]int{
+		"key id":     0,
+		"nonce":      encryptionKeyIDSize + 1,
+		"ciphertext": encryptionKeyIDSize + encryptionNonceSize + 1,
+		"tag":        len(sealed) - 1,
+	}
+	for name, offset := range regions {
+		t.Run(name, func(t *testing.T) {
+			tampered := bytes.Clone(sealed)
+			tampered[offset] ^= 0x80
+			_, err := fc.open(tampered, nil)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err = fc.open(sealed[:encryptionOverhead-1], nil)
+	assert.Error(t, err, "truncated data must be rejected")
+}
+
+func TestFrameCipherKeyRotation(t *testing.T) {
+	oldCipher, err := newFrameCipher([][]byte{testKey1})
+	require.NoError(t, err)
+	sealed := oldCipher.seal([]byte("written before rotation"), nil)
+
+	rotated, err := newFrameCipher([][]byte{testKey2, testKey1})
+	require.NoError(t, err)
+	opened, err := rotated.open(sealed, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("written before rotation"), opened)
+
+	resealed := rotated.seal(opened, nil)
+	assert.Equal(t, encryptionKeyID(testKey2), binary.LittleEndian.Uint32(resealed), "new data must use the first key")
+
+	newOnly, err := newFrameCipher([][]byte{testKey2})
+	require.NoError(t, err)
+	_, err = newOnly.open(sealed, nil)
+	assert.ErrorIs(t, err, errUnknownEncryptionKey)
+
+	_, err = newFrameCipher([][]byte{testKey1, testKey1})
+	assert.Error(t, err, "duplicate keys must be rejected")
+}
+
+func TestEncryptionConfig(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "queue.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(testKey1)+"\n"), 0600))
+
+	cfg := config.MustNewConfigFrom(mapstr.M{
+		"max_size": "100MB",
+		"encryption": mapstr.M{
+			"key_file":      keyFile,
+			"previous_keys": []string{base64.StdEncoding.EncodeToString(testKey2)},
+		},
+	})
+	settings, err := SettingsForUserConfig(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{testKey1, testKey2}, settings.EncryptionKeys)
+
+	cfg = config.MustNewConfigFrom(mapstr.M{
+		"max_size":   "100MB",
+		"encryption": mapstr.M{"key": base64.StdEncoding.EncodeToString([]byte("too short"))},
+	})
+	_, err = SettingsForUserConfig(cfg)
+	assert.Error(t, err)
+
+	cfg = config.MustNewConfigFrom(mapstr.M{
+		"max_size":   "100MB",
+		"encryption": mapstr.M{"key": "a", "key_file": keyFile},
+	})
+	_, err = SettingsForUserConfig(cfg)
+	assert.Error(t, err, "key and key_file are mutually exclusive")
+}
+
+func TestEncryptedStateFile(t *testing.T) {
+	fc, err := newFrameCipher([][]byte{testKey1})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "state.dat")
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	require.NoError(t, err)
+	defer file.Close()
+
+	position := queuePosition{segmentID: 3, byteIndex: 1234, frameIndex: 56}
+	require.NoError(t, writeQueuePositionToHandle(file, position, fc))
+
+	read, err := queuePositionFromPath(path, fc)
+	require.NoError(t, err)
+	assert.Equal(t, position, read)
+
+	_, err = queuePositionFromPath(path, nil)
+	assert.Error(t, err, "encrypted state must not be read without a key")
+
+	// Flip a bit in the encrypted payload.
+	_, err = file.WriteAt([]byte{0xff}, 30)
+	require.NoError(t, err)
+	_, err = queuePositionFromPath(path, fc)
+	assert.Error(t, err, "tampered state must be rejected")
+}
+
+func TestReaderRejectsTamperedFrame(t *testing.T) {
+	fc, err := newFrameCipher([][]byte{testKey1})
+	require.NoError(t, err)
+
+	settings := DefaultSettings()
+	settings.Path = t.TempDir()
+	settings.EncryptionKeys = [][]byte{testKey1}
+
+	encoder := newEventEncoder(SerializationCBOR)
+	serialized, err := encoder.encode(publisher.Event{
+		Content: beat.Event{Timestamp: eventTime, Fields: mapstr.M{"message": "secret"}},
+	})
+	require.NoError(t, err)
+
+	writeSegment := func(t *testing.T, id segmentID, settings Settings, frames ...[]byte) *queueSegment {
+		t.Helper()
+		segment := &queueSegment{id: id}
+		sw, err := segment.getWriter(settings)
+		require.NoError(t, err)
+		for _, data := range frames {
+			frameSize := uint32(len(data) + frameMetadataSize)
+			require.NoError(t, binary.Write(sw, binary.LittleEndian, frameSize))
+			_, err = sw.Write(data)
+			require.NoError(t, err)
+			// The checksum is recomputed on purpose: an attacker can do that too,
+			// so only the authentication tag can detect the modification.
+			require.NoError(t, binary.Write(sw, binary.LittleEndian, computeChecksum(data)))
+			require.NoError(t, binary.Write(sw, binary.LittleEndian, frameSize))
+			segment.byteCount += uint64(frameSize)
+		}
+		require.NoError(t, sw.Close())
+		segment.byteCount += segment.headerSize()
+		return segment
+	}
+
+	readSegment := func(t *testing.T, segment *queueSegment, cipher *frameCipher) ([]*readFrame, error) {
+		t.Helper()
+		rl := newReaderLoop(settings, nil, cipher)
+		response := rl.processRequest(readerLoopRequest{
+			segment:       segment,
+			startPosition: segment.headerSize(),
+			endPosition:   segment.byteCount,
+		})
+		close(rl.output)
+		var frames []*readFrame
+		for frame := range rl.output {
+			frames = append(frames, frame)
+		}
+		return frames, response.err
+	}
+
+	t.Run("valid frames", func(t *testing.T) {
+		segment := writeSegment(t, 1, settings,
+			fc.seal(serialized, frameContext(1, 0)),
+			fc.seal(serialized, frameContext(1, 1)))
+		frames, err := readSegment(t, segment, fc)
+		require.NoError(t, err)
+		require.Len(t, frames, 2)
+		event, ok := frames[1].event.(publisher.Event)
+		require.True(t, ok)
+		assert.Equal(t, "secret", event.Content.Fields["message"])
+	})
+
+	t.Run("tampered frame", func(t *testing.T) {
+		tampered := fc.seal(serialized, frameContext(2, 0))
+		tampered[len(tampered)/2] ^= 0x01
+		_, err := readSegment(t, writeSegment(t, 2, settings, tampered), fc)
+		assert.ErrorContains(t, err, "couldn't decrypt data frame")
+	})
+
+	t.Run("frame moved to another segment", func(t *testing.T) {
+		_, err := readSegment(t, writeSegment(t, 3, settings, fc.seal(serialized, frameContext(1, 0))), fc)
+		assert.ErrorContains(t, err, "couldn't decrypt data frame")
+	})
+
+	t.Run("reordered frames", func(t *testing.T) {
+		segment := writeSegment(t, 4, settings,
+			fc.seal(serialized, frameContext(4, 1)),
+			fc.seal(serialized, frameContext(4, 0)))
+		frames, err := readSegment(t, segment, fc)
+		assert.ErrorContains(t, err, "couldn't decrypt data frame")
+		assert.Empty(t, frames)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := readSegment(t, writeSegment(t, 5, settings, fc.seal(serialized, frameContext(5, 0))), nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("plaintext segment", func(t *testing.T) {
+		plaintextSettings := settings
+		plaintextSettings.EncryptionKeys = nil
+		frames, err := readSegment(t, writeSegment(t, 6, plaintextSettings, serialized), fc)
+		assert.ErrorContains(t, err, "not encrypted")
+		assert.Empty(t, frames)
+	})
+}
+
+func TestEncryptionRejectsCompression(t *testing.T) {
+	settings := DefaultSettings()
+	settings.Path = t.TempDir()
+	settings.EncryptionKeys = [][]byte{testKey1}
+	settings.UseCompression = true
+
+	_, err := NewQueue(logptest.NewTestingLogger(t, ""), nil, settings, nil)
+	assert.ErrorContains(t, err, "encryption can't be used with compression")
+}
+
+func TestEncryptedQueueKeyRotation(t *testing.T) {
+	const eventCount = 10
+	dir := t.TempDir()
+	logger := logptest.NewTestingLogger(t, "")
+
+	settings := DefaultSettings()
+	settings.Path = dir
+	settings.EncryptionKeys = [][]byte{testKey1}
+
+	q, err := NewQueue(logger, nil, settings, nil)
+	require.NoError(t, err)
+	// The ACK callback is called once events have been written to disk.
+	written := make(chan int, eventCount)
+	producer := q.Producer(queue.ProducerConfig{ACK: func(count int) { written <- count }})
+	for i := 0; i < eventCount; i++ {
+		_, ok := producer.Publish(publisher.Event{
+			Content: beat.Event{Timestamp: eventTime, Fields: mapstr.M{"message": "confidential-payload", "i": i}},
+		})
+		require.True(t, ok)
+	}
+	for total := 0; total < eventCount; {
+		select {
+		case n := <-written:
+			total += n
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for events to be written")
+		}
+	}
+	require.NoError(t, q.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	require.NotEmpty(t, segments)
+	for _, path := range segments {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.False(t, bytes.Contains(data, []byte("confidential-payload")), "segment %s contains plaintext", path)
+	}
+
+	// Reopen the queue after a key rotation, the old events must still be
+	// readable.
+	settings.EncryptionKeys = [][]byte{testKey2, testKey1}
+	q, err = NewQueue(logger, nil, settings, nil)
+	require.NoError(t, err)
+	defer q.Close()
+
+	received := 0
+	for received < eventCount {
+		batch, err := q.Get(eventCount - received)
+		require.NoError(t, err)
+		for i := 0; i < batch.Count(); i++ {
+			event, ok := batch.Entry(i).(publisher.Event)
+			require.True(t, ok)
+			assert.Equal(t, "confidential-payload", event.Content.Fields["message"])
+		}
+		received += batch.Count()
+		batch.Done()
+	}
+}
+
+func TestEncryptedQueueResumesMidSegment(t *testing.T) {
+	const eventCount = 10
+	const ackedCount = 4
+	logger := logptest.NewTestingLogger(t, "")
+
+	settings := DefaultSettings()
+	settings.Path = t.TempDir()
+	settings.EncryptionKeys = [][]byte{testKey1}
+
+	q, err := NewQueue(logger, nil, settings, nil)
+	require.NoError(t, err)
+	written := make(chan int, eventCount)
+	producer := q.Producer(queue.ProducerConfig{ACK: func(count int) { written <- count }})
+	for i := 0; i < eventCount; i++ {
+		_, ok := producer.Publish(publisher.Event{
+			Content: beat.Event{Timestamp: eventTime, Fields: mapstr.M{"i": i}},
+		})
+		require.True(t, ok)
+	}
+	for total := 0; total < eventCount; {
+		select {
+		case n := <-written:
+			total += n
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for events to be written")
+		}
+	}
+	for acked := 0; acked < ackedCount; {
+		batch, err := q.Get(ackedCount - acked)
+		require.NoError(t, err)
+		acked += batch.Count()
+		batch.Done()
+	}
+	require.NoError(t, q.Close())
+
+	// The frames after the saved position must be authenticated with their
+	// index within the segment, not their index in the new session.
+	q, err = NewQueue(logger, nil, settings, nil)
+	require.NoError(t, err)
+	defer q.Close()
+
+	for next := ackedCount; next < eventCount; {
+		batch, err := q.Get(eventCount - next)
+		require.NoError(t, err)
+		for i := 0; i < batch.Count(); i++ {
+			event, ok := batch.Entry(i).(publisher.Event)
+			require.True(t, ok)
+			assert.EqualValues(t, next, event.Content.Fields["i"])
+			next++
+		}
+		batch.Done()
+	}
+}
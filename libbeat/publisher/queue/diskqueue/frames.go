@@ -33,6 +33,11 @@ type writeFrame struct {
 	// header / footer.
 	serialized []byte
 
+	// Whether the writer loop encrypts serialized before writing it. The
+	// frame can only be encrypted once its segment and position are known,
+	// but the core loop needs its final size earlier.
+	encrypted bool
+
 	// The producer that created this frame. This is included in the
 	// frame structure itself because we may need the producer and / or
 	// its config at any time up until it has been completely written:
@@ -68,5 +73,8 @@ const frameFooterSize = 8
 const frameMetadataSize = frameHeaderSize + frameFooterSize
 
 func (frame writeFrame) sizeOnDisk() uint64 {
+	if frame.encrypted {
+		return uint64(len(frame.serialized) + encryptionOverhead + frameMetadataSize)
+	}
 	return uint64(len(frame.serialized) + frameMetadataSize)
 }
@@ -72,6 +72,7 @@ func (producer *diskQueueProducer) publish(
 	request := producerWriteRequest{
 		frame: &writeFrame{
 			serialized: serialized,
+			encrypted:  producer.queue.cipher != nil,
 			producer:   producer,
 		},
 		shouldBlock: shouldBlock,
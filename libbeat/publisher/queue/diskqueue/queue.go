@@ -39,6 +39,11 @@ type diskQueue struct {
 	// Metadata related to the segment files.
 	segments diskQueueSegments
 
+	// If encryption is enabled, cipher is shared by the reader and writer
+	// loops and the acks handler. Otherwise it is nil, and producers check it
+	// to know whether their frames will be encrypted.
+	cipher *frameCipher
+
 	// Metadata related to consumer acks / positions of the oldest remaining
 	// frame.
 	acks *diskQueueACKs
@@ -124,6 +129,12 @@ func NewQueue(
 				"twice the segment size (%v)",
 			settings.MaxBufferSize, settings.MaxSegmentSize)
 	}
+
+	if settings.UseCompression && len(settings.EncryptionKeys) > 0 {
+		return nil, errors.New(
+			"disk queue encryption can't be used with compression, " +
+				"as encrypted frames can't be compressed")
+	}
 	observer.MaxBytes(int(settings.MaxBufferSize))
 
 	// Create the given directory path if it doesn't exist.
@@ -132,8 +143,13 @@ func NewQueue(
 		return nil, fmt.Errorf("couldn't create disk queue directory: %w", err)
 	}
 
+	cipher, err := newFrameCipher(settings.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+
 	// Load the previous queue position, if any.
-	nextReadPosition, err := queuePositionFromPath(settings.stateFilePath())
+	nextReadPosition, err := queuePositionFromPath(settings.stateFilePath(), cipher)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		// Errors reading / writing the position are non-fatal -- we just log a
 		// warning and fall back on the oldest existing segment, if any.
@@ -215,18 +231,20 @@ func NewQueue(
 		logger:   logger,
 		observer: observer,
 		settings: settings,
+		cipher:   cipher,
 
 		segments: diskQueueSegments{
 			reading:          initialSegments,
 			acked:            ackedSegments,
 			nextID:           nextSegmentID,
 			nextReadPosition: nextReadPosition.byteIndex,
+			nextReadIndex:    nextReadPosition.frameIndex,
 		},
 
-		acks: newDiskQueueACKs(logger, nextReadPosition, positionFile),
+		acks: newDiskQueueACKs(logger, nextReadPosition, positionFile, cipher),
 
-		readerLoop:  newReaderLoop(settings, encoder),
-		writerLoop:  newWriterLoop(logger, settings),
+		readerLoop:  newReaderLoop(settings, encoder, cipher),
+		writerLoop:  newWriterLoop(logger, settings, cipher),
 		deleterLoop: newDeleterLoop(settings),
 
 		producerWriteRequestChan: make(chan producerWriteRequest),
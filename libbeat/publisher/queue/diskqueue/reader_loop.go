@@ -26,10 +26,12 @@ import (
 )
 
 // startPosition and endPosition are absolute byte offsets into the segment
-// file on disk, and must point to frame boundaries.
+// file on disk, and must point to frame boundaries. startIndex is the index
+// of the frame at startPosition within the segment.
 type readerLoopRequest struct {
 	segment       *queueSegment
 	startPosition uint64
+	startIndex    uint64
 	startFrameID  frameID
 	endPosition   uint64
 }
@@ -74,9 +76,13 @@ type readerLoop struct {
 	// them from disk, to convert them to their final output serialization
 	// format.
 	outputEncoder queue.Encoder
+
+	// The cipher used to decrypt frames from encrypted segments, or nil if
+	// encryption is not configured.
+	cipher *frameCipher
 }
 
-func newReaderLoop(settings Settings, outputEncoder queue.Encoder) *readerLoop {
+func newReaderLoop(settings Settings, outputEncoder queue.Encoder, cipher *frameCipher) *readerLoop {
 	return &readerLoop{
 		settings: settings,
 
@@ -85,6 +91,7 @@ func newReaderLoop(settings Settings, outputEncoder queue.Encoder) *readerLoop {
 		output:        make(chan *readFrame, settings.ReadAheadLimit),
 		decoder:       newEventDecoder(),
 		outputEncoder: outputEncoder,
+		cipher:        cipher,
 	}
 }
 
@@ -114,6 +121,13 @@ func (rl *readerLoop) processRequest(request readerLoopRequest) readerLoopRespon
 	defer handle.Close()
 	rl.decoder.serializationFormat = handle.serializationFormat
 
+	// Reading a plaintext segment while keys are configured would let
+	// anyone with write access to the queue directory inject events.
+	if rl.cipher != nil && !handle.encrypted {
+		return readerLoopResponse{err: fmt.Errorf(
+			"segment %d is not encrypted but encryption keys are configured", request.segment.id)}
+	}
+
 	_, err = handle.Seek(int64(request.startPosition), io.SeekStart)
 	if err != nil {
 		return readerLoopResponse{err: err}
@@ -126,7 +140,8 @@ func (rl *readerLoop) processRequest(request readerLoopRequest) readerLoopRespon
 		// Try to read the next frame, clipping to the given bound.
 		// If the next frame extends past this boundary, nextFrame will return
 		// an error.
-		frame, err := rl.nextFrame(handle, remainingLength)
+		frameIndex := request.startIndex + frameCount
+		frame, err := rl.nextFrame(handle, remainingLength, frameContext(request.segment.id, frameIndex))
 		if frame != nil {
 			// Add the segment / frame ID, which nextFrame leaves blank.
 			frame.segment = request.segment
@@ -183,9 +198,10 @@ func (rl *readerLoop) processRequest(request readerLoopRequest) readerLoopRespon
 
 // nextFrame reads and decodes one frame from the given file handle, as long
 // it does not exceed the given length bound. The returned frame leaves the
-// segment and frame IDs unset.
+// segment and frame IDs unset. If the segment is encrypted, context must be
+// the frameContext of the frame's position.
 // The returned error will be set if and only if the returned frame is nil.
-func (rl *readerLoop) nextFrame(handle *segmentReader, maxLength uint64) (*readFrame, error) {
+func (rl *readerLoop) nextFrame(handle *segmentReader, maxLength uint64, context []byte) (*readFrame, error) {
 	// Ensure we are allowed to read the frame header.
 	if maxLength < frameHeaderSize {
 		return nil, fmt.Errorf(
@@ -244,6 +260,20 @@ func (rl *readerLoop) nextFrame(handle *segmentReader, maxLength uint64) (*readF
 			frameLength, duplicateLength)
 	}
 
+	if handle.encrypted {
+		// The checksum only protects against accidental corruption, the
+		// authentication tag of the encrypted frame makes sure the data
+		// wasn't tampered with.
+		if rl.cipher == nil {
+			return nil, fmt.Errorf("data frame is encrypted but no encryption key is configured")
+		}
+		plaintext, err := rl.cipher.open(bytes, context)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't decrypt data frame: %w", err)
+		}
+		copy(rl.decoder.Buffer(len(plaintext)), plaintext)
+	}
+
 	event, err := rl.decoder.Decode()
 	if err != nil {
 		// Unlike errors in the segment or frame metadata, this is entirely
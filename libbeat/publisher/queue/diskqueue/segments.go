@@ -79,6 +79,11 @@ type diskQueueSegments struct {
 	// exact byte position depends on the file header, see
 	// diskQueue.maybeReadPending()).
 	nextReadPosition uint64
+
+	// nextReadIndex is the index within the current read segment of the
+	// frame at nextReadPosition. Encrypted frames are authenticated with
+	// their index, so the reader loop needs it to decrypt them.
+	nextReadIndex uint64
 }
 
 // segmentID is a unique persistent integer id assigned to each created
@@ -153,6 +158,7 @@ const (
 	_                  uint32 = 1 << iota // 0x1
 	ENABLE_COMPRESSION                    // 0x2
 	ENABLE_PROTOBUF                       // 0x4
+	ENABLE_ENCRYPTION                     // 0x8
 )
 
 // Sort order: we store loaded segments in ascending order by their id.
@@ -221,11 +227,11 @@ func (segment *queueSegment) headerSize() uint64 {
 	return segmentHeaderSize
 }
 
-// getReader sets up the segmentReader.  The order of encryption and
-// compression is important.  If both options are enabled we want
-// encrypted compressed data not compressed encrypted data.  This is
-// because encryption will mask the repetions in the data making
-// compression much less effective.  getReader should only be called
+// getReader sets up the segmentReader.  Frames are encrypted one by
+// one before they are written, so the queue doesn't allow enabling
+// both encryption and compression: the encryption masks the
+// repetitions in the data, making compression ineffective.  getReader
+// should only be called
 // from the reader loop. If successful, returns an open segmentReader
 // positioned at the beginning of the segment's data region.
 func (segment *queueSegment) getReader(queueSettings Settings) (*segmentReader, error) {
@@ -258,6 +264,8 @@ func (segment *queueSegment) getReader(queueSettings Settings) (*segmentReader,
 	if (header.options & ENABLE_COMPRESSION) == ENABLE_COMPRESSION {
 		sr.cr = NewCompressionReader(sr.src)
 	}
+
+	sr.encrypted = (header.options & ENABLE_ENCRYPTION) == ENABLE_ENCRYPTION
 	return sr, nil
 }
 
@@ -276,6 +284,10 @@ func (segment *queueSegment) getWriter(queueSettings Settings) (*segmentWriter,
 		options = options | ENABLE_COMPRESSION
 	}
 
+	if len(queueSettings.EncryptionKeys) > 0 {
+		options = options | ENABLE_ENCRYPTION
+	}
+
 	sw := &segmentWriter{}
 	sw.dst = file
 
@@ -436,15 +448,18 @@ func (segments *diskQueueSegments) sizeOnDisk() uint64 {
 // segmentReader handles reading of segments.  getReader sets up the
 // reader and handles setting up the Reader to deal with the different
 // schema version.  With Schema version 2 there is the option for
-// plain data, encrypted data, compressed data and encrypted
-// compressed data.  If compression is enabled operations go through
-// the CompressionReader because compressing encrypted data defeats
-// the purpose of compression since encryption will make the data
-// less compressable.
+// plain data, encrypted data or compressed data.  If compression is
+// enabled operations go through the CompressionReader.  Encryption
+// and compression are not used together, as encrypted data can't be
+// compressed.
 type segmentReader struct {
 	src                 io.ReadSeekCloser
 	cr                  *CompressionReader
 	serializationFormat SerializationFormat
+
+	// encrypted is set if the data frames in this segment were encrypted
+	// when they were written.
+	encrypted bool
 }
 
 func (r *segmentReader) Read(p []byte) (int, error) {
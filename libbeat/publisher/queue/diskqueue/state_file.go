@@ -19,34 +19,62 @@ package diskqueue
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 )
 
 const currentStateFileVersion = 1
 
+// encryptedStateFileVersion is written instead of currentStateFileVersion
+// when the queue is encrypted. The version is followed by the encrypted
+// version 1 payload.
+const encryptedStateFileVersion = 2
+
+// Size of the version 1 payload: segment id, byte index and frame index.
+const stateFilePayloadSize = 24
+
 // Given an open file handle to the queue state, decode the current position
 // and return the result if successful, otherwise an error.
 func queuePositionFromHandle(
 	file *os.File,
+	cipher *frameCipher,
 ) (queuePosition, error) {
 	_, err := file.Seek(0, 0)
 	if err != nil {
 		return queuePosition{}, err
 	}
 
-	reader := bufio.NewReader(file)
+	var reader io.Reader = bufio.NewReader(file)
 	var version uint32
 	err = binary.Read(reader, binary.LittleEndian, &version)
 	if err != nil {
 		return queuePosition{}, err
 	}
-	if version > currentStateFileVersion {
+	if version > encryptedStateFileVersion {
 		return queuePosition{},
 			fmt.Errorf("unsupported queue metadata version (%d)", version)
 	}
 
+	if version == encryptedStateFileVersion {
+		if cipher == nil {
+			return queuePosition{},
+				errors.New("queue metadata is encrypted but no encryption key is configured")
+		}
+		sealed := make([]byte, stateFilePayloadSize+encryptionOverhead)
+		if _, err := io.ReadFull(reader, sealed); err != nil {
+			return queuePosition{}, err
+		}
+		payload, err := cipher.open(sealed, nil)
+		if err != nil {
+			return queuePosition{}, fmt.Errorf("couldn't decrypt queue metadata: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
 	position := queuePosition{}
 	err = binary.Read(reader, binary.LittleEndian, &position.segmentID)
 	if err != nil {
@@ -70,41 +98,44 @@ func queuePositionFromHandle(
 	return position, nil
 }
 
-func queuePositionFromPath(path string) (queuePosition, error) {
+func queuePositionFromPath(path string, cipher *frameCipher) (queuePosition, error) {
 	// Try to open an existing state file.
 	file, err := os.OpenFile(path, os.O_RDONLY, 0600)
 	if err != nil {
 		return queuePosition{}, err
 	}
 	defer file.Close()
-	return queuePositionFromHandle(file)
+	return queuePositionFromHandle(file, cipher)
 }
 
 // Given the queue position, encode and write it to the given file handle.
+// If cipher is non-nil, the position is encrypted before being written.
 // Returns nil if successful, otherwise an error.
 func writeQueuePositionToHandle(
 	file *os.File,
 	position queuePosition,
+	cipher *frameCipher,
 ) error {
 	_, err := file.Seek(0, 0)
 	if err != nil {
 		return err
 	}
 
-	// Want to write: version (0), segment id, segment offset.
-	err = binary.Write(
-		file, binary.LittleEndian, uint32(currentStateFileVersion))
-	if err != nil {
-		return err
-	}
-	err = binary.Write(file, binary.LittleEndian, position.segmentID)
-	if err != nil {
-		return err
-	}
-	err = binary.Write(file, binary.LittleEndian, position.byteIndex)
-	if err != nil {
-		return err
+	// Want to write: segment id, segment offset, frame index.
+	payload := make([]byte, 0, stateFilePayloadSize)
+	payload = binary.LittleEndian.AppendUint64(payload, uint64(position.segmentID))
+	payload = binary.LittleEndian.AppendUint64(payload, position.byteIndex)
+	payload = binary.LittleEndian.AppendUint64(payload, position.frameIndex)
+
+	version := uint32(currentStateFileVersion)
+	if cipher != nil {
+		version = encryptedStateFileVersion
+		payload = cipher.seal(payload, nil)
 	}
-	err = binary.Write(file, binary.LittleEndian, position.frameIndex)
+
+	// Write the version followed by the payload in a single call, so
+	// that the file is never left with a version that doesn't match.
+	buf := binary.LittleEndian.AppendUint32(nil, version)
+	_, err = file.Write(append(buf, payload...))
 	return err
 }
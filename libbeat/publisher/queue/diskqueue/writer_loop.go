@@ -94,11 +94,16 @@ type writerLoop struct {
 
 	// buffer Used to gather write information so there is only one write syscall
 	buffer *bytes.Buffer
+
+	// The cipher used to encrypt frames, or nil if encryption is not
+	// configured.
+	cipher *frameCipher
 }
 
 func newWriterLoop(
 	logger *logp.Logger,
 	settings Settings,
+	cipher *frameCipher,
 ) *writerLoop {
 	buffer := &bytes.Buffer{}
 	return &writerLoop{
@@ -110,6 +115,7 @@ func newWriterLoop(
 
 		currentRetryInterval: settings.RetryInterval,
 		buffer:               buffer,
+		cipher:               cipher,
 	}
 }
 
@@ -191,16 +197,23 @@ outerLoop:
 		// We have the data and a file to write it to. We are now committed
 		// to writing this block unless the queue is closed in the meantime.
 		frameSize := uint32(frameRequest.frame.sizeOnDisk())
+		data := frameRequest.frame.serialized
+		if frameRequest.frame.encrypted {
+			// Bind the frame to its segment and position, so it can't be
+			// moved without failing authentication.
+			frameIndex := uint64(wl.currentSegment.frameCount + curSegmentResponse.framesWritten)
+			data = wl.cipher.seal(data, frameContext(wl.currentSegment.id, frameIndex))
+		}
 
 		// The Write calls to wl.buffer are for performance
 		// reasons, so all the data can be written with one
 		// Write call to the retryWriter.  err is always nil
 		// for writes to a bytes.Buffer
 		_ = binary.Write(wl.buffer, binary.LittleEndian, frameSize)
-		_, _ = wl.buffer.Write(frameRequest.frame.serialized)
+		_, _ = wl.buffer.Write(data)
 
 		// Compute / write the frame's checksum
-		checksum := computeChecksum(frameRequest.frame.serialized)
+		checksum := computeChecksum(data)
 		_ = binary.Write(wl.buffer, binary.LittleEndian, checksum)
 
 		// Write the frame footer's (duplicate) length
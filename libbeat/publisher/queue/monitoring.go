@@ -38,6 +38,23 @@ type Observer interface {
 	RemoveEvents(eventCount int, byteCount int)
 }
 
+// SpillObserver is implemented by observers that can also track the disk
+// overflow of a queue that keeps events in memory but spills them to disk
+// when the memory buffer is full or the output stalls. The spill counters
+// are reported in addition to the regular Observer counters, which cover
+// all events regardless of where they were stored.
+type SpillObserver interface {
+	// SpillActive reports whether new events are currently written to disk.
+	SpillActive(active bool)
+
+	// SpillRestore reports events found on disk from a previous run.
+	SpillRestore(eventCount int, byteCount int)
+
+	SpillAddEvent(byteCount int)
+	SpillConsumeEvents(eventCount int, byteCount int)
+	SpillRemoveEvents(eventCount int, byteCount int)
+}
+
 type queueObserver struct {
 	maxEvents *monitoring.Uint // gauge
 	maxBytes  *monitoring.Uint // gauge
@@ -59,6 +76,14 @@ type queueObserver struct {
 	// extra variable and make sure to always change removedEvents and
 	// acked at the same time.
 	acked *monitoring.Uint
+
+	spillActive         *monitoring.Bool // gauge
+	spillAddedEvents    *monitoring.Uint
+	spillAddedBytes     *monitoring.Uint
+	spillConsumedEvents *monitoring.Uint
+	spillConsumedBytes  *monitoring.Uint
+	spillFilledEvents   *monitoring.Uint // gauge
+	spillFilledBytes    *monitoring.Uint // gauge
 }
 
 type nilObserver struct{}
@@ -95,6 +120,14 @@ func NewQueueObserver(metrics *monitoring.Registry) Observer {
 
 		// backwards compatibility: "acked" is an alias for "removed.events".
 		acked: monitoring.NewUint(queueMetrics, "acked"),
+
+		spillActive:         monitoring.NewBool(queueMetrics, "spill.active"), // gauge
+		spillAddedEvents:    monitoring.NewUint(queueMetrics, "spill.added.events"),
+		spillAddedBytes:     monitoring.NewUint(queueMetrics, "spill.added.bytes"),
+		spillConsumedEvents: monitoring.NewUint(queueMetrics, "spill.consumed.events"),
+		spillConsumedBytes:  monitoring.NewUint(queueMetrics, "spill.consumed.bytes"),
+		spillFilledEvents:   monitoring.NewUint(queueMetrics, "spill.filled.events"), // gauge
+		spillFilledBytes:    monitoring.NewUint(queueMetrics, "spill.filled.bytes"),  // gauge
 	}
 	return ob
 }
@@ -145,9 +178,42 @@ func (ob *queueObserver) updateFilledPct() {
 	}
 }
 
+func (ob *queueObserver) SpillActive(active bool) {
+	ob.spillActive.Set(active)
+}
+
+func (ob *queueObserver) SpillRestore(eventCount int, byteCount int) {
+	ob.spillFilledEvents.Set(uint64(eventCount))
+	ob.spillFilledBytes.Set(uint64(byteCount))
+}
+
+func (ob *queueObserver) SpillAddEvent(byteCount int) {
+	ob.spillAddedEvents.Inc()
+	ob.spillAddedBytes.Add(uint64(byteCount))
+
+	ob.spillFilledEvents.Inc()
+	ob.spillFilledBytes.Add(uint64(byteCount))
+}
+
+func (ob *queueObserver) SpillConsumeEvents(eventCount int, byteCount int) {
+	ob.spillConsumedEvents.Add(uint64(eventCount))
+	ob.spillConsumedBytes.Add(uint64(byteCount))
+}
+
+func (ob *queueObserver) SpillRemoveEvents(eventCount int, byteCount int) {
+	ob.spillFilledEvents.Sub(uint64(eventCount))
+	ob.spillFilledBytes.Sub(uint64(byteCount))
+}
+
 func (nilObserver) MaxEvents(_ int)            {}
 func (nilObserver) MaxBytes(_ int)             {}
 func (nilObserver) Restore(_ int, _ int)       {}
 func (nilObserver) AddEvent(_ int)             {}
 func (nilObserver) ConsumeEvents(_ int, _ int) {}
 func (nilObserver) RemoveEvents(_ int, _ int)  {}
+
+func (nilObserver) SpillActive(_ bool)              {}
+func (nilObserver) SpillRestore(_ int, _ int)       {}
+func (nilObserver) SpillAddEvent(_ int)             {}
+func (nilObserver) SpillConsumeEvents(_ int, _ int) {}
+func (nilObserver) SpillRemoveEvents(_ int, _ int)  {}
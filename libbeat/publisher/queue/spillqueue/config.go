@@ -0,0 +1,149 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// Settings contains the configuration fields to create a new spill queue.
+type Settings struct {
+	// Memory holds the settings of the in-memory buffer that holds events
+	// in the steady state.
+	Memory memqueue.Settings
+
+	// Disk holds the settings of the disk queue events are spilled to.
+	Disk diskqueue.Settings
+
+	// HighWatermark is the fraction of Memory.Events at which new events
+	// start being written to disk instead of memory.
+	HighWatermark float64
+
+	// LowWatermark is the fraction of Memory.Events the memory buffer must
+	// drop back to before new events are stored in memory again.
+	LowWatermark float64
+
+	// OutputTimeout is how long the output can go without acknowledging
+	// any events held in memory before the queue considers it down and
+	// starts spilling. A value of 0 disables the check.
+	OutputTimeout time.Duration
+}
+
+type userConfig struct {
+	Events        int           `config:"events" validate:"min=32"`
+	MaxGetRequest int           `config:"flush.min_events" validate:"min=0"`
+	FlushTimeout  time.Duration `config:"flush.timeout"`
+
+	Spill spillConfig `config:"spill"`
+	Disk  *config.C   `config:"disk"`
+}
+
+type spillConfig struct {
+	HighWatermark float64       `config:"high_watermark"`
+	LowWatermark  float64       `config:"low_watermark"`
+	OutputTimeout time.Duration `config:"output_timeout" validate:"min=0"`
+}
+
+var defaultConfig = userConfig{
+	Events:        3200,
+	MaxGetRequest: 1600,
+	FlushTimeout:  10 * time.Second,
+
+	Spill: spillConfig{
+		HighWatermark: 0.8,
+		LowWatermark:  0.5,
+		OutputTimeout: 30 * time.Second,
+	},
+}
+
+func (c *userConfig) Validate() error {
+	if c.MaxGetRequest > c.Events {
+		return errors.New("flush.min_events must be less events")
+	}
+	if c.Spill.HighWatermark <= 0 || c.Spill.HighWatermark > 1 {
+		return fmt.Errorf("spill.high_watermark (%v) must be in (0, 1]", c.Spill.HighWatermark)
+	}
+	if c.Spill.LowWatermark < 0 || c.Spill.LowWatermark > c.Spill.HighWatermark {
+		return fmt.Errorf(
+			"spill.low_watermark (%v) must be between 0 and spill.high_watermark (%v)",
+			c.Spill.LowWatermark, c.Spill.HighWatermark)
+	}
+	return nil
+}
+
+// SettingsForUserConfig unpacks a ucfg config from a Beats queue
+// configuration and returns the equivalent spillqueue.Settings object.
+func SettingsForUserConfig(cfg *config.C) (Settings, error) {
+	userConfig := defaultConfig
+	if cfg != nil {
+		if err := cfg.Unpack(&userConfig); err != nil {
+			return Settings{}, fmt.Errorf("couldn't unpack spill queue config: %w", err)
+		}
+	}
+	if userConfig.Disk == nil {
+		return Settings{}, errors.New("spill queue requires a disk section with at least max_size set")
+	}
+
+	disk, err := diskqueue.SettingsForUserConfig(userConfig.Disk)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	return Settings{
+		Memory: memqueue.Settings{
+			Events:        userConfig.Events,
+			MaxGetRequest: userConfig.MaxGetRequest,
+			FlushTimeout:  userConfig.FlushTimeout,
+		},
+		Disk:          disk,
+		HighWatermark: userConfig.Spill.HighWatermark,
+		LowWatermark:  userConfig.Spill.LowWatermark,
+		OutputTimeout: userConfig.Spill.OutputTimeout,
+	}, nil
+}
+
+// diskSettings returns the disk queue settings, defaulting the path to a
+// directory that can't collide with a standalone disk queue.
+func (settings Settings) diskSettings() diskqueue.Settings {
+	disk := settings.Disk
+	if disk.Path == "" {
+		disk.Path = paths.Resolve(paths.Data, "spillqueue")
+	}
+	return disk
+}
+
+// highWatermarkEvents and lowWatermarkEvents convert the watermarks to an
+// event count in the memory buffer.
+func (settings Settings) highWatermarkEvents() int {
+	n := int(settings.HighWatermark * float64(settings.Memory.Events))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func (settings Settings) lowWatermarkEvents() int {
+	return int(settings.LowWatermark * float64(settings.Memory.Events))
+}
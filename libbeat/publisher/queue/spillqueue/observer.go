@@ -0,0 +1,128 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+)
+
+// memoryObserver forwards the metrics of the memory queue to the spill
+// queue's observer while tracking how full the memory buffer is and when
+// the output last made progress, which drive the decision to spill.
+type memoryObserver struct {
+	queue.Observer
+
+	mutex sync.Mutex
+	// The number of events in the memory queue, including events that
+	// were handed to the output but haven't been acknowledged yet.
+	filledEvents int
+	// The last time the output acknowledged events, or the time the first
+	// event was added to an empty buffer.
+	lastProgress time.Time
+}
+
+func newMemoryObserver(observer queue.Observer) *memoryObserver {
+	return &memoryObserver{Observer: observer, lastProgress: time.Now()}
+}
+
+// The spill queue reports its own buffer size.
+func (ob *memoryObserver) MaxEvents(_ int) {}
+func (ob *memoryObserver) MaxBytes(_ int)  {}
+
+func (ob *memoryObserver) AddEvent(byteCount int) {
+	ob.mutex.Lock()
+	if ob.filledEvents == 0 {
+		ob.lastProgress = time.Now()
+	}
+	ob.filledEvents++
+	ob.mutex.Unlock()
+	ob.Observer.AddEvent(byteCount)
+}
+
+func (ob *memoryObserver) RemoveEvents(eventCount int, byteCount int) {
+	ob.mutex.Lock()
+	ob.filledEvents -= eventCount
+	ob.lastProgress = time.Now()
+	ob.mutex.Unlock()
+	ob.Observer.RemoveEvents(eventCount, byteCount)
+}
+
+func (ob *memoryObserver) filled() int {
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+	return ob.filledEvents
+}
+
+// stalled returns true if the memory buffer holds events and none of them
+// was acknowledged within the given timeout.
+func (ob *memoryObserver) stalled(timeout time.Duration) bool {
+	if timeout <= 0 {
+		return false
+	}
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+	return ob.filledEvents > 0 && time.Since(ob.lastProgress) > timeout
+}
+
+// diskObserver forwards the metrics of the disk queue to the spill queue's
+// observer and reports them as spill metrics as well.
+type diskObserver struct {
+	queue.Observer
+	spill queue.SpillObserver
+}
+
+func newDiskObserver(observer queue.Observer, spill queue.SpillObserver) *diskObserver {
+	return &diskObserver{Observer: observer, spill: spill}
+}
+
+// The spill queue reports its own buffer size.
+func (ob *diskObserver) MaxEvents(_ int) {}
+func (ob *diskObserver) MaxBytes(_ int)  {}
+
+func (ob *diskObserver) Restore(eventCount int, byteCount int) {
+	ob.Observer.Restore(eventCount, byteCount)
+	ob.spill.SpillRestore(eventCount, byteCount)
+}
+
+func (ob *diskObserver) AddEvent(byteCount int) {
+	ob.Observer.AddEvent(byteCount)
+	ob.spill.SpillAddEvent(byteCount)
+}
+
+func (ob *diskObserver) ConsumeEvents(eventCount int, byteCount int) {
+	ob.Observer.ConsumeEvents(eventCount, byteCount)
+	ob.spill.SpillConsumeEvents(eventCount, byteCount)
+}
+
+func (ob *diskObserver) RemoveEvents(eventCount int, byteCount int) {
+	ob.Observer.RemoveEvents(eventCount, byteCount)
+	ob.spill.SpillRemoveEvents(eventCount, byteCount)
+}
+
+// nilSpillObserver is used when the spill queue's observer doesn't track
+// spill metrics.
+type nilSpillObserver struct{}
+
+func (nilSpillObserver) SpillActive(_ bool)              {}
+func (nilSpillObserver) SpillRestore(_ int, _ int)       {}
+func (nilSpillObserver) SpillAddEvent(_ int)             {}
+func (nilSpillObserver) SpillConsumeEvents(_ int, _ int) {}
+func (nilSpillObserver) SpillRemoveEvents(_ int, _ int)  {}
@@ -0,0 +1,143 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+)
+
+// producer routes each event to the memory or the disk queue depending on
+// the queue's spill state. Both queues acknowledge events in the order
+// they were published to them, but the disk queue does so as soon as an
+// event is written while the memory queue waits for the output. To keep
+// acknowledgments in publish order for the pipeline, ACKs from either
+// queue are held until all earlier events were acknowledged as well.
+type producer struct {
+	queue  *spillQueue
+	memory queue.Producer
+	disk   queue.Producer
+
+	ackCallback func(count int)
+
+	mutex sync.Mutex
+	// runs lists consecutive events published to the same queue that
+	// haven't been acknowledged yet, oldest first.
+	runs      []publishRun
+	memACKed  int
+	diskACKed int
+}
+
+type publishRun struct {
+	disk  bool
+	count int
+}
+
+func newProducer(q *spillQueue, cfg queue.ProducerConfig) *producer {
+	p := &producer{queue: q, ackCallback: cfg.ACK}
+	var memCfg, diskCfg queue.ProducerConfig
+	if cfg.ACK != nil {
+		memCfg.ACK = func(count int) { p.handleACK(false, count) }
+		diskCfg.ACK = func(count int) { p.handleACK(true, count) }
+	}
+	p.memory = q.memory.Producer(memCfg)
+	p.disk = q.disk.Producer(diskCfg)
+	return p
+}
+
+func (p *producer) Publish(entry queue.Entry) (queue.EntryID, bool) {
+	if p.queue.shouldSpill() {
+		id, ok := p.disk.Publish(entry)
+		p.handlePublish(true, ok)
+		return id, ok
+	}
+	id, ok := p.memory.Publish(entry)
+	p.handlePublish(false, ok)
+	return id, ok
+}
+
+func (p *producer) TryPublish(entry queue.Entry) (queue.EntryID, bool) {
+	if p.queue.shouldSpill() {
+		id, ok := p.disk.TryPublish(entry)
+		p.handlePublish(true, ok)
+		return id, ok
+	}
+	id, ok := p.memory.TryPublish(entry)
+	p.handlePublish(false, ok)
+	return id, ok
+}
+
+func (p *producer) Close() {
+	p.memory.Close()
+	p.disk.Close()
+}
+
+func (p *producer) handlePublish(disk bool, ok bool) {
+	if !ok || p.ackCallback == nil {
+		return
+	}
+	p.mutex.Lock()
+	if n := len(p.runs); n > 0 && p.runs[n-1].disk == disk {
+		p.runs[n-1].count++
+	} else {
+		p.runs = append(p.runs, publishRun{disk: disk, count: 1})
+	}
+	// The inner queue may have acknowledged the event before we got here.
+	p.releaseACKs()
+	p.mutex.Unlock()
+}
+
+func (p *producer) handleACK(disk bool, count int) {
+	p.mutex.Lock()
+	if disk {
+		p.diskACKed += count
+	} else {
+		p.memACKed += count
+	}
+	p.releaseACKs()
+	p.mutex.Unlock()
+}
+
+// releaseACKs removes acknowledged events from the front of the run list
+// and reports them to the pipeline. It must be called with the mutex held,
+// which also keeps the callback from being invoked concurrently.
+func (p *producer) releaseACKs() {
+	released := 0
+	for len(p.runs) > 0 {
+		run := &p.runs[0]
+		acked := &p.memACKed
+		if run.disk {
+			acked = &p.diskACKed
+		}
+		n := min(run.count, *acked)
+		if n == 0 {
+			break
+		}
+		run.count -= n
+		*acked -= n
+		released += n
+		if run.count > 0 {
+			break
+		}
+		p.runs = p.runs[1:]
+	}
+	if released > 0 {
+		p.ackCallback(released)
+	}
+}
@@ -0,0 +1,229 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// The string used to specify this queue in beats configurations.
+const QueueType = "spill"
+
+// spillQueue keeps events in a memory queue in the steady state and
+// writes them to a disk queue instead when the memory buffer crosses its
+// high watermark or the output stops acknowledging events. Consumers are
+// always served from the disk queue first, so a backlog from an outage or
+// a previous run is drained before the memory buffer.
+type spillQueue struct {
+	logger   *logp.Logger
+	settings Settings
+
+	memory queue.Queue
+	disk   queue.Queue
+
+	memoryObserver *memoryObserver
+	spillObserver  queue.SpillObserver
+
+	// spilling is true while new events are routed to the disk queue.
+	spillLock sync.Mutex
+	spilling  bool
+
+	// The batch size most recently requested by a consumer, used by the
+	// readers that pull batches from the inner queues.
+	getCount    atomic.Int64
+	memBatches  chan queue.Batch
+	diskBatches chan queue.Batch
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+}
+
+// FactoryForSettings is a simple wrapper around NewQueue so a concrete
+// Settings object can be wrapped in a queue-agnostic interface.
+func FactoryForSettings(settings Settings) queue.QueueFactory {
+	return func(
+		logger *logp.Logger,
+		observer queue.Observer,
+		inputQueueSize int,
+		encoderFactory queue.EncoderFactory,
+	) (queue.Queue, error) {
+		return NewQueue(logger, observer, settings, inputQueueSize, encoderFactory)
+	}
+}
+
+// NewQueue returns a spill queue configured with the given settings. The
+// disk queue is opened right away so events left from a previous run are
+// delivered before anything new.
+func NewQueue(
+	logger *logp.Logger,
+	observer queue.Observer,
+	settings Settings,
+	inputQueueSize int,
+	encoderFactory queue.EncoderFactory,
+) (*spillQueue, error) {
+	if logger == nil {
+		logger = logp.NewLogger("spillqueue")
+	} else {
+		logger = logger.Named("spillqueue")
+	}
+	if observer == nil {
+		observer = queue.NewQueueObserver(nil)
+	}
+	spillObserver, ok := observer.(queue.SpillObserver)
+	if !ok {
+		spillObserver = nilSpillObserver{}
+	}
+
+	q := &spillQueue{
+		logger:        logger,
+		settings:      settings,
+		spillObserver: spillObserver,
+		memBatches:    make(chan queue.Batch),
+		diskBatches:   make(chan queue.Batch),
+		closing:       make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	q.memoryObserver = newMemoryObserver(observer)
+
+	disk, err := diskqueue.NewQueue(
+		logger, newDiskObserver(observer, spillObserver), settings.diskSettings(), encoderFactory)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open spill queue disk storage: %w", err)
+	}
+	q.disk = disk
+	q.memory = memqueue.NewQueue(
+		logger, q.memoryObserver, settings.Memory, inputQueueSize, encoderFactory)
+
+	observer.MaxEvents(settings.Memory.Events)
+	spillObserver.SpillActive(false)
+
+	// Batches are read ahead from both queues so Get can tell whether the
+	// disk queue has anything to deliver without blocking on it.
+	q.getCount.Store(int64(settings.Memory.MaxGetRequest))
+	go q.readBatches(q.disk, q.diskBatches)
+	go q.readBatches(q.memory, q.memBatches)
+
+	go func() {
+		<-q.memory.Done()
+		<-q.disk.Done()
+		close(q.done)
+	}()
+
+	return q, nil
+}
+
+func (q *spillQueue) Close() error {
+	q.closeOnce.Do(func() {
+		close(q.closing)
+	})
+	memErr := q.memory.Close()
+	diskErr := q.disk.Close()
+	if memErr != nil {
+		return memErr
+	}
+	return diskErr
+}
+
+func (q *spillQueue) Done() <-chan struct{} {
+	return q.done
+}
+
+func (q *spillQueue) QueueType() string {
+	return QueueType
+}
+
+func (q *spillQueue) BufferConfig() queue.BufferConfig {
+	// Like the disk queue, the spill queue doesn't have a fixed limit on
+	// the number of events it can hold.
+	return queue.BufferConfig{MaxEvents: 0}
+}
+
+func (q *spillQueue) Producer(cfg queue.ProducerConfig) queue.Producer {
+	return newProducer(q, cfg)
+}
+
+// Get returns a batch from the disk queue if one is ready, and otherwise
+// waits for whichever of the two queues produces a batch first.
+func (q *spillQueue) Get(eventCount int) (queue.Batch, error) {
+	q.getCount.Store(int64(eventCount))
+
+	select {
+	case batch := <-q.diskBatches:
+		return batch, nil
+	default:
+	}
+
+	select {
+	case batch := <-q.diskBatches:
+		return batch, nil
+	case batch := <-q.memBatches:
+		return batch, nil
+	case <-q.closing:
+		return nil, io.EOF
+	}
+}
+
+// readBatches forwards batches from one of the inner queues to Get until
+// the queue is closed.
+func (q *spillQueue) readBatches(source queue.Queue, out chan<- queue.Batch) {
+	for {
+		batch, err := source.Get(int(q.getCount.Load()))
+		if err != nil {
+			return
+		}
+		select {
+		case out <- batch:
+		case <-q.closing:
+			return
+		}
+	}
+}
+
+// shouldSpill reports whether the next event should be written to disk,
+// updating the spill state if the memory buffer or the output state
+// crossed one of the thresholds.
+func (q *spillQueue) shouldSpill() bool {
+	filled := q.memoryObserver.filled()
+	stalled := q.memoryObserver.stalled(q.settings.OutputTimeout)
+
+	q.spillLock.Lock()
+	defer q.spillLock.Unlock()
+	if !q.spilling && (filled >= q.settings.highWatermarkEvents() || stalled) {
+		q.spilling = true
+		q.spillObserver.SpillActive(true)
+		if stalled {
+			q.logger.Infof("Output hasn't acknowledged events in %v, spilling new events to disk", q.settings.OutputTimeout)
+		} else {
+			q.logger.Infof("Memory buffer holds %d events, spilling new events to disk", filled)
+		}
+	} else if q.spilling && filled <= q.settings.lowWatermarkEvents() && !stalled {
+		q.spilling = false
+		q.spillObserver.SpillActive(false)
+		q.logger.Infof("Memory buffer drained to %d events, storing new events in memory", filled)
+	}
+	return q.spilling
+}
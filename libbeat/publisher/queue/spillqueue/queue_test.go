@@ -0,0 +1,259 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/queuetest"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+func TestProduceConsumer(t *testing.T) {
+	events := 1024
+	batchSize := 64
+
+	factory := func(t *testing.T) queue.Queue {
+		q, err := NewQueue(logptest.NewTestingLogger(t, ""), nil, testSettings(t, 64), 0, nil)
+		require.NoError(t, err)
+		return q
+	}
+
+	t.Run("single", func(t *testing.T) {
+		queuetest.TestSingleProducerConsumer(t, events, batchSize, factory)
+	})
+	t.Run("multi", func(t *testing.T) {
+		queuetest.TestMultiProducerConsumer(t, events, batchSize, factory)
+	})
+}
+
+func TestSpillAboveHighWatermark(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	q, err := NewQueue(logptest.NewTestingLogger(t, ""), queue.NewQueueObserver(reg), testSettings(t, 8), 0, nil)
+	require.NoError(t, err)
+	defer q.Close()
+
+	producer := q.Producer(queue.ProducerConfig{})
+	for i := 0; i < 10; i++ {
+		_, ok := producer.Publish(testEvent(i))
+		require.True(t, ok)
+	}
+
+	// With a high watermark of 4 events, the first 4 events stay in memory
+	// and the rest is written to disk.
+	require.Eventually(t, func() bool {
+		return metricValue(t, reg, "queue.spill.added.events") == int64(6)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, true, metricValue(t, reg, "queue.spill.active"))
+	assert.EqualValues(t, 10, metricValue(t, reg, "queue.added.events"))
+
+	// Wait until the disk queue has a batch ready, which must be delivered
+	// before the events held in memory.
+	require.Eventually(t, func() bool {
+		return metricValue(t, reg, "queue.spill.consumed.events").(int64) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	batch, err := q.Get(10)
+	require.NoError(t, err)
+	assert.Equal(t, 4, testEventIndex(t, batch.Entry(0)), "first batch should come from disk")
+
+	received := batch.Count()
+	batch.Done()
+	for received < 10 {
+		batch, err := q.Get(10)
+		require.NoError(t, err)
+		received += batch.Count()
+		batch.Done()
+	}
+
+	assert.EqualValues(t, 6, metricValue(t, reg, "queue.spill.consumed.events"))
+	require.Eventually(t, func() bool {
+		return q.memoryObserver.filled() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Once the memory buffer is below the low watermark, new events are
+	// stored in memory again.
+	_, ok := producer.Publish(testEvent(10))
+	require.True(t, ok)
+	assert.Equal(t, false, metricValue(t, reg, "queue.spill.active"))
+	assert.EqualValues(t, 6, metricValue(t, reg, "queue.spill.added.events"))
+}
+
+func TestSpillWhenOutputStalls(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	settings := testSettings(t, 64)
+	settings.OutputTimeout = 50 * time.Millisecond
+	q, err := NewQueue(logptest.NewTestingLogger(t, ""), queue.NewQueueObserver(reg), settings, 0, nil)
+	require.NoError(t, err)
+	defer q.Close()
+
+	producer := q.Producer(queue.ProducerConfig{})
+	_, ok := producer.Publish(testEvent(0))
+	require.True(t, ok)
+	assert.Equal(t, false, metricValue(t, reg, "queue.spill.active"))
+
+	time.Sleep(100 * time.Millisecond)
+	_, ok = producer.Publish(testEvent(1))
+	require.True(t, ok)
+	assert.Equal(t, true, metricValue(t, reg, "queue.spill.active"))
+}
+
+func TestACKsInPublishOrder(t *testing.T) {
+	q, err := NewQueue(logptest.NewTestingLogger(t, ""), nil, testSettings(t, 8), 0, nil)
+	require.NoError(t, err)
+	defer q.Close()
+
+	var acked atomic.Int64
+	producer := q.Producer(queue.ProducerConfig{
+		ACK: func(count int) { acked.Add(int64(count)) },
+	})
+	for i := 0; i < 6; i++ {
+		_, ok := producer.Publish(testEvent(i))
+		require.True(t, ok)
+	}
+
+	// The disk queue acknowledges the last 2 events once they're written,
+	// but that must not be reported before the events held in memory.
+	time.Sleep(200 * time.Millisecond)
+	assert.Zero(t, acked.Load())
+
+	received := 0
+	for received < 6 {
+		batch, err := q.Get(6)
+		require.NoError(t, err)
+		received += batch.Count()
+		batch.Done()
+	}
+	require.Eventually(t, func() bool {
+		return acked.Load() == 6
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDrainsDiskFromPreviousRun(t *testing.T) {
+	settings := testSettings(t, 8)
+
+	q, err := NewQueue(logptest.NewTestingLogger(t, ""), nil, settings, 0, nil)
+	require.NoError(t, err)
+	producer := newProducer(q, queue.ProducerConfig{ACK: func(int) {}})
+	for i := 0; i < 6; i++ {
+		_, ok := producer.Publish(testEvent(i))
+		require.True(t, ok)
+	}
+	// Wait for the disk queue to write its events. The events held in
+	// memory are never acknowledged, so the disk ACKs stay pending.
+	require.Eventually(t, func() bool {
+		producer.mutex.Lock()
+		defer producer.mutex.Unlock()
+		return producer.diskACKed == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, q.Close())
+
+	q, err = NewQueue(logptest.NewTestingLogger(t, ""), nil, settings, 0, nil)
+	require.NoError(t, err)
+	defer q.Close()
+
+	batch, err := q.Get(10)
+	require.NoError(t, err)
+	require.Equal(t, 2, batch.Count())
+	assert.Equal(t, 4, testEventIndex(t, batch.Entry(0)))
+	assert.Equal(t, 5, testEventIndex(t, batch.Entry(1)))
+	batch.Done()
+}
+
+func TestSettingsForUserConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg := config.MustNewConfigFrom(mapstr.M{"disk.max_size": "1GB"})
+		settings, err := SettingsForUserConfig(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, 3200, settings.Memory.Events)
+		assert.Equal(t, 0.8, settings.HighWatermark)
+		assert.Equal(t, 0.5, settings.LowWatermark)
+		assert.Equal(t, 30*time.Second, settings.OutputTimeout)
+		assert.Equal(t, uint64(1000*1000*1000), settings.Disk.MaxBufferSize)
+	})
+
+	t.Run("disk section is required", func(t *testing.T) {
+		_, err := SettingsForUserConfig(config.MustNewConfigFrom(mapstr.M{"events": 64}))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid watermarks", func(t *testing.T) {
+		cfg := config.MustNewConfigFrom(mapstr.M{
+			"disk.max_size":        "1GB",
+			"spill.high_watermark": 0.5,
+			"spill.low_watermark":  0.6,
+		})
+		_, err := SettingsForUserConfig(cfg)
+		assert.Error(t, err)
+	})
+}
+
+func testSettings(t *testing.T, events int) Settings {
+	disk := diskqueue.DefaultSettings()
+	disk.Path = t.TempDir()
+	return Settings{
+		Memory: memqueue.Settings{
+			Events:        events,
+			MaxGetRequest: events / 2,
+			FlushTimeout:  10 * time.Millisecond,
+		},
+		Disk:          disk,
+		HighWatermark: 0.5,
+		LowWatermark:  0.25,
+	}
+}
+
+func testEvent(i int) queue.Entry {
+	return queuetest.MakeEvent(mapstr.M{"message": strconv.Itoa(i)})
+}
+
+func testEventIndex(t *testing.T, entry queue.Entry) int {
+	event, ok := entry.(publisher.Event)
+	require.True(t, ok)
+	value, err := event.Content.Fields.GetValue("message")
+	require.NoError(t, err)
+	i, err := strconv.Atoi(value.(string))
+	require.NoError(t, err)
+	return i
+}
+
+func metricValue(t *testing.T, reg *monitoring.Registry, name string) interface{} {
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	if v, ok := snapshot.Ints[name]; ok {
+		return v
+	}
+	if v, ok := snapshot.Bools[name]; ok {
+		return v
+	}
+	t.Fatalf("metric %s not found", name)
+	return nil
+}
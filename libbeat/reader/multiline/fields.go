@@ -0,0 +1,252 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package multiline
+
+import (
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/beats/v7/libbeat/reader/readfile"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// fieldsReader combines structured events, like the events decoded by the
+// ndjson parser, into one multiline event.
+//
+// An event is added to the current multiline event if the values of the
+// group_by fields are the same as in the first event and if its message field
+// matches the pattern. The message fields are concatenated, the other fields
+// are taken from the first event.
+//
+// Errors will force the multiline reader to return the currently active
+// multiline event first and finally return the actual error on next call to Next.
+type fieldsReader struct {
+	reader       reader.Reader
+	matcher      lineMatcherFunc
+	groupBy      []string
+	messageField string
+	separator    []byte
+	skipNewline  bool
+	maxBytes     int
+	maxLines     int
+	logger       *logp.Logger
+
+	// message is the current multiline event, text its message field if
+	// the message field of the first event is a string.
+	message   reader.Message
+	text      []byte
+	hasText   bool
+	numLines  int
+	truncated int
+
+	// pending is the event and error returned by the next call to Next.
+	pending    reader.Message
+	pendingErr error
+	closed     bool
+}
+
+func newMultilineFieldsReader(
+	r reader.Reader,
+	separator string,
+	maxBytes int,
+	config *Config,
+) (reader.Reader, error) {
+	maxLines := defaultMaxLines
+	if config.MaxLines != nil {
+		maxLines = *config.MaxLines
+	}
+
+	tout := defaultMultilineTimeout
+	if config.Timeout != nil {
+		tout = *config.Timeout
+	}
+
+	if tout > 0 {
+		r = readfile.NewTimeoutReader(r, sigMultilineTimeout, tout)
+	}
+
+	matcherFunc := lineMatcher(*config.Pattern)
+	if config.Negate {
+		matcherFunc = negatedLineMatcher(matcherFunc)
+	}
+
+	return &fieldsReader{
+		reader:       r,
+		matcher:      matcherFunc,
+		groupBy:      config.GroupBy,
+		messageField: config.messageField(),
+		separator:    []byte(separator),
+		skipNewline:  config.SkipNewLine,
+		maxBytes:     maxBytes,
+		maxLines:     maxLines,
+		logger:       logp.NewLogger("reader_multiline"),
+	}, nil
+}
+
+// Next returns next multi-line event.
+func (fr *fieldsReader) Next() (reader.Message, error) {
+	if fr.closed {
+		return reader.Message{}, io.EOF
+	}
+	if fr.pendingErr != nil {
+		message, err := fr.pending, fr.pendingErr
+		fr.pending, fr.pendingErr = reader.Message{}, nil
+		return message, err
+	}
+
+	for {
+		message, err := fr.reader.Next()
+		if err != nil {
+			if errors.Is(err, sigMultilineTimeout) {
+				// no events buffered -> ignore timeout
+				if fr.isEmpty() {
+					continue
+				}
+				fr.logger.Debug("Multiline event flushed because timeout reached.")
+				return fr.finalize(), nil
+			}
+
+			if fr.isEmpty() {
+				return message, err
+			}
+
+			// return the multiline event and the error on next read
+			if message.Bytes > 0 && fr.continues(message) {
+				fr.add(message)
+				message = reader.Message{}
+			}
+			fr.pending, fr.pendingErr = message, err
+			return fr.finalize(), nil
+		}
+
+		if message.Bytes == 0 {
+			continue
+		}
+
+		if fr.isEmpty() {
+			fr.start(message)
+			continue
+		}
+
+		if fr.continues(message) {
+			fr.add(message)
+			continue
+		}
+
+		msg := fr.finalize()
+		fr.start(message)
+		return msg, nil
+	}
+}
+
+// continues reports if an event is added to the current multiline event.
+func (fr *fieldsReader) continues(message reader.Message) bool {
+	if !fr.hasText {
+		return false
+	}
+	for _, field := range fr.groupBy {
+		first, _ := fr.message.Fields.GetValue(field)
+		value, _ := message.Fields.GetValue(field)
+		if !reflect.DeepEqual(first, value) {
+			return false
+		}
+	}
+
+	text, ok := fr.textOf(message)
+	return ok && fr.matcher(text)
+}
+
+// textOf returns the message field of an event.
+func (fr *fieldsReader) textOf(message reader.Message) ([]byte, bool) {
+	value, err := message.Fields.GetValue(fr.messageField)
+	if err != nil {
+		return nil, false
+	}
+	text, ok := value.(string)
+	return []byte(text), ok
+}
+
+func (fr *fieldsReader) start(message reader.Message) {
+	fr.message = message
+	fr.text, fr.hasText = fr.textOf(message)
+	fr.numLines = 1
+	fr.truncated = 0
+}
+
+// add adds the message field and content of an event to the multiline
+// event, up to the maximum number of bytes and lines.
+func (fr *fieldsReader) add(message reader.Message) {
+	fr.message.Bytes += message.Bytes
+
+	text, _ := fr.textOf(message)
+	if fr.numLines >= fr.maxLines {
+		fr.truncated += len(text)
+		return
+	}
+	fr.numLines++
+
+	var truncated int
+	fr.text, truncated = fr.appendLimited(fr.text, text)
+	fr.truncated += truncated
+	if len(fr.message.Content) > 0 {
+		fr.message.Content, _ = fr.appendLimited(fr.message.Content, message.Content)
+	}
+}
+
+// appendLimited appends a line to buf, truncated to the maximum number of
+// bytes. It returns the number of bytes truncated.
+func (fr *fieldsReader) appendLimited(buf, line []byte) ([]byte, int) {
+	if len(buf) > 0 && !fr.skipNewline {
+		line = append(fr.separator[:len(fr.separator):len(fr.separator)], line...)
+	}
+	if fr.maxBytes <= 0 || len(buf)+len(line) <= fr.maxBytes {
+		return append(buf, line...), 0
+	}
+	space := max(fr.maxBytes-len(buf), 0)
+	return append(buf, line[:space]...), len(line) - space
+}
+
+// finalize returns the current multiline event and resets the reader.
+func (fr *fieldsReader) finalize() reader.Message {
+	msg := fr.message
+	if fr.numLines > 1 {
+		msg.Fields.Put(fr.messageField, string(fr.text)) //nolint:errcheck // It is safe to ignore the error.
+		msg.AddFlagsWithKey("log.flags", "multiline")    //nolint:errcheck // It is safe to ignore the error.
+	}
+	if fr.truncated > 0 {
+		msg.AddFlagsWithKey("log.flags", "truncated") //nolint:errcheck // It is safe to ignore the error.
+	}
+
+	fr.message = reader.Message{}
+	fr.text = nil
+	fr.hasText = false
+	fr.numLines = 0
+	fr.truncated = 0
+	return msg
+}
+
+func (fr *fieldsReader) isEmpty() bool {
+	return fr.numLines == 0
+}
+
+func (fr *fieldsReader) Close() error {
+	fr.closed = true
+	return fr.reader.Close()
+}
@@ -0,0 +1,185 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package multiline
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/common/match"
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// messagesReader returns messages and then an error.
+type messagesReader struct {
+	messages []reader.Message
+	err      error
+}
+
+func (r *messagesReader) Next() (reader.Message, error) {
+	if len(r.messages) == 0 {
+		return reader.Message{}, r.err
+	}
+	m := r.messages[0]
+	r.messages = r.messages[1:]
+	return m, nil
+}
+
+func (r *messagesReader) Close() error { return nil }
+
+func fieldsMessage(logger, text string) reader.Message {
+	return reader.Message{
+		Bytes: 10,
+		Fields: mapstr.M{
+			"log":     mapstr.M{"logger": logger},
+			"message": text,
+		},
+	}
+}
+
+func readAllMessages(t *testing.T, r reader.Reader) []reader.Message {
+	t.Helper()
+	var messages []reader.Message
+	for {
+		message, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return messages
+		}
+		require.NoError(t, err)
+		messages = append(messages, message)
+	}
+}
+
+func TestMultilineFields(t *testing.T) {
+	pattern := match.MustCompile(`^(\s|Caused by:)`)
+	var noTimeout time.Duration
+
+	newReader := func(t *testing.T, maxBytes int, maxLines *int, messages ...reader.Message) reader.Reader {
+		r, err := New(&messagesReader{messages: messages, err: io.EOF}, "\n", maxBytes, &Config{
+			Type:     fieldsMode,
+			Pattern:  &pattern,
+			GroupBy:  []string{"log.logger"},
+			MaxLines: maxLines,
+			Timeout:  &noTimeout,
+		})
+		require.NoError(t, err)
+		return r
+	}
+
+	t.Run("events are grouped by field and pattern", func(t *testing.T) {
+		r := newReader(t, 0, nil,
+			fieldsMessage("app", "java.lang.Exception: failed"),
+			fieldsMessage("app", "\tat Main.main(Main.java:10)"),
+			fieldsMessage("other", "\tat interleaved"),
+			fieldsMessage("app", "\tat Main.run(Main.java:20)"),
+			fieldsMessage("app", "started"),
+		)
+
+		messages := readAllMessages(t, r)
+		require.Len(t, messages, 4)
+
+		require.Equal(t, "java.lang.Exception: failed\n\tat Main.main(Main.java:10)", messages[0].Fields["message"])
+		require.Equal(t, 20, messages[0].Bytes)
+		flags, _ := messages[0].Fields.GetValue("log.flags")
+		require.Equal(t, []string{"multiline"}, flags)
+		logger, _ := messages[0].Fields.GetValue("log.logger")
+		require.Equal(t, "app", logger)
+
+		require.Equal(t, "\tat interleaved", messages[1].Fields["message"])
+		require.Equal(t, "\tat Main.run(Main.java:20)", messages[2].Fields["message"])
+		require.Equal(t, "started", messages[3].Fields["message"])
+		_, err := messages[3].Fields.GetValue("log.flags")
+		require.Error(t, err, "single events must not be flagged")
+	})
+
+	t.Run("first event fields are kept", func(t *testing.T) {
+		first := fieldsMessage("app", "failed")
+		first.Fields["level"] = "error"
+		next := fieldsMessage("app", " cause")
+		next.Fields["level"] = "debug"
+		next.Fields["extra"] = true
+
+		messages := readAllMessages(t, newReader(t, 0, nil, first, next))
+		require.Len(t, messages, 1)
+		require.Equal(t, "error", messages[0].Fields["level"])
+		require.NotContains(t, messages[0].Fields, "extra")
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		maxLines := 2
+		messages := readAllMessages(t, newReader(t, 0, &maxLines,
+			fieldsMessage("app", "failed"),
+			fieldsMessage("app", " at 1"),
+			fieldsMessage("app", " at 2"),
+		))
+		require.Len(t, messages, 1)
+		require.Equal(t, "failed\n at 1", messages[0].Fields["message"])
+		require.Equal(t, 30, messages[0].Bytes)
+		flags, _ := messages[0].Fields.GetValue("log.flags")
+		require.Equal(t, []string{"multiline", "truncated"}, flags)
+
+		messages = readAllMessages(t, newReader(t, 10, nil,
+			fieldsMessage("app", "failed"),
+			fieldsMessage("app", " at 1"),
+		))
+		require.Len(t, messages, 1)
+		require.Equal(t, "failed\n at", messages[0].Fields["message"])
+	})
+
+	t.Run("content is concatenated", func(t *testing.T) {
+		first := fieldsMessage("app", "failed")
+		first.Content = []byte("failed")
+		next := fieldsMessage("app", " cause")
+		next.Content = []byte(" cause")
+
+		messages := readAllMessages(t, newReader(t, 0, nil, first, next))
+		require.Len(t, messages, 1)
+		require.Equal(t, "failed\n cause", string(messages[0].Content))
+	})
+
+	t.Run("message field is not a string", func(t *testing.T) {
+		first := fieldsMessage("app", "")
+		first.Fields["message"] = 42
+
+		messages := readAllMessages(t, newReader(t, 0, nil, first, fieldsMessage("app", " cause")))
+		require.Len(t, messages, 2)
+	})
+
+	t.Run("error is returned after the buffered event", func(t *testing.T) {
+		readErr := errors.New("read error")
+		r, err := New(&messagesReader{
+			messages: []reader.Message{fieldsMessage("app", "failed"), fieldsMessage("app", " cause")},
+			err:      readErr,
+		}, "\n", 0, &Config{Type: fieldsMode, Pattern: &pattern, Timeout: &noTimeout})
+		require.NoError(t, err)
+
+		message, err := r.Next()
+		require.NoError(t, err)
+		require.Equal(t, "failed\n cause", message.Fields["message"])
+
+		_, err = r.Next()
+		require.ErrorIs(t, err, readErr)
+	})
+}
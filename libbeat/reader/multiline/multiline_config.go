@@ -20,6 +20,7 @@ package multiline
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/elastic/beats/v7/libbeat/common/match"
@@ -31,10 +32,12 @@ const (
 	patternMode multilineType = iota
 	countMode
 	whilePatternMode
+	fieldsMode
 
 	patternStr      = "pattern"
 	countStr        = "count"
 	whilePatternStr = "while_pattern"
+	fieldsStr       = "fields"
 )
 
 var (
@@ -42,6 +45,7 @@ var (
 		patternStr:      patternMode,
 		countStr:        countMode,
 		whilePatternStr: whilePatternMode,
+		fieldsStr:       fieldsMode,
 	}
 
 	ErrMissingPattern = errors.New("multiline.pattern cannot be empty when pattern based matching is selected")
@@ -61,6 +65,13 @@ type Config struct {
 
 	LinesCount  int  `config:"count_lines" validate:"positive"`
 	SkipNewLine bool `config:"skip_newline"`
+
+	// GroupBy are the fields that must be equal in all the events of a
+	// multiline event, in fields mode.
+	GroupBy []string `config:"group_by"`
+	// MessageField is the field the pattern is matched against and that is
+	// concatenated, in fields mode.
+	MessageField string `config:"message_field"`
 }
 
 // Validate validates the Config option for multiline reader.
@@ -80,12 +91,33 @@ func (c *Config) Validate() error {
 		if c.Pattern == nil {
 			return ErrMissingPattern
 		}
+	} else if c.Type == fieldsMode {
+		if c.Pattern == nil {
+			return ErrMissingPattern
+		}
+		if slices.Contains(c.GroupBy, c.messageField()) {
+			return fmt.Errorf("multiline.group_by cannot contain the message field %s", c.messageField())
+		}
 	} else {
 		return fmt.Errorf("unknown multiline type %d", c.Type)
 	}
 	return nil
 }
 
+// CombinesFields reports whether the multiline reader combines decoded
+// events by their fields, which requires a reader of structured events.
+func (c *Config) CombinesFields() bool {
+	return c.Type == fieldsMode
+}
+
+// messageField returns the message field of the fields mode.
+func (c *Config) messageField() string {
+	if c.MessageField == "" {
+		return "message"
+	}
+	return c.MessageField
+}
+
 // Unpack selects the approriate aggregation method for creating multiline events.
 // If it is not configured pattern matching is chosen.
 func (m *multilineType) Unpack(value string) error {
@@ -55,6 +55,21 @@ func TestInvalidConfiguration(t *testing.T) {
 			},
 			expectedError: ErrMissingPattern,
 		},
+		"missing multiline pattern when fields type is selected": {
+			config: map[string]interface{}{
+				"type":     "fields",
+				"group_by": []string{"log.logger"},
+			},
+			expectedError: ErrMissingPattern,
+		},
+		"message field in group_by fields": {
+			config: map[string]interface{}{
+				"type":     "fields",
+				"pattern":  "^\\s",
+				"group_by": []string{"log.logger", "message"},
+			},
+			expectedError: fmt.Errorf("multiline.group_by cannot contain the message field message"),
+		},
 	}
 
 	for name, test := range testcases {
@@ -86,6 +101,14 @@ func TestValidConfiguration(t *testing.T) {
 				"pattern": "^\n",
 			},
 		},
+		"correct fields based multiline": {
+			config: map[string]interface{}{
+				"type":          "fields",
+				"pattern":       "^\\s",
+				"group_by":      []string{"log.logger"},
+				"message_field": "error.stack_trace",
+			},
+		},
 		"correct count based multiline": {
 			config: map[string]interface{}{
 				"type":        "count",
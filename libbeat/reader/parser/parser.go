@@ -28,6 +28,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/reader"
 	"github.com/elastic/beats/v7/libbeat/reader/filter"
 	"github.com/elastic/beats/v7/libbeat/reader/multiline"
+	"github.com/elastic/beats/v7/libbeat/reader/readcsv"
 	"github.com/elastic/beats/v7/libbeat/reader/readfile"
 	"github.com/elastic/beats/v7/libbeat/reader/readjson"
 	"github.com/elastic/beats/v7/libbeat/reader/syslog"
@@ -50,6 +51,13 @@ type CommonConfig struct {
 	LineTerminator readfile.LineTerminator `config:"line_terminator"`
 }
 
+// State is the state of the parsers of a source. Inputs persisting it allow
+// parsers to resume reading a source at an offset.
+type State struct {
+	// CSVHeader is the header read by the csv parser.
+	CSVHeader []string `json:"csv_header,omitempty" struct:"csv_header,omitempty"`
+}
+
 type Config struct {
 	Suffix string
 
@@ -84,6 +92,7 @@ func (c *Config) Unpack(cc *config.C) error {
 
 func NewConfig(pCfg CommonConfig, parsers []config.Namespace) (*Config, error) {
 	var suffix string
+	var hasCSV bool
 	for _, ns := range parsers {
 		name := ns.Name()
 		switch name {
@@ -128,6 +137,17 @@ func NewConfig(pCfg CommonConfig, parsers []config.Namespace) (*Config, error) {
 			if err != nil {
 				return nil, fmt.Errorf("error while parsing include_message parser config: %w", err)
 			}
+		case "csv":
+			config := readcsv.DefaultConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing csv parser config: %w", err)
+			}
+			if hasCSV {
+				return nil, fmt.Errorf("only one csv parser is allowed")
+			}
+			hasCSV = true
 		default:
 			return nil, fmt.Errorf("%s: %w", name, ErrNoSuchParser)
 		}
@@ -142,6 +162,12 @@ func NewConfig(pCfg CommonConfig, parsers []config.Namespace) (*Config, error) {
 }
 
 func (c *Config) Create(in reader.Reader) Parser {
+	return c.CreateWithState(in, &State{})
+}
+
+// CreateWithState creates the parsers of a source that is read from the
+// offset state belongs to. The parsers update state as they read the source.
+func (c *Config) CreateWithState(in reader.Reader, state *State) Parser {
 	p := in
 	for _, ns := range c.parsers {
 		name := ns.Name()
@@ -189,6 +215,14 @@ func (c *Config) Create(in reader.Reader) Parser {
 				return p
 			}
 			p = filter.NewParser(p, &config)
+		case "csv":
+			config := readcsv.DefaultConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return p
+			}
+			p = readcsv.NewParser(p, &config, &state.CSVHeader)
 		default:
 			return p
 		}
@@ -196,3 +230,44 @@ func (c *Config) Create(in reader.Reader) Parser {
 
 	return p
 }
+
+// NeedsState reports whether the parsers are missing state to resume reading
+// a source at an offset other than its beginning.
+func (c *Config) NeedsState(state State) bool {
+	config, _, found := c.csvConfig()
+	return found && len(config.Columns) == 0 && len(state.CSVHeader) == 0
+}
+
+// RestoreState reads the state of the parsers from the beginning of a
+// source, for sources read from an offset the parsers did not reach before.
+func (c *Config) RestoreState(in reader.Reader, state *State) error {
+	config, i, found := c.csvConfig()
+	if !found || len(config.Columns) > 0 {
+		return nil
+	}
+
+	// The header is read from the output of the parsers preceding the
+	// csv parser.
+	preceding := Config{pCfg: c.pCfg, parsers: c.parsers[:i]}
+	header, err := readcsv.ReadHeader(preceding.Create(in), &config)
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	state.CSVHeader = header
+	return nil
+}
+
+// csvConfig returns the configuration of the csv parser and its position.
+func (c *Config) csvConfig() (readcsv.Config, int, bool) {
+	for i, ns := range c.parsers {
+		if ns.Name() != "csv" {
+			continue
+		}
+		config := readcsv.DefaultConfig()
+		if err := ns.Config().Unpack(&config); err != nil {
+			return config, i, false
+		}
+		return config, i, true
+	}
+	return readcsv.Config{}, 0, false
+}
@@ -768,6 +768,114 @@ func TestParserIncludeMessages(t *testing.T) {
 	require.Equal(t, expectedMessages, readMsgs, "fii")
 }
 
+func TestParserCSVState(t *testing.T) {
+	newParsersConfig := func(t *testing.T, parsers ...map[string]interface{}) *Config {
+		var c inputParsersConfig
+		require.NoError(t, config.MustNewConfigFrom(map[string]interface{}{"parsers": parsers}).Unpack(&c))
+		return &c.Parsers
+	}
+	csvReader := func(lines string) reader.Reader {
+		return readfile.NewStripNewline(testReader(lines), readfile.AutoLineTerminator)
+	}
+	csvParser := map[string]interface{}{"csv": map[string]interface{}{}}
+
+	t.Run("header is kept in the state", func(t *testing.T) {
+		c := newParsersConfig(t, csvParser)
+
+		var state State
+		require.True(t, c.NeedsState(state))
+		p := c.CreateWithState(csvReader("a,b\n1,2\n"), &state)
+		msg, err := p.Next()
+		require.NoError(t, err)
+		require.Equal(t, mapstr.M{"a": "1", "b": "2"}, msg.Fields)
+		require.Equal(t, []string{"a", "b"}, state.CSVHeader)
+		require.False(t, c.NeedsState(state))
+
+		p = c.CreateWithState(csvReader("3,4\n"), &state)
+		msg, err = p.Next()
+		require.NoError(t, err)
+		require.Equal(t, mapstr.M{"a": "3", "b": "4"}, msg.Fields)
+	})
+
+	t.Run("restore state", func(t *testing.T) {
+		c := newParsersConfig(t,
+			map[string]interface{}{"include_message": map[string]interface{}{"patterns": []string{"^[^#]"}}},
+			csvParser)
+
+		var state State
+		require.NoError(t, c.RestoreState(csvReader("# comment\na,b\n1,2\n"), &state))
+		require.Equal(t, []string{"a", "b"}, state.CSVHeader)
+	})
+
+	t.Run("configured columns", func(t *testing.T) {
+		c := newParsersConfig(t, map[string]interface{}{"csv": map[string]interface{}{"columns": []string{"x"}}})
+
+		var state State
+		require.False(t, c.NeedsState(state))
+		require.NoError(t, c.RestoreState(csvReader(""), &state))
+		msg, err := c.CreateWithState(csvReader("1\n"), &state).Next()
+		require.NoError(t, err)
+		require.Equal(t, mapstr.M{"x": "1"}, msg.Fields)
+		require.Nil(t, state.CSVHeader)
+	})
+
+	t.Run("only one csv parser", func(t *testing.T) {
+		var c inputParsersConfig
+		err := config.MustNewConfigFrom(map[string]interface{}{
+			"parsers": []map[string]interface{}{csvParser, csvParser},
+		}).Unpack(&c)
+		require.ErrorContains(t, err, "only one csv parser is allowed")
+	})
+}
+
+func TestParserMultilineFields(t *testing.T) {
+	parserConfig := map[string]interface{}{
+		"parsers": []map[string]interface{}{
+			{
+				"ndjson": map[string]interface{}{
+					"target": "",
+				},
+			},
+			{
+				"multiline": map[string]interface{}{
+					"type":     "fields",
+					"group_by": []string{"log.logger"},
+					"pattern":  `^(\s|Caused by:)`,
+					"timeout":  "0s",
+				},
+			},
+		},
+	}
+
+	lines := `{"log":{"logger":"app","level":"error"},"message":"java.lang.Exception: failed"}
+{"log":{"logger":"app","level":"error"},"message":"\tat Main.main(Main.java:10)"}
+{"log":{"logger":"db","level":"info"},"message":"\tconnected"}
+{"log":{"logger":"app","level":"error"},"message":"Caused by: java.io.IOException"}
+`
+
+	cfg := config.MustNewConfigFrom(parserConfig)
+	var c inputParsersConfig
+	require.NoError(t, cfg.Unpack(&c))
+
+	p := c.Parsers.Create(readfile.NewStripNewline(testReader(lines), readfile.AutoLineTerminator))
+
+	var messages []interface{}
+	var bytes int
+	msg, err := p.Next()
+	for err == nil {
+		messages = append(messages, msg.Fields["message"])
+		bytes += msg.Bytes
+		msg, err = p.Next()
+	}
+
+	require.Equal(t, []interface{}{
+		"java.lang.Exception: failed\n\tat Main.main(Main.java:10)",
+		"\tconnected",
+		"Caused by: java.io.IOException",
+	}, messages)
+	require.Equal(t, len(lines), bytes)
+}
+
 type testParsersConfig struct {
 	Parsers []config.Namespace `struct:"parsers"`
 }
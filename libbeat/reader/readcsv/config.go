@@ -0,0 +1,82 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package readcsv
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/elastic/beats/v7/libbeat/processors/convert"
+)
+
+// Config holds the options of the CSV parser.
+type Config struct {
+	// Separator is the character separating the values of a record.
+	Separator string `config:"separator"`
+
+	// Columns names the values of the records. If empty, the first record
+	// of the source is read as header.
+	Columns []string `config:"columns"`
+
+	// Target is the field the values are written to. Values are written to
+	// the root of the event if empty.
+	Target string `config:"target"`
+
+	// Types converts the values of columns to the given types.
+	Types map[string]convert.DataType `config:"types"`
+
+	// TrimLeadingSpace ignores the leading white space of values.
+	TrimLeadingSpace bool `config:"trim_leading_space"`
+
+	// KeepMessage keeps the record in the message field.
+	KeepMessage bool `config:"keep_message"`
+
+	// MaxLines bounds the number of lines of a record with quoted values
+	// containing line breaks.
+	MaxLines int `config:"max_lines" validate:"min=1"`
+}
+
+// DefaultConfig returns the default configuration of the CSV parser.
+func DefaultConfig() Config {
+	return Config{
+		Separator: ",",
+		MaxLines:  500,
+	}
+}
+
+// Validate validates the Config option for the CSV parser.
+func (c *Config) Validate() error {
+	if utf8.RuneCountInString(c.Separator) != 1 {
+		return fmt.Errorf("separator must be a single character, got '%s'", c.Separator)
+	}
+	if sep, _ := utf8.DecodeRuneInString(c.Separator); sep == '"' || sep == '\r' || sep == '\n' {
+		return fmt.Errorf("invalid separator %q", sep)
+	}
+	for _, col := range c.Columns {
+		if col == "" {
+			return errors.New("column names must not be empty")
+		}
+	}
+	return nil
+}
+
+func (c *Config) separator() rune {
+	sep, _ := utf8.DecodeRuneInString(c.Separator)
+	return sep
+}
@@ -0,0 +1,258 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package readcsv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/elastic/beats/v7/libbeat/processors/convert"
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// Parser decodes CSV records into named fields. Records with quoted values
+// containing line breaks span several messages, which are joined.
+//
+// Unless the columns are configured, the first record of the source is its
+// header. The header is kept in the slice passed to NewParser, so that a
+// parser reading the source from an offset can be given the header read
+// previously.
+type Parser struct {
+	r      reader.Reader
+	cfg    *Config
+	header *[]string
+	logger *logp.Logger
+}
+
+// NewParser creates a new CSV parser. header holds the header of the source,
+// it is read from the source if it is empty. It is not used if the columns
+// are configured.
+func NewParser(r reader.Reader, cfg *Config, header *[]string) *Parser {
+	if len(cfg.Columns) > 0 {
+		columns := cfg.Columns
+		header = &columns
+	} else if header == nil {
+		header = new([]string)
+	}
+	return &Parser{
+		r:      r,
+		cfg:    cfg,
+		header: header,
+		logger: logp.NewLogger("parser_csv"),
+	}
+}
+
+// ReadHeader reads the header from the beginning of a source.
+func ReadHeader(r reader.Reader, cfg *Config) ([]string, error) {
+	var header []string
+	p := &Parser{r: r, cfg: cfg, header: &header}
+	_, err := p.readHeader()
+	return header, err
+}
+
+// Next returns the next record of the source.
+func (p *Parser) Next() (reader.Message, error) {
+	// The bytes of the header and of blank lines are accounted for in the
+	// offset of the record.
+	discarded, err := p.readHeader()
+	if err != nil {
+		return reader.Message{}, err
+	}
+
+	message, skipped, err := p.readRecord()
+	message.Offset += discarded + skipped
+	if err != nil {
+		return message, err
+	}
+
+	values, err := p.parse(message.Content)
+	if err != nil {
+		p.logger.Debugf("Error decoding CSV record: %v", err)
+		message.AddFields(mapstr.M{"error": createCSVError(fmt.Sprintf("Error decoding CSV record: %v", err))})
+		return message, nil
+	}
+
+	fields, err := p.fields(values)
+	if err != nil {
+		p.logger.Debugf("Error converting CSV values: %v", err)
+		message.AddFields(mapstr.M{"error": createCSVError(fmt.Sprintf("Error converting CSV values: %v", err))})
+	}
+	if p.cfg.Target != "" {
+		fields = mapstr.M{p.cfg.Target: fields}
+	}
+	message.AddFields(fields)
+	if !p.cfg.KeepMessage {
+		message.Content = nil
+	}
+	return message, nil
+}
+
+// readHeader reads the header if it is unknown. It returns the number of
+// bytes read.
+func (p *Parser) readHeader() (int, error) {
+	if len(*p.header) > 0 {
+		return 0, nil
+	}
+
+	message, skipped, err := p.readRecord()
+	if err != nil {
+		return skipped + message.Offset, err
+	}
+	values, err := p.parse(message.Content)
+	if err != nil {
+		return skipped + message.Offset, fmt.Errorf("failed to decode CSV header: %w", err)
+	}
+	values[0] = strings.TrimPrefix(values[0], "\ufeff")
+	for i, v := range values {
+		if v == "" {
+			values[i] = columnName(i)
+		}
+	}
+	*p.header = values
+	return skipped + message.Offset + message.Bytes, nil
+}
+
+// readRecord reads the messages of the next record, skipping blank lines. It
+// returns the number of bytes skipped.
+func (p *Parser) readRecord() (reader.Message, int, error) {
+	var skipped int
+	for {
+		message, err := p.r.Next()
+		if err != nil {
+			return message, skipped, err
+		}
+		if len(bytes.TrimSpace(message.Content)) == 0 {
+			skipped += message.Bytes + message.Offset
+			continue
+		}
+
+		lines := 1
+		for !p.complete(message.Content) {
+			if lines == p.cfg.MaxLines {
+				p.logger.Debugf("CSV record exceeds %d lines, it is decoded as is", p.cfg.MaxLines)
+				break
+			}
+			next, err := p.r.Next()
+			if err != nil {
+				return message, skipped, err
+			}
+			content := make([]byte, 0, len(message.Content)+1+len(next.Content))
+			content = append(append(append(content, message.Content...), '\n'), next.Content...)
+			message.Content = content
+			message.Bytes += next.Bytes
+			message.Offset += next.Offset
+			lines++
+		}
+		return message, skipped, nil
+	}
+}
+
+// complete reports whether a record does not end within a quoted value.
+// Like encoding/csv, only values starting with a quote are quoted, and
+// quotes are escaped by doubling them.
+func (p *Parser) complete(record []byte) bool {
+	sep := p.cfg.separator()
+	quoted, valueStart := false, true
+	for i := 0; i < len(record); {
+		r, size := utf8.DecodeRune(record[i:])
+		i += size
+		switch {
+		case quoted:
+			if r == '"' {
+				if i < len(record) && record[i] == '"' {
+					i++
+				} else {
+					quoted = false
+				}
+			}
+		case r == sep:
+			valueStart = true
+		case valueStart && r == '"':
+			quoted, valueStart = true, false
+		case valueStart && p.cfg.TrimLeadingSpace && unicode.IsSpace(r):
+		default:
+			valueStart = false
+		}
+	}
+	return !quoted
+}
+
+func (p *Parser) parse(record []byte) ([]string, error) {
+	r := csv.NewReader(bytes.NewReader(record))
+	r.Comma = p.cfg.separator()
+	r.TrimLeadingSpace = p.cfg.TrimLeadingSpace
+	r.FieldsPerRecord = -1
+	return r.Read()
+}
+
+// fields names the values of a record. Values beyond the header are named by
+// their position, and empty values are omitted. Values that cannot be
+// converted to the type of their column are kept as string, and an error is
+// returned.
+func (p *Parser) fields(values []string) (mapstr.M, error) {
+	header := *p.header
+	fields := make(mapstr.M, len(values))
+	var errs []string
+	for i, v := range values {
+		if v == "" {
+			continue
+		}
+		name := columnName(i)
+		if i < len(header) {
+			name = header[i]
+		}
+
+		typ, found := p.cfg.Types[name]
+		if !found {
+			fields[name] = v
+			continue
+		}
+		converted, err := convert.TransformType(typ, v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("cannot convert value of column '%s' to %s: %v", name, typ, err))
+			fields[name] = v
+			continue
+		}
+		fields[name] = converted
+	}
+	if len(errs) > 0 {
+		return fields, errors.New(strings.Join(errs, "; "))
+	}
+	return fields, nil
+}
+
+func createCSVError(message string) mapstr.M {
+	return mapstr.M{"message": message, "type": "csv"}
+}
+
+// columnName is the name of a column without name in the header.
+func columnName(i int) string {
+	return "column" + strconv.Itoa(i+1)
+}
+
+func (p *Parser) Close() error {
+	return p.r.Close()
+}
@@ -0,0 +1,223 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package readcsv
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+
+	"github.com/elastic/beats/v7/libbeat/reader"
+)
+
+// lineReader returns the lines of a string without line terminators, like
+// the readers preceding the parsers.
+type lineReader struct {
+	lines []string
+}
+
+func newLineReader(s string) *lineReader {
+	return &lineReader{lines: strings.SplitAfter(s, "\n")}
+}
+
+func (r *lineReader) Next() (reader.Message, error) {
+	for len(r.lines) > 0 {
+		line := r.lines[0]
+		r.lines = r.lines[1:]
+		if line == "" {
+			continue
+		}
+		return reader.Message{
+			Content: []byte(strings.TrimRight(line, "\r\n")),
+			Bytes:   len(line),
+		}, nil
+	}
+	return reader.Message{}, io.EOF
+}
+
+func (r *lineReader) Close() error { return nil }
+
+type result struct {
+	fields  mapstr.M
+	content string
+}
+
+func readAll(t *testing.T, p *Parser) ([]result, int) {
+	t.Helper()
+	var results []result
+	var total int
+	for {
+		msg, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			return results, total
+		}
+		require.NoError(t, err)
+		total += msg.Bytes + msg.Offset
+		results = append(results, result{fields: msg.Fields, content: string(msg.Content)})
+	}
+}
+
+func TestParser(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]interface{}
+		input  string
+		want   []result
+	}{
+		"header": {
+			input: "name,count\na,1\n\nb,2\n",
+			want: []result{
+				{fields: mapstr.M{"name": "a", "count": "1"}},
+				{fields: mapstr.M{"name": "b", "count": "2"}},
+			},
+		},
+		"quoted values with line breaks": {
+			input: "name,text\r\na,\"first\r\nsecond, \"\"quoted\"\"\"\r\nb,\"x\"\r\n",
+			want: []result{
+				{fields: mapstr.M{"name": "a", "text": "first\nsecond, \"quoted\""}},
+				{fields: mapstr.M{"name": "b", "text": "x"}},
+			},
+		},
+		"types": {
+			config: map[string]interface{}{"types": map[string]string{"count": "long", "ratio": "double", "ok": "boolean"}},
+			input:  "count,ratio,ok\n1,0.5,true\n",
+			want:   []result{{fields: mapstr.M{"count": int64(1), "ratio": 0.5, "ok": true}}},
+		},
+		"conversion error": {
+			config: map[string]interface{}{"types": map[string]string{"count": "long"}},
+			input:  "name,count\na,many\n",
+			want: []result{{fields: mapstr.M{
+				"name":  "a",
+				"count": "many",
+				"error": mapstr.M{
+					"message": `Error converting CSV values: cannot convert value of column 'count' to long: strconv.ParseInt: parsing "many": invalid syntax`,
+					"type":    "csv",
+				},
+			}}},
+		},
+		"missing, empty and extra values": {
+			input: "a,,c\n1\n1,,3,4\n",
+			want: []result{
+				{fields: mapstr.M{"a": "1"}},
+				{fields: mapstr.M{"a": "1", "c": "3", "column4": "4"}},
+			},
+		},
+		"columns": {
+			config: map[string]interface{}{"columns": []string{"x", "y"}},
+			input:  "1,2\n3,4\n",
+			want: []result{
+				{fields: mapstr.M{"x": "1", "y": "2"}},
+				{fields: mapstr.M{"x": "3", "y": "4"}},
+			},
+		},
+		"target and keep message": {
+			config: map[string]interface{}{"target": "csv", "keep_message": true},
+			input:  "\ufeffa,b\n1,2\n",
+			want:   []result{{fields: mapstr.M{"csv": mapstr.M{"a": "1", "b": "2"}}, content: "1,2"}},
+		},
+		"quotes within values": {
+			input: "a,b\n1,\"y \"\"\"\n",
+			want:  []result{{fields: mapstr.M{"a": "1", "b": `y "`}}},
+		},
+		"tsv": {
+			config: map[string]interface{}{"separator": "\t", "trim_leading_space": true},
+			input:  "a\tb\n1\t  2\n",
+			want:   []result{{fields: mapstr.M{"a": "1", "b": "2"}}},
+		},
+		"invalid record": {
+			input: "a,b\n1,x\"y\n2,z\n",
+			want: []result{{
+				fields: mapstr.M{"error": mapstr.M{
+					"message": `Error decoding CSV record: parse error on line 1, column 4: bare " in non-quoted-field`,
+					"type":    "csv",
+				}},
+				content: `1,x"y`,
+			}, {
+				fields: mapstr.M{"a": "2", "b": "z"},
+			}},
+		},
+		"max lines": {
+			config: map[string]interface{}{"max_lines": 2, "keep_message": true},
+			input:  "a\n\"1\n2\n3\n",
+			want: []result{
+				{
+					fields: mapstr.M{"error": mapstr.M{
+						"message": `Error decoding CSV record: record on line 1; parse error on line 2, column 2: extraneous or missing " in quoted-field`,
+						"type":    "csv",
+					}},
+					content: "\"1\n2",
+				},
+				{fields: mapstr.M{"a": "3"}, content: "3"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultConfig()
+			require.NoError(t, conf.MustNewConfigFrom(tc.config).Unpack(&config))
+
+			results, total := readAll(t, NewParser(newLineReader(tc.input), &config, nil))
+			assert.Equal(t, tc.want, results)
+			assert.Equal(t, len(tc.input), total, "all bytes are accounted for")
+		})
+	}
+}
+
+func TestParserHeaderState(t *testing.T) {
+	config := DefaultConfig()
+	var header []string
+
+	results, _ := readAll(t, NewParser(newLineReader("a,b\n1,2\n"), &config, &header))
+	require.Len(t, results, 1)
+	assert.Equal(t, []string{"a", "b"}, header)
+
+	// A parser resuming after the header uses the header read before.
+	results, _ = readAll(t, NewParser(newLineReader("3,4\n"), &config, &header))
+	assert.Equal(t, []result{{fields: mapstr.M{"a": "3", "b": "4"}}}, results)
+}
+
+func TestReadHeader(t *testing.T) {
+	config := DefaultConfig()
+
+	header, err := ReadHeader(newLineReader("\n\"a\nb\",c\n1,2\n"), &config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a\nb", "c"}, header)
+
+	_, err = ReadHeader(newLineReader(""), &config)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestConfigValidate(t *testing.T) {
+	for name, c := range map[string]map[string]interface{}{
+		"long separator":  {"separator": ";;"},
+		"quote separator": {"separator": `"`},
+		"empty column":    {"columns": []string{"a", ""}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultConfig()
+			assert.Error(t, conf.MustNewConfigFrom(c).Unpack(&config))
+		})
+	}
+}
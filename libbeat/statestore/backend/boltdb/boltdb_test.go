@@ -0,0 +1,111 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package boltdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/internal/storecompliance"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+)
+
+func TestCompliance(t *testing.T) {
+	storecompliance.TestBackendCompliance(t, func(testPath string) (backend.Registry, error) {
+		logger := logptest.NewTestingLogger(t, "")
+		return New(logger.Named("test"), Settings{Root: testPath})
+	})
+}
+
+func TestStoreFile(t *testing.T) {
+	path := t.TempDir()
+	reg, err := New(logptest.NewTestingLogger(t, ""), Settings{Root: path, FileMode: 0640})
+	require.NoError(t, err)
+	defer reg.Close()
+
+	store, err := reg.Access("test")
+	require.NoError(t, err)
+	require.NoError(t, store.Set("key", map[string]interface{}{"offset": 42}))
+	require.NoError(t, store.Close())
+
+	info, err := os.Stat(filepath.Join(path, "test.db"))
+	require.NoError(t, err)
+	if os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	}
+}
+
+func TestStoreLockedByOtherRegistry(t *testing.T) {
+	path := t.TempDir()
+	reg1, err := New(logptest.NewTestingLogger(t, ""), Settings{Root: path})
+	require.NoError(t, err)
+	defer reg1.Close()
+	reg2, err := New(logptest.NewTestingLogger(t, ""), Settings{Root: path, Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	defer reg2.Close()
+
+	store, err := reg1.Access("test")
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, err = reg2.Access("test")
+	assert.Error(t, err)
+}
+
+func TestSetAll(t *testing.T) {
+	reg, err := New(logptest.NewTestingLogger(t, ""), Settings{Root: t.TempDir()})
+	require.NoError(t, err)
+	defer reg.Close()
+
+	s, err := reg.Access("test")
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Set("old", map[string]interface{}{"a": 1}))
+	err = s.(*store).SetAll(map[string]interface{}{
+		"key1": map[string]interface{}{"offset": uint64(1) << 40},
+		"key2": struct {
+			Name string `struct:"name"`
+		}{Name: "test"},
+	})
+	require.NoError(t, err)
+
+	has, err := s.Has("old")
+	require.NoError(t, err)
+	assert.False(t, has, "SetAll must replace existing entries")
+
+	var state struct {
+		Offset uint64 `struct:"offset"`
+	}
+	require.NoError(t, s.Get("key1", &state))
+	assert.Equal(t, uint64(1)<<40, state.Offset)
+
+	var keys []string
+	err = s.Each(func(key string, _ backend.ValueDecoder) (bool, error) {
+		keys = append(keys, key)
+		return true, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key1", "key2"}, keys)
+}
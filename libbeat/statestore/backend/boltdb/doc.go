@@ -0,0 +1,32 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package boltdb implements a statestore backend on top of bbolt, an
+// embedded pure-Go key-value store.
+//
+// Unlike memlog, which loads all key-value pairs into memory on startup and
+// periodically writes the complete state to a new data file, boltdb keeps
+// the state in a B+tree on disk. Opening a store does not need to replay any
+// log, and each update only writes the pages it touches. This makes it a
+// better fit for registries with a large number of entries, at the cost of
+// an fsync per update.
+//
+// Each store is kept in a single database file named `<name>.db` in the
+// registry root directory. Key-value pairs are stored in the `state`
+// bucket, with values encoded as JSON objects, similar to the values found
+// in memlog data files.
+package boltdb
@@ -0,0 +1,169 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package boltdb
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/elastic/beats/v7/libbeat/common/transform/typeconv"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var bucketName = []byte("state")
+
+// store implements a store backed by a bbolt database file. Every update
+// is written in its own transaction, bbolt serializes writers and allows
+// concurrent readers.
+type store struct {
+	log *logp.Logger
+	db  *bolt.DB
+}
+
+// entry decodes a JSON encoded value. It must only be used while the
+// transaction that read the value is still open.
+type entry struct {
+	raw []byte
+}
+
+func openStore(log *logp.Logger, path string, mode os.FileMode, timeout time.Duration) (*store, error) {
+	db, err := openBolt(path, mode, timeout)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Opened store database at '%v'", path)
+	return &store{log: log, db: db}, nil
+}
+
+// Close closes the database file. Access to the store after close returns
+// an error.
+func (s *store) Close() error {
+	return s.db.Close()
+}
+
+// Has checks if the key is known.
+func (s *store) Has(key string) (bool, error) {
+	var has bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		has = tx.Bucket(bucketName).Get([]byte(key)) != nil
+		return nil
+	})
+	return has, err
+}
+
+// Get retrieves and decodes the key-value pair into to.
+func (s *store) Get(key string, to interface{}) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(bucketName).Get([]byte(key))
+		if raw == nil {
+			return errKeyUnknown
+		}
+		return entry{raw: raw}.Decode(to)
+	})
+}
+
+// Set inserts or overwrites a key-value pair. The value is normalized the
+// same way memlog does, so both backends store identical documents.
+func (s *store) Set(key string, value interface{}) error {
+	raw, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(key), raw)
+	})
+}
+
+// Remove removes a key from the store. The operation does not check if the
+// key exists.
+func (s *store) Remove(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Delete([]byte(key))
+	})
+}
+
+// Each iterates over all key-value pairs in the store in key order. The
+// iteration runs in a single read transaction, fn must not update the
+// store.
+func (s *store) Each(fn func(string, backend.ValueDecoder) (bool, error)) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketName).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			cont, err := fn(string(k), entry{raw: v})
+			if !cont || err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetAll replaces the content of the store with the given key-value pairs
+// in a single transaction. It is used to import the state of another
+// backend.
+func (s *store) SetAll(values map[string]interface{}) error {
+	encoded := make(map[string][]byte, len(values))
+	for k, v := range values {
+		raw, err := encodeValue(v)
+		if err != nil {
+			return err
+		}
+		encoded[k] = raw
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketName); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucket(bucketName)
+		if err != nil {
+			return err
+		}
+		for k, raw := range encoded {
+			if err := bucket.Put([]byte(k), raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) SetID(_ string) {
+	// NOOP
+}
+
+func (e entry) Decode(to interface{}) error {
+	var tmp map[string]interface{}
+	if err := json.Unmarshal(e.raw, &tmp); err != nil {
+		return err
+	}
+	return typeconv.Convert(to, tmp)
+}
+
+func encodeValue(value interface{}) ([]byte, error) {
+	var tmp mapstr.M
+	if err := typeconv.Convert(&tmp, value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(tmp)
+}
@@ -17,8 +17,8 @@
 
 // Package storecompliance provides a common test suite that a store
 // implementation must succeed in order to be compliant to the beats
-// statestore. The Internal tests are used by statestore/storetest and
-// statestore/backend/memlog.
+// statestore. The Internal tests are used by statestore/storetest,
+// statestore/backend/memlog, and statestore/backend/boltdb.
 //
 // The package adds the `-keep` and `-dir <path>` CLI flags:
 //   - `-dir <path>`: configure path where to create test folders in (defaults